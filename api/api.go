@@ -4,16 +4,25 @@ import (
 	"fmt"
 	blnk "github.com/northstar-pay/nucleus"
 	"net/http"
+	"time"
 
 	"github.com/typesense/typesense-go/typesense/api"
 
 	"github.com/northstar-pay/nucleus/config"
+	redis_db "github.com/northstar-pay/nucleus/internal/redis-db"
 
 	"github.com/northstar-pay/nucleus/api/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// idempotencyProcessingTTL is how long the idempotency key of a running request is held at a time. The key is
+// renewed until the request finishes, so this only bounds how long a key outlives a process that died.
+const idempotencyProcessingTTL = 30 * time.Second
+
+// NextCursorHeader carries the cursor of the next page for listings whose body is a bare array.
+const NextCursorHeader = "X-Next-Cursor"
+
 type Api struct {
 	blnk   *blnk.Blnk
 	router *gin.Engine
@@ -25,10 +34,31 @@ func (a Api) Router() *gin.Engine {
 	router.GET("/ledgers/:id", a.GetLedger)
 	router.GET("/ledgers", a.GetAllLedgers)
 
+	router.POST("/currencies", a.CreateCurrency)
+	router.GET("/currencies/:code", a.GetCurrency)
+	router.GET("/currencies", a.GetAllCurrencies)
+
+	router.POST("/fx/rate-sets", a.CreateFXRateSet)
+	router.GET("/fx/rate-sets/:id", a.GetFXRateSet)
+	router.POST("/fx/quotes", a.CreateFXQuote)
+	router.GET("/fx/quotes/:id", a.GetFXQuote)
+
 	router.POST("/balances", a.CreateBalance)
+	router.GET("/balances", a.GetAllBalances)
 	router.GET("/balances/:id", a.GetBalance)
+	router.GET("/balances/:id/history", a.GetBalanceHistory)
+	router.GET("/balances/:id/statement", a.GetStatement)
+	router.PUT("/balances/:id/overdraft", a.SetOverdraftLimit)
+	router.PUT("/balances/:id/status", a.ChangeBalanceStatus)
+	router.GET("/balances/:id/status-history", a.GetBalanceStatusChanges)
+	router.GET("/balances/:id/allowance", a.GetBalanceAllowance)
 	router.POST("/balances/indicator", a.BalanceByIndicator)
 
+	router.POST("/limits", a.CreateSpendingLimit)
+	router.GET("/limits", a.GetAllSpendingLimits)
+	router.GET("/limits/:id", a.GetSpendingLimit)
+	router.DELETE("/limits/:id", a.DeleteSpendingLimit)
+
 	router.POST("/balance-monitors", a.CreateBalanceMonitor)
 	router.GET("/balance-monitors/:id", a.GetBalanceMonitor)
 	router.GET("/balance-monitors", a.GetAllBalanceMonitors)
@@ -36,21 +66,53 @@ func (a Api) Router() *gin.Engine {
 	router.PUT("/balance-monitors/:id", a.UpdateBalanceMonitor)
 
 	router.POST("/transactions", a.QueueTransaction)
+	router.POST("/transactions/simulate", a.SimulateTransaction)
 	router.POST("/refund-transaction/:id", a.RefundTransaction)
+	router.GET("/transactions", a.GetAllTransactions)
+	router.GET("/transactions/scheduled", a.GetScheduledTransactions)
 	router.GET("/transactions/:id", a.GetTransaction)
+	router.DELETE("/transactions/:id/schedule", a.CancelScheduledTransaction)
+	router.PATCH("/transactions/:id/schedule", a.RescheduleTransaction)
 	router.PUT("/transactions/inflight/:txID", a.UpdateInflightStatus)
 
+	router.POST("/recurring-transactions", a.CreateRecurringTransaction)
+	router.GET("/recurring-transactions", a.GetAllRecurringTransactions)
+	router.GET("/recurring-transactions/:id", a.GetRecurringTransaction)
+	router.GET("/recurring-transactions/:id/occurrences", a.GetRecurringOccurrences)
+	router.POST("/recurring-transactions/:id/pause", a.PauseRecurringTransaction)
+	router.POST("/recurring-transactions/:id/resume", a.ResumeRecurringTransaction)
+	router.POST("/recurring-transactions/:id/cancel", a.CancelRecurringTransaction)
+
+	router.POST("/journal-entries", a.CreateJournalEntry)
+	router.GET("/journal-entries/:id", a.GetJournalEntry)
+
 	router.POST("/identities", a.CreateIdentity)
 	router.GET("/identities/:id", a.GetIdentity)
 	router.PUT("/identities/:id", a.UpdateIdentity)
 	router.GET("/identities", a.GetAllIdentities)
+	router.PUT("/identities/:id/tier", a.ChangeIdentityTier)
+	router.GET("/identities/:id/tier-history", a.GetIdentityTierChanges)
+
+	router.POST("/kyc-tiers", a.CreateKYCTier)
+	router.GET("/kyc-tiers", a.GetAllKYCTiers)
+	router.GET("/kyc-tiers/:id", a.GetKYCTier)
 
 	router.POST("/accounts", a.CreateAccount)
 	router.GET("/accounts/:id", a.GetAccount)
+	router.GET("/accounts/:id/statement", a.GetStatement)
 	router.GET("/accounts", a.GetAllAccounts)
 
 	router.GET("/mocked-account", a.generateMockAccount)
 
+	// Queue tasks carry transaction payloads and redriving, rejecting or deleting them moves funds, so the queue
+	// routes are for privileged keys only.
+	queues := router.Group("/queues", middleware.RequirePrivileged())
+	queues.GET("", a.GetQueueStats)
+	queues.GET("/:queue/tasks", a.ListQueueTasks)
+	queues.POST("/:queue/tasks/:id/redrive", a.RedriveQueueTask)
+	queues.POST("/:queue/tasks/:id/reject", a.RejectQueueTask)
+	queues.DELETE("/:queue/tasks/:id", a.DeleteQueueTask)
+
 	router.GET("/backup", a.BackupDB)
 	router.GET("/backup-s3", a.BackupDBS3)
 
@@ -58,17 +120,23 @@ func (a Api) Router() *gin.Engine {
 	return a.router
 }
 
-func NewAPI(b *blnk.Blnk) *Api {
+func NewAPI(b *blnk.Blnk) (*Api, error) {
 	gin.SetMode(gin.ReleaseMode)
 	conf, err := config.Fetch()
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	r := gin.Default()
 	if conf.Server.Secure {
 		r.Use(middleware.SecretKeyAuthMiddleware())
 	}
 
+	redisClient, err := redis_db.NewRedisClient([]string{fmt.Sprintf("redis://%s", conf.Redis.Dns)}, conf.Redis.Password)
+	if err != nil {
+		return nil, err
+	}
+	r.Use(middleware.IdempotencyMiddleware(redisClient.Client(), time.Duration(conf.Server.IdempotencyTTLSeconds)*time.Second, idempotencyProcessingTTL))
+
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(200, "server running...")
 	})
@@ -84,7 +152,7 @@ func NewAPI(b *blnk.Blnk) *Api {
 		c.JSON(200, "webhook received")
 	})
 
-	return &Api{blnk: b, router: r}
+	return &Api{blnk: b, router: r}, nil
 }
 
 func (a Api) Search(c *gin.Context) {
@@ -2,7 +2,10 @@ package api
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/northstar-pay/nucleus/api/middleware"
 	model2 "github.com/northstar-pay/nucleus/api/model"
 
 	"github.com/gin-gonic/gin"
@@ -54,6 +57,119 @@ func (a Api) CreateBalance(c *gin.Context) {
 	c.JSON(http.StatusCreated, resp)
 }
 
+func (a Api) GetAllBalances(c *gin.Context) {
+	var query model2.ListBalances
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := query.ValidateListBalances()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.GetAllBalances(query.ToBalanceFilter(), query.Limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetOverdraftLimit sets how far below zero a balance may go. It requires a privileged key.
+func (a Api) SetOverdraftLimit(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+	if !middleware.IsPrivileged(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "setting an overdraft limit requires a privileged key"})
+		return
+	}
+
+	var limit model2.SetOverdraftLimit
+	if err := c.ShouldBindJSON(&limit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+	if err := limit.ValidateSetOverdraftLimit(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.SetOverdraftLimit(c.Request.Context(), id, limit.Limit, limit.PreciseLimit, limit.ExpiryTime())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ChangeBalanceStatus freezes, blocks, reactivates or closes a balance. It requires a privileged key.
+func (a Api) ChangeBalanceStatus(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+	if !middleware.IsPrivileged(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "changing a balance's status requires a privileged key"})
+		return
+	}
+
+	var status model2.ChangeBalanceStatus
+	if err := c.ShouldBindJSON(&status); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+	if err := status.ValidateChangeBalanceStatus(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.ChangeBalanceStatus(c.Request.Context(), id, status.ToStatusChange(), status.SweepTo)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetBalanceStatusChanges lists the status changes of a balance, newest first.
+func (a Api) GetBalanceStatusChanges(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	var query model2.ListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := query.ValidateListQuery()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.GetBalanceStatusChanges(id, query.CursorID(), query.Limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 func (a Api) GetBalance(c *gin.Context) {
 	id, passed := c.Params.Get("id")
 
@@ -65,6 +181,23 @@ func (a Api) GetBalance(c *gin.Context) {
 	// Extracting 'include' parameter from the query
 	includes := c.QueryArray("include")
 
+	if asOf := c.Query("as_of"); asOf != "" {
+		at, err := time.Parse(time.RFC3339, asOf)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "as_of must be an RFC3339 timestamp (e.g., 2024-04-22T15:28:03+00:00)"})
+			return
+		}
+
+		resp, err := a.blnk.GetBalanceAt(id, at, includes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
 	resp, err := a.blnk.GetBalanceByID(id, includes)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -74,6 +207,41 @@ func (a Api) GetBalance(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+func (a Api) GetBalanceHistory(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	var from, to time.Time
+	var err error
+	if value := c.Query("from"); value != "" {
+		from, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC3339 timestamp"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		to, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC3339 timestamp"})
+			return
+		}
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	resp, err := a.blnk.GetBalanceHistory(id, from, to, limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 func (a Api) CreateBalanceMonitor(c *gin.Context) {
 	var newMonitor model2.CreateBalanceMonitor
 	if err := c.ShouldBindJSON(&newMonitor); err != nil {
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+
+	model2 "github.com/northstar-pay/nucleus/api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (a Api) CreateCurrency(c *gin.Context) {
+	var newCurrency model2.CreateCurrency
+	if err := c.ShouldBindJSON(&newCurrency); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := newCurrency.ValidateCreateCurrency()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.CreateCurrency(newCurrency.ToCurrency())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (a Api) GetCurrency(c *gin.Context) {
+	code, passed := c.Params.Get("code")
+
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required. pass code in the route /:code"})
+		return
+	}
+
+	resp, err := a.blnk.GetCurrency(code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a Api) GetAllCurrencies(c *gin.Context) {
+	resp, err := a.blnk.GetAllCurrencies()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
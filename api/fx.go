@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+
+	model2 "github.com/northstar-pay/nucleus/api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (a Api) CreateFXRateSet(c *gin.Context) {
+	var newRateSet model2.CreateFXRateSet
+	if err := c.ShouldBindJSON(&newRateSet); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := newRateSet.ValidateCreateFXRateSet()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.CreateFXRateSet(newRateSet.ToFXRateSet())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (a Api) GetFXRateSet(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	resp, err := a.blnk.GetFXRateSet(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a Api) CreateFXQuote(c *gin.Context) {
+	var newQuote model2.CreateFXQuote
+	if err := c.ShouldBindJSON(&newQuote); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := newQuote.ValidateCreateFXQuote()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.CreateFXQuote(newQuote.BaseCurrency, newQuote.QuoteCurrency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (a Api) GetFXQuote(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	resp, err := a.blnk.GetFXQuote(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
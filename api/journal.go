@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+
+	model2 "github.com/northstar-pay/nucleus/api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (a Api) CreateJournalEntry(c *gin.Context) {
+	var newEntry model2.CreateJournalEntry
+	if err := c.ShouldBindJSON(&newEntry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := newEntry.ValidateCreateJournalEntry()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+	if !allowOverdraftPermitted(c, newEntry.AllowOverdraft) {
+		return
+	}
+
+	resp, err := a.blnk.RecordJournalEntry(c.Request.Context(), newEntry.ToJournalEntry())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (a Api) GetJournalEntry(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	resp, err := a.blnk.GetJournalEntry(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
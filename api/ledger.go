@@ -47,12 +47,29 @@ func (a Api) GetLedger(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// GetAllLedgers lists ledgers newest first. The body stays the bare array it has always been; the cursor of the
+// next page, if there is one, is sent in the X-Next-Cursor header.
 func (a Api) GetAllLedgers(c *gin.Context) {
-	resp, err := a.blnk.GetAllLedgers()
+	var query model2.ListLedgers
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := query.ValidateListQuery()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.GetAllLedgers(query.ToLedgerFilter(), query.Limit)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	if resp.NextCursor != "" {
+		c.Header(NextCursorHeader, resp.NextCursor)
+	}
+	c.JSON(http.StatusOK, resp.Data)
 }
@@ -63,9 +63,12 @@ func setupRouter() (*gin.Engine, *blnk.Blnk, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	router := NewAPI(newBlnk).Router()
+	newAPI, err := NewAPI(newBlnk)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return router, newBlnk, nil
+	return newAPI.Router(), newBlnk, nil
 }
 
 func TestCreateLedger(t *testing.T) {
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/northstar-pay/nucleus/api/middleware"
+	model2 "github.com/northstar-pay/nucleus/api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateSpendingLimit caps what a balance, or every balance of an identity or ledger, may send per window. It
+// requires a privileged key.
+func (a Api) CreateSpendingLimit(c *gin.Context) {
+	if !middleware.IsPrivileged(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "creating a spending limit requires a privileged key"})
+		return
+	}
+
+	var newLimit model2.CreateSpendingLimit
+	if err := c.ShouldBindJSON(&newLimit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := newLimit.ValidateCreateSpendingLimit()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.CreateSpendingLimit(newLimit.ToSpendingLimit(), newLimit.MaxAmount)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (a Api) GetSpendingLimit(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	resp, err := a.blnk.GetSpendingLimit(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetAllSpendingLimits lists spending limits newest first, optionally only those of one scope.
+func (a Api) GetAllSpendingLimits(c *gin.Context) {
+	var query model2.ListSpendingLimits
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := query.ValidateListSpendingLimits()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.GetAllSpendingLimits(query.ToSpendingLimitFilter(), query.Limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteSpendingLimit removes a spending limit. It requires a privileged key.
+func (a Api) DeleteSpendingLimit(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+	if !middleware.IsPrivileged(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "deleting a spending limit requires a privileged key"})
+		return
+	}
+
+	err := a.blnk.DeleteSpendingLimit(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "SpendingLimit deleted successfully"})
+}
+
+// GetBalanceAllowance shows how much a balance may still send under each limit that applies to it.
+func (a Api) GetBalanceAllowance(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	resp, err := a.blnk.GetBalanceAllowance(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
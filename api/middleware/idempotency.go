@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+
+	idempotencyProcessing = "processing"
+	idempotencyCompleted  = "completed"
+)
+
+// Scripts that renew or release a key only while it still holds the pending value they were given, so a request
+// never touches a key that expired and was taken by another.
+const (
+	renewIdempotencyKey   = "if redis.call('get', KEYS[1]) == ARGV[1] then return redis.call('pexpire', KEYS[1], ARGV[2]) else return 0 end"
+	releaseIdempotencyKey = "if redis.call('get', KEYS[1]) == ARGV[1] then return redis.call('del', KEYS[1]) else return 0 end"
+)
+
+// idempotentResponse is what is kept under an Idempotency-Key: the request fingerprint and, once the
+// handler has finished, the response to replay.
+type idempotentResponse struct {
+	State       string `json:"state"`
+	Fingerprint string `json:"fingerprint"`
+	Holder      string `json:"holder,omitempty"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// responseRecorder copies everything the handler writes so it can be stored.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware replays the stored response of a POST request sent again with the same Idempotency-Key.
+// Retries with a different payload get a 422 and retries that arrive while the first request is still running
+// get a 409. Server errors are not stored so the request can be retried. Keys are scoped to the API key the
+// request authenticated with, so callers cannot see each other's responses. A key is held while its request
+// runs, renewed for processingTTL at a time, so the key of a request whose process died is freed soon after, and
+// the response is kept for ttl.
+func IdempotencyMiddleware(client redis.UniversalClient, ttl, processingTTL time.Duration) gin.HandlerFunc {
+	processingTTL = min(processingTTL, ttl)
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		redisKey := "idempotency:" + c.GetString(apiKeyHashContextKey) + ":" + key
+		fingerprint := requestFingerprint(c.Request, body)
+
+		pending, _ := json.Marshal(idempotentResponse{State: idempotencyProcessing, Fingerprint: fingerprint, Holder: uuid.NewString()})
+		reserved, err := client.SetNX(ctx, redisKey, pending, processingTTL).Result()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !reserved {
+			replayResponse(c, client, redisKey, fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		release := holdIdempotencyKey(ctx, client, redisKey, pending, processingTTL)
+		c.Next()
+		release()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			if err := client.Eval(ctx, releaseIdempotencyKey, []string{redisKey}, pending).Err(); err != nil {
+				logrus.Errorf("error releasing idempotency key %s: %v", key, err)
+			}
+			return
+		}
+
+		stored, _ := json.Marshal(idempotentResponse{
+			State:       idempotencyCompleted,
+			Fingerprint: fingerprint,
+			Status:      recorder.Status(),
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err := client.Set(ctx, redisKey, stored, ttl).Err(); err != nil {
+			logrus.Errorf("error storing response for idempotency key %s: %v", key, err)
+		}
+	}
+}
+
+// holdIdempotencyKey renews the key of a running request every third of processingTTL until the returned func
+// is called, however long the request takes.
+func holdIdempotencyKey(ctx context.Context, client redis.UniversalClient, redisKey string, pending []byte, processingTTL time.Duration) func() {
+	// The request may be cancelled while its handler still runs, and the key must stay held until it returns.
+	ctx = context.WithoutCancel(ctx)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(processingTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := client.Eval(ctx, renewIdempotencyKey, []string{redisKey}, pending, processingTTL.Milliseconds()).Err(); err != nil {
+					logrus.Errorf("error renewing idempotency key %s: %v", redisKey, err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func replayResponse(c *gin.Context, client redis.UniversalClient, redisKey, fingerprint string) {
+	data, err := client.Get(c.Request.Context(), redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key has just finished. retry it"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var stored idempotentResponse
+	if err := json.Unmarshal(data, &stored); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if stored.Fingerprint != fingerprint {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key has already been used with a different request"})
+		return
+	}
+	if stored.State != idempotencyCompleted {
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still being processed"})
+		return
+	}
+
+	c.Header(IdempotencyReplayedHeader, "true")
+	c.Data(stored.Status, stored.ContentType, stored.Body)
+	c.Abort()
+}
+
+// requestFingerprint identifies a request by its route and payload. JSON bodies are compared by content,
+// so key order and whitespace do not matter.
+func requestFingerprint(r *http.Request, body []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var payload interface{}
+	if err := decoder.Decode(&payload); err == nil {
+		if canonical, err := json.Marshal(payload); err == nil {
+			body = canonical
+		}
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupIdempotentRouter(t *testing.T) (*gin.Engine, *int) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("an error '%s' occurred when starting miniredis", err)
+	}
+	t.Cleanup(mr.Close)
+
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	router := gin.New()
+	router.Use(IdempotencyMiddleware(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Minute, 10*time.Second))
+	router.POST("/ledgers", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusCreated, gin.H{"ledger_id": "ldg_1", "call": calls})
+	})
+	router.POST("/fail", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+	})
+	return router, &calls
+}
+
+func post(router *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	router, calls := setupIdempotentRouter(t)
+
+	first := post(router, "/ledgers", "key-1", `{"name": "general", "meta_data": {"a": 1}}`)
+	retry := post(router, "/ledgers", "key-1", `{"meta_data":{"a":1},"name":"general"}`)
+
+	assert.Equal(t, 1, *calls)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(IdempotencyReplayedHeader))
+}
+
+func TestIdempotencyRejectsDifferentPayload(t *testing.T) {
+	router, calls := setupIdempotentRouter(t)
+
+	post(router, "/ledgers", "key-1", `{"name": "general"}`)
+	w := post(router, "/ledgers", "key-1", `{"name": "other"}`)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, 1, *calls)
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	router, calls := setupIdempotentRouter(t)
+
+	post(router, "/fail", "key-1", `{}`)
+	w := post(router, "/fail", "key-1", `{}`)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, 2, *calls)
+}
+
+func TestIdempotencyIgnoresRequestsWithoutKey(t *testing.T) {
+	router, calls := setupIdempotentRouter(t)
+
+	post(router, "/ledgers", "", `{"name": "general"}`)
+	post(router, "/ledgers", "", `{"name": "general"}`)
+
+	assert.Equal(t, 2, *calls)
+}
+
+func TestIdempotencyKeysAreScopedToTheAPIKey(t *testing.T) {
+	mr := miniredis.RunT(t)
+	gin.SetMode(gin.TestMode)
+	calls := 0
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set(apiKeyHashContextKey, hashAPIKey(c.GetHeader("X-Blnk-Key")))
+	})
+	router.Use(IdempotencyMiddleware(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Minute, 10*time.Second))
+	router.POST("/ledgers", func(c *gin.Context) {
+		calls++
+		// The key is held only briefly while the request runs.
+		assert.Equal(t, 10*time.Second, mr.TTL("idempotency:"+hashAPIKey(c.GetHeader("X-Blnk-Key"))+":key-1"))
+		c.JSON(http.StatusCreated, gin.H{"call": calls})
+	})
+
+	send := func(apiKey string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/ledgers", strings.NewReader(`{"name": "general"}`))
+		req.Header.Set("X-Blnk-Key", apiKey)
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("key-a")
+	other := send("key-b")
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, other.Header().Get(IdempotencyReplayedHeader))
+	assert.NotEqual(t, first.Body.String(), other.Body.String())
+
+	// Once the response is stored it is kept for the full ttl.
+	assert.Equal(t, time.Minute, mr.TTL("idempotency:"+hashAPIKey("key-a")+":key-1"))
+	replay := send("key-a")
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, "true", replay.Header().Get(IdempotencyReplayedHeader))
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+}
+
+func TestIdempotencyKeyIsRenewedWhileTheRequestRuns(t *testing.T) {
+	mr := miniredis.RunT(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(IdempotencyMiddleware(redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Minute, 300*time.Millisecond))
+	router.POST("/slow", func(c *gin.Context) {
+		// The request outlives its processing ttl several times over and still holds its key.
+		for i := 0; i < 4; i++ {
+			mr.FastForward(150 * time.Millisecond)
+			time.Sleep(150 * time.Millisecond)
+			assert.True(t, mr.Exists("idempotency::key-1"))
+		}
+		c.JSON(http.StatusCreated, gin.H{"done": true})
+	})
+
+	w := post(router, "/slow", "key-1", `{}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, time.Minute, mr.TTL("idempotency::key-1"))
+}
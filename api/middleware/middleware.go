@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"crypto/sha256"
 	"crypto/subtle"
+	"encoding/hex"
 	"net/http"
 
 	"github.com/northstar-pay/nucleus/config"
@@ -9,6 +11,13 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// privilegedContextKey marks requests authenticated with one of the configured privileged keys.
+const privilegedContextKey = "blnk_privileged"
+
+// apiKeyHashContextKey holds the SHA-256 of the key a request authenticated with, so what is kept per caller,
+// such as idempotency keys, can be scoped to it without storing the key.
+const apiKeyHashContextKey = "blnk_api_key_hash"
+
 func SecretKeyAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		conf, err := config.Fetch()
@@ -29,15 +38,51 @@ func SecretKeyAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		for _, privilegedKey := range conf.Server.PrivilegedKeys {
+			if privilegedKey != "" && secureCompare(privilegedKey, clientSecret) {
+				c.Set(privilegedContextKey, true)
+				c.Set(apiKeyHashContextKey, hashAPIKey(clientSecret))
+				c.Next()
+				return
+			}
+		}
+
 		if !secureCompare(secretKey, clientSecret) {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid secret key"})
 			return
 		}
 
+		c.Set(apiKeyHashContextKey, hashAPIKey(clientSecret))
+		c.Next()
+	}
+}
+
+// IsPrivileged reports whether the request was made with a privileged key. Every request is privileged when the
+// server runs without authentication.
+func IsPrivileged(c *gin.Context) bool {
+	conf, err := config.Fetch()
+	if err != nil {
+		return false
+	}
+	return !conf.Server.Secure || c.GetBool(privilegedContextKey)
+}
+
+// RequirePrivileged refuses requests not made with a privileged key, for routes that only operators may use.
+func RequirePrivileged() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsPrivileged(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "this route requires a privileged key"})
+			return
+		}
 		c.Next()
 	}
 }
 
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
 func secureCompare(a, b string) bool {
 	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
 }
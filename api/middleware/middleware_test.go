@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/northstar-pay/nucleus/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretKeyAuthMarksPrivilegedKeys(t *testing.T) {
+	config.ConfigStore.Store(&config.Configuration{Server: config.ServerConfig{Secure: true, SecretKey: "secret", PrivilegedKeys: []string{"root"}}})
+	t.Cleanup(func() { config.MockConfig(false, "", "") })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(SecretKeyAuthMiddleware())
+	router.GET("/privileged", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"privileged": IsPrivileged(c)})
+	})
+
+	for key, want := range map[string]string{"secret": `{"privileged":false}`, "root": `{"privileged":true}`} {
+		req, _ := http.NewRequest(http.MethodGet, "/privileged", nil)
+		req.Header.Set("X-Blnk-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, want, w.Body.String())
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "/privileged", nil)
+	req.Header.Set("X-Blnk-Key", "wrong")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRequirePrivilegedRefusesOrdinaryKeys(t *testing.T) {
+	config.ConfigStore.Store(&config.Configuration{Server: config.ServerConfig{Secure: true, SecretKey: "secret", PrivilegedKeys: []string{"root"}}})
+	t.Cleanup(func() { config.MockConfig(false, "", "") })
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(SecretKeyAuthMiddleware())
+	router.DELETE("/queues/:queue/tasks/:id", RequirePrivileged(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for key, want := range map[string]int{"secret": http.StatusForbidden, "root": http.StatusNoContent} {
+		req, _ := http.NewRequest(http.MethodDelete, "/queues/transactions_1/tasks/task_1", nil)
+		req.Header.Set("X-Blnk-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, want, w.Code)
+	}
+}
@@ -1,13 +1,29 @@
 package model
 
+import "encoding/json"
+
 type CreateBalance struct {
 	LedgerId   string                 `json:"ledger_id"`
 	IdentityId string                 `json:"identity_id"`
 	Currency   string                 `json:"currency"`
 	Precision  float64                `json:"precision"`
+	Hot        bool                   `json:"hot"`
 	MetaData   map[string]interface{} `json:"meta_data"`
 }
 
+type SetOverdraftLimit struct {
+	Limit        json.Number `json:"limit"`
+	PreciseLimit int64       `json:"precise_limit"`
+	ExpiresAt    string      `json:"expires_at"`
+}
+
+type ChangeBalanceStatus struct {
+	Status    string `json:"status"`
+	Reason    string `json:"reason"`
+	ChangedBy string `json:"changed_by"`
+	SweepTo   string `json:"sweep_to"`
+}
+
 type BalanceByIndicator struct {
 	Indicator string `json:"indicator"`
 	Currency  string `json:"currency"`
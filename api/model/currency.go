@@ -0,0 +1,9 @@
+package model
+
+type CreateCurrency struct {
+	Code       string                 `json:"code"`
+	Name       string                 `json:"name"`
+	Symbol     string                 `json:"symbol"`
+	MinorUnits int                    `json:"minor_units"`
+	MetaData   map[string]interface{} `json:"meta_data"`
+}
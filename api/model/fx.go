@@ -0,0 +1,20 @@
+package model
+
+import "encoding/json"
+
+type CreateFXRateSet struct {
+	EffectiveAt string                 `json:"effective_at"`
+	Rates       []FXRate               `json:"rates"`
+	MetaData    map[string]interface{} `json:"meta_data"`
+}
+
+type FXRate struct {
+	BaseCurrency  string      `json:"base_currency"`
+	QuoteCurrency string      `json:"quote_currency"`
+	Rate          json.Number `json:"rate"`
+}
+
+type CreateFXQuote struct {
+	BaseCurrency  string `json:"base_currency"`
+	QuoteCurrency string `json:"quote_currency"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import "encoding/json"
+
+type CreateJournalEntry struct {
+	Reference      string                 `json:"reference"`
+	Description    string                 `json:"description"`
+	AllowOverdraft bool                   `json:"allow_overdraft"`
+	Lines          []JournalLine          `json:"lines"`
+	MetaData       map[string]interface{} `json:"meta_data"`
+}
+
+type JournalLine struct {
+	BalanceId   string      `json:"balance_id"`
+	Direction   string      `json:"direction"`
+	Amount      json.Number `json:"amount"`
+	Precision   int64       `json:"precision"`
+	Currency    string      `json:"currency"`
+	Description string      `json:"description"`
+}
@@ -0,0 +1,20 @@
+package model
+
+import "encoding/json"
+
+type CreateSpendingLimit struct {
+	Scope            string                 `json:"scope"`
+	ScopeID          string                 `json:"scope_id"`
+	Window           string                 `json:"window"`
+	Currency         string                 `json:"currency"`
+	MaxAmount        json.Number            `json:"max_amount"`
+	PreciseMaxAmount int64                  `json:"precise_max_amount"`
+	MaxCount         int64                  `json:"max_count"`
+	MetaData         map[string]interface{} `json:"meta_data"`
+}
+
+type ListSpendingLimits struct {
+	ListQuery
+	Scope   string `form:"scope"`
+	ScopeID string `form:"scope_id"`
+}
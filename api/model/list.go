@@ -0,0 +1,32 @@
+package model
+
+// ListQuery holds the paging and date parameters shared by list endpoints.
+type ListQuery struct {
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+	From   string `form:"from"`
+	To     string `form:"to"`
+}
+
+type ListTransactions struct {
+	ListQuery
+	Status            string            `form:"status"`
+	BalanceID         string            `form:"balance_id"`
+	Currency          string            `form:"currency"`
+	ParentTransaction string            `form:"parent_transaction"`
+	MetaData          map[string]string `form:"-"`
+}
+
+type ListBalances struct {
+	ListQuery
+	BalanceRange       string `form:"balance"`
+	CreditBalanceRange string `form:"credit_balance"`
+	DebitBalanceRange  string `form:"debit_balance"`
+	Currency           string `form:"currency"`
+	LedgerID           string `form:"ledger_id"`
+	IdentityID         string `form:"identity_id"`
+}
+
+type ListLedgers struct {
+	ListQuery
+}
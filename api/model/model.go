@@ -1,7 +1,11 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math/big"
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -43,6 +47,61 @@ func validateDateFormat(format, value string) error {
 	return nil
 }
 
+func (c *CreateCurrency) ValidateCreateCurrency() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.Code, validation.Required, validation.Length(1, 16)),
+		validation.Field(&c.MinorUnits, validation.Min(0), validation.Max(model.MaxMinorUnits)),
+	)
+}
+
+func (r *CreateFXRateSet) ValidateCreateFXRateSet() error {
+	return validation.ValidateStruct(r,
+		validation.Field(&r.Rates, validation.Required),
+		validation.Field(&r.EffectiveAt, validation.When(r.EffectiveAt != "", validation.By(func(value interface{}) error {
+			dateStr, ok := value.(string)
+			if !ok {
+				return errors.New("invalid type for effective date")
+			}
+			return validateDateFormat("2006-01-02T15:04:05Z07:00", dateStr)
+		}))),
+	)
+}
+
+func (r *CreateRecurringTransaction) ValidateCreateRecurringTransaction() error {
+	err := validation.ValidateStruct(r,
+		validation.Field(&r.Cron, validation.When(r.IntervalSeconds == 0, validation.Required.Error("either cron or interval_seconds is required"))),
+		validation.Field(&r.IntervalSeconds, validation.Min(int64(0)), validation.When(r.Cron != "", validation.Empty.Error("pass either cron or interval_seconds, not both"))),
+		validation.Field(&r.MaxOccurrences, validation.Min(int64(0))),
+		validation.Field(&r.StartAt, validation.By(validDate)),
+		validation.Field(&r.EndAt, validation.By(validDate)),
+	)
+	if err != nil {
+		return err
+	}
+	if r.Template.ScheduledFor != "" || r.Template.InflightExpiryDate != "" {
+		return errors.New("template: scheduled_for and inflight_expiry_date cannot be set on a recurring template")
+	}
+	if err := r.Template.ValidateRecordTransaction(); err != nil {
+		return fmt.Errorf("template: %w", err)
+	}
+	return nil
+}
+
+func (r FXRate) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.BaseCurrency, validation.Required),
+		validation.Field(&r.QuoteCurrency, validation.Required),
+		validation.Field(&r.Rate, validation.Required, validation.By(positiveDecimal)),
+	)
+}
+
+func (q *CreateFXQuote) ValidateCreateFXQuote() error {
+	return validation.ValidateStruct(q,
+		validation.Field(&q.BaseCurrency, validation.Required),
+		validation.Field(&q.QuoteCurrency, validation.Required),
+	)
+}
+
 func (b *CreateBalance) ValidateCreateBalance() error {
 	return validation.ValidateStruct(b,
 		validation.Field(&b.LedgerId, validation.Required),
@@ -92,9 +151,42 @@ func (c *MonitorCondition) ValidateMonitorCondition() error {
 	)
 }
 
+// positiveDecimal accepts amounts sent either as JSON numbers or as decimal strings such as "10.50".
+func positiveDecimal(value interface{}) error {
+	amount, ok := value.(json.Number)
+	if !ok || amount == "" {
+		return nil
+	}
+	r, valid := new(big.Rat).SetString(amount.String())
+	if !valid {
+		return errors.New("must be a decimal number")
+	}
+	if r.Sign() <= 0 {
+		return errors.New("must be greater than zero")
+	}
+	return nil
+}
+
+func nonNegativeDecimal(value interface{}) error {
+	amount, ok := value.(json.Number)
+	if !ok || amount == "" {
+		return nil
+	}
+	r, valid := new(big.Rat).SetString(amount.String())
+	if !valid {
+		return errors.New("must be a decimal number")
+	}
+	if r.Sign() < 0 {
+		return errors.New("cannot be negative")
+	}
+	return nil
+}
+
 func (t *RecordTransaction) ValidateRecordTransaction() error {
 	return validation.ValidateStruct(t,
-		validation.Field(&t.Amount, validation.Required),
+		validation.Field(&t.Amount, validation.When(t.PreciseAmount == 0, validation.Required.Error("either amount or precise_amount is required")), validation.By(positiveDecimal)),
+		validation.Field(&t.PreciseAmount, validation.Min(int64(0)), validation.When(t.Amount != "", validation.Empty.Error("pass either amount or precise_amount, not both"))),
+		validation.Field(&t.Rate, validation.By(positiveDecimal), validation.When(t.QuoteID != "", validation.Empty.Error("pass either rate or quote_id, not both"))),
 		validation.Field(&t.Currency, validation.Required),
 		validation.Field(&t.Reference, validation.Required),
 		validation.Field(&t.Description, validation.Required),
@@ -139,12 +231,100 @@ func (a *CreateAccount) ValidateCreateAccount() error {
 	)
 }
 
+func (j *CreateJournalEntry) ValidateCreateJournalEntry() error {
+	return validation.ValidateStruct(j,
+		validation.Field(&j.Reference, validation.Required),
+		validation.Field(&j.Lines, validation.Required, validation.Length(2, 0)),
+	)
+}
+
+func (l JournalLine) Validate() error {
+	return validation.ValidateStruct(&l,
+		validation.Field(&l.BalanceId, validation.Required),
+		validation.Field(&l.Direction, validation.Required, validation.In(model.DirectionDebit, model.DirectionCredit)),
+		validation.Field(&l.Amount, validation.Required, validation.By(positiveDecimal)),
+		validation.Field(&l.Currency, validation.Required),
+	)
+}
+
 func (l *CreateLedger) ToLedger() model.Ledger {
 	return model.Ledger{Name: l.Name, MetaData: l.MetaData}
 }
 
+func (c *CreateCurrency) ToCurrency() model.Currency {
+	return model.Currency{Code: c.Code, Name: c.Name, Symbol: c.Symbol, MinorUnits: c.MinorUnits, MetaData: c.MetaData}
+}
+
+func (r *CreateFXRateSet) ToFXRateSet() model.FXRateSet {
+	var effectiveAt time.Time
+	if r.EffectiveAt != "" {
+		effectiveAt, _ = time.Parse(time.RFC3339, r.EffectiveAt)
+	}
+	rates := make([]model.FXRate, 0, len(r.Rates))
+	for _, rate := range r.Rates {
+		rates = append(rates, model.FXRate{BaseCurrency: rate.BaseCurrency, QuoteCurrency: rate.QuoteCurrency, Rate: rate.Rate})
+	}
+	return model.FXRateSet{EffectiveAt: effectiveAt, Rates: rates, MetaData: r.MetaData}
+}
+
+func (r *CreateRecurringTransaction) ToRecurringTransaction() model.RecurringTransaction {
+	var startAt, endAt time.Time
+	if r.StartAt != "" {
+		startAt, _ = time.Parse(time.RFC3339, r.StartAt)
+	}
+	if r.EndAt != "" {
+		endAt, _ = time.Parse(time.RFC3339, r.EndAt)
+	}
+	return model.RecurringTransaction{Cron: r.Cron, IntervalSeconds: r.IntervalSeconds, StartAt: startAt, EndAt: endAt, MaxOccurrences: r.MaxOccurrences,
+		Template: *r.Template.ToTransaction(), MetaData: r.MetaData}
+}
+
+func (o *SetOverdraftLimit) ValidateSetOverdraftLimit() error {
+	return validation.ValidateStruct(o,
+		validation.Field(&o.Limit, validation.When(o.PreciseLimit == 0, validation.Required.Error("either limit or precise_limit is required")), validation.By(nonNegativeDecimal)),
+		validation.Field(&o.PreciseLimit, validation.Min(int64(0)), validation.When(o.Limit != "", validation.Empty.Error("pass either limit or precise_limit, not both"))),
+		validation.Field(&o.ExpiresAt, validation.By(validDate)),
+	)
+}
+
+// ExpiryTime returns the already validated expiry, or nil when the limit does not expire.
+func (o *SetOverdraftLimit) ExpiryTime() *time.Time {
+	if o.ExpiresAt == "" {
+		return nil
+	}
+	expiresAt, _ := time.Parse(time.RFC3339, o.ExpiresAt)
+	return &expiresAt
+}
+
+func (s *ChangeBalanceStatus) ValidateChangeBalanceStatus() error {
+	return validation.ValidateStruct(s,
+		validation.Field(&s.Status, validation.Required, validation.In(model.BalanceActive, model.BalanceFrozen, model.BalanceDebitBlocked,
+			model.BalanceCreditBlocked, model.BalanceClosed)),
+		validation.Field(&s.Reason, validation.Required),
+		validation.Field(&s.SweepTo, validation.When(s.Status != model.BalanceClosed, validation.Empty.Error("sweep_to can only be passed when closing a balance"))),
+	)
+}
+
+func (s *ChangeBalanceStatus) ToStatusChange() model.BalanceStatusChange {
+	return model.BalanceStatusChange{ToStatus: s.Status, Reason: s.Reason, ChangedBy: s.ChangedBy}
+}
+
+func (l *CreateSpendingLimit) ToSpendingLimit() model.SpendingLimit {
+	return model.SpendingLimit{Scope: l.Scope, ScopeID: l.ScopeID, Window: l.Window, Currency: l.Currency, MaxAmount: l.PreciseMaxAmount,
+		MaxCount: l.MaxCount, MetaData: l.MetaData}
+}
+
+func (t *CreateKYCTier) ToKYCTier() model.KYCTier {
+	return model.KYCTier{Name: t.Name, Currency: t.Currency, MaxBalance: t.PreciseMaxBalance, MaxTransaction: t.PreciseMaxTransaction,
+		DailyVolume: t.PreciseDailyVolume, CapAction: t.CapAction, MetaData: t.MetaData}
+}
+
+func (t *ChangeIdentityTier) ToTierChange() model.TierChange {
+	return model.TierChange{ToTier: t.TierID, Reason: t.Reason, ChangedBy: t.ChangedBy}
+}
+
 func (b *CreateBalance) ToBalance() model.Balance {
-	return model.Balance{LedgerID: b.LedgerId, IdentityID: b.IdentityId, Currency: b.Currency, MetaData: b.MetaData, CurrencyMultiplier: b.Precision}
+	return model.Balance{LedgerID: b.LedgerId, IdentityID: b.IdentityId, Currency: b.Currency, MetaData: b.MetaData, CurrencyMultiplier: b.Precision, Hot: b.Hot}
 }
 
 func (b *CreateBalanceMonitor) ToBalanceMonitor() model.BalanceMonitor {
@@ -184,5 +364,204 @@ func (t *RecordTransaction) ToTransaction() *model.Transaction {
 
 	}
 
-	return &model.Transaction{Currency: t.Currency, Source: t.Source, Description: t.Description, Reference: t.Reference, ScheduledFor: scheduledFor, Destination: t.Destination, Amount: t.Amount, AllowOverdraft: t.AllowOverDraft, MetaData: t.MetaData, Sources: t.Sources, Destinations: t.Destinations, Inflight: t.Inflight, Precision: t.Precision, InflightExpiryDate: inflightExpiryDate, Rate: t.Rate}
+	return &model.Transaction{Currency: t.Currency, Source: t.Source, Description: t.Description, Reference: t.Reference, ScheduledFor: scheduledFor, Destination: t.Destination, Amount: t.Amount, PreciseAmount: t.PreciseAmount, AllowOverdraft: t.AllowOverDraft, MetaData: t.MetaData, Sources: t.Sources, Destinations: t.Destinations, Inflight: t.Inflight, Atomic: t.Atomic, Reserve: t.Reserve, Precision: t.Precision, InflightExpiryDate: inflightExpiryDate, Rate: t.Rate, QuoteID: t.QuoteID}
+}
+
+func (j *CreateJournalEntry) ToJournalEntry() *model.JournalEntry {
+	lines := make([]model.JournalLine, 0, len(j.Lines))
+	for _, line := range j.Lines {
+		lines = append(lines, model.JournalLine{BalanceID: line.BalanceId, Direction: line.Direction, Amount: line.Amount, Precision: line.Precision, Currency: line.Currency, Description: line.Description})
+	}
+	return &model.JournalEntry{Reference: j.Reference, Description: j.Description, AllowOverdraft: j.AllowOverdraft, Lines: lines, MetaData: j.MetaData}
+}
+
+func validCursor(value interface{}) error {
+	cursor, _ := value.(string)
+	_, err := model.DecodeCursor(cursor)
+	return err
+}
+
+func validDate(value interface{}) error {
+	date, _ := value.(string)
+	if date == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, date); err != nil {
+		return errors.New("must be formatted as 'YYYY-MM-DDTHH:MM:SS+00:00' (e.g., 2024-04-22T15:28:03+00:00)")
+	}
+	return nil
+}
+
+func validRange(value interface{}) error {
+	r, _ := value.(string)
+	_, err := model.ParseRange(r)
+	return err
+}
+
+func (q *ListQuery) ValidateListQuery() error {
+	return validation.ValidateStruct(q,
+		validation.Field(&q.Limit, validation.Min(0), validation.Max(model.MaxPageLimit)),
+		validation.Field(&q.Cursor, validation.By(validCursor)),
+		validation.Field(&q.From, validation.By(validDate)),
+		validation.Field(&q.To, validation.By(validDate)),
+	)
+}
+
+func (t *ListTransactions) ValidateListTransactions() error {
+	if err := t.ValidateListQuery(); err != nil {
+		return err
+	}
+	return validation.ValidateStruct(t,
+		validation.Field(&t.Status, validation.By(func(value interface{}) error {
+			status, _ := value.(string)
+			return validation.Validate(strings.ToUpper(status), validation.In("QUEUED", "APPLIED", "INFLIGHT", "VOID", "REJECTED", "SCHEDULED"))
+		})),
+	)
+}
+
+func (r *ListRecurringTransactions) ValidateListRecurringTransactions() error {
+	if err := r.ValidateListQuery(); err != nil {
+		return err
+	}
+	return validation.ValidateStruct(r,
+		validation.Field(&r.Status, validation.By(func(value interface{}) error {
+			status, _ := value.(string)
+			return validation.Validate(strings.ToUpper(status), validation.In(model.RecurringActive, model.RecurringPaused, model.RecurringCancelled, model.RecurringCompleted))
+		})),
+	)
+}
+
+func (l *CreateSpendingLimit) ValidateCreateSpendingLimit() error {
+	return validation.ValidateStruct(l,
+		validation.Field(&l.Scope, validation.Required, validation.In(model.LimitScopeBalance, model.LimitScopeIdentity, model.LimitScopeLedger)),
+		validation.Field(&l.ScopeID, validation.Required),
+		validation.Field(&l.Window, validation.Required, validation.In(model.LimitWindowDaily, model.LimitWindowWeekly, model.LimitWindowMonthly)),
+		validation.Field(&l.Currency, validation.When(l.MaxAmount != "" || l.PreciseMaxAmount != 0, validation.Required.Error("an amount limit needs a currency"))),
+		validation.Field(&l.MaxAmount, validation.By(positiveDecimal)),
+		validation.Field(&l.PreciseMaxAmount, validation.Min(int64(0)), validation.When(l.MaxAmount != "", validation.Empty.Error("pass either max_amount or precise_max_amount, not both"))),
+		validation.Field(&l.MaxCount, validation.Min(int64(0)), validation.When(l.MaxAmount == "" && l.PreciseMaxAmount == 0, validation.Required.Error("pass max_amount, max_count or both"))),
+	)
+}
+
+func (t *CreateKYCTier) ValidateCreateKYCTier() error {
+	eitherCap := func(amount json.Number) validation.Rule {
+		return validation.When(amount != "", validation.Empty.Error("pass either the amount or its precise_ counterpart, not both"))
+	}
+	return validation.ValidateStruct(t,
+		validation.Field(&t.Name, validation.Required),
+		validation.Field(&t.Currency, validation.Required),
+		validation.Field(&t.MaxBalance, validation.By(positiveDecimal)),
+		validation.Field(&t.PreciseMaxBalance, validation.Min(int64(0)), eitherCap(t.MaxBalance)),
+		validation.Field(&t.MaxTransaction, validation.By(positiveDecimal)),
+		validation.Field(&t.PreciseMaxTransaction, validation.Min(int64(0)), eitherCap(t.MaxTransaction)),
+		validation.Field(&t.DailyVolume, validation.By(positiveDecimal)),
+		validation.Field(&t.PreciseDailyVolume, validation.Min(int64(0)), eitherCap(t.DailyVolume)),
+		validation.Field(&t.CapAction, validation.In(model.TierCapReject, model.TierCapHold)),
+	)
+}
+
+func (t *ChangeIdentityTier) ValidateChangeIdentityTier() error {
+	return validation.ValidateStruct(t,
+		validation.Field(&t.TierID, validation.Required),
+		validation.Field(&t.ChangedBy, validation.Required),
+	)
+}
+
+func (l *ListSpendingLimits) ValidateListSpendingLimits() error {
+	if err := l.ValidateListQuery(); err != nil {
+		return err
+	}
+	return validation.ValidateStruct(l,
+		validation.Field(&l.Scope, validation.In(model.LimitScopeBalance, model.LimitScopeIdentity, model.LimitScopeLedger)),
+	)
+}
+
+func (b *ListBalances) ValidateListBalances() error {
+	if err := b.ValidateListQuery(); err != nil {
+		return err
+	}
+	return validation.ValidateStruct(b,
+		validation.Field(&b.BalanceRange, validation.By(validRange)),
+		validation.Field(&b.CreditBalanceRange, validation.By(validRange)),
+		validation.Field(&b.DebitBalanceRange, validation.By(validRange)),
+	)
+}
+
+// bounds converts the already validated paging parameters.
+func (q *ListQuery) bounds() (int64, time.Time, time.Time) {
+	id, _ := model.DecodeCursor(q.Cursor)
+	var from, to time.Time
+	if q.From != "" {
+		from, _ = time.Parse(time.RFC3339, q.From)
+	}
+	if q.To != "" {
+		to, _ = time.Parse(time.RFC3339, q.To)
+	}
+	return id, from, to
+}
+
+func (t *ListTransactions) ToTransactionFilter() model.TransactionFilter {
+	id, from, to := t.bounds()
+	return model.TransactionFilter{
+		ID:                id,
+		Status:            strings.ToUpper(t.Status),
+		BalanceID:         t.BalanceID,
+		Currency:          t.Currency,
+		ParentTransaction: t.ParentTransaction,
+		MetaData:          t.MetaData,
+		From:              from,
+		To:                to,
+	}
+}
+
+func (b *ListBalances) ToBalanceFilter() model.BalanceFilter {
+	id, from, to := b.bounds()
+	return model.BalanceFilter{
+		ID:                 id,
+		BalanceRange:       b.BalanceRange,
+		CreditBalanceRange: b.CreditBalanceRange,
+		DebitBalanceRange:  b.DebitBalanceRange,
+		Currency:           b.Currency,
+		LedgerID:           b.LedgerID,
+		IdentityID:         b.IdentityID,
+		From:               from,
+		To:                 to,
+	}
+}
+
+func (r *ListRecurringTransactions) ToRecurringTransactionFilter() model.RecurringTransactionFilter {
+	id, from, to := r.bounds()
+	return model.RecurringTransactionFilter{ID: id, Status: strings.ToUpper(r.Status), From: from, To: to}
+}
+
+func (l *ListSpendingLimits) ToSpendingLimitFilter() model.SpendingLimitFilter {
+	return model.SpendingLimitFilter{ID: l.CursorID(), Scope: l.Scope, ScopeID: l.ScopeID}
+}
+
+// CursorID returns the already validated position to continue a listing after.
+func (q *ListQuery) CursorID() int64 {
+	id, _, _ := q.bounds()
+	return id
+}
+
+func (l *ListLedgers) ToLedgerFilter() model.LedgerFilter {
+	id, from, to := l.bounds()
+	return model.LedgerFilter{ID: id, From: from, To: to}
+}
+
+func (r *RescheduleTransaction) ValidateRescheduleTransaction() error {
+	return validation.ValidateStruct(r,
+		validation.Field(&r.ScheduledFor, validation.Required, validation.By(func(value interface{}) error {
+			dateStr, ok := value.(string)
+			if !ok {
+				return errors.New("invalid type for scheduled date")
+			}
+			return validateDateFormat("2006-01-02T15:04:05Z07:00", dateStr)
+		})),
+	)
+}
+
+func (r *RescheduleTransaction) ScheduledTime() time.Time {
+	scheduledFor, _ := time.Parse("2006-01-02T15:04:05Z07:00", r.ScheduledFor)
+	return scheduledFor
 }
@@ -0,0 +1,8 @@
+package model
+
+// ListQueueTasks pages through the tasks of one queue in one state: pending, active, scheduled, retry or archived.
+type ListQueueTasks struct {
+	State string `form:"state"`
+	Page  int    `form:"page"`
+	Limit int    `form:"limit"`
+}
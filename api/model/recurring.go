@@ -0,0 +1,16 @@
+package model
+
+type CreateRecurringTransaction struct {
+	Cron            string                 `json:"cron"`
+	IntervalSeconds int64                  `json:"interval_seconds"`
+	StartAt         string                 `json:"start_at"`
+	EndAt           string                 `json:"end_at"`
+	MaxOccurrences  int64                  `json:"max_occurrences"`
+	Template        RecordTransaction      `json:"template"`
+	MetaData        map[string]interface{} `json:"meta_data"`
+}
+
+type ListRecurringTransactions struct {
+	ListQuery
+	Status string `form:"status"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import "encoding/json"
+
+type CreateKYCTier struct {
+	Name                  string                 `json:"name"`
+	Currency              string                 `json:"currency"`
+	MaxBalance            json.Number            `json:"max_balance"`
+	PreciseMaxBalance     int64                  `json:"precise_max_balance"`
+	MaxTransaction        json.Number            `json:"max_transaction"`
+	PreciseMaxTransaction int64                  `json:"precise_max_transaction"`
+	DailyVolume           json.Number            `json:"daily_volume"`
+	PreciseDailyVolume    int64                  `json:"precise_daily_volume"`
+	CapAction             string                 `json:"cap_action"`
+	MetaData              map[string]interface{} `json:"meta_data"`
+}
+
+type ChangeIdentityTier struct {
+	TierID    string `json:"tier_id"`
+	Reason    string `json:"reason"`
+	ChangedBy string `json:"changed_by"`
+}
@@ -1,15 +1,21 @@
 package model
 
 import (
+	"encoding/json"
+
 	"github.com/northstar-pay/nucleus/model"
 )
 
 type RecordTransaction struct {
-	Amount             float64                `json:"amount"`
-	Rate               float64                `json:"rate"`
-	Precision          float64                `json:"precision"`
+	Amount             json.Number            `json:"amount"`
+	PreciseAmount      int64                  `json:"precise_amount"`
+	Rate               json.Number            `json:"rate"`
+	QuoteID            string                 `json:"quote_id"`
+	Precision          int64                  `json:"precision"`
 	AllowOverDraft     bool                   `json:"allow_overdraft"`
 	Inflight           bool                   `json:"inflight"`
+	Atomic             bool                   `json:"atomic"`
+	Reserve            bool                   `json:"reserve"`
 	Source             string                 `json:"source"`
 	Reference          string                 `json:"reference"`
 	Destination        string                 `json:"destination"`
@@ -23,7 +29,11 @@ type RecordTransaction struct {
 	MetaData           map[string]interface{} `json:"meta_data"`
 }
 
+type RescheduleTransaction struct {
+	ScheduledFor string `json:"scheduled_for"`
+}
+
 type InflightUpdate struct {
-	Status string  `json:"status"`
-	Amount float64 `json:"amount"`
+	Status string      `json:"status"`
+	Amount json.Number `json:"amount"`
 }
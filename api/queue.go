@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	model2 "github.com/northstar-pay/nucleus/api/model"
+)
+
+func (a Api) GetQueueStats(c *gin.Context) {
+	resp, err := a.blnk.GetQueueStats()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a Api) ListQueueTasks(c *gin.Context) {
+	var query model2.ListQueueTasks
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.ListQueueTasks(c.Param("queue"), query.State, query.Page, query.Limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a Api) RedriveQueueTask(c *gin.Context) {
+	if err := a.blnk.RedriveArchivedTask(c.Param("queue"), c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task re-driven successfully"})
+}
+
+func (a Api) DeleteQueueTask(c *gin.Context) {
+	if err := a.blnk.DeleteArchivedTask(c.Request.Context(), c.Param("queue"), c.Param("id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
+}
+
+func (a Api) RejectQueueTask(c *gin.Context) {
+	resp, err := a.blnk.RejectArchivedTask(c.Request.Context(), c.Param("queue"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
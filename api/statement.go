@@ -0,0 +1,68 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/northstar-pay/nucleus/model"
+)
+
+var statementFormats = map[string]struct{ contentType, extension string }{
+	model.StatementFormatJSON:    {"application/json", "json"},
+	model.StatementFormatCSV:     {"text/csv", "csv"},
+	model.StatementFormatCamt053: {"application/xml", "xml"},
+}
+
+// GetStatement serves the statement of a balance or account. from is required, to defaults to now and
+// format is one of json, csv or camt053.
+func (a Api) GetStatement(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", model.StatementFormatJSON))
+	output, ok := statementFormats[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of json, csv or camt053"})
+		return
+	}
+
+	from, err := time.Parse(time.RFC3339, c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from is required and must be an RFC3339 timestamp"})
+		return
+	}
+	var to time.Time
+	if value := c.Query("to"); value != "" {
+		to, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC3339 timestamp"})
+			return
+		}
+	}
+
+	statement, err := a.blnk.GenerateStatement(id, from, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if format == model.StatementFormatJSON {
+		c.JSON(http.StatusOK, statement)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := statement.Write(&body, format); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=\""+statement.StatementID+"."+output.extension+"\"")
+	c.Data(http.StatusOK, output.contentType, body.Bytes())
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/northstar-pay/nucleus/api/middleware"
+	model2 "github.com/northstar-pay/nucleus/api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateKYCTier adds a tier identities can be assigned. It requires a privileged key.
+func (a Api) CreateKYCTier(c *gin.Context) {
+	if !middleware.IsPrivileged(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "creating a kyc tier requires a privileged key"})
+		return
+	}
+
+	var newTier model2.CreateKYCTier
+	if err := c.ShouldBindJSON(&newTier); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := newTier.ValidateCreateKYCTier()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.CreateKYCTier(newTier.ToKYCTier(), newTier.MaxBalance, newTier.MaxTransaction, newTier.DailyVolume)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (a Api) GetKYCTier(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	resp, err := a.blnk.GetKYCTier(id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a Api) GetAllKYCTiers(c *gin.Context) {
+	resp, err := a.blnk.GetAllKYCTiers()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ChangeIdentityTier assigns an identity to a tier and records who did it. It requires a privileged key.
+func (a Api) ChangeIdentityTier(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+	if !middleware.IsPrivileged(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "changing an identity's tier requires a privileged key"})
+		return
+	}
+
+	var req model2.ChangeIdentityTier
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := req.ValidateChangeIdentityTier()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.ChangeIdentityTier(c.Request.Context(), id, req.ToTierChange())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetIdentityTierChanges lists the history of an identity's tier, newest first.
+func (a Api) GetIdentityTierChanges(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	var query model2.ListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := query.ValidateListQuery()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.GetIdentityTierChanges(id, query.CursorID(), query.Limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
@@ -44,7 +44,7 @@ func TestRecordTransaction(t *testing.T) {
 		{
 			name: "Valid Transaction",
 			payload: model2.RecordTransaction{
-				Amount:      750,
+				Amount:      "750",
 				Precision:   100,
 				Reference:   "ref_001adcfgf",
 				Description: "For fees",
@@ -70,7 +70,7 @@ func TestRecordTransaction(t *testing.T) {
 		{
 			name: "Missing Reference",
 			payload: model2.RecordTransaction{
-				Amount:      750,
+				Amount:      "750",
 				Description: "For fees",
 				Currency:    "NGN",
 				Source:      newSourceBalance.BalanceID,
@@ -82,7 +82,7 @@ func TestRecordTransaction(t *testing.T) {
 		{
 			name: "Missing Currency",
 			payload: model2.RecordTransaction{
-				Amount:      750,
+				Amount:      "750",
 				Reference:   "ref_001adcfgf",
 				Description: "For fees",
 				Source:      newSourceBalance.BalanceID,
@@ -94,7 +94,7 @@ func TestRecordTransaction(t *testing.T) {
 		{
 			name: "Missing Source",
 			payload: model2.RecordTransaction{
-				Amount:      750,
+				Amount:      "750",
 				Reference:   "ref_001adcfgf",
 				Description: "For fees",
 				Currency:    "NGN",
@@ -106,7 +106,7 @@ func TestRecordTransaction(t *testing.T) {
 		{
 			name: "Missing Destination",
 			payload: model2.RecordTransaction{
-				Amount:      750,
+				Amount:      "750",
 				Reference:   "ref_001adcfgf",
 				Description: "For fees",
 				Currency:    "NGN",
@@ -138,7 +138,7 @@ func TestRecordTransaction(t *testing.T) {
 
 			if !tt.wantErr && tt.expectedCode == http.StatusCreated {
 				assert.Equal(t, tt.payload.Amount, response.Amount)
-				assert.Equal(t, int64(tt.payload.Precision*tt.payload.Amount), response.PreciseAmount)
+				assert.Equal(t, int64(75000), response.PreciseAmount)
 				assert.Equal(t, tt.payload.Reference, response.Reference)
 				assert.Equal(t, tt.payload.Description, response.Description)
 				assert.Equal(t, tt.payload.Currency, response.Currency)
@@ -169,7 +169,7 @@ func TestRecordTransactionWithExitingRef(t *testing.T) {
 		return
 	}
 	validPayload := model2.RecordTransaction{
-		Amount:      10000,
+		Amount:      "10000",
 		Reference:   gofakeit.UUID(),
 		Description: "test",
 		Currency:    "NGN",
@@ -3,18 +3,36 @@ package api
 import (
 	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/sirupsen/logrus"
 
+	blnk "github.com/northstar-pay/nucleus"
+
+	"github.com/northstar-pay/nucleus/api/middleware"
 	model2 "github.com/northstar-pay/nucleus/api/model"
 	"github.com/northstar-pay/nucleus/model"
 
 	"github.com/gin-gonic/gin"
 )
 
+// allowOverdraftPermitted responds 403 and returns false when a request without a privileged key asks to skip
+// balance checks with allow_overdraft.
+func allowOverdraftPermitted(c *gin.Context, allowOverdraft bool) bool {
+	if allowOverdraft && !middleware.IsPrivileged(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "allow_overdraft requires a privileged key"})
+		return false
+	}
+	return true
+}
+
+// RecordTransaction records a transaction without queuing it and responds with its final status and balances.
+// It serves POST /transactions?sync=true. If recording outlasts the sync timeout, it responds 202 with the
+// transaction so the client can fetch the outcome later.
 func (a Api) RecordTransaction(c *gin.Context) {
 	var newTransaction model2.RecordTransaction
 	if err := c.ShouldBindJSON(&newTransaction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
 		return
 	}
 
@@ -23,8 +41,23 @@ func (a Api) RecordTransaction(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
 		return
 	}
-	resp, err := a.blnk.RecordTransaction(c.Request.Context(), newTransaction.ToTransaction())
-
+	if !allowOverdraftPermitted(c, newTransaction.AllowOverDraft) {
+		return
+	}
+	resp, err := a.blnk.RecordTransactionSync(c.Request.Context(), newTransaction.ToTransaction())
+	if errors.Is(err, blnk.ErrSyncTimeout) {
+		c.JSON(http.StatusAccepted, gin.H{"error": err.Error(), "transaction": resp.Transaction})
+		return
+	}
+	var rejection *blnk.RejectionError
+	if errors.As(blnk.ClassifyTransactionError(err), &rejection) {
+		response := gin.H{"error": err.Error(), "code": rejection.Code}
+		if resp != nil {
+			response["transaction"] = resp.Transaction
+		}
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -33,7 +66,34 @@ func (a Api) RecordTransaction(c *gin.Context) {
 	c.JSON(http.StatusCreated, resp)
 }
 
+// SimulateTransaction reports whether a transaction would be applied and the balances it would leave, without recording it.
+func (a Api) SimulateTransaction(c *gin.Context) {
+	var newTransaction model2.RecordTransaction
+	if err := c.ShouldBindJSON(&newTransaction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	err := newTransaction.ValidateRecordTransaction()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.SimulateTransaction(c.Request.Context(), newTransaction.ToTransaction())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 func (a Api) QueueTransaction(c *gin.Context) {
+	if c.Query("sync") == "true" {
+		a.RecordTransaction(c)
+		return
+	}
 
 	var newTransaction model2.RecordTransaction
 	if err := c.ShouldBindJSON(&newTransaction); err != nil {
@@ -45,6 +105,9 @@ func (a Api) QueueTransaction(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
 		return
 	}
+	if !allowOverdraftPermitted(c, newTransaction.AllowOverDraft) {
+		return
+	}
 
 	resp, err := a.blnk.QueueTransaction(c.Request.Context(), newTransaction.ToTransaction())
 	if err != nil {
@@ -90,6 +153,86 @@ func (a Api) GetTransaction(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// GetScheduledTransactions lists transactions waiting for their scheduled time, soonest first.
+func (a Api) GetScheduledTransactions(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	resp, err := a.blnk.GetScheduledTransactions(limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a Api) CancelScheduledTransaction(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	resp, err := a.blnk.CancelScheduledTransaction(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (a Api) RescheduleTransaction(c *gin.Context) {
+	id, passed := c.Params.Get("id")
+	if !passed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required. pass id in the route /:id"})
+		return
+	}
+
+	var req model2.RescheduleTransaction
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+	if err := req.ValidateRescheduleTransaction(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.RescheduleTransaction(c.Request.Context(), id, req.ScheduledTime())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetAllTransactions lists transactions newest first. Metadata is matched with meta_data[key]=value query parameters;
+// a value such as 42 or true matches that number or boolean as well as the string.
+func (a Api) GetAllTransactions(c *gin.Context) {
+	var query model2.ListTransactions
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+	query.MetaData = c.QueryMap("meta_data")
+
+	err := query.ValidateListTransactions()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": err.Error()})
+		return
+	}
+
+	resp, err := a.blnk.GetAllTransactions(query.ToTransactionFilter(), query.Limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
 func (a Api) UpdateInflightStatus(c *gin.Context) {
 	var resp *model.Transaction
 	id, passed := c.Params.Get("txID")
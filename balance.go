@@ -1,7 +1,11 @@
 package blnk
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/northstar-pay/nucleus/internal/notification"
 
@@ -39,6 +43,21 @@ func (l *Blnk) checkBalanceMonitors(updatedBalance *model.Balance) {
 
 }
 
+// notifyOverdrawn sends a balance.overdrawn webhook for a balance left below zero, carrying how much of its
+// overdraft limit is still available.
+func (l *Blnk) notifyOverdrawn(balance *model.Balance) {
+	if balance.Balance >= 0 {
+		return
+	}
+	err := SendWebhook(NewWebhook{
+		Event:   "balance.overdrawn",
+		Payload: balance,
+	})
+	if err != nil {
+		notification.NotifyError(err)
+	}
+}
+
 func (l *Blnk) getOrCreateBalanceByIndicator(indicator, currency string) (*model.Balance, error) {
 	balance, err := l.datasource.GetBalanceByIndicator(indicator, currency)
 	if err != nil {
@@ -46,9 +65,10 @@ func (l *Blnk) getOrCreateBalanceByIndicator(indicator, currency string) (*model
 			Indicator: indicator,
 			LedgerID:  GeneralLedgerID,
 			Currency:  currency,
+			Hot:       isHotIndicator(indicator),
 		} //TODO refactor
 		// Save the new balance to the datasource
-		_, err := l.datasource.CreateBalance(*balance)
+		_, err := l.CreateBalance(*balance)
 		if err != nil {
 			return nil, err
 		}
@@ -61,10 +81,52 @@ func (l *Blnk) getOrCreateBalanceByIndicator(indicator, currency string) (*model
 	return balance, nil
 }
 
+// CreateBalance takes the balance precision from the currency registry, rejecting unknown currencies and
+// precisions that disagree with it.
 func (l *Blnk) CreateBalance(balance model.Balance) (model.Balance, error) {
+	precision, err := l.resolvePrecision(balance.Currency, int64(balance.CurrencyMultiplier))
+	if err != nil {
+		return model.Balance{}, err
+	}
+	balance.CurrencyMultiplier = float64(precision)
+	if balance.Hot {
+		if err := l.checkHotAllowed(context.Background(), &balance); err != nil {
+			return model.Balance{}, err
+		}
+	}
 	return l.datasource.CreateBalance(balance)
 }
 
+// SetOverdraftLimit lets a balance go as far as limit below zero, until expiresAt when it is not nil. limit is
+// in the balance's major units; preciseLimit, in minor units, is used when limit is empty. A zero limit removes
+// the overdraft.
+func (l *Blnk) SetOverdraftLimit(ctx context.Context, balanceID string, limit json.Number, preciseLimit int64, expiresAt *time.Time) (*model.Balance, error) {
+	balance, err := l.datasource.GetBalanceByIDLite(balanceID)
+	if err != nil {
+		return nil, err
+	}
+	if limit != "" {
+		preciseLimit, err = model.ToPrecise(limit, int64(balance.CurrencyMultiplier))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if preciseLimit < 0 {
+		return nil, errors.New("overdraft limit cannot be negative")
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, errors.New("overdraft expiry must be in the future")
+	}
+
+	if err := l.datasource.UpdateOverdraftLimit(ctx, balanceID, preciseLimit, expiresAt); err != nil {
+		return nil, err
+	}
+	balance.OverdraftLimit = preciseLimit
+	balance.OverdraftExpiresAt = expiresAt
+	balance.ComputeAvailableBalance()
+	return balance, nil
+}
+
 func (l *Blnk) GetBalanceByIndicator(indicator, currency string) (*model.Balance, error) {
 	return l.datasource.GetBalanceByIndicator(indicator, currency)
 }
@@ -73,8 +135,51 @@ func (l *Blnk) GetBalanceByID(id string, include []string) (*model.Balance, erro
 	return l.datasource.GetBalanceByID(id, include)
 }
 
-func (l *Blnk) GetAllBalances() ([]model.Balance, error) {
-	return l.datasource.GetAllBalances()
+// GetBalanceAt returns the balance with its balance, credit, debit and inflight figures as they stood at asOf.
+// Hot balances are snapshotted as their deltas are flushed, so theirs are the figures of the last flush before asOf.
+func (l *Blnk) GetBalanceAt(id string, asOf time.Time, include []string) (*model.Balance, error) {
+	balance, err := l.datasource.GetBalanceByID(id, include)
+	if err != nil {
+		return nil, err
+	}
+	if asOf.Before(balance.CreatedAt) {
+		return nil, fmt.Errorf("balance '%s' did not exist at %s", id, asOf.Format(time.RFC3339))
+	}
+
+	snapshot, err := l.datasource.GetBalanceSnapshotAt(id, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	balance.Balance = snapshot.Balance
+	balance.CreditBalance = snapshot.CreditBalance
+	balance.DebitBalance = snapshot.DebitBalance
+	balance.InflightBalance = snapshot.InflightBalance
+	balance.InflightCreditBalance = snapshot.InflightCreditBalance
+	balance.InflightDebitBalance = snapshot.InflightDebitBalance
+	balance.Version = snapshot.Version
+	// Reservations are not snapshotted, so a past balance shows none.
+	balance.ReservedBalance = 0
+	balance.ComputeAvailableBalance()
+	return balance, nil
+}
+
+// GetBalanceHistory returns the snapshots recorded for a balance between from and to, oldest first.
+func (l *Blnk) GetBalanceHistory(id string, from, to time.Time, limit int) ([]model.BalanceSnapshot, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	return l.datasource.GetBalanceSnapshots(id, from, to, limit)
+}
+
+// GetAllBalances returns one page of balances matching the filter, newest first.
+func (l *Blnk) GetAllBalances(filter model.BalanceFilter, limit int) (model.Page[model.Balance], error) {
+	limit = model.PageLimit(limit)
+	balances, err := l.datasource.GetAllBalances(filter, limit+1)
+	if err != nil {
+		return model.Page[model.Balance]{}, err
+	}
+	return model.NewPage(balances, limit, func(balance model.Balance) int64 { return balance.ID }), nil
 }
 
 func (l *Blnk) CreateMonitor(monitor model.BalanceMonitor) (model.BalanceMonitor, error) {
@@ -0,0 +1,205 @@
+package blnk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/northstar-pay/nucleus/internal/notification"
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// ChangeBalanceStatus moves a balance to change.ToStatus, keeping change.Reason and change.ChangedBy in the
+// balance's audit trail. A balance can only be closed once it holds nothing; when sweepTo is set, whatever is
+// left on it is first moved to that balance. A closed balance cannot be reopened.
+func (l *Blnk) ChangeBalanceStatus(ctx context.Context, balanceID string, change model.BalanceStatusChange, sweepTo string) (*model.Balance, error) {
+	if !model.IsBalanceStatus(change.ToStatus) {
+		return nil, fmt.Errorf("unknown balance status %q", change.ToStatus)
+	}
+	if change.Reason == "" {
+		return nil, errors.New("a reason is required to change a balance's status")
+	}
+	if sweepTo != "" && change.ToStatus != model.BalanceClosed {
+		return nil, errors.New("sweep_to can only be passed when closing a balance")
+	}
+
+	balance, err := l.datasource.GetBalanceByIDLite(balanceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatusChange(balance, change.ToStatus); err != nil {
+		return nil, err
+	}
+
+	// Hold the locks of the balance and of sweepTo so the change does not land in the middle of a transaction
+	// that already checked the old status, and the sweep moves what is really left on the balance.
+	keys := []string{balance.BalanceID}
+	if sweepTo != "" {
+		key, err := l.balanceLockKey(sweepTo, balance.Currency)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	ctx, locker, err := l.lockBalances(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer l.releaseLock(ctx, locker)
+
+	balance, err = l.datasource.GetBalanceByIDLite(balanceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatusChange(balance, change.ToStatus); err != nil {
+		return nil, err
+	}
+
+	change.BalanceID = balanceID
+	change.FromStatus = statusOf(balance)
+	change.CreatedAt = time.Now()
+
+	var sweep *model.Transaction
+	var target *model.Balance
+	if change.ToStatus == model.BalanceClosed && balance.Balance != 0 {
+		if sweepTo == "" {
+			return nil, fmt.Errorf("balance %s must be zero to be closed, pass sweep_to to move what is left", balanceID)
+		}
+		sweep, target, err = l.prepareSweep(balance, sweepTo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sweep balance %s: %w", balanceID, err)
+		}
+		change.SweepTransactionID = sweep.TransactionID
+	}
+
+	var updated bool
+	if sweep == nil {
+		updated, err = l.datasource.UpdateBalanceStatus(ctx, change)
+	} else {
+		updated, err = l.datasource.SweepAndCloseBalance(ctx, change, sweep, balance, target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("balance %s changed while its status was being updated, try again", balanceID)
+	}
+	if sweep != nil {
+		go l.checkBalanceMonitors(target)
+		l.postTransactionActions(ctx, sweep)
+	}
+
+	balance, err = l.datasource.GetBalanceByIDLite(balanceID)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		err := SendWebhook(NewWebhook{
+			Event:   "balance.status_changed",
+			Payload: change,
+		})
+		if err != nil {
+			notification.NotifyError(err)
+		}
+	}()
+	return balance, nil
+}
+
+// GetBalanceStatusChanges returns the audit trail of a balance's status, newest first.
+func (l *Blnk) GetBalanceStatusChanges(balanceID string, cursor int64, limit int) (model.Page[model.BalanceStatusChange], error) {
+	if _, err := l.datasource.GetBalanceByIDLite(balanceID); err != nil {
+		return model.Page[model.BalanceStatusChange]{}, err
+	}
+	limit = model.PageLimit(limit)
+	changes, err := l.datasource.GetBalanceStatusChanges(balanceID, cursor, limit+1)
+	if err != nil {
+		return model.Page[model.BalanceStatusChange]{}, err
+	}
+	return model.NewPage(changes, limit, func(change model.BalanceStatusChange) int64 { return change.ID }), nil
+}
+
+// checkStatusChange rejects changes out of the closed status, changes that would leave the status as it is and
+// closing a balance that still has funds in flight.
+func checkStatusChange(balance *model.Balance, status string) error {
+	current := statusOf(balance)
+	if current == model.BalanceClosed {
+		return fmt.Errorf("balance %s is closed and cannot be reopened", balance.BalanceID)
+	}
+	if current == status {
+		return fmt.Errorf("balance %s is already %s", balance.BalanceID, status)
+	}
+	if status != model.BalanceClosed {
+		return nil
+	}
+	if balance.Hot {
+		return fmt.Errorf("balance %s is a hot balance and cannot be closed", balance.BalanceID)
+	}
+	if balance.InflightCreditBalance != 0 || balance.InflightDebitBalance != 0 {
+		return fmt.Errorf("balance %s has inflight transactions, commit or void them before closing it", balance.BalanceID)
+	}
+	if balance.ReservedBalance != 0 {
+		return fmt.Errorf("balance %s has funds reserved for queued transactions", balance.BalanceID)
+	}
+	return nil
+}
+
+// statusOf returns the balance's status, treating balances saved before statuses existed as active.
+func statusOf(balance *model.Balance) string {
+	if balance.Status == "" {
+		return model.BalanceActive
+	}
+	return balance.Status
+}
+
+// prepareSweep applies to a balance that is being closed, in memory, the transaction that moves what is left on
+// it to sweepTo, or pulls from sweepTo what it takes to bring an overdrawn balance back to zero. The caller holds
+// both balances' locks and persists the sweep with the status change. The sweep is a system transfer: the
+// balance being closed may be frozen or blocked, so its status is not checked, and spending limits and KYC tiers
+// do not apply. sweepTo's status and funds are checked.
+func (l *Blnk) prepareSweep(balance *model.Balance, sweepTo string) (*model.Transaction, *model.Balance, error) {
+	target, err := l.resolveBalance(sweepTo, balance.Currency)
+	if err != nil {
+		return nil, nil, err
+	}
+	if target.BalanceID == balance.BalanceID {
+		return nil, nil, errors.New("a balance cannot be swept into itself")
+	}
+
+	transaction := &model.Transaction{
+		TransactionID:   model.GenerateUUIDWithSuffix("txn"),
+		Reference:       model.GenerateUUIDWithSuffix("ref"),
+		Currency:        balance.Currency,
+		Precision:       int64(balance.CurrencyMultiplier),
+		Source:          balance.BalanceID,
+		Destination:     target.BalanceID,
+		Description:     fmt.Sprintf("Closing sweep of balance %s", balance.BalanceID),
+		Status:          StatusApplied,
+		SkipStatusCheck: true,
+		CreatedAt:       time.Now(),
+		MetaData:        map[string]interface{}{"blnk_swept_balance": balance.BalanceID},
+	}
+	source, destination := balance, target
+	if balance.Balance > 0 {
+		transaction.PreciseAmount = balance.Balance
+		err = target.CheckCredit()
+	} else {
+		transaction.PreciseAmount = -balance.Balance
+		transaction.Source, transaction.Destination = target.BalanceID, balance.BalanceID
+		source, destination = target, balance
+		err = target.CheckDebit()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := l.checkBalanceCurrencies(transaction, source, destination); err != nil {
+		return nil, nil, err
+	}
+	transaction.Amount = model.FromPrecise(transaction.PreciseAmount, transaction.Precision)
+	transaction.Hash = transaction.HashTxn()
+
+	if err := model.UpdateBalances(transaction, source, destination); err != nil {
+		return nil, nil, err
+	}
+	return transaction, target, nil
+}
@@ -0,0 +1,153 @@
+package blnk
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expectBalanceLite expects a lite read of a USD balance with the given figures and status.
+func expectBalanceLite(mock sqlmock.Sqlmock, balanceID string, balance, inflightDebit int64, status string) {
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.balances WHERE balance_id = $1`)).WithArgs(balanceID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+			AddRow(balanceID, "USD", 100, "ledger-id", balance, max(balance, 0), max(-balance, 0), -inflightDebit, 0, inflightDebit, time.Now(), 1, 0, false, 0, nil, status, "", ""))
+}
+
+// expectStatusUpdate expects a balance's status to be changed and the change recorded.
+func expectStatusUpdate(mock sqlmock.Sqlmock, balanceID, from, to, reason string, sweepTransactionID driver.Value) {
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).
+		WithArgs(balanceID, to, reason, from, model.BalanceClosed).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.balance_status_changes`)).
+		WithArgs(balanceID, from, to, reason, "ops@example.com", sweepTransactionID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func TestChangeBalanceStatus(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	balanceID := gofakeit.UUID()
+	expectBalanceLite(mock, balanceID, 5000, 0, model.BalanceActive)
+	// The balance is read again once it is locked.
+	expectBalanceLite(mock, balanceID, 5000, 0, model.BalanceActive)
+	expectStatusUpdate(mock, balanceID, model.BalanceActive, model.BalanceFrozen, "fraud review", nil)
+	expectBalanceLite(mock, balanceID, 5000, 0, model.BalanceFrozen)
+
+	change := model.BalanceStatusChange{ToStatus: model.BalanceFrozen, Reason: "fraud review", ChangedBy: "ops@example.com"}
+	balance, err := d.ChangeBalanceStatus(context.Background(), balanceID, change, "")
+	require.NoError(t, err)
+	assert.Equal(t, model.BalanceFrozen, balance.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The reason is part of the audit trail and cannot be left out.
+	_, err = d.ChangeBalanceStatus(context.Background(), balanceID, model.BalanceStatusChange{ToStatus: model.BalanceActive}, "")
+	assert.Error(t, err)
+}
+
+func TestCloseBalanceRefusals(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	balanceID := gofakeit.UUID()
+	closing := model.BalanceStatusChange{ToStatus: model.BalanceClosed, Reason: "customer request", ChangedBy: "ops@example.com"}
+
+	expectBalanceLite(mock, balanceID, 5000, 0, model.BalanceActive)
+	expectBalanceLite(mock, balanceID, 5000, 0, model.BalanceActive)
+	_, err = d.ChangeBalanceStatus(context.Background(), balanceID, closing, "")
+	assert.ErrorContains(t, err, "must be zero")
+
+	expectBalanceLite(mock, balanceID, 0, 300, model.BalanceActive)
+	_, err = d.ChangeBalanceStatus(context.Background(), balanceID, closing, gofakeit.UUID())
+	assert.ErrorContains(t, err, "inflight")
+
+	expectBalanceLite(mock, balanceID, 0, 0, model.BalanceClosed)
+	_, err = d.ChangeBalanceStatus(context.Background(), balanceID, model.BalanceStatusChange{ToStatus: model.BalanceActive, Reason: "reopen"}, "")
+	assert.ErrorContains(t, err, "cannot be reopened")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCloseBalanceSweepsRemainder(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	balanceID, sweepTo := gofakeit.UUID(), gofakeit.UUID()
+	expectBalanceLite(mock, balanceID, 5000, 0, model.BalanceFrozen)
+	// Once both balances are locked, the frozen balance's remainder is swept out, although it could not send money
+	// otherwise. The sweep is a system transfer, so no spending limits are looked up.
+	expectBalanceLite(mock, balanceID, 5000, 0, model.BalanceFrozen)
+	expectBalanceLite(mock, sweepTo, 0, 0, model.BalanceActive)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).
+		WithArgs(balanceID, 0, 5000, 5000, 0, 0, 0, "USD", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).
+		WithArgs(sweepTo, 5000, 5000, 0, 0, 0, 0, "USD", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	insertArgs := make([]driver.Value, 20)
+	for i := range insertArgs {
+		insertArgs[i] = sqlmock.AnyArg()
+	}
+	insertArgs[2], insertArgs[9], insertArgs[11] = balanceID, sweepTo, StatusApplied
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.transactions`)).WithArgs(insertArgs...).WillReturnResult(sqlmock.NewResult(1, 1))
+	// The status changes in the same database transaction as the sweep.
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).
+		WithArgs(balanceID, model.BalanceClosed, "customer request", model.BalanceFrozen, model.BalanceClosed).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.balance_status_changes`)).
+		WithArgs(balanceID, model.BalanceFrozen, model.BalanceClosed, "customer request", "ops@example.com", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	expectBalanceLite(mock, balanceID, 0, 0, model.BalanceClosed)
+
+	closing := model.BalanceStatusChange{ToStatus: model.BalanceClosed, Reason: "customer request", ChangedBy: "ops@example.com"}
+	balance, err := d.ChangeBalanceStatus(context.Background(), balanceID, closing, sweepTo)
+	require.NoError(t, err)
+	assert.Equal(t, model.BalanceClosed, balance.Status)
+	assert.Equal(t, int64(0), balance.Balance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCloseBalanceRollsBackSweepWhenStatusChangeFails(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	balanceID, sweepTo := gofakeit.UUID(), gofakeit.UUID()
+	expectBalanceLite(mock, balanceID, 5000, 0, model.BalanceActive)
+	expectBalanceLite(mock, balanceID, 5000, 0, model.BalanceActive)
+	expectBalanceLite(mock, sweepTo, 0, 0, model.BalanceActive)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).WithArgs(balanceID, 0, 5000, 5000, 0, 0, 0, "USD", sqlmock.AnyArg(), sqlmock.AnyArg(),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).WithArgs(sweepTo, 5000, 5000, 0, 0, 0, 0, "USD", sqlmock.AnyArg(), sqlmock.AnyArg(),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	insertArgs := make([]driver.Value, 20)
+	for i := range insertArgs {
+		insertArgs[i] = sqlmock.AnyArg()
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.transactions`)).WithArgs(insertArgs...).WillReturnResult(sqlmock.NewResult(1, 1))
+	// Someone changed the status in the meantime, so nothing is swept either.
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).
+		WithArgs(balanceID, model.BalanceClosed, "customer request", model.BalanceActive, model.BalanceClosed).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	closing := model.BalanceStatusChange{ToStatus: model.BalanceClosed, Reason: "customer request", ChangedBy: "ops@example.com"}
+	_, err = d.ChangeBalanceStatus(context.Background(), balanceID, closing, sweepTo)
+	assert.ErrorContains(t, err, "try again")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
 	"testing"
 	"time"
 
@@ -21,7 +22,7 @@ func getBalanceMock(credit, debit, balance int64) *model.Balance {
 }
 
 func getTransactionMock(amount float64, overdraft bool) model.Transaction {
-	transaction := model.Transaction{TransactionID: gofakeit.UUID(), Amount: amount, AllowOverdraft: overdraft, Reference: gofakeit.UUID()}
+	transaction := model.Transaction{TransactionID: gofakeit.UUID(), Amount: json.Number(strconv.FormatFloat(amount, 'f', -1, 64)), AllowOverdraft: overdraft, Reference: gofakeit.UUID()}
 	return transaction
 }
 
@@ -132,8 +133,9 @@ func TestCreateBalance(t *testing.T) {
 
 	// Convert metadata to JSON for mocking
 	metaDataJSON, _ := json.Marshal(balance.MetaData)
+	expectCurrencyLookup(mock, "USD", 2)
 	mock.ExpectExec("INSERT INTO blnk.balances").
-		WithArgs(sqlmock.AnyArg(), balance.Balance, balance.CreditBalance, balance.DebitBalance, balance.Currency, balance.CurrencyMultiplier, balance.LedgerID, balance.IdentityID, sqlmock.AnyArg(), sqlmock.AnyArg(), metaDataJSON).
+		WithArgs(sqlmock.AnyArg(), balance.Balance, balance.CreditBalance, balance.DebitBalance, balance.Currency, float64(100), balance.LedgerID, balance.IdentityID, sqlmock.AnyArg(), sqlmock.AnyArg(), metaDataJSON, false).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	result, err := d.CreateBalance(balance)
@@ -160,9 +162,9 @@ func TestGetBalanceByID(t *testing.T) {
 	mock.ExpectBegin()
 
 	// Adjust the expected SQL to match the actual query structure and fields
-	expectedSQL := "SELECT b\\.balance_id, b\\.balance, b\\.credit_balance, b\\.debit_balance, b\\.currency, b\\.currency_multiplier, b\\.ledger_id, COALESCE\\(b\\.identity_id, ''\\) as identity_id, b\\.created_at, b\\.meta_data, b\\.inflight_balance, b\\.inflight_credit_balance, b\\.inflight_debit_balance, b\\.version FROM \\( SELECT \\* FROM blnk\\.balances WHERE balance_id = \\$1 \\) AS b"
-	rows := sqlmock.NewRows([]string{"balance_id", "balance", "credit_balance", "debit_balance", "currency", "currency_multiplier", "ledger_id", "identity_id", "created_at", "meta_data", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "version"}).
-		AddRow(balanceID, 100, 50, 50, "USD", 100, "test-ledger", "test-identity", time.Now(), `{"key":"value"}`, 0, 0, 0, 0)
+	expectedSQL := "SELECT b\\.balance_id, b\\.balance, b\\.credit_balance, b\\.debit_balance, b\\.currency, b\\.currency_multiplier, b\\.ledger_id, COALESCE\\(b\\.identity_id, ''\\) as identity_id, b\\.created_at, b\\.meta_data, b\\.inflight_balance, b\\.inflight_credit_balance, b\\.inflight_debit_balance, b\\.version, b\\.reserved_balance, b\\.hot, b\\.overdraft_limit, b\\.overdraft_expires_at, b\\.status, b\\.status_reason FROM \\( SELECT \\* FROM blnk\\.balances WHERE balance_id = \\$1 \\) AS b"
+	rows := sqlmock.NewRows([]string{"balance_id", "balance", "credit_balance", "debit_balance", "currency", "currency_multiplier", "ledger_id", "identity_id", "created_at", "meta_data", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason"}).
+		AddRow(balanceID, 100, 50, 50, "USD", 100, "test-ledger", "test-identity", time.Now(), `{"key":"value"}`, 0, 0, 0, 0, 0, false, 0, nil, "active", "")
 
 	mock.ExpectQuery(expectedSQL).
 		WithArgs(balanceID).
@@ -180,6 +182,43 @@ func TestGetBalanceByID(t *testing.T) {
 	}
 }
 
+func TestGetBalanceAt(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	if err != nil {
+		t.Fatalf("Error creating test data source: %s", err)
+	}
+
+	d, err := NewBlnk(datasource)
+	if err != nil {
+		t.Fatalf("Error creating Blnk instance: %s", err)
+	}
+	balanceID := gofakeit.UUID()
+	createdAt := time.Now().Add(-48 * time.Hour)
+	asOf := time.Now().Add(-24 * time.Hour)
+
+	mock.ExpectBegin()
+	rows := sqlmock.NewRows([]string{"balance_id", "balance", "credit_balance", "debit_balance", "currency", "currency_multiplier", "ledger_id", "identity_id", "created_at", "meta_data", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason"}).
+		AddRow(balanceID, 500, 700, 200, "USD", 100, "test-ledger", "test-identity", createdAt, `{}`, 0, 0, 0, 4, 0, false, 0, nil, "active", "")
+	mock.ExpectQuery("SELECT b\\.balance_id").WithArgs(balanceID).WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	snapshotRows := sqlmock.NewRows([]string{"balance_id", "version", "transaction_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "currency", "created_at"}).
+		AddRow(balanceID, 2, "txn-2", 300, 300, 0, 0, 0, 0, "USD", asOf.Add(-time.Hour))
+	mock.ExpectQuery("FROM blnk.balance_snapshots WHERE balance_id = \\$1 AND created_at <= \\$2").
+		WithArgs(balanceID, asOf).
+		WillReturnRows(snapshotRows)
+
+	result, err := d.GetBalanceAt(balanceID, asOf, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result.Version)
+	assert.Equal(t, int64(300), result.Balance)
+	assert.Equal(t, int64(0), result.DebitBalance)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestGetAllBalances(t *testing.T) {
 	datasource, mock, err := newTestDataSource()
 	if err != nil {
@@ -190,15 +229,22 @@ func TestGetAllBalances(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error creating Blnk instance: %s", err)
 	}
-	rows := sqlmock.NewRows([]string{"balance_id", "balance", "credit_balance", "debit_balance", "currency", "currency_multiplier", "ledger_id", "created_at", "meta_data"}).
-		AddRow("test-balance", 100, 50, 50, "USD", 1.0, "test-ledger", time.Now(), `{"key":"value"}`)
+	columns := []string{"id", "balance_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "reserved_balance", "currency", "currency_multiplier", "ledger_id", "identity_id", "indicator", "version", "created_at", "meta_data", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason"}
+	rows := sqlmock.NewRows(columns).
+		AddRow(9, "bln_9", 100, 50, 50, 0, 0, 0, 0, "USD", 1.0, "test-ledger", "", "", 1, time.Now(), `{"key":"value"}`, false, 0, nil, "active", "").
+		AddRow(8, "bln_8", 100, 50, 50, 0, 0, 0, 0, "USD", 1.0, "test-ledger", "", "", 1, time.Now(), `{"key":"value"}`, false, 0, nil, "active", "").
+		AddRow(7, "bln_7", 100, 50, 50, 0, 0, 0, 0, "USD", 1.0, "test-ledger", "", "", 1, time.Now(), `{"key":"value"}`, false, 0, nil, "active", "")
 
-	mock.ExpectQuery("SELECT balance_id, balance, credit_balance, debit_balance, currency, currency_multiplier, ledger_id, created_at, meta_data FROM blnk.balances LIMIT 20").WillReturnRows(rows)
+	filter := model.BalanceFilter{ID: 10, Currency: "USD", BalanceRange: "100..", DebitBalanceRange: "..500"}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM blnk.balances WHERE id < $1 AND currency = $2 AND balance >= $3 AND debit_balance <= $4 ORDER BY id DESC LIMIT $5")).
+		WithArgs(int64(10), "USD", int64(100), int64(500), 3).
+		WillReturnRows(rows)
 
-	result, err := d.GetAllBalances()
+	result, err := d.GetAllBalances(filter, 2)
 
 	assert.NoError(t, err)
-	assert.Len(t, result, 1)
+	assert.Len(t, result.Data, 2)
+	assert.Equal(t, model.EncodeCursor(8), result.NextCursor)
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -261,18 +307,18 @@ func TestUpdateBalances(t *testing.T) {
 
 	// Expect the first update (for sourceBalance)
 	mock.ExpectExec("UPDATE blnk.balances").WithArgs(
-		sourceBalance.BalanceID, sourceBalance.Balance, sourceBalance.CreditBalance, sourceBalance.DebitBalance, sourceBalance.InflightBalance, sourceBalance.InflightCreditBalance, sourceBalance.InflightDebitBalance, sourceBalance.Currency, sourceBalance.CurrencyMultiplier, sourceBalance.LedgerID, sourceBalance.CreatedAt, sqlmock.AnyArg(), sourceBalance.Version,
+		sourceBalance.BalanceID, sourceBalance.Balance, sourceBalance.CreditBalance, sourceBalance.DebitBalance, sourceBalance.InflightBalance, sourceBalance.InflightCreditBalance, sourceBalance.InflightDebitBalance, sourceBalance.Currency, sourceBalance.CurrencyMultiplier, sourceBalance.LedgerID, sourceBalance.CreatedAt, sqlmock.AnyArg(), sourceBalance.Version, "txn-id",
 	).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	// Expect the second update (for destinationBalance)
 	mock.ExpectExec("UPDATE blnk.balances").WithArgs(
-		destinationBalance.BalanceID, destinationBalance.Balance, destinationBalance.CreditBalance, destinationBalance.DebitBalance, destinationBalance.InflightBalance, destinationBalance.InflightCreditBalance, destinationBalance.InflightDebitBalance, destinationBalance.Currency, destinationBalance.CurrencyMultiplier, destinationBalance.LedgerID, destinationBalance.CreatedAt, sqlmock.AnyArg(), destinationBalance.Version,
+		destinationBalance.BalanceID, destinationBalance.Balance, destinationBalance.CreditBalance, destinationBalance.DebitBalance, destinationBalance.InflightBalance, destinationBalance.InflightCreditBalance, destinationBalance.InflightDebitBalance, destinationBalance.Currency, destinationBalance.CurrencyMultiplier, destinationBalance.LedgerID, destinationBalance.CreatedAt, sqlmock.AnyArg(), destinationBalance.Version, "txn-id",
 	).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	// Expect transaction commit
 	mock.ExpectCommit()
 
-	err = d.datasource.UpdateBalances(context.Background(), sourceBalance, destinationBalance)
+	err = d.datasource.UpdateBalances(context.Background(), &model.Transaction{TransactionID: "txn-id"}, sourceBalance, destinationBalance)
 	assert.NoError(t, err)
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -280,6 +326,34 @@ func TestUpdateBalances(t *testing.T) {
 	}
 }
 
+func TestSetOverdraftLimit(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	balanceID := gofakeit.UUID()
+	expiresAt := time.Now().Add(24 * time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.balances WHERE balance_id = $1`)).WithArgs(balanceID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+			AddRow(balanceID, "USD", 100, "ledger-id", -2000, 0, 2000, 0, 0, 0, time.Now(), 1, 0, false, 0, nil, "active", "", ""))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances SET overdraft_limit = $2, overdraft_expires_at = $3`)).
+		WithArgs(balanceID, int64(5000), &expiresAt).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	balance, err := d.SetOverdraftLimit(context.Background(), balanceID, "50", 0, &expiresAt)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5000), balance.OverdraftLimit)
+	assert.Equal(t, int64(3000), balance.AvailableCredit)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	past := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.balances WHERE balance_id = $1`)).WithArgs(balanceID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+			AddRow(balanceID, "USD", 100, "ledger-id", 0, 0, 0, 0, 0, 0, time.Now(), 1, 0, false, 0, nil, "active", "", ""))
+	_, err = d.SetOverdraftLimit(context.Background(), balanceID, "", 100, &past)
+	assert.Error(t, err)
+}
+
 func TestCreateMonitor(t *testing.T) {
 	datasource, mock, err := newTestDataSource()
 	if err != nil {
@@ -4,11 +4,13 @@ import (
 	"context"
 	"embed"
 	"fmt"
+	"sync"
 
 	"github.com/typesense/typesense-go/typesense/api"
 
 	"github.com/northstar-pay/nucleus/config"
 	"github.com/northstar-pay/nucleus/database"
+	redlock "github.com/northstar-pay/nucleus/internal/lock"
 	redis_db "github.com/northstar-pay/nucleus/internal/redis-db"
 	"github.com/northstar-pay/nucleus/model"
 	"github.com/redis/go-redis/v9"
@@ -18,8 +20,11 @@ type Blnk struct {
 	queue      *Queue
 	search     *TypesenseClient
 	redis      redis.UniversalClient
+	locks      redlock.Provider
 	datasource database.IDataSource
 	bt         *model.BalanceTracker
+	// hotBalances holds the IDs of balances seen to be hot.
+	hotBalances sync.Map
 }
 
 const (
@@ -42,7 +47,11 @@ func NewBlnk(db database.IDataSource) (*Blnk, error) {
 	newQueue := NewQueue(configuration)
 
 	newSearch := NewTypesenseClient("blnk-api-key", []string{configuration.TypeSense.Dns})
-	newBlnk := &Blnk{datasource: db, bt: bt, queue: newQueue, redis: redisClient.Client(), search: newSearch}
+	var locks redlock.Provider = redlock.NewRedisProvider(redisClient.Client())
+	if configuration.Transaction.LockProvider == config.LockProviderPostgres {
+		locks = redlock.NewPostgresProvider()
+	}
+	newBlnk := &Blnk{datasource: db, bt: bt, queue: newQueue, redis: redisClient.Client(), locks: locks, search: newSearch}
 	return newBlnk, nil
 }
 
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	blnk "github.com/northstar-pay/nucleus"
+	"github.com/northstar-pay/nucleus/model"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func fxCommands(b *blnkInstance) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fx",
+		Short: "manage blnk fx rates",
+	}
+
+	cmd.AddCommand(fxLoadCommands(b))
+
+	return cmd
+}
+
+// fxLoadCommands publishes a rate set from a JSON file shaped like {"effective_at": "...", "rates": [{"base_currency": "USD", "quote_currency": "NGN", "rate": "1300.5"}]}.
+func fxLoadCommands(b *blnkInstance) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:  "load [file]",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			f, err := os.Open(args[0])
+			if err != nil {
+				logrus.Error(err)
+				return
+			}
+			defer f.Close()
+
+			var rateSet model.FXRateSet
+			if err := json.NewDecoder(f).Decode(&rateSet); err != nil {
+				logrus.Error(err)
+				return
+			}
+			rateSet.Source = blnk.RateSourceFile
+
+			created, err := b.blnk.CreateFXRateSet(rateSet)
+			if err != nil {
+				logrus.Error(err)
+				return
+			}
+			fmt.Printf("Loaded rate set %s (version %d) with %d rates\n", created.RateSetID, created.Version, len(created.Rates))
+		},
+	}
+
+	return cmd
+}
@@ -87,6 +87,9 @@ func NewCLI() *Blnk {
 	rootCmd.AddCommand(workerCommands(b))
 	rootCmd.AddCommand(migrateCommands(b))
 	rootCmd.AddCommand(backupCommands(b))
+	rootCmd.AddCommand(fxCommands(b))
+	rootCmd.AddCommand(statementCommands(b))
+	rootCmd.AddCommand(queueCommands(b))
 	return &Blnk{cmd: rootCmd}
 }
 
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/northstar-pay/nucleus/model"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// queueCommands inspects the task queues and handles tasks that ran out of retries, e.g. blnk queue list new:webhoook --state archived.
+func queueCommands(b *blnkInstance) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "inspect blnk queues and archived tasks",
+	}
+
+	cmd.AddCommand(queueStatsCommand(b))
+	cmd.AddCommand(queueListCommand(b))
+	cmd.AddCommand(queueTaskCommand("redrive", "put an archived task back in its queue", func(queue, id string) error {
+		return b.blnk.RedriveArchivedTask(queue, id)
+	}))
+	cmd.AddCommand(queueTaskCommand("delete", "delete an archived task", func(queue, id string) error {
+		return b.blnk.DeleteArchivedTask(context.Background(), queue, id)
+	}))
+	cmd.AddCommand(queueTaskCommand("reject", "record the transaction of an archived task as rejected", func(queue, id string) error {
+		_, err := b.blnk.RejectArchivedTask(context.Background(), queue, id)
+		return err
+	}))
+
+	return cmd
+}
+
+func queueStatsCommand(b *blnkInstance) *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "show the depth and latency of every queue",
+		Run: func(cmd *cobra.Command, args []string) {
+			stats, err := b.blnk.GetQueueStats()
+			if err != nil {
+				logrus.Error(err)
+				return
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "QUEUE\tPENDING\tACTIVE\tSCHEDULED\tRETRY\tARCHIVED\tLATENCY\tPAUSED")
+			for _, s := range stats {
+				fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%s\t%t\n", s.Queue, s.Pending, s.Active, s.Scheduled, s.Retry, s.Archived, s.Latency, s.Paused)
+			}
+			_ = w.Flush()
+		},
+	}
+}
+
+func queueListCommand(b *blnkInstance) *cobra.Command {
+	var state string
+	var page, limit int
+
+	cmd := &cobra.Command{
+		Use:   "list [queue]",
+		Short: "list the tasks of a queue with their payloads",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			tasks, err := b.blnk.ListQueueTasks(args[0], state, page, limit)
+			if err != nil {
+				logrus.Error(err)
+				return
+			}
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(tasks); err != nil {
+				logrus.Error(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&state, "state", model.TaskStateArchived, "pending, active, scheduled, retry or archived")
+	cmd.Flags().IntVar(&page, "page", 1, "page to list")
+	cmd.Flags().IntVar(&limit, "limit", 20, "tasks per page")
+
+	return cmd
+}
+
+func queueTaskCommand(use, short string, run func(queue, id string) error) *cobra.Command {
+	return &cobra.Command{
+		Use:   use + " [queue] [task id]",
+		Short: short,
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := run(args[0], args[1]); err != nil {
+				logrus.Error(err)
+				return
+			}
+			fmt.Printf("%s: done for task %s in %s\n", use, args[1], args[0])
+		},
+	}
+}
@@ -53,7 +53,11 @@ func serverCommands(b *blnkInstance) *cobra.Command {
 		Use:   "start",
 		Short: "start blnk server",
 		Run: func(cmd *cobra.Command, args []string) {
-			router := api.NewAPI(b.blnk).Router()
+			newAPI, err := api.NewAPI(b.blnk)
+			if err != nil {
+				log.Fatal(err)
+			}
+			router := newAPI.Router()
 			cfg, err := config.Fetch()
 			if err != nil {
 				log.Fatal(err)
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/northstar-pay/nucleus/model"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// statementCommands prints the statement of a balance or account, e.g. blnk statement acc_... --from 2024-01-01T00:00:00Z --format csv.
+func statementCommands(b *blnkInstance) *cobra.Command {
+	var from, to, format, output string
+
+	cmd := &cobra.Command{
+		Use:   "statement [balance or account id]",
+		Short: "export a balance statement",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			start, err := time.Parse(time.RFC3339, from)
+			if err != nil {
+				logrus.Error("--from must be an RFC3339 timestamp")
+				return
+			}
+			var end time.Time
+			if to != "" {
+				end, err = time.Parse(time.RFC3339, to)
+				if err != nil {
+					logrus.Error("--to must be an RFC3339 timestamp")
+					return
+				}
+			}
+
+			statement, err := b.blnk.GenerateStatement(args[0], start, end)
+			if err != nil {
+				logrus.Error(err)
+				return
+			}
+
+			var w io.Writer = os.Stdout
+			if output != "" {
+				f, err := os.Create(output)
+				if err != nil {
+					logrus.Error(err)
+					return
+				}
+				defer f.Close()
+				w = f
+			}
+
+			if err := statement.Write(w, format); err != nil {
+				logrus.Error(err)
+			}
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "start of the statement period (RFC3339)")
+	cmd.Flags().StringVar(&to, "to", "", "end of the statement period (RFC3339), defaults to now")
+	cmd.Flags().StringVar(&format, "format", model.StatementFormatJSON, "json, csv or camt053")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "file to write the statement to, defaults to stdout")
+	_ = cmd.MarkFlagRequired("from")
+
+	return cmd
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	blnk "github.com/northstar-pay/nucleus"
+	"errors"
 	"log"
+	"math/rand"
+	"time"
+
+	blnk "github.com/northstar-pay/nucleus"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -24,27 +27,50 @@ func (b *blnkInstance) processTransaction(cxt context.Context, t *asynq.Task) er
 		return err
 	}
 	_, err := b.blnk.RecordTransaction(cxt, &txn)
-	if err != nil {
-		return err
+	if err == nil {
+		log.Println(" [*] Transaction Processed", txn.ID)
+		return nil
 	}
-	//todo only reject insufficient balance transaction and push the rest back for retry
 
-	if err != nil {
-		_, err := b.blnk.RejectTransaction(cxt, &txn, err.Error())
-		if err != nil {
+	var rejection *blnk.RejectionError
+	if !errors.As(blnk.ClassifyTransactionError(err), &rejection) {
+		// Transient failures go back to the queue. Once retries run out, a transaction holding reserved funds
+		// is rejected so the funds are not held by an archived task.
+		if txn.ReservedAmount == 0 || !isLastAttempt(cxt) {
 			return err
 		}
-		err = blnk.SendWebhook(blnk.NewWebhook{
-			Event:   "transaction.rejected",
-			Payload: txn,
-		})
-		return err
+		rejection = &blnk.RejectionError{Code: blnk.RejectionRetriesExhausted, Err: err}
+	}
+
+	if rejection.Code == blnk.RejectionDuplicateReference && b.blnk.TransactionRecorded(cxt, &txn) {
+		// An earlier attempt posted the transaction but the task was not acknowledged.
+		return nil
 	}
 
-	log.Println(" [*] Transaction Processed", txn.ID)
+	if _, err := b.blnk.RejectTransaction(cxt, &txn, rejection); err != nil {
+		return err
+	}
+	logrus.Printf(" [*] Transaction %s rejected: %s", txn.TransactionID, rejection.Code)
 	return nil
 }
 
+// retryDelay backs off exponentially between attempts at a failed task, with jitter so tasks that failed
+// together, for example on the same locked balance, do not retry together.
+func retryDelay(n int, _ error, _ *asynq.Task) time.Duration {
+	delay := time.Second << min(n, 6)
+	return delay + time.Duration(rand.Int63n(int64(delay/2)))
+}
+
+// isLastAttempt reports whether a failing task will be archived instead of retried.
+func isLastAttempt(ctx context.Context) bool {
+	retried, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return false
+	}
+	maxRetry, ok := asynq.GetMaxRetry(ctx)
+	return ok && retried >= maxRetry
+}
+
 func (b *blnkInstance) procesInflightExpiry(cxt context.Context, t *asynq.Task) error {
 	var txnID string
 	if err := json.Unmarshal(t.Payload(), &txnID); err != nil {
@@ -61,6 +87,74 @@ func (b *blnkInstance) procesInflightExpiry(cxt context.Context, t *asynq.Task)
 	return nil
 }
 
+// flushHotBalances folds pending deltas into hot balances every interval for as long as the worker runs.
+func (b *blnkInstance) flushHotBalances(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := b.blnk.FlushHotBalances(context.Background()); err != nil {
+			logrus.Errorf("failed to flush hot balances: %v", err)
+		}
+	}
+}
+
+// runRecurringTransactions queues the recurring transaction occurrences that fall due every interval for as
+// long as the worker runs.
+func (b *blnkInstance) runRecurringTransactions(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := b.blnk.RunDueRecurringTransactions(context.Background()); err != nil {
+			logrus.Errorf("failed to run recurring transactions: %v", err)
+		}
+	}
+}
+
+// newWorkerServer builds a server for the webhook and inflight expiry queues and the given count of transaction
+// queues, weighted as configured.
+func (b *blnkInstance) newWorkerServer(conf *config.Configuration, transactionQueues int) (*asynq.Server, *asynq.ServeMux) {
+	queues := make(map[string]int)
+	queues[blnk.WEBHOOK_QUEUE] = conf.Queue.WebhookWeight
+	queues[blnk.EXPIREDINFLIGHT_QUEUE] = conf.Queue.InflightExpiryWeight
+
+	mux := asynq.NewServeMux()
+	for _, queueName := range blnk.TransactionQueues(transactionQueues) {
+		queues[queueName] = conf.Queue.TransactionWeight
+		mux.HandleFunc(queueName, b.processTransaction)
+	}
+	mux.HandleFunc(blnk.WEBHOOK_QUEUE, blnk.ProcessWebhook)
+	mux.HandleFunc(blnk.EXPIREDINFLIGHT_QUEUE, b.procesInflightExpiry)
+
+	srv := asynq.NewServer(
+		asynq.RedisClientOpt{Addr: conf.Redis.Dns, Password: conf.Redis.Password},
+		asynq.Config{
+			Concurrency:    conf.Queue.Concurrency,
+			Queues:         queues,
+			RetryDelayFunc: retryDelay,
+		},
+	)
+	return srv, mux
+}
+
+// drainTransactionQueues serves the queues of the previous count until a reshard has drained them, so no
+// transaction is taken from the new queues while an older one for the same balance is still waiting.
+func (b *blnkInstance) drainTransactionQueues(conf *config.Configuration, previous int) error {
+	logrus.Infof("resharding transaction queues from %d to %d, draining the old queues", previous, conf.Queue.TransactionQueues)
+	srv, mux := b.newWorkerServer(conf, previous)
+	if err := srv.Start(mux); err != nil {
+		return err
+	}
+	defer srv.Shutdown()
+
+	if err := b.blnk.DrainTransactionQueues(context.Background(), previous, conf.Queue.TransactionQueues); err != nil {
+		return err
+	}
+	logrus.Infof("old transaction queues drained; previous_transaction_queues can be removed from the config")
+	return nil
+}
+
 func workerCommands(b *blnkInstance) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "workers",
@@ -72,32 +166,15 @@ func workerCommands(b *blnkInstance) *cobra.Command {
 				return
 			}
 
-			queues := make(map[string]int)
-			queues[blnk.WEBHOOK_QUEUE] = 3
-			queues[blnk.EXPIREDINFLIGHT_QUEUE] = 3
-
-			for i := 1; i <= blnk.NumberOfQueues; i++ {
-				queueName := fmt.Sprintf("%s_%d", blnk.TRANSACTION_QUEUE, i)
-				queues[queueName] = 1
-			}
-
-			srv := asynq.NewServer(
-				asynq.RedisClientOpt{Addr: conf.Redis.Dns, Password: conf.Redis.Password},
-				asynq.Config{
-					Concurrency: 1,
-					Queues:      queues,
-				},
-			)
-
-			mux := asynq.NewServeMux()
-			// Register handler for each queue
-			for i := 1; i <= blnk.NumberOfQueues; i++ {
-				queueName := fmt.Sprintf("%s_%d", blnk.TRANSACTION_QUEUE, i)
-				mux.HandleFunc(queueName, b.processTransaction)
+			if previous := conf.Queue.PreviousTransactionQueues; previous > 0 {
+				if err := b.drainTransactionQueues(conf, previous); err != nil {
+					log.Fatal("Error draining transaction queues:", err)
+				}
 			}
 
-			mux.HandleFunc(blnk.WEBHOOK_QUEUE, blnk.ProcessWebhook)
-			mux.HandleFunc(blnk.EXPIREDINFLIGHT_QUEUE, b.procesInflightExpiry)
+			srv, mux := b.newWorkerServer(conf, conf.Queue.TransactionQueues)
+			go b.flushHotBalances(time.Duration(conf.Transaction.HotFlushIntervalSeconds) * time.Second)
+			go b.runRecurringTransactions(time.Duration(conf.Transaction.RecurringIntervalSeconds) * time.Second)
 			if err := srv.Run(mux); err != nil {
 				log.Fatal("Error running server:", err)
 			}
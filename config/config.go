@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"strings"
@@ -14,7 +15,22 @@ import (
 )
 
 const (
-	DEFAULT_PORT = "5001"
+	DEFAULT_PORT               = "5001"
+	DEFAULT_FX_QUOTE_TTL       = 60
+	DEFAULT_FX_REVENUE_BALANCE = "@FXRevenue"
+	DEFAULT_IDEMPOTENCY_TTL    = 86400
+	DEFAULT_SYNC_TIMEOUT       = 30
+	DEFAULT_LOCK_WAIT_TIMEOUT  = 10
+	DEFAULT_HOT_FLUSH_INTERVAL = 1
+	DEFAULT_RECURRING_INTERVAL = 10
+	DEFAULT_TRANSACTION_QUEUES = 20
+	DEFAULT_WORKER_CONCURRENCY = 1
+)
+
+// Lock providers balances can be locked with while posting.
+const (
+	LockProviderRedis    = "redis"
+	LockProviderPostgres = "postgres"
 )
 
 var ConfigStore atomic.Value
@@ -23,9 +39,14 @@ type ServerConfig struct {
 	SSL       bool   `json:"ssl" envconfig:"BLNK_SERVER_SSL"`
 	Secure    bool   `json:"secure" envconfig:"BLNK_SERVER_SECURE"`
 	SecretKey string `json:"secret_key" envconfig:"BLNK_SERVER_SECRET_KEY"`
-	Domain    string `json:"domain" envconfig:"BLNK_SERVER_SSL_DOMAIN"`
-	Email     string `json:"ssl_email" envconfig:"BLNK_SERVER_SSL_EMAIL"`
-	Port      string `json:"port" envconfig:"BLNK_SERVER_PORT"`
+	// PrivilegedKeys are secret keys that, besides everything the secret key can do, may override balance
+	// checks with allow_overdraft and set overdraft limits.
+	PrivilegedKeys []string `json:"privileged_keys" envconfig:"BLNK_SERVER_PRIVILEGED_KEYS"`
+	Domain         string   `json:"domain" envconfig:"BLNK_SERVER_SSL_DOMAIN"`
+	Email          string   `json:"ssl_email" envconfig:"BLNK_SERVER_SSL_EMAIL"`
+	Port           string   `json:"port" envconfig:"BLNK_SERVER_PORT"`
+	// IdempotencyTTLSeconds is how long a response is kept for replay under its Idempotency-Key.
+	IdempotencyTTLSeconds int `json:"idempotency_ttl_seconds" envconfig:"BLNK_SERVER_IDEMPOTENCY_TTL_SECONDS"`
 }
 
 type DataSourceConfig struct {
@@ -41,6 +62,44 @@ type TypeSenseConfig struct {
 	Dns string `json:"dns" envconfig:"BLNK_TYPESENSE_DNS"`
 }
 
+type FXConfig struct {
+	SpreadBps       int64  `json:"spread_bps" envconfig:"BLNK_FX_SPREAD_BPS"`
+	QuoteTTLSeconds int64  `json:"quote_ttl_seconds" envconfig:"BLNK_FX_QUOTE_TTL_SECONDS"`
+	RevenueBalance  string `json:"revenue_balance" envconfig:"BLNK_FX_REVENUE_BALANCE"`
+}
+
+type TransactionConfig struct {
+	// SyncTimeoutSeconds bounds how long a synchronous transaction request waits for its outcome.
+	SyncTimeoutSeconds int64 `json:"sync_timeout_seconds" envconfig:"BLNK_TRANSACTION_SYNC_TIMEOUT_SECONDS"`
+	// LockWaitTimeoutSeconds bounds how long posting waits for another transaction to release a balance.
+	LockWaitTimeoutSeconds int64 `json:"lock_wait_timeout_seconds" envconfig:"BLNK_TRANSACTION_LOCK_WAIT_TIMEOUT_SECONDS"`
+	// LockProvider selects where balance locks are held: "redis" (the default) or "postgres", which takes them as
+	// advisory locks in the database transaction that writes the balances, within LockWaitTimeoutSeconds.
+	LockProvider string `json:"lock_provider" envconfig:"BLNK_TRANSACTION_LOCK_PROVIDER"`
+	// HotIndicators lists indicator balances, such as "@World", that are created hot.
+	HotIndicators []string `json:"hot_indicators" envconfig:"BLNK_TRANSACTION_HOT_INDICATORS"`
+	// HotFlushIntervalSeconds is how often workers fold pending deltas into hot balances.
+	HotFlushIntervalSeconds int64 `json:"hot_flush_interval_seconds" envconfig:"BLNK_TRANSACTION_HOT_FLUSH_INTERVAL_SECONDS"`
+	// RecurringIntervalSeconds is how often workers look for recurring transactions that are due.
+	RecurringIntervalSeconds int64 `json:"recurring_interval_seconds" envconfig:"BLNK_TRANSACTION_RECURRING_INTERVAL_SECONDS"`
+}
+
+// QueueConfig sizes the transaction queues and the workers that consume them. Transactions are sharded across
+// TransactionQueues queues by source balance, so each balance's transactions are processed in order.
+type QueueConfig struct {
+	TransactionQueues int `json:"transaction_queues" envconfig:"BLNK_QUEUE_TRANSACTION_QUEUES"`
+	// PreviousTransactionQueues is the queue count being resharded from. While it is set, workers drain the
+	// queues of the old count before they take transactions from the new ones.
+	PreviousTransactionQueues int `json:"previous_transaction_queues" envconfig:"BLNK_QUEUE_PREVIOUS_TRANSACTION_QUEUES"`
+	// Concurrency is how many tasks each worker process runs at once. Above one, transactions of the same
+	// balance may run side by side, and the balance locks rather than queue order keep them consistent.
+	Concurrency int `json:"concurrency" envconfig:"BLNK_QUEUE_CONCURRENCY"`
+	// The weights set how often each kind of queue is polled relative to the others.
+	TransactionWeight    int `json:"transaction_weight" envconfig:"BLNK_QUEUE_TRANSACTION_WEIGHT"`
+	WebhookWeight        int `json:"webhook_weight" envconfig:"BLNK_QUEUE_WEBHOOK_WEIGHT"`
+	InflightExpiryWeight int `json:"inflight_expiry_weight" envconfig:"BLNK_QUEUE_INFLIGHT_EXPIRY_WEIGHT"`
+}
+
 type AccountNumberGenerationConfig struct {
 	EnableAutoGeneration bool `json:"enable_auto_generation"`
 	HttpService          struct {
@@ -83,6 +142,9 @@ type Configuration struct {
 	AccountNumberGeneration AccountNumberGenerationConfig `json:"account_number_generation"`
 	Notification            Notification                  `json:"notification"`
 	OtelGrafanaCloud        OtelGrafanaCloud              `json:"otel_grafana_cloud"`
+	FX                      FXConfig                      `json:"fx"`
+	Transaction             TransactionConfig             `json:"transaction"`
+	Queue                   QueueConfig                   `json:"queue"`
 }
 
 func loadConfigFromFile(file string) error {
@@ -184,6 +246,66 @@ func (cnf *Configuration) validateAndAddDefaults() error {
 		log.Printf("Warning: Port not specified in config. Setting default port: %s", DEFAULT_PORT)
 	}
 
+	if cnf.Server.IdempotencyTTLSeconds <= 0 {
+		cnf.Server.IdempotencyTTLSeconds = DEFAULT_IDEMPOTENCY_TTL
+	}
+
+	if cnf.Transaction.SyncTimeoutSeconds <= 0 {
+		cnf.Transaction.SyncTimeoutSeconds = DEFAULT_SYNC_TIMEOUT
+	}
+
+	if cnf.Transaction.LockWaitTimeoutSeconds <= 0 {
+		cnf.Transaction.LockWaitTimeoutSeconds = DEFAULT_LOCK_WAIT_TIMEOUT
+	}
+
+	if cnf.Transaction.HotFlushIntervalSeconds <= 0 {
+		cnf.Transaction.HotFlushIntervalSeconds = DEFAULT_HOT_FLUSH_INTERVAL
+	}
+
+	if cnf.Transaction.RecurringIntervalSeconds <= 0 {
+		cnf.Transaction.RecurringIntervalSeconds = DEFAULT_RECURRING_INTERVAL
+	}
+
+	switch cnf.Transaction.LockProvider = strings.ToLower(strings.TrimSpace(cnf.Transaction.LockProvider)); cnf.Transaction.LockProvider {
+	case "":
+		cnf.Transaction.LockProvider = LockProviderRedis
+	case LockProviderRedis, LockProviderPostgres:
+	default:
+		return fmt.Errorf("unknown transaction lock provider %q", cnf.Transaction.LockProvider)
+	}
+
+	if cnf.Queue.TransactionQueues <= 0 {
+		cnf.Queue.TransactionQueues = DEFAULT_TRANSACTION_QUEUES
+	}
+
+	if cnf.Queue.PreviousTransactionQueues == cnf.Queue.TransactionQueues || cnf.Queue.PreviousTransactionQueues < 0 {
+		cnf.Queue.PreviousTransactionQueues = 0
+	}
+
+	if cnf.Queue.Concurrency <= 0 {
+		cnf.Queue.Concurrency = DEFAULT_WORKER_CONCURRENCY
+	}
+
+	if cnf.Queue.TransactionWeight <= 0 {
+		cnf.Queue.TransactionWeight = 1
+	}
+
+	if cnf.Queue.WebhookWeight <= 0 {
+		cnf.Queue.WebhookWeight = 3
+	}
+
+	if cnf.Queue.InflightExpiryWeight <= 0 {
+		cnf.Queue.InflightExpiryWeight = 3
+	}
+
+	if cnf.FX.QuoteTTLSeconds <= 0 {
+		cnf.FX.QuoteTTLSeconds = DEFAULT_FX_QUOTE_TTL
+	}
+
+	if cnf.FX.RevenueBalance == "" {
+		cnf.FX.RevenueBalance = DEFAULT_FX_REVENUE_BALANCE
+	}
+
 	return nil
 }
 
@@ -212,6 +334,8 @@ func MockConfig(enableAutoGeneration bool, url string, authorizationToken string
 		},
 	}
 
+	// Mocked configurations get the same defaults as loaded ones.
+	_ = mockConfig.validateAndAddDefaults()
 	ConfigStore.Store(&mockConfig)
 }
 
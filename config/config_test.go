@@ -61,6 +61,29 @@ func TestValidateAndAddDefaults(t *testing.T) {
 	if cnf.Server.Port != DEFAULT_PORT {
 		t.Errorf("Expected default port %s, got %s", DEFAULT_PORT, cnf.Server.Port)
 	}
+
+	// Test lock provider default and validation
+	if cnf.Transaction.LockProvider != LockProviderRedis {
+		t.Errorf("Expected default lock provider %s, got %s", LockProviderRedis, cnf.Transaction.LockProvider)
+	}
+	cnf.Transaction.LockProvider = " Postgres "
+	if err := cnf.validateAndAddDefaults(); err != nil || cnf.Transaction.LockProvider != LockProviderPostgres {
+		t.Errorf("Expected lock provider %s, got %s (%v)", LockProviderPostgres, cnf.Transaction.LockProvider, err)
+	}
+	cnf.Transaction.LockProvider = "etcd"
+	if err := cnf.validateAndAddDefaults(); err == nil {
+		t.Errorf("Expected unknown lock provider error")
+	}
+	cnf.Transaction.LockProvider = ""
+
+	// Test queue defaults; a previous count equal to the current one is not a reshard
+	cnf.Queue = QueueConfig{PreviousTransactionQueues: DEFAULT_TRANSACTION_QUEUES}
+	if err := cnf.validateAndAddDefaults(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if cnf.Queue.TransactionQueues != DEFAULT_TRANSACTION_QUEUES || cnf.Queue.PreviousTransactionQueues != 0 || cnf.Queue.Concurrency != DEFAULT_WORKER_CONCURRENCY {
+		t.Errorf("Expected queue defaults, got %+v", cnf.Queue)
+	}
 }
 
 func TestLoadConfigFromFile(t *testing.T) {
@@ -0,0 +1,47 @@
+package blnk
+
+import (
+	"fmt"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+func (l *Blnk) CreateCurrency(currency model.Currency) (model.Currency, error) {
+	if currency.MinorUnits < 0 || currency.MinorUnits > model.MaxMinorUnits {
+		return model.Currency{}, fmt.Errorf("minor units must be between 0 and %d", model.MaxMinorUnits)
+	}
+	return l.datasource.CreateCurrency(currency)
+}
+
+func (l *Blnk) GetCurrency(code string) (*model.Currency, error) {
+	return l.datasource.GetCurrencyByCode(code)
+}
+
+func (l *Blnk) GetAllCurrencies() ([]model.Currency, error) {
+	return l.datasource.GetAllCurrencies()
+}
+
+// resolvePrecision returns the registered precision for a currency. A precision supplied by the caller is
+// accepted only when it agrees with the registry; zero means the caller left it to the registry.
+func (l *Blnk) resolvePrecision(code string, precision int64) (int64, error) {
+	currency, err := l.datasource.GetCurrencyByCode(code)
+	if err != nil {
+		return 0, err
+	}
+
+	registered := currency.Precision()
+	if precision != 0 && precision != registered {
+		return 0, fmt.Errorf("precision %d does not match the registered precision %d for currency %s: %w", precision, registered, code, model.ErrPrecisionMismatch)
+	}
+	return registered, nil
+}
+
+// applyCurrencyPrecision sets the transaction's precision from the currency registry.
+func (l *Blnk) applyCurrencyPrecision(transaction *model.Transaction) error {
+	precision, err := l.resolvePrecision(transaction.Currency, transaction.Precision)
+	if err != nil {
+		return err
+	}
+	transaction.Precision = precision
+	return nil
+}
@@ -0,0 +1,131 @@
+package blnk
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+func expectCurrencyLookup(mock sqlmock.Sqlmock, code string, minorUnits int) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT code, COALESCE(name, ''), COALESCE(symbol, ''), minor_units, created_at, meta_data FROM blnk.currencies WHERE code = $1`)).
+		WithArgs(code).
+		WillReturnRows(sqlmock.NewRows([]string{"code", "name", "symbol", "minor_units", "created_at", "meta_data"}).
+			AddRow(code, "", "", minorUnits, time.Now(), nil))
+}
+
+func TestCreateBalance_RejectsMismatchedPrecision(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	expectCurrencyLookup(mock, "USD", 2)
+
+	_, err = d.CreateBalance(model.Balance{Currency: "USD", LedgerID: "test-id", CurrencyMultiplier: 1000})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match the registered precision 100")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCreateBalance_RejectsUnknownCurrency(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT code, COALESCE(name, ''), COALESCE(symbol, ''), minor_units, created_at, meta_data FROM blnk.currencies WHERE code = $1`)).
+		WithArgs("XYZ").
+		WillReturnRows(sqlmock.NewRows([]string{"code", "name", "symbol", "minor_units", "created_at", "meta_data"}))
+
+	_, err = d.CreateBalance(model.Balance{Currency: "XYZ", LedgerID: "test-id"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "currency 'XYZ' is not registered")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRecordTransaction_RejectsBalanceCurrencyMismatch(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := gofakeit.UUID()
+	destination := gofakeit.UUID()
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      source,
+		Destination: destination,
+		Amount:      "10",
+		Currency:    "NGN",
+	}
+
+	balanceColumns := []string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}
+	balanceQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "NGN", 2)
+	mock.ExpectQuery(balanceQuery).WithArgs(source).WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(source, "NGN", 100, "ledger-id", 10000, 10000, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+	mock.ExpectQuery(balanceQuery).WithArgs(destination).WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(destination, "USD", 100, "ledger-id", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "is in USD but the transaction is in NGN")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRecordTransaction_RejectsBalancePrecisionMismatch(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := gofakeit.UUID()
+	destination := gofakeit.UUID()
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      source,
+		Destination: destination,
+		Amount:      "10",
+		Currency:    "NGN",
+	}
+
+	balanceColumns := []string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}
+	balanceQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "NGN", 2)
+	mock.ExpectQuery(balanceQuery).WithArgs(source).WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(source, "NGN", 100, "ledger-id", 10000, 10000, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+	// The destination was created with three decimal places, before NGN was registered with two.
+	mock.ExpectQuery(balanceQuery).WithArgs(destination).WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(destination, "NGN", 1000, "ledger-id", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	var rejection *RejectionError
+	assert.ErrorAs(t, err, &rejection)
+	assert.Equal(t, RejectionPrecisionMismatch, rejection.Code)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	redlock "github.com/northstar-pay/nucleus/internal/lock"
 	"github.com/northstar-pay/nucleus/model"
 )
 
@@ -28,7 +29,7 @@ func prepareQueries(queryBuilder strings.Builder, include []string) string {
 	selectFields = append(selectFields,
 		"b.balance_id", "b.balance", "b.credit_balance", "b.debit_balance",
 		"b.currency", "b.currency_multiplier", "b.ledger_id",
-		"COALESCE(b.identity_id, '') as identity_id", "b.created_at", "b.meta_data", "b.inflight_balance", "b.inflight_credit_balance", "b.inflight_debit_balance", "b.version")
+		"COALESCE(b.identity_id, '') as identity_id", "b.created_at", "b.meta_data", "b.inflight_balance", "b.inflight_credit_balance", "b.inflight_debit_balance", "b.version", "b.reserved_balance", "b.hot", "b.overdraft_limit", "b.overdraft_expires_at", "b.status", "b.status_reason")
 
 	// Append fields and joins based on 'include'
 	if contains(include, "identity") {
@@ -75,7 +76,7 @@ func scanRow(row *sql.Row, tx *sql.Tx, include []string) (*model.Balance, error)
 	// Add scan arguments for default fields
 	scanArgs = append(scanArgs, &balance.BalanceID, &balance.Balance, &balance.CreditBalance,
 		&balance.DebitBalance, &balance.Currency, &balance.CurrencyMultiplier,
-		&balance.LedgerID, &balance.IdentityID, &balance.CreatedAt, &metaDataJSON, &balance.InflightBalance, &balance.InflightCreditBalance, &balance.InflightDebitBalance, &balance.Version)
+		&balance.LedgerID, &balance.IdentityID, &balance.CreatedAt, &metaDataJSON, &balance.InflightBalance, &balance.InflightCreditBalance, &balance.InflightDebitBalance, &balance.Version, &balance.ReservedBalance, &balance.Hot, &balance.OverdraftLimit, &balance.OverdraftExpiresAt, &balance.Status, &balance.StatusReason)
 
 	if contains(include, "identity") {
 		scanArgs = append(scanArgs, &identity.IdentityID, &identity.FirstName, &identity.OrganizationName, &identity.Category, &identity.LastName,
@@ -101,6 +102,7 @@ func scanRow(row *sql.Row, tx *sql.Tx, include []string) (*model.Balance, error)
 		return nil, err
 	}
 
+	balance.ComputeAvailableBalance()
 	if contains(include, "identity") {
 		balance.Identity = identity
 	}
@@ -121,6 +123,7 @@ func (d Datasource) CreateBalance(balance model.Balance) (model.Balance, error)
 
 	balance.BalanceID = model.GenerateUUIDWithSuffix("bln")
 	balance.CreatedAt = time.Now()
+	balance.Status = model.BalanceActive
 
 	// Replace empty string with null for identity_id
 	var identityID interface{} = balance.IdentityID
@@ -134,11 +137,16 @@ func (d Datasource) CreateBalance(balance model.Balance) (model.Balance, error)
 		indicator = nil
 	}
 
-	// insert into database
+	// insert into database, together with the balance's first snapshot
 	_, err = d.Conn.Exec(`
-		INSERT INTO blnk.balances (balance_id, balance, credit_balance, debit_balance, currency, currency_multiplier, ledger_id, identity_id, indicator, created_at, meta_data)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10,$11)
-	`, balance.BalanceID, balance.Balance, balance.CreditBalance, balance.DebitBalance, balance.Currency, balance.CurrencyMultiplier, balance.LedgerID, identityID, indicator, balance.CreatedAt, &metaDataJSON)
+		WITH created AS (
+			INSERT INTO blnk.balances (balance_id, balance, credit_balance, debit_balance, currency, currency_multiplier, ledger_id, identity_id, indicator, created_at, meta_data, hot)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10,$11, $12)
+			RETURNING balance_id, version, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, currency, created_at
+		)
+		INSERT INTO blnk.balance_snapshots (balance_id, version, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, currency, created_at)
+		SELECT balance_id, version, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, currency, created_at FROM created
+	`, balance.BalanceID, balance.Balance, balance.CreditBalance, balance.DebitBalance, balance.Currency, balance.CurrencyMultiplier, balance.LedgerID, identityID, indicator, balance.CreatedAt, &metaDataJSON, balance.Hot)
 
 	return balance, err
 }
@@ -159,7 +167,7 @@ func (d Datasource) GetBalanceByID(id string, include []string) (*model.Balance,
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Handle no rows error
-			return nil, fmt.Errorf("balance with ID '%s' not found", id)
+			return nil, fmt.Errorf("balance with ID '%s' %w", id, model.ErrNotFound)
 		} else {
 			// Handle other errors
 			return nil, err
@@ -170,25 +178,34 @@ func (d Datasource) GetBalanceByID(id string, include []string) (*model.Balance,
 		return nil, err
 	}
 
+	if err := d.loadHotFigures(balance); err != nil {
+		return nil, err
+	}
+
 	return balance, nil
 }
 
 func (d Datasource) GetBalanceByIDLite(id string) (*model.Balance, error) {
 	var balance model.Balance
 	row := d.Conn.QueryRow(`
-	   SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version FROM blnk.balances WHERE balance_id = $1
+	   SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1
 	`, id)
 
 	err := row.Scan(&balance.BalanceID, &balance.Currency, &balance.CurrencyMultiplier, &balance.LedgerID, &balance.Balance, &balance.CreditBalance,
-		&balance.DebitBalance, &balance.InflightBalance, &balance.InflightCreditBalance, &balance.InflightDebitBalance, &balance.CreatedAt, &balance.Version)
+		&balance.DebitBalance, &balance.InflightBalance, &balance.InflightCreditBalance, &balance.InflightDebitBalance, &balance.CreatedAt, &balance.Version, &balance.ReservedBalance, &balance.Hot,
+		&balance.OverdraftLimit, &balance.OverdraftExpiresAt, &balance.Status, &balance.StatusReason, &balance.IdentityID)
 	if err != nil {
 		logrus.Errorf("balance lite error %v", err)
 		if err == sql.ErrNoRows {
-			return &model.Balance{}, fmt.Errorf("balance with ID '%s' not found", id)
+			return &model.Balance{}, fmt.Errorf("balance with ID '%s' %w", id, model.ErrNotFound)
 		} else {
 			return nil, err
 		}
 	}
+	balance.ComputeAvailableBalance()
+	if err := d.loadHotFigures(&balance); err != nil {
+		return nil, err
+	}
 
 	return &balance, nil
 }
@@ -196,30 +213,50 @@ func (d Datasource) GetBalanceByIDLite(id string) (*model.Balance, error) {
 func (d Datasource) GetBalanceByIndicator(indicator, currency string) (*model.Balance, error) {
 	var balance model.Balance
 	row := d.Conn.QueryRow(`
-	   SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version FROM blnk.balances WHERE indicator = $1 AND currency = $2 
+	   SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE indicator = $1 AND currency = $2 
 	`, indicator, currency)
 
 	err := row.Scan(&balance.BalanceID, &balance.Currency, &balance.CurrencyMultiplier, &balance.LedgerID, &balance.Balance, &balance.CreditBalance,
-		&balance.DebitBalance, &balance.InflightBalance, &balance.InflightCreditBalance, &balance.InflightDebitBalance, &balance.CreatedAt, &balance.Version)
+		&balance.DebitBalance, &balance.InflightBalance, &balance.InflightCreditBalance, &balance.InflightDebitBalance, &balance.CreatedAt, &balance.Version, &balance.ReservedBalance, &balance.Hot,
+		&balance.OverdraftLimit, &balance.OverdraftExpiresAt, &balance.Status, &balance.StatusReason, &balance.IdentityID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return &model.Balance{}, fmt.Errorf("balance with indicator '%s' not found", indicator)
+			return &model.Balance{}, fmt.Errorf("balance with indicator '%s' %w", indicator, model.ErrNotFound)
 		} else {
 			return nil, err
 		}
 	}
+	balance.ComputeAvailableBalance()
+	if err := d.loadHotFigures(&balance); err != nil {
+		return nil, err
+	}
 
 	return &balance, nil
 }
 
-// GetAllBalances retrieves all balances from the database
-func (d Datasource) GetAllBalances() ([]model.Balance, error) {
-	// select all balances from database
+// GetAllBalances lists balances matching the filter, newest first, up to limit rows.
+func (d Datasource) GetAllBalances(filter model.BalanceFilter, limit int) ([]model.Balance, error) {
+	q := &queryFilter{}
+	q.addCursor(filter.ID)
+	q.addEqual("currency", filter.Currency)
+	q.addEqual("ledger_id", filter.LedgerID)
+	q.addEqual("identity_id", filter.IdentityID)
+	ranges := []struct{ column, value string }{
+		{"balance", filter.BalanceRange},
+		{"credit_balance", filter.CreditBalanceRange},
+		{"debit_balance", filter.DebitBalanceRange},
+	}
+	for _, r := range ranges {
+		if err := q.addRange(r.column, r.value); err != nil {
+			return nil, err
+		}
+	}
+	q.addTimeRange("created_at", filter.From, filter.To)
+
 	rows, err := d.Conn.Query(`
-		SELECT balance_id, balance, credit_balance, debit_balance, currency, currency_multiplier, ledger_id, created_at, meta_data
-		FROM blnk.balances
-		LIMIT 20
-	`)
+		SELECT id, balance_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, reserved_balance, currency,
+		       currency_multiplier, ledger_id, COALESCE(identity_id, ''), COALESCE(indicator, ''), version, created_at, meta_data, hot, overdraft_limit, overdraft_expires_at, status, status_reason
+		FROM blnk.balances`+q.page(limit), q.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -231,22 +268,35 @@ func (d Datasource) GetAllBalances() ([]model.Balance, error) {
 	}(rows)
 
 	// create slice to store balances
-	var balances []model.Balance
+	balances := []model.Balance{}
 
 	// iterate through result set and parse metadata from JSON
 	for rows.Next() {
 		balance := model.Balance{}
 		var metaDataJSON []byte
 		err = rows.Scan(
+			&balance.ID,
 			&balance.BalanceID,
 			&balance.Balance,
 			&balance.CreditBalance,
 			&balance.DebitBalance,
+			&balance.InflightBalance,
+			&balance.InflightCreditBalance,
+			&balance.InflightDebitBalance,
+			&balance.ReservedBalance,
 			&balance.Currency,
 			&balance.CurrencyMultiplier,
 			&balance.LedgerID,
+			&balance.IdentityID,
+			&balance.Indicator,
+			&balance.Version,
 			&balance.CreatedAt,
 			&metaDataJSON,
+			&balance.Hot,
+			&balance.OverdraftLimit,
+			&balance.OverdraftExpiresAt,
+			&balance.Status,
+			&balance.StatusReason,
 		)
 		if err != nil {
 			return nil, err
@@ -257,11 +307,15 @@ func (d Datasource) GetAllBalances() ([]model.Balance, error) {
 		if err != nil {
 			return nil, err
 		}
+		balance.ComputeAvailableBalance()
+		if err := d.loadHotFigures(&balance); err != nil {
+			return nil, err
+		}
 
 		balances = append(balances, balance)
 	}
 
-	return balances, nil
+	return balances, rows.Err()
 }
 
 func (d Datasource) GetSourceDestination(sourceId, destinationId string) ([]*model.Balance, error) {
@@ -297,6 +351,7 @@ func (d Datasource) GetSourceDestination(sourceId, destinationId string) ([]*mod
 			&balance.LedgerID,
 			&balance.CreatedAt,
 			&metaDataJSON,
+			&balance.Hot,
 		)
 		if err != nil {
 			return nil, err
@@ -314,7 +369,10 @@ func (d Datasource) GetSourceDestination(sourceId, destinationId string) ([]*mod
 	return balances, nil
 }
 
-func (d Datasource) UpdateBalances(ctx context.Context, sourceBalance, destinationBalance *model.Balance) error {
+// UpdateBalances saves both balances of a transaction in one database transaction, snapshotting each against
+// the transaction. The transaction's spending limits are charged, or released for a void, and the funds reserved
+// for it converted in the same database transaction.
+func (d Datasource) UpdateBalances(ctx context.Context, transaction *model.Transaction, sourceBalance, destinationBalance *model.Balance) error {
 	tx, err := d.Conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault})
 	if err != nil {
 		return err
@@ -324,13 +382,37 @@ func (d Datasource) UpdateBalances(ctx context.Context, sourceBalance, destinati
 		_ = tx.Rollback()
 	}(tx)
 
-	if err := updateBalance(ctx, tx, sourceBalance); err != nil {
+	if err := redlock.TakeAdvisoryLocks(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := updateBalance(ctx, tx, sourceBalance, transaction.TransactionID); err != nil {
+		return err
+	}
+
+	if err := updateBalance(ctx, tx, destinationBalance, transaction.TransactionID); err != nil {
 		return err
 	}
 
-	if err := updateBalance(ctx, tx, destinationBalance); err != nil {
+	if err := chargeSpendingLimits(ctx, tx, transaction.LimitCharges); err != nil {
 		return err
 	}
+	if transaction.Inflight {
+		if err := keepSpendingLimitCharges(ctx, tx, transaction.TransactionID, transaction.LimitCharges); err != nil {
+			return err
+		}
+	}
+	if transaction.ReleasedAmount > 0 {
+		if err := releaseSpendingLimits(ctx, tx, transaction.ParentTransaction, transaction.ReleasedAmount); err != nil {
+			return err
+		}
+	}
+
+	if transaction.ReservedAmount > 0 {
+		if err := convertReservations(ctx, tx, transaction.TransactionID); err != nil {
+			return err
+		}
+	}
 
 	if err := tx.Commit(); err != nil {
 		return err
@@ -339,20 +421,31 @@ func (d Datasource) UpdateBalances(ctx context.Context, sourceBalance, destinati
 	return nil
 }
 
-func updateBalance(ctx context.Context, tx *sql.Tx, balance *model.Balance) error {
+// updateBalance applies an optimistic-locked update and records the resulting figures as a snapshot in the same
+// statement, so a balance can never change without leaving history behind.
+func updateBalance(ctx context.Context, tx *sql.Tx, balance *model.Balance, transactionID string) error {
+	if balance.Hot {
+		return recordBalanceDelta(ctx, tx, balance, transactionID)
+	}
+
 	metaDataJSON, err := json.Marshal(balance.MetaData)
 	if err != nil {
 		return err
 	}
 
 	query := `
-        UPDATE blnk.balances
-        SET balance = $2, credit_balance = $3, debit_balance = $4, inflight_balance = $5, inflight_credit_balance = $6, inflight_debit_balance = $7, currency = $8, currency_multiplier = $9, ledger_id = $10, created_at = $11, meta_data = $12, version = version + 1
-        WHERE balance_id = $1 AND version = $13
+        WITH updated AS (
+            UPDATE blnk.balances
+            SET balance = $2, credit_balance = $3, debit_balance = $4, inflight_balance = $5, inflight_credit_balance = $6, inflight_debit_balance = $7, currency = $8, currency_multiplier = $9, ledger_id = $10, created_at = $11, meta_data = $12, version = version + 1
+            WHERE balance_id = $1 AND version = $13
+            RETURNING balance_id, version, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, currency
+        )
+        INSERT INTO blnk.balance_snapshots (balance_id, version, transaction_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, currency, created_at)
+        SELECT balance_id, version, $14, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, currency, NOW() FROM updated
     `
 
 	// Execute the update within the transaction
-	result, err := tx.ExecContext(ctx, query, balance.BalanceID, balance.Balance, balance.CreditBalance, balance.DebitBalance, balance.InflightBalance, balance.InflightCreditBalance, balance.InflightDebitBalance, balance.Currency, balance.CurrencyMultiplier, balance.LedgerID, balance.CreatedAt, metaDataJSON, balance.Version)
+	result, err := tx.ExecContext(ctx, query, balance.BalanceID, balance.Balance, balance.CreditBalance, balance.DebitBalance, balance.InflightBalance, balance.InflightCreditBalance, balance.InflightDebitBalance, balance.Currency, balance.CurrencyMultiplier, balance.LedgerID, balance.CreatedAt, metaDataJSON, balance.Version, transactionID)
 	if err != nil {
 		return err
 	}
@@ -387,6 +480,25 @@ func (d Datasource) UpdateBalance(balance *model.Balance) error {
 	return err
 }
 
+// UpdateOverdraftLimit sets how far below zero a balance may go, until expiresAt when it is not nil.
+func (d Datasource) UpdateOverdraftLimit(ctx context.Context, balanceID string, limit int64, expiresAt *time.Time) error {
+	result, err := d.Conn.ExecContext(ctx, `
+		UPDATE blnk.balances SET overdraft_limit = $2, overdraft_expires_at = $3
+		WHERE balance_id = $1
+	`, balanceID, limit, expiresAt)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("balance with ID '%s' %w", balanceID, model.ErrNotFound)
+	}
+	return nil
+}
+
 func (d Datasource) CreateMonitor(monitor model.BalanceMonitor) (model.BalanceMonitor, error) {
 	monitor.MonitorID = model.GenerateUUIDWithSuffix("mon")
 	monitor.CreatedAt = time.Now()
@@ -481,3 +593,98 @@ func (d Datasource) DeleteMonitor(id string) error {
 	`, id)
 	return err
 }
+
+const balanceSnapshotColumns = `balance_id, version, COALESCE(transaction_id, ''), balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, currency, created_at`
+
+func scanBalanceSnapshot(row interface {
+	Scan(dest ...interface{}) error
+}) (model.BalanceSnapshot, error) {
+	snapshot := model.BalanceSnapshot{}
+	err := row.Scan(&snapshot.BalanceID, &snapshot.Version, &snapshot.TransactionID, &snapshot.Balance, &snapshot.CreditBalance, &snapshot.DebitBalance,
+		&snapshot.InflightBalance, &snapshot.InflightCreditBalance, &snapshot.InflightDebitBalance, &snapshot.Currency, &snapshot.CreatedAt)
+	return snapshot, err
+}
+
+// GetBalanceSnapshots returns a balance's history between from and to, oldest first. Zero times leave that end open.
+func (d Datasource) GetBalanceSnapshots(balanceID string, from, to time.Time, limit int) ([]model.BalanceSnapshot, error) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	rows, err := d.Conn.Query(`
+		SELECT `+balanceSnapshotColumns+`
+		FROM blnk.balance_snapshots
+		WHERE balance_id = $1 AND created_at >= $2 AND created_at <= $3
+		ORDER BY version
+		LIMIT $4
+	`, balanceID, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []model.BalanceSnapshot{}
+	for rows.Next() {
+		snapshot, err := scanBalanceSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// GetBalanceSnapshotsByTransaction returns the snapshots written by one transaction, transaction group or journal entry.
+func (d Datasource) GetBalanceSnapshotsByTransaction(transactionID string) ([]model.BalanceSnapshot, error) {
+	rows, err := d.Conn.Query(`
+		SELECT `+balanceSnapshotColumns+`
+		FROM blnk.balance_snapshots
+		WHERE transaction_id = $1
+		ORDER BY id
+	`, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []model.BalanceSnapshot{}
+	for rows.Next() {
+		snapshot, err := scanBalanceSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// GetBalanceSnapshotAt returns the last snapshot taken at or before asOf.
+func (d Datasource) GetBalanceSnapshotAt(balanceID string, asOf time.Time) (*model.BalanceSnapshot, error) {
+	snapshot, err := getBalanceSnapshotAt(d.Conn, balanceID, asOf)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no history for balance '%s' at %s", balanceID, asOf.Format(time.RFC3339))
+		}
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func getBalanceSnapshotAt(q queryer, balanceID string, asOf time.Time) (*model.BalanceSnapshot, error) {
+	row := q.QueryRow(`
+		SELECT `+balanceSnapshotColumns+`
+		FROM blnk.balance_snapshots
+		WHERE balance_id = $1 AND created_at <= $2
+		ORDER BY version DESC
+		LIMIT 1
+	`, balanceID, asOf)
+
+	snapshot, err := scanBalanceSnapshot(row)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
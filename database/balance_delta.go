@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/northstar-pay/nucleus/model"
+	"go.opentelemetry.io/otel"
+)
+
+// loadHotFigures replaces the figures of a hot balance with its row plus every delta not yet flushed into it,
+// read in one statement so a concurrent flush is counted exactly once. The result is the baseline later
+// deltas are measured from.
+func (d Datasource) loadHotFigures(balance *model.Balance) error {
+	if !balance.Hot {
+		return nil
+	}
+
+	row := d.Conn.QueryRow(`
+		SELECT b.balance + COALESCE(SUM(d.balance), 0), b.credit_balance + COALESCE(SUM(d.credit_balance), 0),
+		       b.debit_balance + COALESCE(SUM(d.debit_balance), 0), b.inflight_balance + COALESCE(SUM(d.inflight_balance), 0),
+		       b.inflight_credit_balance + COALESCE(SUM(d.inflight_credit_balance), 0),
+		       b.inflight_debit_balance + COALESCE(SUM(d.inflight_debit_balance), 0), b.version
+		FROM blnk.balances b
+		LEFT JOIN blnk.balance_deltas d ON d.balance_id = b.balance_id AND d.flushed_at IS NULL
+		WHERE b.balance_id = $1
+		GROUP BY b.balance_id, b.balance, b.credit_balance, b.debit_balance, b.inflight_balance, b.inflight_credit_balance, b.inflight_debit_balance, b.version
+	`, balance.BalanceID)
+
+	err := row.Scan(&balance.Balance, &balance.CreditBalance, &balance.DebitBalance, &balance.InflightBalance,
+		&balance.InflightCreditBalance, &balance.InflightDebitBalance, &balance.Version)
+	if err != nil {
+		return err
+	}
+
+	balance.ComputeAvailableBalance()
+	balance.MarkBaseline()
+	return nil
+}
+
+// recordBalanceDelta saves what a transaction changed on a hot balance instead of updating its row, so
+// transactions crediting the same hot balance do not contend for it.
+func recordBalanceDelta(ctx context.Context, tx *sql.Tx, balance *model.Balance, transactionID string) error {
+	delta := balance.PendingDelta(transactionID)
+	if delta.IsZero() {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO blnk.balance_deltas (balance_id, transaction_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, delta.BalanceID, delta.TransactionID, delta.Balance, delta.CreditBalance, delta.DebitBalance, delta.InflightBalance, delta.InflightCreditBalance, delta.InflightDebitBalance)
+	if err != nil {
+		return err
+	}
+
+	balance.MarkBaseline()
+	return nil
+}
+
+// FlushBalanceDeltas folds up to limit of the oldest pending deltas into their balances. Deltas are kept as the
+// hot balance's history and only marked as flushed; each one gets its own version and a snapshot against the
+// transaction that caused it, taken at the time the delta was recorded. Deltas another flush is working on are
+// skipped. It returns the number of deltas flushed.
+func (d Datasource) FlushBalanceDeltas(ctx context.Context, limit int) (int64, error) {
+	ctx, span := otel.Tracer("Hot balances").Start(ctx, "Flushing balance deltas")
+	defer span.End()
+
+	result, err := d.Conn.ExecContext(ctx, `
+		WITH batch AS (
+			UPDATE blnk.balance_deltas SET flushed_at = NOW()
+			WHERE id IN (SELECT id FROM blnk.balance_deltas WHERE flushed_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED)
+			RETURNING id, balance_id, transaction_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at
+		), running AS (
+			SELECT balance_id, transaction_id, created_at, ROW_NUMBER() OVER w AS step,
+			       SUM(balance) OVER w AS balance, SUM(credit_balance) OVER w AS credit_balance, SUM(debit_balance) OVER w AS debit_balance,
+			       SUM(inflight_balance) OVER w AS inflight_balance, SUM(inflight_credit_balance) OVER w AS inflight_credit_balance,
+			       SUM(inflight_debit_balance) OVER w AS inflight_debit_balance
+			FROM batch
+			WINDOW w AS (PARTITION BY balance_id ORDER BY id)
+		), totals AS (
+			SELECT balance_id, COUNT(*) AS steps, SUM(balance) AS balance, SUM(credit_balance) AS credit_balance, SUM(debit_balance) AS debit_balance,
+			       SUM(inflight_balance) AS inflight_balance, SUM(inflight_credit_balance) AS inflight_credit_balance,
+			       SUM(inflight_debit_balance) AS inflight_debit_balance
+			FROM batch GROUP BY balance_id
+		), previous AS (
+			UPDATE blnk.balances b
+			SET balance = b.balance + t.balance, credit_balance = b.credit_balance + t.credit_balance,
+			    debit_balance = b.debit_balance + t.debit_balance, inflight_balance = b.inflight_balance + t.inflight_balance,
+			    inflight_credit_balance = b.inflight_credit_balance + t.inflight_credit_balance,
+			    inflight_debit_balance = b.inflight_debit_balance + t.inflight_debit_balance, version = b.version + t.steps
+			FROM totals t
+			WHERE b.balance_id = t.balance_id
+			RETURNING b.balance_id, b.version - t.steps AS version, b.balance - t.balance AS balance, b.credit_balance - t.credit_balance AS credit_balance,
+			          b.debit_balance - t.debit_balance AS debit_balance, b.inflight_balance - t.inflight_balance AS inflight_balance,
+			          b.inflight_credit_balance - t.inflight_credit_balance AS inflight_credit_balance,
+			          b.inflight_debit_balance - t.inflight_debit_balance AS inflight_debit_balance, b.currency
+		)
+		INSERT INTO blnk.balance_snapshots (balance_id, version, transaction_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, currency, created_at)
+		SELECT p.balance_id, p.version + r.step, r.transaction_id, p.balance + r.balance, p.credit_balance + r.credit_balance, p.debit_balance + r.debit_balance,
+		       p.inflight_balance + r.inflight_balance, p.inflight_credit_balance + r.inflight_credit_balance, p.inflight_debit_balance + r.inflight_debit_balance,
+		       p.currency, r.created_at
+		FROM previous p JOIN running r ON r.balance_id = p.balance_id
+	`, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	redlock "github.com/northstar-pay/nucleus/internal/lock"
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// UpdateBalanceStatus moves a balance to change.ToStatus and adds the change to the balance's audit trail, in
+// one database transaction. The update only applies while the balance's status is still change.FromStatus, and
+// a balance is only closed while it holds nothing, inflight and reserved funds included. It reports whether the
+// change was applied.
+func (d Datasource) UpdateBalanceStatus(ctx context.Context, change model.BalanceStatusChange) (bool, error) {
+	tx, err := d.Conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault})
+	if err != nil {
+		return false, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if err := redlock.TakeAdvisoryLocks(ctx, tx); err != nil {
+		return false, err
+	}
+
+	updated, err := updateBalanceStatus(ctx, tx, change)
+	if err != nil || !updated {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// SweepAndCloseBalance posts sweep, the transaction that empties a balance being closed, saving both balances it
+// moved, then changes the balance's status as UpdateBalanceStatus does, all in one database transaction. If the
+// status cannot be changed the sweep is rolled back with it.
+func (d Datasource) SweepAndCloseBalance(ctx context.Context, change model.BalanceStatusChange, sweep *model.Transaction, balances ...*model.Balance) (bool, error) {
+	tx, err := d.Conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault})
+	if err != nil {
+		return false, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if err := redlock.TakeAdvisoryLocks(ctx, tx); err != nil {
+		return false, err
+	}
+
+	for _, balance := range balances {
+		if err := updateBalance(ctx, tx, balance, sweep.TransactionID); err != nil {
+			return false, err
+		}
+	}
+	if err := insertTransaction(ctx, tx, sweep); err != nil {
+		return false, err
+	}
+
+	updated, err := updateBalanceStatus(ctx, tx, change)
+	if err != nil || !updated {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// updateBalanceStatus moves the balance's version with its status, so a posting that checked the old status
+// fails its update.
+func updateBalanceStatus(ctx context.Context, tx *sql.Tx, change model.BalanceStatusChange) (bool, error) {
+	result, err := tx.ExecContext(ctx, `
+		UPDATE blnk.balances
+		SET status = $2, status_reason = $3, version = version + 1
+		WHERE balance_id = $1 AND status = $4
+		  AND ($2 <> $5 OR (balance = 0 AND inflight_balance = 0 AND reserved_balance = 0))
+	`, change.BalanceID, change.ToStatus, change.Reason, change.FromStatus, model.BalanceClosed)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return false, err
+	}
+
+	var sweepTransactionID interface{} = change.SweepTransactionID
+	if change.SweepTransactionID == "" {
+		sweepTransactionID = nil
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO blnk.balance_status_changes (balance_id, from_status, to_status, reason, changed_by, sweep_transaction_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, change.BalanceID, change.FromStatus, change.ToStatus, change.Reason, change.ChangedBy, sweepTransactionID, change.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetBalanceStatusChanges lists the audit trail of a balance's status, newest first, up to limit rows. cursor
+// is the position to continue after.
+func (d Datasource) GetBalanceStatusChanges(balanceID string, cursor int64, limit int) ([]model.BalanceStatusChange, error) {
+	q := &queryFilter{}
+	q.addCursor(cursor)
+	q.addEqual("balance_id", balanceID)
+
+	rows, err := d.Conn.Query(`
+		SELECT id, balance_id, from_status, to_status, reason, changed_by, COALESCE(sweep_transaction_id, ''), created_at
+		FROM blnk.balance_status_changes`+q.page(limit), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []model.BalanceStatusChange{}
+	for rows.Next() {
+		change := model.BalanceStatusChange{}
+		err := rows.Scan(&change.ID, &change.BalanceID, &change.FromStatus, &change.ToStatus, &change.Reason, &change.ChangedBy,
+			&change.SweepTransactionID, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
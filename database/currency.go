@@ -0,0 +1,92 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+func (d Datasource) CreateCurrency(currency model.Currency) (model.Currency, error) {
+	metaDataJSON, err := json.Marshal(currency.MetaData)
+	if err != nil {
+		return model.Currency{}, err
+	}
+
+	currency.CreatedAt = time.Now()
+
+	_, err = d.Conn.Exec(`
+		INSERT INTO blnk.currencies (code, name, symbol, minor_units, created_at, meta_data)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, currency.Code, currency.Name, currency.Symbol, currency.MinorUnits, currency.CreatedAt, metaDataJSON)
+	if err != nil {
+		return model.Currency{}, err
+	}
+
+	return currency, nil
+}
+
+// GetCurrencyByCode retrieves a registered currency by its code
+func (d Datasource) GetCurrencyByCode(code string) (*model.Currency, error) {
+	currency := model.Currency{}
+
+	row := d.Conn.QueryRow(`
+		SELECT code, COALESCE(name, ''), COALESCE(symbol, ''), minor_units, created_at, meta_data
+		FROM blnk.currencies
+		WHERE code = $1
+	`, code)
+
+	var metaDataJSON []byte
+	err := row.Scan(&currency.Code, &currency.Name, &currency.Symbol, &currency.MinorUnits, &currency.CreatedAt, &metaDataJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("currency '%s' is not registered: %w", code, model.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	if metaDataJSON != nil {
+		err = json.Unmarshal(metaDataJSON, &currency.MetaData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &currency, nil
+}
+
+// GetAllCurrencies retrieves every registered currency
+func (d Datasource) GetAllCurrencies() ([]model.Currency, error) {
+	rows, err := d.Conn.Query(`
+		SELECT code, COALESCE(name, ''), COALESCE(symbol, ''), minor_units, created_at, meta_data
+		FROM blnk.currencies
+		ORDER BY code
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	currencies := []model.Currency{}
+	for rows.Next() {
+		currency := model.Currency{}
+		var metaDataJSON []byte
+		err = rows.Scan(&currency.Code, &currency.Name, &currency.Symbol, &currency.MinorUnits, &currency.CreatedAt, &metaDataJSON)
+		if err != nil {
+			return nil, err
+		}
+
+		if metaDataJSON != nil {
+			err = json.Unmarshal(metaDataJSON, &currency.MetaData)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		currencies = append(currencies, currency)
+	}
+
+	return currencies, nil
+}
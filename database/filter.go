@@ -0,0 +1,118 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// queryFilter collects WHERE clauses and numbers their placeholders as they are added.
+type queryFilter struct {
+	clauses []string
+	args    []interface{}
+}
+
+// add appends a clause whose placeholders are written as $%[1]d and bound to arg.
+func (q *queryFilter) add(clause string, arg interface{}) {
+	q.args = append(q.args, arg)
+	q.clauses = append(q.clauses, fmt.Sprintf(clause, len(q.args)))
+}
+
+func (q *queryFilter) addEqual(column, value string) {
+	if value != "" {
+		q.add(column+" = $%[1]d", value)
+	}
+}
+
+func (q *queryFilter) addTimeRange(column string, from, to time.Time) {
+	if !from.IsZero() {
+		q.add(column+" >= $%[1]d", from)
+	}
+	if !to.IsZero() {
+		q.add(column+" < $%[1]d", to)
+	}
+}
+
+func (q *queryFilter) addRange(column, value string) error {
+	r, err := model.ParseRange(value)
+	if err != nil {
+		return err
+	}
+	if r.Min != nil {
+		q.add(column+" >= $%[1]d", *r.Min)
+	}
+	if r.Max != nil {
+		q.add(column+" <= $%[1]d", *r.Max)
+	}
+	return nil
+}
+
+// addMetaData keeps rows whose meta_data holds every key with its value. Values come from the query string, so
+// one that reads as a JSON number or boolean, such as 42 or true, matches that number or boolean as well as the
+// string. Each comparison is a containment the GIN index on meta_data can serve.
+func (q *queryFilter) addMetaData(metaData map[string]string) error {
+	keys := make([]string, 0, len(metaData))
+	for key := range metaData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := metaData[key]
+		asString, err := json.Marshal(map[string]string{key: value})
+		if err != nil {
+			return err
+		}
+		if !isJSONScalar(value) {
+			q.add("meta_data @> $%[1]d::jsonb", string(asString))
+			continue
+		}
+		asScalar, err := json.Marshal(map[string]json.RawMessage{key: json.RawMessage(value)})
+		if err != nil {
+			return err
+		}
+		q.args = append(q.args, string(asString), string(asScalar))
+		q.clauses = append(q.clauses, fmt.Sprintf("(meta_data @> $%d::jsonb OR meta_data @> $%d::jsonb)", len(q.args)-1, len(q.args)))
+	}
+	return nil
+}
+
+// isJSONScalar reports whether value is a JSON number or boolean.
+func isJSONScalar(value string) bool {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(value)))
+	decoder.UseNumber()
+	var scalar interface{}
+	if err := decoder.Decode(&scalar); err != nil || decoder.More() {
+		return false
+	}
+	switch scalar.(type) {
+	case json.Number, bool:
+		return true
+	}
+	return false
+}
+
+// addCursor continues a newest-first listing after the row with the given id.
+func (q *queryFilter) addCursor(id int64) {
+	if id > 0 {
+		q.add("id < $%[1]d", id)
+	}
+}
+
+func (q *queryFilter) where() string {
+	if len(q.clauses) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.clauses, " AND ")
+}
+
+// page renders the WHERE, ORDER and LIMIT tail of a newest-first listing.
+func (q *queryFilter) page(limit int) string {
+	q.args = append(q.args, limit)
+	return fmt.Sprintf("%s ORDER BY id DESC LIMIT $%d", q.where(), len(q.args))
+}
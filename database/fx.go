@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// CreateFXRateSet stores a rate set and its rates together. The version is assigned by the database.
+func (d Datasource) CreateFXRateSet(rateSet model.FXRateSet) (model.FXRateSet, error) {
+	metaDataJSON, err := json.Marshal(rateSet.MetaData)
+	if err != nil {
+		return model.FXRateSet{}, err
+	}
+
+	ctx := context.Background()
+	tx, err := d.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return model.FXRateSet{}, err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO blnk.fx_rate_sets (rate_set_id, source, effective_at, created_at, meta_data)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING version
+	`, rateSet.RateSetID, rateSet.Source, rateSet.EffectiveAt, rateSet.CreatedAt, metaDataJSON).Scan(&rateSet.Version)
+	if err != nil {
+		return model.FXRateSet{}, err
+	}
+
+	for _, rate := range rateSet.Rates {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO blnk.fx_rates (rate_set_id, base_currency, quote_currency, rate)
+			VALUES ($1, $2, $3, $4)
+		`, rateSet.RateSetID, rate.BaseCurrency, rate.QuoteCurrency, rate.Rate)
+		if err != nil {
+			return model.FXRateSet{}, err
+		}
+	}
+
+	return rateSet, tx.Commit()
+}
+
+// GetFXRateSet retrieves a rate set and its rates by ID
+func (d Datasource) GetFXRateSet(id string) (*model.FXRateSet, error) {
+	rateSet := model.FXRateSet{}
+
+	var metaDataJSON []byte
+	err := d.Conn.QueryRow(`
+		SELECT rate_set_id, version, source, effective_at, created_at, meta_data
+		FROM blnk.fx_rate_sets
+		WHERE rate_set_id = $1
+	`, id).Scan(&rateSet.RateSetID, &rateSet.Version, &rateSet.Source, &rateSet.EffectiveAt, &rateSet.CreatedAt, &metaDataJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("rate set with ID '%s' not found", id)
+		}
+		return nil, err
+	}
+
+	if metaDataJSON != nil {
+		err = json.Unmarshal(metaDataJSON, &rateSet.MetaData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rows, err := d.Conn.Query(`
+		SELECT base_currency, quote_currency, rate
+		FROM blnk.fx_rates
+		WHERE rate_set_id = $1
+		ORDER BY base_currency, quote_currency
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rateSet.Rates = []model.FXRate{}
+	for rows.Next() {
+		rate := model.FXRate{}
+		if err := rows.Scan(&rate.BaseCurrency, &rate.QuoteCurrency, &rate.Rate); err != nil {
+			return nil, err
+		}
+		rateSet.Rates = append(rateSet.Rates, rate)
+	}
+
+	return &rateSet, rows.Err()
+}
+
+// GetLatestFXRate returns the pair's rate from the newest rate set that is already in effect.
+func (d Datasource) GetLatestFXRate(baseCurrency, quoteCurrency string) (*model.FXRate, error) {
+	rate := model.FXRate{}
+
+	err := d.Conn.QueryRow(`
+		SELECT s.rate_set_id, s.version, r.base_currency, r.quote_currency, r.rate
+		FROM blnk.fx_rates r
+		JOIN blnk.fx_rate_sets s ON s.rate_set_id = r.rate_set_id
+		WHERE r.base_currency = $1 AND r.quote_currency = $2 AND s.effective_at <= NOW()
+		ORDER BY s.version DESC
+		LIMIT 1
+	`, baseCurrency, quoteCurrency).Scan(&rate.RateSetID, &rate.Version, &rate.BaseCurrency, &rate.QuoteCurrency, &rate.Rate)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("no rate available for %s/%s", baseCurrency, quoteCurrency)
+		}
+		return nil, err
+	}
+
+	return &rate, nil
+}
+
+func (d Datasource) CreateFXQuote(quote model.FXQuote) (model.FXQuote, error) {
+	_, err := d.Conn.Exec(`
+		INSERT INTO blnk.fx_quotes (quote_id, rate_set_id, rate_set_version, base_currency, quote_currency, rate, spread_bps, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, quote.QuoteID, quote.RateSetID, quote.RateSetVersion, quote.BaseCurrency, quote.QuoteCurrency, quote.Rate, quote.SpreadBps, quote.ExpiresAt, quote.CreatedAt)
+	if err != nil {
+		return model.FXQuote{}, err
+	}
+
+	return quote, nil
+}
+
+// GetFXQuote retrieves a quote by ID
+func (d Datasource) GetFXQuote(id string) (*model.FXQuote, error) {
+	quote := model.FXQuote{}
+
+	err := d.Conn.QueryRow(`
+		SELECT quote_id, rate_set_id, rate_set_version, base_currency, quote_currency, rate, spread_bps, expires_at, created_at, COALESCE(used_by, '')
+		FROM blnk.fx_quotes
+		WHERE quote_id = $1
+	`, id).Scan(&quote.QuoteID, &quote.RateSetID, &quote.RateSetVersion, &quote.BaseCurrency, &quote.QuoteCurrency, &quote.Rate, &quote.SpreadBps, &quote.ExpiresAt, &quote.CreatedAt, &quote.UsedBy)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("quote with ID '%s' %w", id, model.ErrNotFound)
+		}
+		return nil, err
+	}
+
+	return &quote, nil
+}
+
+// useFXQuote marks a quote as used by transactionID. A quote already used by another transaction is not
+// updated, so of two postings racing for one quote only the first to commit converts with it.
+func useFXQuote(ctx context.Context, conn execer, quoteID, transactionID string) error {
+	result, err := conn.ExecContext(ctx, `
+		UPDATE blnk.fx_quotes SET used_by = $2, used_at = NOW() WHERE quote_id = $1 AND (used_by IS NULL OR used_by = $2)
+	`, quoteID, transactionID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("quote %s: %w", quoteID, model.ErrQuoteUsed)
+	}
+	return nil
+}
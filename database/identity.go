@@ -37,7 +37,7 @@ func (d Datasource) GetIdentityByID(id string) (*model.Identity, error) {
 	}
 
 	row := tx.QueryRow(`
-	SELECT identity_id, identity_type, first_name, last_name, other_names, gender, dob, email_address, phone_number, nationality, organization_name, category, street, country, state, post_code, city, created_at, meta_data
+	SELECT identity_id, identity_type, first_name, last_name, other_names, gender, dob, email_address, phone_number, nationality, organization_name, category, street, country, state, post_code, city, COALESCE(tier_id, ''), created_at, meta_data
 	FROM blnk.identity
 	WHERE identity_id = $1
 `, id)
@@ -48,7 +48,7 @@ func (d Datasource) GetIdentityByID(id string) (*model.Identity, error) {
 		&identity.IdentityID, &identity.IdentityType,
 		&identity.FirstName, &identity.LastName, &identity.OtherNames, &identity.Gender, &identity.DOB, &identity.EmailAddress, &identity.PhoneNumber, &identity.Nationality,
 		&identity.OrganizationName, &identity.Category,
-		&identity.Street, &identity.Country, &identity.State, &identity.PostCode, &identity.City, &identity.CreatedAt, &metaDataJSON,
+		&identity.Street, &identity.Country, &identity.State, &identity.PostCode, &identity.City, &identity.TierID, &identity.CreatedAt, &metaDataJSON,
 	)
 	if err != nil {
 		_ = tx.Rollback()
@@ -72,7 +72,7 @@ func (d Datasource) GetIdentityByID(id string) (*model.Identity, error) {
 // GetAllIdentities retrieves all identities from the database
 func (d Datasource) GetAllIdentities() ([]model.Identity, error) {
 	rows, err := d.Conn.Query(`
-	SELECT identity_id, identity_type, first_name, last_name, other_names, gender, dob, email_address, phone_number, nationality, organization_name, category, street, country, state, post_code, city, created_at, meta_data
+	SELECT identity_id, identity_type, first_name, last_name, other_names, gender, dob, email_address, phone_number, nationality, organization_name, category, street, country, state, post_code, city, COALESCE(tier_id, ''), created_at, meta_data
 	FROM blnk.identity
 	ORDER BY created_at DESC
 `)
@@ -89,7 +89,7 @@ func (d Datasource) GetAllIdentities() ([]model.Identity, error) {
 			&identity.IdentityID, &identity.IdentityType,
 			&identity.FirstName, &identity.LastName, &identity.OtherNames, &identity.Gender, &identity.DOB, &identity.EmailAddress, &identity.PhoneNumber, &identity.Nationality,
 			&identity.OrganizationName, &identity.Category,
-			&identity.Street, &identity.Country, &identity.State, &identity.PostCode, &identity.City, &identity.CreatedAt, &metaDataJSON,
+			&identity.Street, &identity.Country, &identity.State, &identity.PostCode, &identity.City, &identity.TierID, &identity.CreatedAt, &metaDataJSON,
 		)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+
+	redlock "github.com/northstar-pay/nucleus/internal/lock"
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// RecordJournalEntry updates the balances touched by a journal entry, charges its spending limits and stores
+// the entry with its lines in a single database transaction.
+func (d Datasource) RecordJournalEntry(cxt context.Context, entry *model.JournalEntry, balances []*model.Balance) error {
+	cxt, span := otel.Tracer("Journal entry").Start(cxt, "Saving journal entry to db")
+	defer span.End()
+
+	metaDataJSON, err := json.Marshal(entry.MetaData)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.Conn.BeginTx(cxt, &sql.TxOptions{Isolation: sql.LevelDefault})
+	if err != nil {
+		return err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if err := redlock.TakeAdvisoryLocks(cxt, tx); err != nil {
+		return err
+	}
+
+	for _, balance := range balances {
+		if err := updateBalance(cxt, tx, balance, entry.EntryID); err != nil {
+			return err
+		}
+	}
+
+	if err := chargeSpendingLimits(cxt, tx, entry.LimitCharges); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(cxt, `
+		INSERT INTO blnk.journal_entries (entry_id, reference, description, status, created_at, meta_data)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, entry.EntryID, entry.Reference, entry.Description, entry.Status, entry.CreatedAt, metaDataJSON)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range entry.Lines {
+		_, err = tx.ExecContext(cxt, `
+			INSERT INTO blnk.journal_lines (line_id, entry_id, balance_id, direction, amount, precise_amount, precision, currency, description)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, line.LineID, line.EntryID, line.BalanceID, line.Direction, line.Amount, line.PreciseAmount, line.Precision, line.Currency, line.Description)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (d Datasource) GetJournalEntry(id string) (*model.JournalEntry, error) {
+	entry := &model.JournalEntry{}
+	var metaDataJSON []byte
+
+	row := d.Conn.QueryRow(`
+		SELECT entry_id, reference, description, status, created_at, meta_data
+		FROM blnk.journal_entries
+		WHERE entry_id = $1
+	`, id)
+	err := row.Scan(&entry.EntryID, &entry.Reference, &entry.Description, &entry.Status, &entry.CreatedAt, &metaDataJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("journal entry with ID '%s' not found", id)
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(metaDataJSON, &entry.MetaData)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := d.Conn.Query(`
+		SELECT line_id, entry_id, balance_id, direction, amount, precise_amount, precision, currency, description
+		FROM blnk.journal_lines
+		WHERE entry_id = $1
+		ORDER BY id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		line := model.JournalLine{}
+		err = rows.Scan(&line.LineID, &line.EntryID, &line.BalanceID, &line.Direction, &line.Amount, &line.PreciseAmount, &line.Precision, &line.Currency, &line.Description)
+		if err != nil {
+			return nil, err
+		}
+		entry.Lines = append(entry.Lines, line)
+	}
+
+	return entry, rows.Err()
+}
@@ -33,14 +33,15 @@ func (d Datasource) CreateLedger(ledger model.Ledger) (model.Ledger, error) {
 	return ledger, nil
 }
 
-// GetAllLedgers retrieves all ledgers from the database
-func (d Datasource) GetAllLedgers() ([]model.Ledger, error) {
-	// select all ledgers from database
+// GetAllLedgers lists ledgers matching the filter, newest first, up to limit rows.
+func (d Datasource) GetAllLedgers(filter model.LedgerFilter, limit int) ([]model.Ledger, error) {
+	q := &queryFilter{}
+	q.addCursor(filter.ID)
+	q.addTimeRange("created_at", filter.From, filter.To)
+
 	rows, err := d.Conn.Query(`
-		SELECT ledger_id,name, created_at, meta_data
-		FROM blnk.ledgers
-		OFFSET 0 LIMIT 20
-		`)
+		SELECT id, ledger_id, name, created_at, meta_data
+		FROM blnk.ledgers`+q.page(limit), q.args...)
 	if err != nil {
 		return nil, err
 	}
@@ -53,7 +54,7 @@ func (d Datasource) GetAllLedgers() ([]model.Ledger, error) {
 	for rows.Next() {
 		ledger := model.Ledger{}
 		var metaDataJSON []byte
-		err = rows.Scan(&ledger.LedgerID, &ledger.Name, &ledger.CreatedAt, &metaDataJSON)
+		err = rows.Scan(&ledger.ID, &ledger.LedgerID, &ledger.Name, &ledger.CreatedAt, &metaDataJSON)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+const spendingLimitColumns = `id, limit_id, scope, scope_id, period, currency, max_amount, max_count, created_at, meta_data`
+
+func scanSpendingLimit(row interface {
+	Scan(dest ...interface{}) error
+}) (model.SpendingLimit, error) {
+	limit := model.SpendingLimit{}
+	var metaDataJSON []byte
+	err := row.Scan(&limit.ID, &limit.LimitID, &limit.Scope, &limit.ScopeID, &limit.Window, &limit.Currency, &limit.MaxAmount, &limit.MaxCount,
+		&limit.CreatedAt, &metaDataJSON)
+	if err != nil {
+		return limit, err
+	}
+	if metaDataJSON != nil {
+		if err := json.Unmarshal(metaDataJSON, &limit.MetaData); err != nil {
+			return limit, err
+		}
+	}
+	return limit, nil
+}
+
+func (d Datasource) CreateSpendingLimit(limit model.SpendingLimit) (model.SpendingLimit, error) {
+	metaDataJSON, err := json.Marshal(limit.MetaData)
+	if err != nil {
+		return model.SpendingLimit{}, err
+	}
+
+	err = d.Conn.QueryRow(`
+		INSERT INTO blnk.spending_limits (limit_id, scope, scope_id, period, currency, max_amount, max_count, created_at, meta_data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, limit.LimitID, limit.Scope, limit.ScopeID, limit.Window, limit.Currency, limit.MaxAmount, limit.MaxCount, limit.CreatedAt, metaDataJSON).Scan(&limit.ID)
+	if err != nil {
+		return model.SpendingLimit{}, err
+	}
+	return limit, nil
+}
+
+func (d Datasource) GetSpendingLimit(id string) (*model.SpendingLimit, error) {
+	limit, err := scanSpendingLimit(d.Conn.QueryRow(`
+		SELECT `+spendingLimitColumns+`
+		FROM blnk.spending_limits
+		WHERE limit_id = $1
+	`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("spending limit with ID '%s' %w", id, model.ErrNotFound)
+		}
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// GetAllSpendingLimits lists spending limits matching the filter, newest first, up to limit rows.
+func (d Datasource) GetAllSpendingLimits(filter model.SpendingLimitFilter, limit int) ([]model.SpendingLimit, error) {
+	q := &queryFilter{}
+	q.addCursor(filter.ID)
+	q.addEqual("scope", filter.Scope)
+	q.addEqual("scope_id", filter.ScopeID)
+
+	return d.querySpendingLimits(context.Background(), `
+		SELECT `+spendingLimitColumns+`
+		FROM blnk.spending_limits`+q.page(limit), q.args...)
+}
+
+// GetBalanceSpendingLimits returns every limit that applies to a balance: its own, its identity's and its
+// ledger's.
+func (d Datasource) GetBalanceSpendingLimits(ctx context.Context, balance *model.Balance) ([]model.SpendingLimit, error) {
+	return d.querySpendingLimits(ctx, `
+		SELECT `+spendingLimitColumns+`
+		FROM blnk.spending_limits
+		WHERE (scope = $1 AND scope_id = $2) OR (scope = $3 AND scope_id = $4) OR (scope = $5 AND scope_id = $6)
+		ORDER BY id
+	`, model.LimitScopeBalance, balance.BalanceID, model.LimitScopeIdentity, balance.IdentityID, model.LimitScopeLedger, balance.LedgerID)
+}
+
+func (d Datasource) querySpendingLimits(ctx context.Context, query string, args ...interface{}) ([]model.SpendingLimit, error) {
+	rows, err := d.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	limits := []model.SpendingLimit{}
+	for rows.Next() {
+		limit, err := scanSpendingLimit(rows)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, limit)
+	}
+	return limits, rows.Err()
+}
+
+func (d Datasource) DeleteSpendingLimit(id string) error {
+	result, err := d.Conn.Exec(`DELETE FROM blnk.spending_limits WHERE limit_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("spending limit with ID '%s' %w", id, model.ErrNotFound)
+	}
+	return nil
+}
+
+// GetSpendingLimitUsage returns what the balances in a limit's scope have sent in the window starting at
+// windowStart. Postings are counted when they are applied or, for inflight ones, when they are created, so
+// commits are not counted again; voiding an inflight one gives back what was not committed.
+func (d Datasource) GetSpendingLimitUsage(ctx context.Context, limit model.SpendingLimit, windowStart time.Time) (model.LimitUsage, error) {
+	usage := model.LimitUsage{}
+	err := d.Conn.QueryRowContext(ctx, `
+		SELECT amount, count
+		FROM blnk.spending_limit_usage
+		WHERE limit_id = $1 AND scope_id = $2 AND window_start = $3
+	`, limit.LimitID, limit.ScopeID, windowStart).Scan(&usage.Amount, &usage.Count)
+	if err == sql.ErrNoRows {
+		return usage, nil
+	}
+	return usage, err
+}
+
+// chargeSpendingLimits adds each charge to its limit's usage. The increment only happens while the limit still
+// allows it, so concurrent postings that would together go past a limit cannot both be charged; the one that
+// cannot gets a *model.LimitError and its database transaction is rolled back.
+func chargeSpendingLimits(ctx context.Context, tx *sql.Tx, charges []model.LimitCharge) error {
+	for _, charge := range charges {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO blnk.spending_limit_usage AS u (limit_id, scope_id, window_start, amount, count)
+			SELECT $1, $2, $3, $4::BIGINT, 1
+			WHERE ($5::BIGINT = 0 OR $4::BIGINT <= $5::BIGINT) AND ($6::BIGINT = 0 OR $6::BIGINT >= 1)
+			ON CONFLICT (limit_id, scope_id, window_start) DO UPDATE
+			SET amount = u.amount + EXCLUDED.amount, count = u.count + 1
+			WHERE ($5::BIGINT = 0 OR u.amount + EXCLUDED.amount <= $5::BIGINT) AND ($6::BIGINT = 0 OR u.count + 1 <= $6::BIGINT)
+		`, charge.Limit.LimitID, charge.Limit.ScopeID, charge.WindowStart, charge.Amount, charge.Limit.MaxAmount, charge.Limit.MaxCount)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected > 0 {
+			continue
+		}
+
+		usage := model.LimitUsage{}
+		err = tx.QueryRowContext(ctx, `
+			SELECT amount, count FROM blnk.spending_limit_usage WHERE limit_id = $1 AND scope_id = $2 AND window_start = $3
+		`, charge.Limit.LimitID, charge.Limit.ScopeID, charge.WindowStart).Scan(&usage.Amount, &usage.Count)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err := charge.Limit.Check(usage, charge.Amount); err != nil {
+			return err
+		}
+		return fmt.Errorf("spending limit %s could not be charged", charge.Limit.LimitID)
+	}
+	return nil
+}
+
+// keepSpendingLimitCharges records what an inflight transaction was charged, so voiding it can release the
+// usage from the windows it was charged to.
+func keepSpendingLimitCharges(ctx context.Context, tx *sql.Tx, transactionID string, charges []model.LimitCharge) error {
+	for _, charge := range charges {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO blnk.spending_limit_charges (transaction_id, limit_id, scope_id, window_start, amount)
+			VALUES ($1, $2, $3, $4, $5)
+		`, transactionID, charge.Limit.LimitID, charge.Limit.ScopeID, charge.WindowStart, charge.Amount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releaseSpendingLimits gives back amount of what an inflight transaction was charged. The posting is only
+// uncounted when the whole charge is given back; a void after partial commits leaves what was committed in the
+// usage. A transaction is voided once, so its charges are dropped.
+func releaseSpendingLimits(ctx context.Context, tx *sql.Tx, transactionID string, amount int64) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE blnk.spending_limit_usage AS u
+		SET amount = GREATEST(u.amount - LEAST(c.amount, $2::BIGINT), 0),
+			count = CASE WHEN $2::BIGINT >= c.amount THEN GREATEST(u.count - 1, 0) ELSE u.count END
+		FROM blnk.spending_limit_charges c
+		WHERE c.transaction_id = $1 AND u.limit_id = c.limit_id AND u.scope_id = c.scope_id AND u.window_start = c.window_start
+	`, transactionID, amount)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM blnk.spending_limit_charges WHERE transaction_id = $1`, transactionID)
+	return err
+}
@@ -0,0 +1,235 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+const recurringTransactionColumns = `id, recurring_id, cron, interval_seconds, start_at, end_at, max_occurrences, occurrences, next_run_at, last_run_at, status, template, created_at, updated_at, meta_data`
+
+const recurringOccurrenceColumns = `id, recurring_id, sequence, scheduled_for, COALESCE(transaction_id, ''), reference, status, COALESCE(error, ''), attempts, created_at, updated_at`
+
+func scanRecurringTransaction(row interface {
+	Scan(dest ...interface{}) error
+}) (model.RecurringTransaction, error) {
+	recurring := model.RecurringTransaction{}
+	var templateJSON, metaDataJSON []byte
+	err := row.Scan(&recurring.ID, &recurring.RecurringID, &recurring.Cron, &recurring.IntervalSeconds, &recurring.StartAt, &recurring.EndAt,
+		&recurring.MaxOccurrences, &recurring.Occurrences, &recurring.NextRunAt, &recurring.LastRunAt, &recurring.Status, &templateJSON,
+		&recurring.CreatedAt, &recurring.UpdatedAt, &metaDataJSON)
+	if err != nil {
+		return recurring, err
+	}
+	if err := json.Unmarshal(templateJSON, &recurring.Template); err != nil {
+		return recurring, err
+	}
+	if metaDataJSON != nil {
+		if err := json.Unmarshal(metaDataJSON, &recurring.MetaData); err != nil {
+			return recurring, err
+		}
+	}
+	return recurring, nil
+}
+
+func (d Datasource) CreateRecurringTransaction(recurring model.RecurringTransaction) (model.RecurringTransaction, error) {
+	templateJSON, err := json.Marshal(recurring.Template)
+	if err != nil {
+		return model.RecurringTransaction{}, err
+	}
+	metaDataJSON, err := json.Marshal(recurring.MetaData)
+	if err != nil {
+		return model.RecurringTransaction{}, err
+	}
+
+	err = d.Conn.QueryRow(`
+		INSERT INTO blnk.recurring_transactions (recurring_id, cron, interval_seconds, start_at, end_at, max_occurrences, occurrences, next_run_at, last_run_at, status, template, created_at, updated_at, meta_data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id
+	`, recurring.RecurringID, recurring.Cron, recurring.IntervalSeconds, recurring.StartAt, recurring.EndAt, recurring.MaxOccurrences, recurring.Occurrences,
+		recurring.NextRunAt, recurring.LastRunAt, recurring.Status, templateJSON, recurring.CreatedAt, recurring.UpdatedAt, metaDataJSON).Scan(&recurring.ID)
+	if err != nil {
+		return model.RecurringTransaction{}, err
+	}
+	return recurring, nil
+}
+
+func (d Datasource) GetRecurringTransaction(id string) (*model.RecurringTransaction, error) {
+	recurring, err := scanRecurringTransaction(d.Conn.QueryRow(`
+		SELECT `+recurringTransactionColumns+`
+		FROM blnk.recurring_transactions
+		WHERE recurring_id = $1
+	`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("recurring transaction with ID '%s' %w", id, model.ErrNotFound)
+		}
+		return nil, err
+	}
+	return &recurring, nil
+}
+
+// GetAllRecurringTransactions lists recurring transactions matching the filter, newest first, up to limit rows.
+func (d Datasource) GetAllRecurringTransactions(filter model.RecurringTransactionFilter, limit int) ([]model.RecurringTransaction, error) {
+	q := &queryFilter{}
+	q.addCursor(filter.ID)
+	q.addEqual("status", filter.Status)
+	q.addTimeRange("created_at", filter.From, filter.To)
+
+	return d.queryRecurringTransactions(context.Background(), `
+		SELECT `+recurringTransactionColumns+`
+		FROM blnk.recurring_transactions`+q.page(limit), q.args...)
+}
+
+// GetDueRecurringTransactions returns up to limit active recurring transactions whose next occurrence is due at
+// the given time, the longest overdue first.
+func (d Datasource) GetDueRecurringTransactions(ctx context.Context, at time.Time, limit int) ([]model.RecurringTransaction, error) {
+	return d.queryRecurringTransactions(ctx, `
+		SELECT `+recurringTransactionColumns+`
+		FROM blnk.recurring_transactions
+		WHERE status = $1 AND next_run_at <= $2
+		ORDER BY next_run_at
+		LIMIT $3
+	`, model.RecurringActive, at, limit)
+}
+
+func (d Datasource) queryRecurringTransactions(ctx context.Context, query string, args ...interface{}) ([]model.RecurringTransaction, error) {
+	rows, err := d.Conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recurringTransactions := []model.RecurringTransaction{}
+	for rows.Next() {
+		recurring, err := scanRecurringTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		recurringTransactions = append(recurringTransactions, recurring)
+	}
+	return recurringTransactions, rows.Err()
+}
+
+// ClaimRecurringOccurrence saves the schedule position of an active recurring transaction after one of its
+// occurrences is claimed and records the occurrence as pending, in one database transaction, so an occurrence
+// whose transaction is never queued is left behind to be retried. The claim only applies while the stored count
+// of occurrences is still previous, so when several workers find the same occurrence due, exactly one of them
+// gets true back.
+func (d Datasource) ClaimRecurringOccurrence(ctx context.Context, recurring *model.RecurringTransaction, previous int64, occurrence model.RecurringOccurrence) (bool, error) {
+	tx, err := d.Conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault})
+	if err != nil {
+		return false, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE blnk.recurring_transactions
+		SET occurrences = $2, next_run_at = $3, last_run_at = $4, status = $5, updated_at = NOW()
+		WHERE recurring_id = $1 AND occurrences = $6 AND status = $7
+	`, recurring.RecurringID, recurring.Occurrences, recurring.NextRunAt, recurring.LastRunAt, recurring.Status, previous, model.RecurringActive)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected != 1 {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO blnk.recurring_occurrences (recurring_id, sequence, scheduled_for, reference, status, attempts, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 1, $6, $6)
+		ON CONFLICT (recurring_id, sequence) DO NOTHING
+	`, occurrence.RecurringID, occurrence.Sequence, occurrence.ScheduledFor, occurrence.Reference, occurrence.Status, occurrence.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// UpdateRecurringTransactionStatus saves the status and next run of a recurring transaction, provided its
+// status is still from. It reports whether the change was applied.
+func (d Datasource) UpdateRecurringTransactionStatus(ctx context.Context, recurring *model.RecurringTransaction, from string) (bool, error) {
+	result, err := d.Conn.ExecContext(ctx, `
+		UPDATE blnk.recurring_transactions
+		SET status = $2, next_run_at = $3, updated_at = NOW()
+		WHERE recurring_id = $1 AND status = $4
+	`, recurring.RecurringID, recurring.Status, recurring.NextRunAt, from)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
+
+// UpdateRecurringOccurrence saves the outcome of queuing an occurrence's transaction.
+func (d Datasource) UpdateRecurringOccurrence(ctx context.Context, occurrence model.RecurringOccurrence) error {
+	_, err := d.Conn.ExecContext(ctx, `
+		UPDATE blnk.recurring_occurrences
+		SET status = $3, transaction_id = NULLIF($4, ''), error = NULLIF($5, ''), updated_at = NOW()
+		WHERE recurring_id = $1 AND sequence = $2
+	`, occurrence.RecurringID, occurrence.Sequence, occurrence.Status, occurrence.TransactionID, occurrence.Error)
+	return err
+}
+
+// ClaimRetryableRecurringOccurrences claims up to limit occurrences to queue again: failed ones and pending
+// ones left behind by a worker that stopped, both untouched since staleBefore and tried fewer than maxAttempts
+// times, of recurring transactions that were not cancelled. A claim counts as an attempt and holds the
+// occurrence off from other workers until it is stale again.
+func (d Datasource) ClaimRetryableRecurringOccurrences(ctx context.Context, staleBefore time.Time, maxAttempts, limit int) ([]model.RecurringOccurrence, error) {
+	rows, err := d.Conn.QueryContext(ctx, `
+		UPDATE blnk.recurring_occurrences o
+		SET attempts = o.attempts + 1, updated_at = NOW()
+		WHERE o.id IN (
+			SELECT c.id
+			FROM blnk.recurring_occurrences c
+			JOIN blnk.recurring_transactions r ON r.recurring_id = c.recurring_id
+			WHERE c.status IN ($1, $2) AND c.updated_at <= $3 AND c.attempts < $4 AND r.status <> $5
+			ORDER BY c.id
+			LIMIT $6
+			FOR UPDATE OF c SKIP LOCKED
+		)
+		RETURNING `+recurringOccurrenceColumns, model.OccurrencePending, model.OccurrenceFailed, staleBefore, maxAttempts, model.RecurringCancelled, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecurringOccurrences(rows)
+}
+
+// GetRecurringOccurrences lists the occurrences of a recurring transaction, newest first, up to limit rows.
+// cursor is the position to continue after.
+func (d Datasource) GetRecurringOccurrences(recurringID string, cursor int64, limit int) ([]model.RecurringOccurrence, error) {
+	q := &queryFilter{}
+	q.addCursor(cursor)
+	q.addEqual("recurring_id", recurringID)
+
+	rows, err := d.Conn.Query(`
+		SELECT `+recurringOccurrenceColumns+`
+		FROM blnk.recurring_occurrences`+q.page(limit), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanRecurringOccurrences(rows)
+}
+
+func scanRecurringOccurrences(rows *sql.Rows) ([]model.RecurringOccurrence, error) {
+	defer rows.Close()
+
+	occurrences := []model.RecurringOccurrence{}
+	for rows.Next() {
+		occurrence := model.RecurringOccurrence{}
+		err := rows.Scan(&occurrence.ID, &occurrence.RecurringID, &occurrence.Sequence, &occurrence.ScheduledFor, &occurrence.TransactionID,
+			&occurrence.Reference, &occurrence.Status, &occurrence.Error, &occurrence.Attempts, &occurrence.CreatedAt, &occurrence.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences, rows.Err()
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/northstar-pay/nucleus/model"
 )
@@ -13,22 +14,90 @@ type IDataSource interface {
 	identity
 	balanceMonitor
 	account
+	journal
+	currency
+	fx
+	statement
+	reservation
+	recurring
+	spendingLimit
+	kycTier
 }
 
 type transaction interface {
 	RecordTransaction(cxt context.Context, txn *model.Transaction) (*model.Transaction, error)
+	RecordTransactionGroup(cxt context.Context, parent *model.Transaction, balances []*model.Balance, txns []*model.Transaction) error
 	GetTransaction(id string) (*model.Transaction, error)
 	IsParentTransactionVoid(parentID string) (bool, error)
 	GetTransactionByRef(cxt context.Context, reference string) (model.Transaction, error)
 	TransactionExistsByRef(ctx context.Context, reference string) (bool, error)
 	UpdateTransactionStatus(id string, status string) error
-	GetAllTransactions() ([]model.Transaction, error)
+	GetAllTransactions(filter model.TransactionFilter, limit int) ([]model.Transaction, error)
 	GetTotalCommittedTransactions(parentID string) (int64, error)
 }
 
+type journal interface {
+	RecordJournalEntry(cxt context.Context, entry *model.JournalEntry, balances []*model.Balance) error
+	GetJournalEntry(id string) (*model.JournalEntry, error)
+}
+
+type currency interface {
+	CreateCurrency(currency model.Currency) (model.Currency, error)
+	GetCurrencyByCode(code string) (*model.Currency, error)
+	GetAllCurrencies() ([]model.Currency, error)
+}
+
+type statement interface {
+	GetBalanceStatement(ctx context.Context, balanceID string, precision int64, from, to time.Time) (int64, []model.StatementEntry, error)
+}
+
+type recurring interface {
+	CreateRecurringTransaction(recurring model.RecurringTransaction) (model.RecurringTransaction, error)
+	GetRecurringTransaction(id string) (*model.RecurringTransaction, error)
+	GetAllRecurringTransactions(filter model.RecurringTransactionFilter, limit int) ([]model.RecurringTransaction, error)
+	GetDueRecurringTransactions(ctx context.Context, at time.Time, limit int) ([]model.RecurringTransaction, error)
+	ClaimRecurringOccurrence(ctx context.Context, recurring *model.RecurringTransaction, previous int64, occurrence model.RecurringOccurrence) (bool, error)
+	UpdateRecurringTransactionStatus(ctx context.Context, recurring *model.RecurringTransaction, from string) (bool, error)
+	UpdateRecurringOccurrence(ctx context.Context, occurrence model.RecurringOccurrence) error
+	ClaimRetryableRecurringOccurrences(ctx context.Context, staleBefore time.Time, maxAttempts, limit int) ([]model.RecurringOccurrence, error)
+	GetRecurringOccurrences(recurringID string, cursor int64, limit int) ([]model.RecurringOccurrence, error)
+}
+
+type spendingLimit interface {
+	CreateSpendingLimit(limit model.SpendingLimit) (model.SpendingLimit, error)
+	GetSpendingLimit(id string) (*model.SpendingLimit, error)
+	GetAllSpendingLimits(filter model.SpendingLimitFilter, limit int) ([]model.SpendingLimit, error)
+	GetBalanceSpendingLimits(ctx context.Context, balance *model.Balance) ([]model.SpendingLimit, error)
+	DeleteSpendingLimit(id string) error
+	GetSpendingLimitUsage(ctx context.Context, limit model.SpendingLimit, windowStart time.Time) (model.LimitUsage, error)
+}
+
+type kycTier interface {
+	CreateKYCTier(tier model.KYCTier) (model.KYCTier, error)
+	GetKYCTier(id string) (*model.KYCTier, error)
+	GetAllKYCTiers() ([]model.KYCTier, error)
+	GetIdentityTier(ctx context.Context, identityID string) (*model.KYCTier, error)
+	IdentityHasHotBalances(ctx context.Context, identityID, currency string) (bool, error)
+	UpdateIdentityTier(ctx context.Context, change model.TierChange) (bool, error)
+	GetIdentityTierChanges(identityID string, cursor int64, limit int) ([]model.TierChange, error)
+}
+
+type reservation interface {
+	PlaceReservations(ctx context.Context, reservations []model.Reservation, allowOverdraft bool) error
+	ReleaseReservations(ctx context.Context, transactionID string) error
+}
+
+type fx interface {
+	CreateFXRateSet(rateSet model.FXRateSet) (model.FXRateSet, error)
+	GetFXRateSet(id string) (*model.FXRateSet, error)
+	GetLatestFXRate(baseCurrency, quoteCurrency string) (*model.FXRate, error)
+	CreateFXQuote(quote model.FXQuote) (model.FXQuote, error)
+	GetFXQuote(id string) (*model.FXQuote, error)
+}
+
 type ledger interface {
 	CreateLedger(ledger model.Ledger) (model.Ledger, error)
-	GetAllLedgers() ([]model.Ledger, error)
+	GetAllLedgers(filter model.LedgerFilter, limit int) ([]model.Ledger, error)
 	GetLedgerByID(id string) (*model.Ledger, error)
 }
 
@@ -36,11 +105,19 @@ type balance interface {
 	CreateBalance(balance model.Balance) (model.Balance, error)
 	GetBalanceByID(id string, include []string) (*model.Balance, error)
 	GetBalanceByIDLite(id string) (*model.Balance, error)
-	GetAllBalances() ([]model.Balance, error)
+	GetAllBalances(filter model.BalanceFilter, limit int) ([]model.Balance, error)
 	UpdateBalance(balance *model.Balance) error
+	UpdateOverdraftLimit(ctx context.Context, balanceID string, limit int64, expiresAt *time.Time) error
+	UpdateBalanceStatus(ctx context.Context, change model.BalanceStatusChange) (bool, error)
+	SweepAndCloseBalance(ctx context.Context, change model.BalanceStatusChange, sweep *model.Transaction, balances ...*model.Balance) (bool, error)
+	GetBalanceStatusChanges(balanceID string, cursor int64, limit int) ([]model.BalanceStatusChange, error)
 	GetBalanceByIndicator(indicator, currency string) (*model.Balance, error)
-	UpdateBalances(ctx context.Context, sourceBalance, destinationBalance *model.Balance) error
+	UpdateBalances(ctx context.Context, transaction *model.Transaction, sourceBalance, destinationBalance *model.Balance) error
 	GetSourceDestination(sourceId, destinationId string) ([]*model.Balance, error)
+	GetBalanceSnapshots(balanceID string, from, to time.Time, limit int) ([]model.BalanceSnapshot, error)
+	GetBalanceSnapshotsByTransaction(transactionID string) ([]model.BalanceSnapshot, error)
+	GetBalanceSnapshotAt(balanceID string, asOf time.Time) (*model.BalanceSnapshot, error)
+	FlushBalanceDeltas(ctx context.Context, limit int) (int64, error)
 }
 
 type account interface {
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	redlock "github.com/northstar-pay/nucleus/internal/lock"
+	"github.com/northstar-pay/nucleus/model"
+	"go.opentelemetry.io/otel"
+)
+
+// PlaceReservations holds funds on each balance for a queued transaction. Every hold must fit in what the
+// balance has left once earlier reservations are set aside, together with its overdraft limit, unless
+// allowOverdraft is set, and no funds are held on a balance whose status stops it being debited; if one hold
+// cannot be placed, none are.
+func (d Datasource) PlaceReservations(ctx context.Context, reservations []model.Reservation, allowOverdraft bool) error {
+	ctx, span := otel.Tracer("Queue transaction").Start(ctx, "Placing reservations")
+	defer span.End()
+
+	tx, err := d.Conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault})
+	if err != nil {
+		return err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if err := redlock.TakeAdvisoryLocks(ctx, tx); err != nil {
+		return err
+	}
+
+	// The version moves with the hold, so a posting that read the balance before it fails its update instead of
+	// spending the held funds.
+	for _, reservation := range reservations {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE blnk.balances SET reserved_balance = reserved_balance + $2, version = version + 1
+			WHERE balance_id = $1 AND status NOT IN ($4, $5, $6) AND ($3 OR balance - reserved_balance - $2 >= -CASE
+				WHEN overdraft_expires_at IS NULL OR overdraft_expires_at > NOW() THEN overdraft_limit ELSE 0
+			END)
+		`, reservation.BalanceID, reservation.Amount, allowOverdraft, model.BalanceFrozen, model.BalanceDebitBlocked, model.BalanceClosed)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rowsAffected == 0 {
+			return reservationRefused(ctx, tx, reservation.BalanceID)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO blnk.reservations (transaction_id, balance_id, amount, status)
+			VALUES ($1, $2, $3, $4)
+		`, reservation.TransactionID, reservation.BalanceID, reservation.Amount, model.ReservationHeld)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// convertReservations marks the holds of a transaction being applied as converted and takes them off the
+// reserved balances, since the debit itself now accounts for the money. It runs in the database transaction
+// that applies the debit, so funds are never both held and spent.
+func convertReservations(ctx context.Context, conn execer, transactionID string) error {
+	return settleReservations(ctx, conn, transactionID, model.ReservationConverted)
+}
+
+// ReleaseReservations gives the funds held for a transaction that will not be applied back to its balances.
+func (d Datasource) ReleaseReservations(ctx context.Context, transactionID string) error {
+	return settleReservations(ctx, d.Conn, transactionID, model.ReservationReleased)
+}
+
+func settleReservations(ctx context.Context, conn execer, transactionID, status string) error {
+	_, err := conn.ExecContext(ctx, `
+		WITH settled AS (
+			UPDATE blnk.reservations SET status = $2, updated_at = NOW()
+			WHERE transaction_id = $1 AND status = $3
+			RETURNING balance_id, amount
+		)
+		UPDATE blnk.balances b SET reserved_balance = GREATEST(b.reserved_balance - settled.amount, 0)
+		FROM settled
+		WHERE b.balance_id = settled.balance_id
+	`, transactionID, status, model.ReservationHeld)
+	return err
+}
+
+// reservationRefused explains why a hold could not be placed on a balance: its status or its funds.
+func reservationRefused(ctx context.Context, tx *sql.Tx, balanceID string) error {
+	balance := &model.Balance{BalanceID: balanceID}
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM blnk.balances WHERE balance_id = $1`, balanceID).Scan(&balance.Status); err != nil {
+		return err
+	}
+	if err := balance.CheckDebit(); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w %s", model.ErrInsufficientFunds, balanceID)
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx so reads can run inside or outside a database transaction.
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// balanceAmountSQL is a transaction's amount in the minor units of balance $1 with multiplier $2. Converted
+// credits land in the destination currency, so they are read from destination_amount.
+const balanceAmountSQL = `CASE WHEN destination = $1 AND destination_amount IS NOT NULL THEN ROUND(destination_amount * $2)::BIGINT ELSE precise_amount END`
+
+// statementRowsSQL lists what moved balance $1 from $3 onwards: its applied transactions, and the lines of
+// applied journal entries, which have no counterparty. Callers bound the end themselves.
+const statementRowsSQL = `
+	SELECT transaction_id AS id, reference, COALESCE(description, '') AS description, source, destination, ` + balanceAmountSQL + ` AS amount, created_at, 0 AS kind, id AS seq
+	FROM blnk.transactions
+	WHERE status = 'APPLIED' AND (source = $1 OR destination = $1) AND source <> destination AND created_at >= $3
+	UNION ALL
+	SELECT e.entry_id, e.reference, COALESCE(l.description, e.description, ''),
+	       CASE WHEN l.direction = 'debit' THEN l.balance_id ELSE '' END,
+	       CASE WHEN l.direction = 'credit' THEN l.balance_id ELSE '' END,
+	       l.precise_amount, e.created_at, 1, l.id
+	FROM blnk.journal_lines l JOIN blnk.journal_entries e ON e.entry_id = l.entry_id
+	WHERE e.status = 'APPLIED' AND l.balance_id = $1 AND e.created_at >= $3`
+
+// GetBalanceStatement reads a balance's statement for [from, to) at one point in time: the balance at from, from
+// its last snapshot taken by then, and what moved it during the period, oldest first. A balance with no
+// snapshot by from, such as one from before snapshots were kept, opens at its current balance less what moved it
+// since. Running balances are left for the caller to fill in.
+func (d Datasource) GetBalanceStatement(ctx context.Context, balanceID string, precision int64, from, to time.Time) (int64, []model.StatementEntry, error) {
+	tx, err := d.Conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	var opening int64
+	snapshot, err := getBalanceSnapshotAt(tx, balanceID, from)
+	switch {
+	case err == nil:
+		opening = snapshot.Balance
+	case errors.Is(err, sql.ErrNoRows):
+		if opening, err = balanceBefore(tx, balanceID, precision, from); err != nil {
+			return 0, nil, err
+		}
+	default:
+		return 0, nil, err
+	}
+
+	entries, err := statementEntries(tx, balanceID, precision, from, to)
+	if err != nil {
+		return 0, nil, err
+	}
+	return opening, entries, tx.Commit()
+}
+
+// balanceBefore returns a balance as it was before since: its current balance less the net effect of what
+// moved it from since onwards.
+func balanceBefore(q queryer, balanceID string, precision int64, since time.Time) (int64, error) {
+	var opening int64
+	err := q.QueryRow(`
+		SELECT b.balance - COALESCE((
+			SELECT SUM(CASE WHEN m.destination = $1 THEN m.amount ELSE -m.amount END) FROM (`+statementRowsSQL+`) m
+		), 0)::BIGINT
+		FROM blnk.balances b
+		WHERE b.balance_id = $1
+	`, balanceID, precision, since).Scan(&opening)
+	return opening, err
+}
+
+func statementEntries(q queryer, balanceID string, precision int64, from, to time.Time) ([]model.StatementEntry, error) {
+	rows, err := q.Query(`
+		SELECT id, reference, description, source, destination, amount, created_at
+		FROM (`+statementRowsSQL+`) m
+		WHERE created_at < $4
+		ORDER BY created_at, kind, seq
+	`, balanceID, precision, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []model.StatementEntry{}
+	for rows.Next() {
+		entry := model.StatementEntry{}
+		var source, destination string
+		err := rows.Scan(&entry.TransactionID, &entry.Reference, &entry.Description, &source, &destination, &entry.Amount, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.Direction, entry.Counterparty = model.EntryDebit, destination
+		if destination == balanceID {
+			entry.Direction, entry.Counterparty = model.EntryCredit, source
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
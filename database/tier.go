@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+const kycTierColumns = `t.id, t.tier_id, t.name, t.currency, t.max_balance, t.max_transaction, t.daily_volume, t.cap_action, t.created_at, t.meta_data`
+
+func scanKYCTier(row interface {
+	Scan(dest ...interface{}) error
+}) (model.KYCTier, error) {
+	tier := model.KYCTier{}
+	var metaDataJSON []byte
+	err := row.Scan(&tier.ID, &tier.TierID, &tier.Name, &tier.Currency, &tier.MaxBalance, &tier.MaxTransaction, &tier.DailyVolume,
+		&tier.CapAction, &tier.CreatedAt, &metaDataJSON)
+	if err != nil {
+		return tier, err
+	}
+	if metaDataJSON != nil {
+		if err := json.Unmarshal(metaDataJSON, &tier.MetaData); err != nil {
+			return tier, err
+		}
+	}
+	return tier, nil
+}
+
+func (d Datasource) CreateKYCTier(tier model.KYCTier) (model.KYCTier, error) {
+	metaDataJSON, err := json.Marshal(tier.MetaData)
+	if err != nil {
+		return model.KYCTier{}, err
+	}
+
+	err = d.Conn.QueryRow(`
+		INSERT INTO blnk.kyc_tiers (tier_id, name, currency, max_balance, max_transaction, daily_volume, cap_action, created_at, meta_data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, tier.TierID, tier.Name, tier.Currency, tier.MaxBalance, tier.MaxTransaction, tier.DailyVolume, tier.CapAction, tier.CreatedAt, metaDataJSON).Scan(&tier.ID)
+	if err != nil {
+		return model.KYCTier{}, err
+	}
+	return tier, nil
+}
+
+func (d Datasource) GetKYCTier(id string) (*model.KYCTier, error) {
+	tier, err := scanKYCTier(d.Conn.QueryRow(`
+		SELECT `+kycTierColumns+`
+		FROM blnk.kyc_tiers t
+		WHERE t.tier_id = $1
+	`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("kyc tier with ID '%s' %w", id, model.ErrNotFound)
+		}
+		return nil, err
+	}
+	return &tier, nil
+}
+
+// GetAllKYCTiers returns every tier, in the order they were created.
+func (d Datasource) GetAllKYCTiers() ([]model.KYCTier, error) {
+	rows, err := d.Conn.Query(`
+		SELECT ` + kycTierColumns + `
+		FROM blnk.kyc_tiers t
+		ORDER BY t.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tiers := []model.KYCTier{}
+	for rows.Next() {
+		tier, err := scanKYCTier(rows)
+		if err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, rows.Err()
+}
+
+// GetIdentityTier returns the tier an identity is assigned, or nil when it has none.
+func (d Datasource) GetIdentityTier(ctx context.Context, identityID string) (*model.KYCTier, error) {
+	tier, err := scanKYCTier(d.Conn.QueryRowContext(ctx, `
+		SELECT `+kycTierColumns+`
+		FROM blnk.identity i
+		JOIN blnk.kyc_tiers t ON t.tier_id = i.tier_id
+		WHERE i.identity_id = $1
+	`, identityID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tier, nil
+}
+
+// IdentityHasHotBalances reports whether any of an identity's balances in currency is hot.
+func (d Datasource) IdentityHasHotBalances(ctx context.Context, identityID, currency string) (bool, error) {
+	var hot bool
+	err := d.Conn.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM blnk.balances WHERE identity_id = $1 AND currency = $2 AND hot)
+	`, identityID, currency).Scan(&hot)
+	return hot, err
+}
+
+// UpdateIdentityTier moves an identity to change.ToTier and adds the change to the identity's tier history, in
+// one database transaction. The update only applies while the identity is still on change.FromTier. It reports
+// whether the change was applied.
+func (d Datasource) UpdateIdentityTier(ctx context.Context, change model.TierChange) (bool, error) {
+	tx, err := d.Conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelDefault})
+	if err != nil {
+		return false, err
+	}
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE blnk.identity
+		SET tier_id = $2
+		WHERE identity_id = $1 AND COALESCE(tier_id, '') = $3
+	`, change.IdentityID, change.ToTier, change.FromTier)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO blnk.identity_tier_changes (identity_id, from_tier, to_tier, reason, changed_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, change.IdentityID, change.FromTier, change.ToTier, change.Reason, change.ChangedBy, change.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetIdentityTierChanges lists the history of an identity's tier, newest first, up to limit rows. cursor is the
+// position to continue after.
+func (d Datasource) GetIdentityTierChanges(identityID string, cursor int64, limit int) ([]model.TierChange, error) {
+	q := &queryFilter{}
+	q.addCursor(cursor)
+	q.addEqual("identity_id", identityID)
+
+	rows, err := d.Conn.Query(`
+		SELECT id, identity_id, from_tier, to_tier, reason, changed_by, created_at
+		FROM blnk.identity_tier_changes`+q.page(limit), q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []model.TierChange{}
+	for rows.Next() {
+		change := model.TierChange{}
+		err := rows.Scan(&change.ID, &change.IdentityID, &change.FromTier, &change.ToTier, &change.Reason, &change.ChangedBy, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
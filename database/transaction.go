@@ -5,35 +5,48 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"strings"
 
 	"go.opentelemetry.io/otel"
 
+	redlock "github.com/northstar-pay/nucleus/internal/lock"
 	"github.com/northstar-pay/nucleus/model"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
 )
 
-func (d Datasource) RecordTransaction(cxt context.Context, txn *model.Transaction) (*model.Transaction, error) {
-	cxt, span := otel.Tracer("Queue transaction").Start(cxt, "Saving transaction to db")
-	defer span.End()
+// execer is satisfied by both *sql.DB and *sql.Tx so inserts can run inside or outside a database transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// nullableNumber sends an empty decimal as NULL, since NUMERIC columns reject an empty string.
+func nullableNumber(n json.Number) interface{} {
+	if n == "" {
+		return nil
+	}
+	return n
+}
+
+func insertTransaction(cxt context.Context, conn execer, txn *model.Transaction) error {
 	metaDataJSON, err := json.Marshal(txn.MetaData)
 	if err != nil {
-		return txn, err
+		return err
 	}
 
-	_, err = d.Conn.ExecContext(cxt,
+	_, err = conn.ExecContext(cxt,
 		`
-		INSERT INTO blnk.transactions(transaction_id,parent_transaction,source,reference,amount,precise_amount,precision,rate,currency,destination,description,status,created_at,meta_data,scheduled_for,hash) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)
+		INSERT INTO blnk.transactions(transaction_id,parent_transaction,source,reference,amount,precise_amount,precision,rate,currency,destination,description,status,created_at,meta_data,scheduled_for,hash,group_id,quote_id,source_amount,destination_amount) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20)
 	`,
 		txn.TransactionID,
 		txn.ParentTransaction,
 		txn.Source,
 		txn.Reference,
-		txn.Amount,
+		nullableNumber(txn.Amount),
 		txn.PreciseAmount,
 		txn.Precision,
-		txn.Rate,
+		nullableNumber(txn.Rate),
 		txn.Currency,
 		txn.Destination,
 		txn.Description,
@@ -42,8 +55,20 @@ func (d Datasource) RecordTransaction(cxt context.Context, txn *model.Transactio
 		metaDataJSON,
 		txn.ScheduledFor,
 		txn.Hash,
+		txn.GroupID,
+		txn.QuoteID,
+		nullableNumber(txn.SourceAmount),
+		nullableNumber(txn.DestinationAmount),
 	)
 
+	return err
+}
+
+func (d Datasource) RecordTransaction(cxt context.Context, txn *model.Transaction) (*model.Transaction, error) {
+	cxt, span := otel.Tracer("Queue transaction").Start(cxt, "Saving transaction to db")
+	defer span.End()
+
+	err := insertTransaction(cxt, d.Conn, txn)
 	if err != nil {
 		return txn, err
 	}
@@ -51,9 +76,119 @@ func (d Datasource) RecordTransaction(cxt context.Context, txn *model.Transactio
 	return txn, nil
 }
 
+// RecordTransactionGroup updates every balance, charges each leg's spending limits, converts the funds reserved
+// for the group and inserts every transaction in a single database transaction, so either all legs of a group
+// are posted or none are. parent, when given, is the transaction the legs were split from; it is kept by its
+// group ID so it can be fetched by its own ID, and it holds the group's reservations. Without a parent, each
+// transaction holds its own.
+func (d Datasource) RecordTransactionGroup(cxt context.Context, parent *model.Transaction, balances []*model.Balance, txns []*model.Transaction) error {
+	cxt, span := otel.Tracer("Queue transaction").Start(cxt, "Saving transaction group to db")
+	defer span.End()
+
+	tx, err := d.Conn.BeginTx(cxt, &sql.TxOptions{Isolation: sql.LevelDefault})
+	if err != nil {
+		return err
+	}
+
+	defer func(tx *sql.Tx) {
+		_ = tx.Rollback()
+	}(tx)
+
+	if err := redlock.TakeAdvisoryLocks(cxt, tx); err != nil {
+		return err
+	}
+
+	// Balances in a group are snapshotted against the group, since one balance may be moved by several legs.
+	causeID := ""
+	if len(txns) > 0 {
+		causeID = txns[0].GroupID
+		if causeID == "" {
+			causeID = txns[0].TransactionID
+		}
+	}
+
+	for _, balance := range balances {
+		if err := updateBalance(cxt, tx, balance, causeID); err != nil {
+			return err
+		}
+	}
+
+	// A quote is used by the first leg posted with it; the spread leg taken from it follows in the same group.
+	usedQuotes := make(map[string]bool)
+	for _, txn := range txns {
+		if txn.QuoteID == "" || usedQuotes[txn.QuoteID] {
+			continue
+		}
+		if err := useFXQuote(cxt, tx, txn.QuoteID, txn.TransactionID); err != nil {
+			return err
+		}
+		usedQuotes[txn.QuoteID] = true
+	}
+
+	for _, txn := range txns {
+		if err := chargeSpendingLimits(cxt, tx, txn.LimitCharges); err != nil {
+			return err
+		}
+		if txn.Inflight {
+			if err := keepSpendingLimitCharges(cxt, tx, txn.TransactionID, txn.LimitCharges); err != nil {
+				return err
+			}
+		}
+		if err := insertTransaction(cxt, tx, txn); err != nil {
+			return err
+		}
+	}
+	if parent != nil {
+		if err := insertTransactionGroup(cxt, tx, parent); err != nil {
+			return err
+		}
+	}
+
+	holders := txns
+	if parent != nil {
+		holders = []*model.Transaction{parent}
+	}
+	for _, holder := range holders {
+		if holder.ReservedAmount == 0 {
+			continue
+		}
+		if err := convertReservations(cxt, tx, holder.TransactionID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func insertTransactionGroup(cxt context.Context, conn execer, parent *model.Transaction) error {
+	parentJSON, err := json.Marshal(parent)
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(cxt, `
+		INSERT INTO blnk.transaction_groups (group_id, transaction_id, reference, transaction, created_at) VALUES ($1, $2, $3, $4, $5)
+	`, parent.GroupID, parent.TransactionID, parent.Reference, parentJSON, parent.CreatedAt)
+	return err
+}
+
+// getTransactionGroupParent returns the transaction a group's legs were split from, by its ID.
+func (d Datasource) getTransactionGroupParent(id string) (*model.Transaction, error) {
+	var parentJSON []byte
+	err := d.Conn.QueryRow(`SELECT transaction FROM blnk.transaction_groups WHERE transaction_id = $1`, id).Scan(&parentJSON)
+	if err != nil {
+		return nil, err
+	}
+	parent := &model.Transaction{}
+	if err := json.Unmarshal(parentJSON, parent); err != nil {
+		return nil, err
+	}
+	return parent, nil
+}
+
 func (d Datasource) GetTransaction(id string) (*model.Transaction, error) {
 	row := d.Conn.QueryRow(`
-			SELECT transaction_id, source, reference, amount, precise_amount, precision, currency,destination, description, status,created_at, meta_data
+			SELECT transaction_id, source, reference, amount, precise_amount, precision, currency,destination, description, status,created_at, meta_data, COALESCE(group_id, ''),
+			       COALESCE(rate::TEXT, '1'), COALESCE(quote_id, ''), COALESCE(source_amount::TEXT, ''), COALESCE(destination_amount::TEXT, '')
 						FROM blnk.transactions
 					WHERE transaction_id = $1
 				`, id)
@@ -63,7 +198,13 @@ func (d Datasource) GetTransaction(id string) (*model.Transaction, error) {
 	var metaDataJSON []byte
 	err := row.Scan(&txn.TransactionID, &txn.Source, &txn.Reference, &txn.Amount, &txn.PreciseAmount, &txn.Precision, &txn.Currency, &txn.Destination, &txn.Description,
 		&txn.Status,
-		&txn.CreatedAt, &metaDataJSON)
+		&txn.CreatedAt, &metaDataJSON, &txn.GroupID, &txn.Rate, &txn.QuoteID, &txn.SourceAmount, &txn.DestinationAmount)
+	if errors.Is(err, sql.ErrNoRows) {
+		// A distributed transaction posted atomically has no row of its own, only its legs do.
+		if parent, groupErr := d.getTransactionGroupParent(id); groupErr == nil {
+			return parent, nil
+		}
+	}
 	if err != nil {
 		return &model.Transaction{}, err
 	}
@@ -142,27 +283,43 @@ func (d Datasource) UpdateTransactionStatus(id string, status string) error {
 	return err
 }
 
-func (d Datasource) GetAllTransactions() ([]model.Transaction, error) {
+// GetAllTransactions lists transactions matching the filter, newest first, up to limit rows.
+func (d Datasource) GetAllTransactions(filter model.TransactionFilter, limit int) ([]model.Transaction, error) {
+	q := &queryFilter{}
+	q.addCursor(filter.ID)
+	q.addEqual("status", strings.ToUpper(filter.Status))
+	q.addEqual("currency", filter.Currency)
+	q.addEqual("parent_transaction", filter.ParentTransaction)
+	if filter.BalanceID != "" {
+		q.add("(source = $%[1]d OR destination = $%[1]d)", filter.BalanceID)
+	}
+	if err := q.addMetaData(filter.MetaData); err != nil {
+		return nil, err
+	}
+	q.addTimeRange("created_at", filter.From, filter.To)
+
 	rows, err := d.Conn.Query(`
-		SELECT transaction_id, source, reference, amount, currency,destination, description, status, hash, created_at, meta_data
-		FROM blnk.transactions
-		ORDER BY created_at DESC
-	`)
+		SELECT id, transaction_id, source, reference, amount, precise_amount, precision, currency, destination, description, status, hash, created_at, meta_data,
+		       COALESCE(parent_transaction, ''), COALESCE(group_id, '')
+		FROM blnk.transactions`+q.page(limit), q.args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var transactions []model.Transaction
+	transactions := []model.Transaction{}
 
 	for rows.Next() {
 		transaction := model.Transaction{}
 		var metaDataJSON []byte
 		err = rows.Scan(
+			&transaction.ID,
 			&transaction.TransactionID,
 			&transaction.Source,
 			&transaction.Reference,
 			&transaction.Amount,
+			&transaction.PreciseAmount,
+			&transaction.Precision,
 			&transaction.Currency,
 			&transaction.Destination,
 			&transaction.Description,
@@ -170,6 +327,8 @@ func (d Datasource) GetAllTransactions() ([]model.Transaction, error) {
 			&transaction.Hash,
 			&transaction.CreatedAt,
 			&metaDataJSON,
+			&transaction.ParentTransaction,
+			&transaction.GroupID,
 		)
 		if err != nil {
 			return nil, err
@@ -184,7 +343,7 @@ func (d Datasource) GetAllTransactions() ([]model.Transaction, error) {
 		transactions = append(transactions, transaction)
 	}
 
-	return transactions, nil
+	return transactions, rows.Err()
 }
 
 func (d Datasource) GetTotalCommittedTransactions(parentID string) (int64, error) {
@@ -0,0 +1,96 @@
+package blnk
+
+import (
+	"errors"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// Reason codes recorded on rejected transactions under the blnk_rejection_code metadata key. Transactions
+// stopped by a spending limit or a KYC tier carry its code instead, such as daily_amount_limit_exceeded or
+// tier_max_balance_exceeded.
+const (
+	RejectionInsufficientFunds   = "insufficient_funds"
+	RejectionBalanceNotFound     = "balance_not_found"
+	RejectionDuplicateReference  = "duplicate_reference"
+	RejectionCurrencyMismatch    = "currency_mismatch"
+	RejectionInvalidAmount       = "invalid_amount"
+	RejectionInvalidQuote        = "invalid_quote"
+	RejectionRetriesExhausted    = "retries_exhausted"
+	RejectionBalanceStatus       = "balance_status"
+	RejectionNotFound            = "not_found"
+	RejectionPrecisionMismatch   = "precision_mismatch"
+	RejectionInvalidDistribution = "invalid_distribution"
+)
+
+// RejectionError is a transaction failure that retrying cannot fix, such as insufficient funds or a reference
+// that has already been used. Code is one of the Rejection* reason codes.
+type RejectionError struct {
+	Code string
+	Err  error
+}
+
+func (e *RejectionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RejectionError) Unwrap() error {
+	return e.Err
+}
+
+// RetryableError is a transient failure, such as lock contention, a concurrent balance update or a database
+// timeout, that may go away if the transaction is tried again.
+type RetryableError struct {
+	Err error
+}
+
+func (e *RetryableError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryableError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyTransactionError returns a failed posting's error as a *RejectionError or a *RetryableError. Errors
+// that are not known to be permanent are treated as retryable.
+func ClassifyTransactionError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var rejection *RejectionError
+	if errors.As(err, &rejection) {
+		return rejection
+	}
+	var retryable *RetryableError
+	if errors.As(err, &retryable) {
+		return retryable
+	}
+	var limitErr *model.LimitError
+	if errors.As(err, &limitErr) {
+		return &RejectionError{Code: limitErr.Code, Err: err}
+	}
+	var tierErr *model.TierError
+	if errors.As(err, &tierErr) {
+		return &RejectionError{Code: tierErr.Code, Err: err}
+	}
+
+	switch {
+	case errors.Is(err, model.ErrInsufficientFunds):
+		return &RejectionError{Code: RejectionInsufficientFunds, Err: err}
+	case errors.Is(err, model.ErrInvalidAmount):
+		return &RejectionError{Code: RejectionInvalidAmount, Err: err}
+	case errors.Is(err, model.ErrBalanceStatus):
+		return &RejectionError{Code: RejectionBalanceStatus, Err: err}
+	case errors.Is(err, model.ErrNotFound):
+		return &RejectionError{Code: RejectionNotFound, Err: err}
+	case errors.Is(err, model.ErrPrecisionMismatch):
+		return &RejectionError{Code: RejectionPrecisionMismatch, Err: err}
+	case errors.Is(err, model.ErrInvalidDistribution):
+		return &RejectionError{Code: RejectionInvalidDistribution, Err: err}
+	case errors.Is(err, model.ErrQuoteUsed):
+		return &RejectionError{Code: RejectionInvalidQuote, Err: err}
+	}
+	return &RetryableError{Err: err}
+}
@@ -0,0 +1,134 @@
+package blnk
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyTransactionError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		rejection string
+	}{
+		{"insufficient funds", fmt.Errorf("failed to apply transaction to balances: %w", model.ErrInsufficientFunds), RejectionInsufficientFunds},
+		{"invalid amount", model.ErrInvalidAmount, RejectionInvalidAmount},
+		{"duplicate reference", fmt.Errorf("transaction validation failed: %w", &RejectionError{Code: RejectionDuplicateReference, Err: errors.New("reference ref has already been used")}), RejectionDuplicateReference},
+		{"lock contention", fmt.Errorf("failed to acquire lock: %w", &RetryableError{Err: errors.New("lock for key bln is already held")}), ""},
+		{"unknown failure", errors.New("connection reset by peer"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classified := ClassifyTransactionError(tt.err)
+
+			var rejection *RejectionError
+			var retryable *RetryableError
+			if tt.rejection == "" {
+				assert.True(t, errors.As(classified, &retryable), "expected a retryable error, got %T", classified)
+				return
+			}
+			assert.True(t, errors.As(classified, &rejection), "expected a rejection, got %T", classified)
+			assert.Equal(t, tt.rejection, rejection.Code)
+		})
+	}
+}
+
+// TestClassifyDeterministicErrors classifies the errors the posting path really returns for failures that a
+// retry cannot fix.
+func TestClassifyDeterministicErrors(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.currencies WHERE code = $1`)).WithArgs("XYZ").
+		WillReturnRows(sqlmock.NewRows([]string{"code", "name", "symbol", "minor_units", "created_at", "meta_data"}))
+	_, unregistered := d.resolvePrecision("XYZ", 0)
+	expectCurrencyLookup(mock, "USD", 2)
+	_, mismatch := d.resolvePrecision("USD", 1000)
+
+	split := &model.Transaction{Reference: "ref", Amount: "10", Precision: 100, Currency: "USD",
+		Destinations: []model.Distribution{{Identifier: "a", Distribution: "150%"}}}
+	_, distribution := split.SplitTransaction()
+
+	entry := &model.JournalEntry{Lines: []model.JournalLine{
+		{BalanceID: "payroll", Direction: model.DirectionDebit, Amount: "10", Precision: 100, Currency: "USD"},
+		{BalanceID: "tax", Direction: model.DirectionCredit, Amount: "10", Precision: 100, Currency: "USD"},
+	}}
+	require.NoError(t, entry.Validate())
+	overdrawn := model.ApplyJournalEntry(entry, map[string]*model.Balance{
+		"payroll": {BalanceID: "payroll", Currency: "USD", Status: model.BalanceActive},
+		"tax":     {BalanceID: "tax", Currency: "USD", Status: model.BalanceActive},
+	})
+
+	tests := []struct {
+		name      string
+		err       error
+		rejection string
+	}{
+		{"unregistered currency", fmt.Errorf("currency validation failed: %w", unregistered), RejectionNotFound},
+		{"precision mismatch", fmt.Errorf("currency validation failed: %w", mismatch), RejectionPrecisionMismatch},
+		{"invalid distribution", fmt.Errorf("failed to split transaction: %w", distribution), RejectionInvalidDistribution},
+		{"journal insufficient funds", fmt.Errorf("failed to apply journal entry: %w", overdrawn), RejectionInsufficientFunds},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.err)
+			var rejection *RejectionError
+			require.ErrorAs(t, ClassifyTransactionError(tt.err), &rejection)
+			assert.Equal(t, tt.rejection, rejection.Code)
+		})
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRejectTransactionRecordsReasonCode(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	txn := &model.Transaction{
+		TransactionID:  model.GenerateUUIDWithSuffix("txn"),
+		Reference:      gofakeit.UUID(),
+		Source:         gofakeit.UUID(),
+		Destination:    gofakeit.UUID(),
+		Amount:         "10",
+		PreciseAmount:  1000,
+		Precision:      100,
+		Currency:       "NGN",
+		Status:         StatusQueued,
+		ReservedAmount: 1000,
+	}
+
+	insertArgs := make([]driver.Value, 20)
+	for i := range insertArgs {
+		insertArgs[i] = sqlmock.AnyArg()
+	}
+	insertArgs[11] = StatusRejected
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.transactions`)).WithArgs(insertArgs...).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(settleQuery).WithArgs(txn.TransactionID, model.ReservationReleased, model.ReservationHeld).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	rejected, err := d.RejectTransaction(context.Background(), txn, &RejectionError{Code: RejectionInsufficientFunds, Err: model.ErrInsufficientFunds})
+	assert.NoError(t, err)
+	assert.Equal(t, StatusRejected, rejected.Status)
+	assert.Equal(t, RejectionInsufficientFunds, rejected.MetaData["blnk_rejection_code"])
+	assert.Equal(t, model.ErrInsufficientFunds.Error(), rejected.MetaData["blnk_rejection_reason"])
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
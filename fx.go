@@ -0,0 +1,248 @@
+package blnk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/northstar-pay/nucleus/config"
+	"github.com/northstar-pay/nucleus/model"
+)
+
+const (
+	RateSourceAPI  = "api"
+	RateSourceFile = "file"
+)
+
+func fxConfig() (config.FXConfig, error) {
+	cnf, err := config.Fetch()
+	if err != nil {
+		return config.FXConfig{}, err
+	}
+	return cnf.FX, nil
+}
+
+// CreateFXRateSet publishes a new versioned set of rates. Every currency in it must be registered.
+func (l *Blnk) CreateFXRateSet(rateSet model.FXRateSet) (model.FXRateSet, error) {
+	if err := rateSet.Validate(); err != nil {
+		return model.FXRateSet{}, err
+	}
+
+	for _, rate := range rateSet.Rates {
+		if _, err := l.datasource.GetCurrencyByCode(rate.BaseCurrency); err != nil {
+			return model.FXRateSet{}, err
+		}
+		if _, err := l.datasource.GetCurrencyByCode(rate.QuoteCurrency); err != nil {
+			return model.FXRateSet{}, err
+		}
+	}
+
+	if rateSet.Source == "" {
+		rateSet.Source = RateSourceAPI
+	}
+	rateSet.RateSetID = model.GenerateUUIDWithSuffix("fxr")
+	rateSet.CreatedAt = time.Now()
+	if rateSet.EffectiveAt.IsZero() {
+		rateSet.EffectiveAt = rateSet.CreatedAt
+	}
+
+	return l.datasource.CreateFXRateSet(rateSet)
+}
+
+func (l *Blnk) GetFXRateSet(id string) (*model.FXRateSet, error) {
+	return l.datasource.GetFXRateSet(id)
+}
+
+// CreateFXQuote locks the latest rate for a currency pair, together with the configured spread, for the quote TTL.
+func (l *Blnk) CreateFXQuote(baseCurrency, quoteCurrency string) (model.FXQuote, error) {
+	fx, err := fxConfig()
+	if err != nil {
+		return model.FXQuote{}, err
+	}
+
+	rate, err := l.datasource.GetLatestFXRate(baseCurrency, quoteCurrency)
+	if err != nil {
+		return model.FXQuote{}, err
+	}
+
+	now := time.Now()
+	quote := model.FXQuote{
+		QuoteID:        model.GenerateUUIDWithSuffix("fxq"),
+		RateSetID:      rate.RateSetID,
+		RateSetVersion: rate.Version,
+		BaseCurrency:   rate.BaseCurrency,
+		QuoteCurrency:  rate.QuoteCurrency,
+		Rate:           rate.Rate,
+		SpreadBps:      fx.SpreadBps,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(time.Duration(fx.QuoteTTLSeconds) * time.Second),
+	}
+
+	return l.datasource.CreateFXQuote(quote)
+}
+
+func (l *Blnk) GetFXQuote(id string) (*model.FXQuote, error) {
+	return l.datasource.GetFXQuote(id)
+}
+
+// getTransactionQuote loads the quote a transaction references and checks it can still be used. A queued
+// transaction keeps the quote it was accepted with, so expiry is judged against when the transaction was created. A
+// quote can only be posted with one transaction, which takes it when it is recorded.
+func (l *Blnk) getTransactionQuote(transaction *model.Transaction) (*model.FXQuote, error) {
+	if transaction.Inflight || transaction.HasDistributions() {
+		return nil, &RejectionError{Code: RejectionInvalidQuote, Err: errors.New("quoted transactions cannot be inflight or distributed")}
+	}
+
+	quote, err := l.datasource.GetFXQuote(transaction.QuoteID)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, &RejectionError{Code: RejectionInvalidQuote, Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if quote.BaseCurrency != transaction.Currency {
+		return nil, &RejectionError{Code: RejectionInvalidQuote, Err: fmt.Errorf("quote %s converts from %s but the transaction is in %s", quote.QuoteID, quote.BaseCurrency, transaction.Currency)}
+	}
+
+	createdAt := transaction.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	if quote.IsExpired(createdAt) {
+		return nil, &RejectionError{Code: RejectionInvalidQuote, Err: fmt.Errorf("quote %s expired at %s", quote.QuoteID, quote.ExpiresAt.Format(time.RFC3339))}
+	}
+	if quote.UsedBy != "" && quote.UsedBy != transaction.TransactionID {
+		return nil, &RejectionError{Code: RejectionInvalidQuote, Err: fmt.Errorf("quote %s: %w", quote.QuoteID, model.ErrQuoteUsed)}
+	}
+
+	return quote, nil
+}
+
+// RecordQuotedTransaction converts a transaction at its quoted rate. The spread is taken from the source amount in
+// the source currency and posted to the FX revenue balance in the same database transaction as the conversion.
+func (l *Blnk) RecordQuotedTransaction(ctx context.Context, transaction *model.Transaction) (*model.Transaction, error) {
+	ctx, span := tracer.Start(ctx, "Recording quoted transaction")
+	defer span.End()
+
+	quote, legs, err := l.quotedLegs(transaction)
+	if err != nil {
+		return nil, l.logAndRecordError(span, "quote validation failed", err)
+	}
+
+	ctx, locker, err := l.lockTransactions(ctx, legs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer l.releaseLock(ctx, locker)
+
+	balances, err := l.applyLegs(ctx, span, legs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkQuoteDestination(quote, transaction, balances); err != nil {
+		return nil, err
+	}
+
+	if err := l.datasource.RecordTransactionGroup(ctx, nil, balances, legs); err != nil {
+		return nil, l.logAndRecordError(span, "failed to persist quoted transaction", err)
+	}
+
+	for _, balance := range balances {
+		go l.checkBalanceMonitors(balance)
+	}
+	for _, leg := range legs {
+		l.postTransactionActions(ctx, leg)
+	}
+
+	return transaction, nil
+}
+
+// quotedLegs fixes a quoted transaction's rate and amounts and returns it along with the leg that moves the
+// spread to the FX revenue balance, if there is any spread.
+func (l *Blnk) quotedLegs(transaction *model.Transaction) (*model.FXQuote, []*model.Transaction, error) {
+	fx, err := fxConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quote, err := l.getTransactionQuote(transaction)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := l.applyCurrencyPrecision(transaction); err != nil {
+		return nil, nil, err
+	}
+
+	grossAmount, err := model.ApplyPrecision(transaction)
+	if err != nil {
+		return nil, nil, err
+	}
+	spread, err := model.SpreadAmount(grossAmount, quote.SpreadBps)
+	if err != nil {
+		return nil, nil, err
+	}
+	if spread >= grossAmount {
+		return nil, nil, errors.New("amount is too small to cover the FX spread")
+	}
+
+	if transaction.TransactionID == "" {
+		transaction.TransactionID = model.GenerateUUIDWithSuffix("txn")
+	}
+	if transaction.CreatedAt.IsZero() {
+		transaction.CreatedAt = time.Now()
+	}
+	transaction.Rate = quote.Rate
+	transaction.SourceAmount = transaction.Amount
+	transaction.PreciseAmount = grossAmount - spread
+	transaction.Amount = model.FromPrecise(transaction.PreciseAmount, transaction.Precision)
+	// A reservation covers the gross amount, so it is split between the transaction and its spread leg.
+	var spreadReserved int64
+	if transaction.ReservedAmount > 0 {
+		transaction.ReservedAmount = transaction.PreciseAmount
+		spreadReserved = spread
+	}
+
+	legs := []*model.Transaction{transaction}
+	if spread > 0 {
+		if transaction.GroupID == "" {
+			transaction.GroupID = model.GenerateUUIDWithSuffix("grp")
+		}
+		spreadTxn := &model.Transaction{
+			TransactionID:     model.GenerateUUIDWithSuffix("txn"),
+			ParentTransaction: transaction.TransactionID,
+			Source:            transaction.Source,
+			Destination:       fx.RevenueBalance,
+			Reference:         transaction.Reference + "-fx-spread",
+			Amount:            model.FromPrecise(spread, transaction.Precision),
+			PreciseAmount:     spread,
+			Precision:         transaction.Precision,
+			Rate:              "1",
+			Currency:          transaction.Currency,
+			Description:       "FX spread",
+			Status:            transaction.Status,
+			AllowOverdraft:    transaction.AllowOverdraft,
+			ReservedAmount:    spreadReserved,
+			QuoteID:           quote.QuoteID,
+			GroupID:           transaction.GroupID,
+			CreatedAt:         transaction.CreatedAt,
+		}
+		spreadTxn.Hash = spreadTxn.HashTxn()
+		legs = append(legs, spreadTxn)
+	}
+
+	return quote, legs, nil
+}
+
+// checkQuoteDestination makes sure the destination balance is in the currency the quote converts to.
+func checkQuoteDestination(quote *model.FXQuote, transaction *model.Transaction, balances []*model.Balance) error {
+	for _, balance := range balances {
+		if balance.BalanceID == transaction.Destination && balance.Currency != quote.QuoteCurrency {
+			return &RejectionError{Code: RejectionCurrencyMismatch, Err: fmt.Errorf("quote %s converts to %s but the destination balance is in %s", quote.QuoteID, quote.QuoteCurrency, balance.Currency)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package blnk
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+var quoteColumns = []string{"quote_id", "rate_set_id", "rate_set_version", "base_currency", "quote_currency", "rate", "spread_bps", "expires_at", "created_at", "used_by"}
+
+const quoteQuery = `SELECT quote_id, rate_set_id, rate_set_version, base_currency, quote_currency, rate, spread_bps, expires_at, created_at, COALESCE(used_by, '') FROM blnk.fx_quotes WHERE quote_id = $1`
+
+const useQuoteQuery = `UPDATE blnk.fx_quotes SET used_by = $2, used_at = NOW() WHERE quote_id = $1 AND (used_by IS NULL OR used_by = $2)`
+
+// expectQuotedTransfer expects txn to be converted at quote fxq_1 from source to destination, up to the balance
+// updates in the database transaction that posts it.
+func expectQuotedTransfer(mock sqlmock.Sqlmock, txn *model.Transaction, source, destination, revenue string) {
+	mock.ExpectQuery(regexp.QuoteMeta(quoteQuery)).WithArgs("fxq_1").WillReturnRows(sqlmock.NewRows(quoteColumns).
+		AddRow("fxq_1", "fxr_1", 3, "USD", "NGN", "1500", 100, time.Now().Add(time.Minute), time.Now(), ""))
+	expectCurrencyLookup(mock, "USD", 2)
+
+	balanceColumns := []string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}
+	balanceQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
+	indicatorQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE indicator = $1 AND currency = $2`)
+	existsQuery := regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)
+
+	// The revenue indicator is resolved to its balance ID before the balances are locked.
+	mock.ExpectQuery(indicatorQuery).WithArgs("@FXRevenue", "USD").WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(revenue, "USD", 100, "general_ledger_id", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+	mock.ExpectQuery(existsQuery).WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(balanceQuery).WithArgs(source).WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(source, "USD", 100, "ledger-id", 20000, 20000, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+	mock.ExpectQuery(balanceQuery).WithArgs(destination).WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(destination, "NGN", 100, "ledger-id", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+	// The destination's precision is checked against the registry entry for its own currency.
+	expectCurrencyLookup(mock, "NGN", 2)
+	expectNoSpendingLimits(mock)
+	mock.ExpectQuery(existsQuery).WithArgs(txn.Reference + "-fx-spread").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(indicatorQuery).WithArgs("@FXRevenue", "USD").WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(revenue, "USD", 100, "general_ledger_id", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+	expectNoSpendingLimits(mock)
+
+	updateQuery := regexp.QuoteMeta(`
+	  UPDATE blnk.balances
+	  SET balance = $2, credit_balance = $3, debit_balance = $4, inflight_balance = $5, inflight_credit_balance = $6, inflight_debit_balance = $7, currency = $8, currency_multiplier = $9, ledger_id = $10, created_at = $11, meta_data = $12, version = version + 1
+	  WHERE balance_id = $1 AND version = $13
+	`)
+	expectUpdate := func(id string, balance, credit, debit int64) {
+		mock.ExpectExec(updateQuery).WithArgs(id, balance, credit, debit, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 0, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+
+	mock.ExpectBegin()
+	expectUpdate(source, 10000, 20000, 10000)
+	expectUpdate(destination, 14850000, 14850000, 0)
+	expectUpdate(revenue, 100, 100, 0)
+}
+
+func TestRecordQuotedTransaction_PostsSpreadToRevenueBalance(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := "bln_a_source"
+	destination := "bln_b_destination"
+	revenue := "bln_c_revenue"
+
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      source,
+		Destination: destination,
+		Amount:      "100",
+		Currency:    "USD",
+		QuoteID:     "fxq_1",
+	}
+
+	expectQuotedTransfer(mock, txn, source, destination, revenue)
+	// The quote is taken once, by the conversion leg, before either leg is inserted.
+	mock.ExpectExec(regexp.QuoteMeta(useQuoteQuery)).WithArgs("fxq_1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	insertQuery := regexp.QuoteMeta(`INSERT INTO blnk.transactions(transaction_id,parent_transaction,source,reference,amount,precise_amount,precision,rate,currency,destination,description,status,created_at,meta_data,scheduled_for,hash,group_id,quote_id,source_amount,destination_amount) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20)`)
+	mock.ExpectExec(insertQuery).WithArgs(sqlmock.AnyArg(), "", source, txn.Reference, json.Number("99"), 9900, int64(100), json.Number("1500"), "USD", destination,
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+		"fxq_1", json.Number("100"), json.Number("148500")).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insertQuery).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), source, txn.Reference+"-fx-spread", json.Number("1"), 100, int64(100), json.Number("1"), "USD", revenue,
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+		"fxq_1", nil, nil).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	result, err := d.RecordTransaction(context.Background(), txn)
+	assert.NoError(t, err)
+	assert.Equal(t, json.Number("1500"), result.Rate)
+	assert.Equal(t, json.Number("100"), result.SourceAmount)
+	assert.Equal(t, json.Number("148500"), result.DestinationAmount)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRecordQuotedTransaction_RejectsExpiredQuote(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      gofakeit.UUID(),
+		Destination: gofakeit.UUID(),
+		Amount:      "100",
+		Currency:    "USD",
+		QuoteID:     "fxq_1",
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(quoteQuery)).WithArgs("fxq_1").WillReturnRows(sqlmock.NewRows(quoteColumns).
+		AddRow("fxq_1", "fxr_1", 3, "USD", "NGN", "1500", 100, time.Now().Add(-time.Minute), time.Now().Add(-2*time.Minute), ""))
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "expired")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRecordQuotedTransaction_RejectsUsedQuote(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      gofakeit.UUID(),
+		Destination: gofakeit.UUID(),
+		Amount:      "100",
+		Currency:    "USD",
+		QuoteID:     "fxq_1",
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(quoteQuery)).WithArgs("fxq_1").WillReturnRows(sqlmock.NewRows(quoteColumns).
+		AddRow("fxq_1", "fxr_1", 3, "USD", "NGN", "1500", 100, time.Now().Add(time.Minute), time.Now(), "txn_other"))
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	assert.ErrorIs(t, err, model.ErrQuoteUsed)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRecordQuotedTransaction_RejectsQuoteUsedWhilePosting(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := "bln_a_source"
+	destination := "bln_b_destination"
+	revenue := "bln_c_revenue"
+
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      source,
+		Destination: destination,
+		Amount:      "100",
+		Currency:    "USD",
+		QuoteID:     "fxq_1",
+	}
+
+	expectQuotedTransfer(mock, txn, source, destination, revenue)
+	// Another transaction took the quote after it was looked up, so nothing is posted.
+	mock.ExpectExec(regexp.QuoteMeta(useQuoteQuery)).WithArgs("fxq_1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	assert.ErrorIs(t, err, model.ErrQuoteUsed)
+	var rejection *RejectionError
+	assert.ErrorAs(t, ClassifyTransactionError(err), &rejection)
+	assert.Equal(t, RejectionInvalidQuote, rejection.Code)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,51 @@
+package blnk
+
+import (
+	"context"
+
+	"github.com/northstar-pay/nucleus/config"
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// hotFlushBatchSize bounds how many deltas one flush statement folds into hot balances.
+const hotFlushBatchSize = 1000
+
+// isHotIndicator reports whether the indicator balance is configured to be created hot.
+func isHotIndicator(indicator string) bool {
+	cnf, err := config.Fetch()
+	if err != nil {
+		return false
+	}
+	for _, hot := range cnf.Transaction.HotIndicators {
+		if hot == indicator {
+			return true
+		}
+	}
+	return false
+}
+
+// noteHot remembers the balance if it is hot, so it can be recognised by ID before it is loaded again.
+func (l *Blnk) noteHot(balance *model.Balance) {
+	if balance != nil && balance.Hot {
+		l.hotBalances.Store(balance.BalanceID, struct{}{})
+	}
+}
+
+// isHot reports whether a balance ID is known to belong to a hot balance.
+func (l *Blnk) isHot(balanceID string) bool {
+	_, ok := l.hotBalances.Load(balanceID)
+	return ok
+}
+
+// FlushHotBalances folds every pending delta into its hot balance, one batch at a time.
+func (l *Blnk) FlushHotBalances(ctx context.Context) error {
+	for {
+		updated, err := l.datasource.FlushBalanceDeltas(ctx, hotFlushBatchSize)
+		if err != nil {
+			return err
+		}
+		if updated == 0 {
+			return nil
+		}
+	}
+}
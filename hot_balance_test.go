@@ -0,0 +1,84 @@
+package blnk
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brianvoe/gofakeit/v6"
+	redlock "github.com/northstar-pay/nucleus/internal/lock"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordTransactionRecordsDeltaForHotDestination(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	source := gofakeit.UUID()
+	destination := gofakeit.UUID()
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      source,
+		Destination: destination,
+		Amount:      "10",
+		Precision:   100,
+		Currency:    "NGN",
+	}
+
+	// The destination is known to be hot and another holder has it locked; crediting it must not wait.
+	d.hotBalances.Store(destination, struct{}{})
+	busy := redlock.NewLocker(d.redis, destination, "other")
+	require.NoError(t, busy.Lock(ctx, time.Minute))
+	defer func() { _ = busy.Unlock(ctx) }()
+
+	balanceColumns := []string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}
+	balanceQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "NGN", 2)
+	mock.ExpectQuery(balanceQuery).WithArgs(source).WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(source, "NGN", 100, "ledger-id", 10000, 10000, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+	mock.ExpectQuery(balanceQuery).WithArgs(destination).WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(destination, "NGN", 100, "ledger-id", 500, 500, 0, 0, 0, 0, time.Now(), 3, 0, true, 0, nil, "active", "", ""))
+	// Deltas not yet flushed count towards the hot balance.
+	mock.ExpectQuery("LEFT JOIN blnk.balance_deltas").WithArgs(destination).WillReturnRows(
+		sqlmock.NewRows([]string{"balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "version"}).
+			AddRow(700, 700, 0, 0, 0, 0, 3))
+
+	expectNoSpendingLimits(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE blnk.balances").WithArgs(source, 9000, 10000, 1000, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 0, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO blnk.balance_deltas").WithArgs(destination, sqlmock.AnyArg(), 1000, 1000, 0, 0, 0, 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("INSERT INTO blnk.transactions").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), source, txn.Reference, txn.Amount, 1000, txn.Precision,
+		json.Number("1"), txn.Currency, destination, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	_, err = d.RecordTransaction(ctx, txn)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFlushHotBalancesRunsUntilNothingIsPending(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	mock.ExpectExec("UPDATE blnk.balance_deltas SET flushed_at").WithArgs(hotFlushBatchSize).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE blnk.balance_deltas SET flushed_at").WithArgs(hotFlushBatchSize).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	assert.NoError(t, d.FlushHotBalances(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
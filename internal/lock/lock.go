@@ -58,15 +58,34 @@ func (l *Locker) ExtendLock(ctx context.Context, extension time.Duration) error
 	return nil
 }
 
+const (
+	minWaitBackoff = 10 * time.Millisecond
+	maxWaitBackoff = 500 * time.Millisecond
+)
+
+// WaitLock retries Lock until it succeeds or waitTimeout passes, backing off exponentially between attempts.
 func (l *Locker) WaitLock(ctx context.Context, lockTimeout, waitTimeout time.Duration) error {
 	deadline := time.Now().Add(waitTimeout)
-	for time.Now().Before(deadline) {
-		err := l.Lock(ctx, lockTimeout)
-		if err == nil {
+	backoff := minWaitBackoff
+	for {
+		acquired, err := l.client.SetNX(ctx, l.key, l.value, lockTimeout).Result()
+		if err != nil {
+			return err
+		}
+		if acquired {
 			return nil
 		}
-		// Implementing exponential backoff
-		time.Sleep(time.Duration(rand.Intn(100)) * time.Millisecond)
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("failed to acquire lock for key %s within the wait timeout", l.key)
+		}
+
+		// Jitter keeps waiters on the same key from retrying in lockstep.
+		sleep := backoff + time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sleep):
+		}
+		backoff = min(backoff*2, maxWaitBackoff)
 	}
-	return fmt.Errorf("failed to acquire lock for key %s within the wait timeout", l.key)
 }
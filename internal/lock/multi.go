@@ -0,0 +1,110 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// MultiLocker holds locks on several keys as one. Keys are always taken in sorted order, so two holders of
+// overlapping key sets contend for the keys they share in the same order and cannot deadlock. While held, the
+// locks are extended in the background so an operation that runs long does not outlive them.
+type MultiLocker struct {
+	lockers []*Locker
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewMultiLocker prepares a lock on every distinct key. value identifies the holder, as in NewLocker.
+func NewMultiLocker(client redis.UniversalClient, keys []string, value string) *MultiLocker {
+	sorted := sortedKeys(keys)
+	lockers := make([]*Locker, 0, len(sorted))
+	for _, key := range sorted {
+		lockers = append(lockers, NewLocker(client, key, value))
+	}
+	return &MultiLocker{lockers: lockers}
+}
+
+// Keys returns the locked keys in the order they are taken.
+func (m *MultiLocker) Keys() []string {
+	keys := make([]string, 0, len(m.lockers))
+	for _, locker := range m.lockers {
+		keys = append(keys, locker.key)
+	}
+	return keys
+}
+
+// Lock takes every key, waiting up to wait for each one. Each lock expires after ttl unless it is renewed,
+// which happens every ttl/3 until Unlock. If any key cannot be taken, the ones already held are released.
+func (m *MultiLocker) Lock(ctx context.Context, ttl, wait time.Duration) error {
+	for i, locker := range m.lockers {
+		if err := locker.WaitLock(ctx, ttl, wait); err != nil {
+			unlockAll(context.WithoutCancel(ctx), m.lockers[:i])
+			return err
+		}
+	}
+
+	m.stop = make(chan struct{})
+	m.wg.Add(1)
+	go m.keepAlive(context.WithoutCancel(ctx), ttl)
+	return nil
+}
+
+// Unlock stops renewing the locks and releases them.
+func (m *MultiLocker) Unlock(ctx context.Context) error {
+	if m.stop != nil {
+		close(m.stop)
+		m.wg.Wait()
+		m.stop = nil
+	}
+	return unlockAll(ctx, m.lockers)
+}
+
+func (m *MultiLocker) keepAlive(ctx context.Context, ttl time.Duration) {
+	defer m.wg.Done()
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			for _, locker := range m.lockers {
+				if err := locker.ExtendLock(ctx, ttl); err != nil {
+					logrus.Errorf("failed to renew lock: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// sortedKeys returns the distinct keys in the order they are locked.
+func sortedKeys(keys []string) []string {
+	unique := make(map[string]struct{}, len(keys))
+	sorted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := unique[key]; !ok {
+			unique[key] = struct{}{}
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// unlockAll releases the locks in the reverse of the order they were taken.
+func unlockAll(ctx context.Context, lockers []*Locker) error {
+	var errs []error
+	for i := len(lockers) - 1; i >= 0; i-- {
+		if err := lockers[i].Unlock(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
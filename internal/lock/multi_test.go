@@ -0,0 +1,93 @@
+package redlock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	mr := miniredis.RunT(t)
+	return mr, redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
+
+func TestMultiLockerOverlappingKeysDoNotDeadlock(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+
+	var holders, overlaps int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		keys := []string{"bln_a", "bln_b"}
+		if i%2 == 1 {
+			keys = []string{"bln_b", "bln_a"}
+		}
+		wg.Add(1)
+		go func(keys []string) {
+			defer wg.Done()
+			locker := NewMultiLocker(client, keys, "holder")
+			if !assert.NoError(t, locker.Lock(ctx, time.Second, 5*time.Second)) {
+				return
+			}
+			if atomic.AddInt32(&holders, 1) > 1 {
+				atomic.AddInt32(&overlaps, 1)
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&holders, -1)
+			assert.NoError(t, locker.Unlock(ctx))
+		}(keys)
+	}
+	wg.Wait()
+
+	assert.Zero(t, overlaps)
+}
+
+func TestMultiLockerReleasesHeldKeysWhenOneIsBusy(t *testing.T) {
+	mr, client := newTestClient(t)
+	ctx := context.Background()
+
+	busy := NewLocker(client, "bln_b", "other")
+	require.NoError(t, busy.Lock(ctx, time.Minute))
+
+	locker := NewMultiLocker(client, []string{"bln_b", "bln_a"}, "holder")
+	assert.Error(t, locker.Lock(ctx, time.Second, 50*time.Millisecond))
+	assert.False(t, mr.Exists("bln_a"))
+}
+
+func TestMultiLockerRenewsLocks(t *testing.T) {
+	mr, client := newTestClient(t)
+	ctx := context.Background()
+
+	ttl := 150 * time.Millisecond
+	locker := NewMultiLocker(client, []string{"bln_a"}, "holder")
+	require.NoError(t, locker.Lock(ctx, ttl, time.Second))
+
+	mr.FastForward(100 * time.Millisecond)
+	time.Sleep(80 * time.Millisecond)
+	assert.Equal(t, ttl, mr.TTL("bln_a"))
+
+	require.NoError(t, locker.Unlock(ctx))
+	assert.False(t, mr.Exists("bln_a"))
+}
+
+func TestWaitLockWaitsForRelease(t *testing.T) {
+	_, client := newTestClient(t)
+	ctx := context.Background()
+
+	holder := NewLocker(client, "bln_a", "first")
+	require.NoError(t, holder.Lock(ctx, time.Minute))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = holder.Unlock(ctx)
+	}()
+
+	waiter := NewLocker(client, "bln_a", "second")
+	assert.NoError(t, waiter.WaitLock(ctx, time.Minute, time.Second))
+}
@@ -0,0 +1,77 @@
+package redlock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"time"
+)
+
+// PostgresProvider locks keys with transaction-scoped advisory locks, so posting does not need Redis. Lock does
+// not take the keys itself: the database transaction that writes the locked balances takes them with
+// TakeAdvisoryLocks, so they are held on the connection the holder posts with rather than one of their own, and
+// released when it commits or rolls back. A balance read before its key was taken is guarded by the version
+// check on its update.
+type PostgresProvider struct{}
+
+func NewPostgresProvider() *PostgresProvider {
+	return &PostgresProvider{}
+}
+
+// PostgresLock is a set of advisory locks for the holder's database transactions to take before deadline, when
+// its wait runs out.
+type PostgresLock struct {
+	keys     []string
+	deadline time.Time
+}
+
+func (p *PostgresProvider) Lock(_ context.Context, keys []string, _, wait time.Duration) (Lock, error) {
+	return &PostgresLock{keys: sortedKeys(keys), deadline: time.Now().Add(wait)}, nil
+}
+
+// Unlock does nothing; the keys are released with the database transactions that took them.
+func (l *PostgresLock) Unlock(_ context.Context) error {
+	return nil
+}
+
+type lockContextKey struct{}
+
+// WithLock returns a copy of ctx carrying lock, for the database transactions its holder writes with.
+func WithLock(ctx context.Context, lock Lock) context.Context {
+	return context.WithValue(ctx, lockContextKey{}, lock)
+}
+
+// TakeAdvisoryLocks takes the keys of the postgres lock carried by ctx in tx, in order, and does nothing for
+// other locks. It waits for busy keys only as long as the lock's wait has left, however many of the holder's
+// transactions take them.
+func TakeAdvisoryLocks(ctx context.Context, tx *sql.Tx) error {
+	lock, ok := ctx.Value(lockContextKey{}).(*PostgresLock)
+	if !ok {
+		return nil
+	}
+
+	// A lock_timeout of zero waits forever, so the shortest wait is a millisecond.
+	timeout := time.Until(lock.deadline).Milliseconds()
+	if timeout < 1 {
+		timeout = 1
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL lock_timeout = '%dms'", timeout)); err != nil {
+		return err
+	}
+
+	for _, key := range lock.keys {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", advisoryKey(key)); err != nil {
+			return fmt.Errorf("lock for key %s is already held: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// advisoryKey maps a key onto the 64-bit space of advisory locks. Keys that collide share a lock, which only
+// serialises their holders.
+func advisoryKey(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,40 @@
+package redlock
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Lock is a set of keys held together until Unlock.
+type Lock interface {
+	Unlock(ctx context.Context) error
+}
+
+// Provider takes locks on sets of keys. Implementations take the keys of a set in a canonical order, so two
+// holders of overlapping sets cannot deadlock, wait up to wait for keys that are busy, and keep the keys held
+// for as long as the holder is alive. ttl bounds how long a lock outlives a holder that disappeared, where the
+// backend needs one. PostgresProvider leaves taking the keys to the database transactions the holder writes
+// with; see TakeAdvisoryLocks.
+type Provider interface {
+	Lock(ctx context.Context, keys []string, ttl, wait time.Duration) (Lock, error)
+}
+
+// RedisProvider locks keys in Redis with a MultiLocker.
+type RedisProvider struct {
+	client redis.UniversalClient
+}
+
+func NewRedisProvider(client redis.UniversalClient) *RedisProvider {
+	return &RedisProvider{client: client}
+}
+
+func (p *RedisProvider) Lock(ctx context.Context, keys []string, ttl, wait time.Duration) (Lock, error) {
+	locker := NewMultiLocker(p.client, keys, uuid.NewString())
+	if err := locker.Lock(ctx, ttl, wait); err != nil {
+		return nil, err
+	}
+	return locker, nil
+}
@@ -0,0 +1,197 @@
+package redlock
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProviderUnderLoad runs holders of overlapping key sets, given in different orders, against a provider
+// and checks that no two held a shared key at once and none deadlocked. Every provider must pass it the same.
+func testProviderUnderLoad(t *testing.T, provider Provider) {
+	ctx := context.Background()
+	keySets := [][]string{
+		{"bln_a", "bln_b"},
+		{"bln_b", "bln_c"},
+		{"bln_c", "bln_a"},
+		{"bln_b", "bln_a", "bln_b"},
+	}
+
+	held := make(map[string]*int32)
+	for _, key := range []string{"bln_a", "bln_b", "bln_c"} {
+		held[key] = new(int32)
+	}
+	var overlaps int32
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		keys := keySets[i%len(keySets)]
+		wg.Add(1)
+		go func(keys []string) {
+			defer wg.Done()
+			lock, err := provider.Lock(ctx, keys, time.Second, 10*time.Second)
+			if !assert.NoError(t, err) {
+				return
+			}
+			unique := sortedKeys(keys)
+			for _, key := range unique {
+				if atomic.AddInt32(held[key], 1) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+			}
+			time.Sleep(time.Millisecond)
+			for _, key := range unique {
+				atomic.AddInt32(held[key], -1)
+			}
+			assert.NoError(t, lock.Unlock(ctx))
+		}(keys)
+	}
+	wg.Wait()
+
+	assert.Zero(t, overlaps)
+
+	// A busy key makes the lock fail once the wait runs out, and the keys it did get are released.
+	busy, err := provider.Lock(ctx, []string{"bln_b"}, time.Second, time.Second)
+	require.NoError(t, err)
+	_, err = provider.Lock(ctx, []string{"bln_a", "bln_b"}, time.Second, 50*time.Millisecond)
+	assert.Error(t, err)
+	free, err := provider.Lock(ctx, []string{"bln_a"}, time.Second, 50*time.Millisecond)
+	require.NoError(t, err)
+	assert.NoError(t, free.Unlock(ctx))
+	assert.NoError(t, busy.Unlock(ctx))
+}
+
+func TestRedisProviderUnderLoad(t *testing.T) {
+	_, client := newTestClient(t)
+	testProviderUnderLoad(t, NewRedisProvider(client))
+}
+
+// TestPostgresProviderUnderLoad needs a database; set BLNK_TEST_POSTGRES_DSN to run it. Each holder takes its
+// keys in a database transaction of its own, as postings do.
+func TestPostgresProviderUnderLoad(t *testing.T) {
+	dsn := os.Getenv("BLNK_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("BLNK_TEST_POSTGRES_DSN is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	db.SetMaxOpenConns(50)
+
+	testProviderUnderLoad(t, &txProvider{db: db})
+}
+
+// txProvider holds a PostgresLock's keys in a database transaction from Lock to Unlock.
+type txProvider struct {
+	db *sql.DB
+}
+
+type txLock struct {
+	tx *sql.Tx
+}
+
+func (p *txProvider) Lock(ctx context.Context, keys []string, ttl, wait time.Duration) (Lock, error) {
+	lock, err := NewPostgresProvider().Lock(ctx, keys, ttl, wait)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := TakeAdvisoryLocks(WithLock(ctx, lock), tx); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return &txLock{tx: tx}, nil
+}
+
+func (l *txLock) Unlock(_ context.Context) error {
+	return l.tx.Commit()
+}
+
+func TestTakeAdvisoryLocksInOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET LOCAL lock_timeout = '\d+ms'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, key := range []string{"bln_a", "bln_b"} {
+		mock.ExpectExec(`SELECT pg_advisory_xact_lock\(\$1\)`).WithArgs(advisoryKey(key)).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	lock, err := NewPostgresProvider().Lock(ctx, []string{"bln_b", "bln_a", "bln_b"}, time.Second, time.Second)
+	require.NoError(t, err)
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, TakeAdvisoryLocks(WithLock(ctx, lock), tx))
+	assert.NoError(t, tx.Commit())
+	assert.NoError(t, lock.Unlock(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTakeAdvisoryLocksFailsWhenAKeyIsBusy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL lock_timeout").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(\$1\)`).WithArgs(advisoryKey("bln_a")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(\$1\)`).WithArgs(advisoryKey("bln_b")).WillReturnError(errors.New("canceling statement due to lock timeout"))
+
+	lock, err := NewPostgresProvider().Lock(ctx, []string{"bln_a", "bln_b"}, time.Second, time.Second)
+	require.NoError(t, err)
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	assert.ErrorContains(t, TakeAdvisoryLocks(WithLock(ctx, lock), tx), "lock for key bln_b is already held")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// The wait is one budget from Lock on: a transaction that takes the keys after it ran out waits the shortest
+// lock_timeout rather than the whole wait again.
+func TestTakeAdvisoryLocksSpendsTheLocksWait(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SET LOCAL lock_timeout = '1ms'").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(\$1\)`).WithArgs(advisoryKey("bln_a")).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	lock, err := NewPostgresProvider().Lock(ctx, []string{"bln_a"}, time.Second, 20*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	assert.NoError(t, TakeAdvisoryLocks(WithLock(ctx, lock), tx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTakeAdvisoryLocksIgnoresOtherLocks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	_, client := newTestClient(t)
+	ctx := context.Background()
+
+	lock, err := NewRedisProvider(client).Lock(ctx, []string{"bln_a"}, time.Second, time.Second)
+	require.NoError(t, err)
+	mock.ExpectBegin()
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	assert.NoError(t, TakeAdvisoryLocks(WithLock(ctx, lock), tx))
+	assert.NoError(t, TakeAdvisoryLocks(ctx, tx))
+	assert.NoError(t, lock.Unlock(ctx))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,154 @@
+package blnk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/northstar-pay/nucleus/internal/notification"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// RecordJournalEntry posts a balanced set of debit and credit lines. Every balance the entry touches is locked,
+// and all balance updates and the entry itself are written in one database transaction.
+func (l *Blnk) RecordJournalEntry(ctx context.Context, entry *model.JournalEntry) (*model.JournalEntry, error) {
+	ctx, span := tracer.Start(ctx, "Recording journal entry")
+	defer span.End()
+
+	for i := range entry.Lines {
+		precision, err := l.resolvePrecision(entry.Lines[i].Currency, entry.Lines[i].Precision)
+		if err != nil {
+			return nil, l.logAndRecordError(span, "currency validation failed", err)
+		}
+		entry.Lines[i].Precision = precision
+	}
+
+	if err := entry.Validate(); err != nil {
+		return nil, l.logAndRecordError(span, "journal entry validation failed", err)
+	}
+
+	lockKeys := make([]string, 0, len(entry.Lines))
+	for _, line := range entry.Lines {
+		key, err := l.balanceLockKey(line.BalanceID, line.Currency)
+		if err != nil {
+			return nil, l.logAndRecordError(span, "failed to get balance", err)
+		}
+		lockKeys = append(lockKeys, key)
+	}
+	ctx, locker, err := l.lockBalances(ctx, lockKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer l.releaseLock(ctx, locker)
+
+	loaded := make(map[string]*model.Balance)
+	for i := range entry.Lines {
+		line := &entry.Lines[i]
+		balance, ok := loaded[line.BalanceID]
+		if !ok {
+			balance, err = l.resolveBalance(line.BalanceID, line.Currency)
+			if err != nil {
+				return nil, l.logAndRecordError(span, "failed to get balance", err)
+			}
+			loaded[line.BalanceID] = balance
+		}
+		line.BalanceID = balance.BalanceID
+		loaded[balance.BalanceID] = balance
+	}
+
+	if err := l.checkJournalLimits(ctx, entry, loaded); err != nil {
+		return nil, l.logAndRecordError(span, "limit check failed", err)
+	}
+
+	if err := model.ApplyJournalEntry(entry, loaded); err != nil {
+		return nil, l.logAndRecordError(span, "failed to apply journal entry", err)
+	}
+
+	entry.EntryID = model.GenerateUUIDWithSuffix("jnl")
+	entry.Status = StatusApplied
+	entry.CreatedAt = time.Now()
+	for i := range entry.Lines {
+		entry.Lines[i].LineID = model.GenerateUUIDWithSuffix("jln")
+		entry.Lines[i].EntryID = entry.EntryID
+	}
+
+	balances := uniqueBalances(loaded)
+	if err := l.datasource.RecordJournalEntry(ctx, entry, balances); err != nil {
+		return nil, l.logAndRecordError(span, "failed to persist journal entry", err)
+	}
+
+	for _, balance := range balances {
+		go l.checkBalanceMonitors(balance)
+	}
+	go func() {
+		err := SendWebhook(NewWebhook{
+			Event:   "journal_entry.applied",
+			Payload: entry,
+		})
+		if err != nil {
+			notification.NotifyError(err)
+		}
+	}()
+
+	return entry, nil
+}
+
+// checkJournalLimits runs each debit line past the spending limits of its balance, as if it were a transaction
+// from that balance, and sets the entry's limit charges. Every line is also held to the caps of its balance's KYC
+// tier; an entry cannot be held, so a credit past a max balance is rejected whatever the tier's cap action. Lines
+// of one entry share their limits' usage, and credits to the same balance count together against its max balance.
+func (l *Blnk) checkJournalLimits(ctx context.Context, entry *model.JournalEntry, balances map[string]*model.Balance) error {
+	entry.LimitCharges = nil
+	used := make(map[string]model.LimitUsage)
+	credited := make(map[string]int64)
+	for _, line := range entry.Lines {
+		balance := balances[line.BalanceID]
+		tier, err := l.balanceTier(ctx, balance)
+		if err != nil {
+			return err
+		}
+		if tier != nil {
+			if err := tier.CheckTransaction(balance.BalanceID, line.PreciseAmount); err != nil {
+				return err
+			}
+		}
+
+		if line.Direction != model.DirectionDebit {
+			if tier != nil {
+				credited[balance.BalanceID] += line.PreciseAmount
+				if err := tier.CheckBalance(balance, credited[balance.BalanceID]); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		posting := &model.Transaction{Reference: entry.Reference, Source: balance.BalanceID, PreciseAmount: line.PreciseAmount,
+			Precision: line.Precision, Currency: line.Currency}
+		if err := l.checkSpendingLimits(ctx, posting, balance, tier, used); err != nil {
+			return err
+		}
+		entry.LimitCharges = append(entry.LimitCharges, posting.LimitCharges...)
+	}
+	return nil
+}
+
+func (l *Blnk) GetJournalEntry(id string) (*model.JournalEntry, error) {
+	return l.datasource.GetJournalEntry(id)
+}
+
+// uniqueBalances flattens a lookup map that may hold the same balance under several keys, sorted by balance ID.
+func uniqueBalances(loaded map[string]*model.Balance) []*model.Balance {
+	seen := make(map[string]bool, len(loaded))
+	balances := make([]*model.Balance, 0, len(loaded))
+	for _, balance := range loaded {
+		if seen[balance.BalanceID] {
+			continue
+		}
+		seen[balance.BalanceID] = true
+		balances = append(balances, balance)
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].BalanceID < balances[j].BalanceID })
+	return balances
+}
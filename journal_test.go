@@ -0,0 +1,76 @@
+package blnk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordJournalEntryRejectedBySpendingLimit(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	payroll, employee := gofakeit.UUID(), gofakeit.UUID()
+	entry := &model.JournalEntry{
+		Reference: gofakeit.UUID(),
+		Lines: []model.JournalLine{
+			{BalanceID: payroll, Direction: model.DirectionDebit, Amount: "100", Currency: "USD"},
+			{BalanceID: employee, Direction: model.DirectionCredit, Amount: "100", Currency: "USD"},
+		},
+	}
+	limit := model.SpendingLimit{LimitID: "lim_daily", Scope: model.LimitScopeBalance, ScopeID: payroll, Window: model.LimitWindowDaily, Currency: "USD", MaxAmount: 50000}
+
+	expectCurrencyLookup(mock, "USD", 2)
+	expectCurrencyLookup(mock, "USD", 2)
+	expectBalanceLite(mock, payroll, 100000, 0, model.BalanceActive)
+	expectBalanceLite(mock, employee, 0, 0, model.BalanceActive)
+	// 100.00 on top of the 450.00 the balance already sent today goes past its 500.00 cap.
+	expectSpendingLimit(mock, payroll, limit, model.LimitUsage{Amount: 45000, Count: 3})
+
+	_, err = d.RecordJournalEntry(context.Background(), entry)
+	var limitErr *model.LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "daily_amount_limit_exceeded", limitErr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordJournalEntryRejectedByTierMaxBalance(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	payroll, employee, identityID := gofakeit.UUID(), gofakeit.UUID(), "idt_basic"
+	// The tier holds transactions over its max balance, but a journal entry cannot be held.
+	tier := model.KYCTier{TierID: "tier_basic", Name: "basic", Currency: "USD", MaxBalance: 50000, CapAction: model.TierCapHold}
+	entry := &model.JournalEntry{
+		Reference: gofakeit.UUID(),
+		Lines: []model.JournalLine{
+			{BalanceID: payroll, Direction: model.DirectionDebit, Amount: "110", Currency: "USD"},
+			{BalanceID: employee, Direction: model.DirectionCredit, Amount: "60", Currency: "USD"},
+			{BalanceID: employee, Direction: model.DirectionCredit, Amount: "50", Currency: "USD"},
+		},
+	}
+
+	for range entry.Lines {
+		expectCurrencyLookup(mock, "USD", 2)
+	}
+	expectBalanceLite(mock, payroll, 100000, 0, model.BalanceActive)
+	expectIdentityBalanceLite(mock, employee, 40000, identityID)
+	expectNoSpendingLimits(mock)
+	expectIdentityTier(mock, identityID, tier)
+	// 400.00 plus the first 60.00 fits under the 500.00 cap; the second credit of 50.00 does not.
+	expectIdentityTier(mock, identityID, tier)
+
+	_, err = d.RecordJournalEntry(context.Background(), entry)
+	var tierErr *model.TierError
+	require.ErrorAs(t, err, &tierErr)
+	assert.Equal(t, "tier_max_balance_exceeded", tierErr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
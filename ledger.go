@@ -6,8 +6,14 @@ func (l *Blnk) CreateLedger(ledger model.Ledger) (model.Ledger, error) {
 	return l.datasource.CreateLedger(ledger)
 }
 
-func (l *Blnk) GetAllLedgers() ([]model.Ledger, error) {
-	return l.datasource.GetAllLedgers()
+// GetAllLedgers returns one page of ledgers matching the filter, newest first.
+func (l *Blnk) GetAllLedgers(filter model.LedgerFilter, limit int) (model.Page[model.Ledger], error) {
+	limit = model.PageLimit(limit)
+	ledgers, err := l.datasource.GetAllLedgers(filter, limit+1)
+	if err != nil {
+		return model.Page[model.Ledger]{}, err
+	}
+	return model.NewPage(ledgers, limit, func(ledger model.Ledger) int64 { return ledger.ID }), nil
 }
 
 func (l *Blnk) GetLedgerByID(id string) (*model.Ledger, error) {
@@ -77,16 +77,17 @@ func TestGetAllLedgers(t *testing.T) {
 		t.Fatalf("Error creating Blnk instance: %s", err)
 	}
 
-	rows := sqlmock.NewRows([]string{"ledger_id", "name", "created_at", "meta_data"}).
-		AddRow("ldg_1234567", "general ledger", time.Now(), `{"key":"value"}`)
+	rows := sqlmock.NewRows([]string{"id", "ledger_id", "name", "created_at", "meta_data"}).
+		AddRow(1, "ldg_1234567", "general ledger", time.Now(), `{"key":"value"}`)
 
-	mock.ExpectQuery("SELECT ledger_id,name, created_at, meta_data FROM ledgers LIMIT 20").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT id, ledger_id, name, created_at, meta_data FROM blnk.ledgers ORDER BY id DESC LIMIT \\$1").WithArgs(21).WillReturnRows(rows)
 
-	result, err := d.GetAllLedgers()
+	result, err := d.GetAllLedgers(model.LedgerFilter{}, 0)
 
 	assert.NoError(t, err)
-	assert.Len(t, result, 1)
-	assert.Equal(t, "ldg_1234567", result[0].LedgerID)
+	assert.Len(t, result.Data, 1)
+	assert.Equal(t, "ldg_1234567", result.Data[0].LedgerID)
+	assert.Empty(t, result.NextCursor)
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
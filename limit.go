@@ -0,0 +1,166 @@
+package blnk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// CreateSpendingLimit adds a limit to a balance, identity or ledger. maxAmount is the amount cap in major units
+// of the limit's currency; when it is empty, limit.MaxAmount is taken to be in minor units already.
+func (l *Blnk) CreateSpendingLimit(limit model.SpendingLimit, maxAmount json.Number) (model.SpendingLimit, error) {
+	if maxAmount != "" {
+		if limit.Currency == "" {
+			return model.SpendingLimit{}, errors.New("an amount limit needs a currency")
+		}
+		precision, err := l.resolvePrecision(limit.Currency, 0)
+		if err != nil {
+			return model.SpendingLimit{}, err
+		}
+		limit.MaxAmount, err = model.ToPrecise(maxAmount, precision)
+		if err != nil {
+			return model.SpendingLimit{}, err
+		}
+	}
+	if err := limit.Validate(); err != nil {
+		return model.SpendingLimit{}, err
+	}
+	if err := l.checkLimitScope(limit); err != nil {
+		return model.SpendingLimit{}, err
+	}
+
+	limit.LimitID = model.GenerateUUIDWithSuffix("lim")
+	limit.CreatedAt = time.Now()
+	return l.datasource.CreateSpendingLimit(limit)
+}
+
+// checkLimitScope makes sure the balance, identity or ledger a limit is for exists.
+func (l *Blnk) checkLimitScope(limit model.SpendingLimit) error {
+	var err error
+	switch limit.Scope {
+	case model.LimitScopeBalance:
+		_, err = l.datasource.GetBalanceByIDLite(limit.ScopeID)
+	case model.LimitScopeIdentity:
+		_, err = l.datasource.GetIdentityByID(limit.ScopeID)
+	case model.LimitScopeLedger:
+		_, err = l.datasource.GetLedgerByID(limit.ScopeID)
+	}
+	return err
+}
+
+func (l *Blnk) GetSpendingLimit(id string) (*model.SpendingLimit, error) {
+	return l.datasource.GetSpendingLimit(id)
+}
+
+func (l *Blnk) GetAllSpendingLimits(filter model.SpendingLimitFilter, limit int) (model.Page[model.SpendingLimit], error) {
+	limit = model.PageLimit(limit)
+	limits, err := l.datasource.GetAllSpendingLimits(filter, limit+1)
+	if err != nil {
+		return model.Page[model.SpendingLimit]{}, err
+	}
+	return model.NewPage(limits, limit, func(spendingLimit model.SpendingLimit) int64 { return spendingLimit.ID }), nil
+}
+
+func (l *Blnk) DeleteSpendingLimit(id string) error {
+	return l.datasource.DeleteSpendingLimit(id)
+}
+
+// GetBalanceAllowance reports, for every limit that applies to a balance, how much the balance may still send
+// before the limit's window resets.
+func (l *Blnk) GetBalanceAllowance(ctx context.Context, balanceID string) ([]model.LimitAllowance, error) {
+	balance, err := l.datasource.GetBalanceByIDLite(balanceID)
+	if err != nil {
+		return nil, err
+	}
+	tier, err := l.balanceTier(ctx, balance)
+	if err != nil {
+		return nil, err
+	}
+	limits, err := l.balanceSpendingLimits(ctx, balance, tier)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	allowances := make([]model.LimitAllowance, 0, len(limits))
+	for _, limit := range limits {
+		start, _ := limit.Period(now)
+		usage, err := l.datasource.GetSpendingLimitUsage(ctx, limit, start)
+		if err != nil {
+			return nil, err
+		}
+		allowances = append(allowances, limit.Allowance(usage, now))
+	}
+	return allowances, nil
+}
+
+// balanceSpendingLimits returns the limits set on a balance, its identity and its ledger, along with the daily
+// volume of tier, the tier that binds the balance, if it has one.
+func (l *Blnk) balanceSpendingLimits(ctx context.Context, balance *model.Balance, tier *model.KYCTier) ([]model.SpendingLimit, error) {
+	limits, err := l.datasource.GetBalanceSpendingLimits(ctx, balance)
+	if err != nil {
+		return nil, err
+	}
+	if tier != nil {
+		if limit, ok := tier.DailyLimit(balance.IdentityID); ok {
+			limits = append(limits, limit)
+		}
+	}
+	return limits, nil
+}
+
+// checkSpendingLimits returns a *model.LimitError when the transaction would take its source past one of the
+// limits that apply to it, the daily volume of tier included, and otherwise sets the transaction's limit charges
+// so posting it adds to those limits' usage. used holds what earlier legs of the same batch have taken from each
+// limit, which is not in the database yet, and is updated with this transaction; it is nil for a single
+// transaction. The check here rejects early; the limit is enforced when the charges are written.
+func (l *Blnk) checkSpendingLimits(ctx context.Context, transaction *model.Transaction, source *model.Balance, tier *model.KYCTier, used map[string]model.LimitUsage) error {
+	transaction.LimitCharges = nil
+	limits, err := l.balanceSpendingLimits(ctx, source, tier)
+	if err != nil || len(limits) == 0 {
+		return err
+	}
+	amount, err := model.ApplyPrecision(transaction)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	charges := make([]model.LimitCharge, 0, len(limits))
+	for _, limit := range limits {
+		if !limit.AppliesTo(transaction.Currency) {
+			continue
+		}
+		start, _ := limit.Period(now)
+		usage, err := l.datasource.GetSpendingLimitUsage(ctx, limit, start)
+		if err != nil {
+			return err
+		}
+		pending := used[limitUsageKey(limit)]
+		usage.Amount += pending.Amount
+		usage.Count += pending.Count
+		if err := limit.Check(usage, amount); err != nil {
+			return err
+		}
+		charges = append(charges, model.LimitCharge{Limit: limit, WindowStart: start, Amount: amount})
+	}
+
+	if used != nil {
+		for _, charge := range charges {
+			key := limitUsageKey(charge.Limit)
+			pending := used[key]
+			used[key] = model.LimitUsage{Amount: pending.Amount + amount, Count: pending.Count + 1}
+		}
+	}
+	transaction.LimitCharges = charges
+	return nil
+}
+
+// limitUsageKey tells limits apart in a batch's usage. A tier's daily volume is one limit per identity on the
+// tier, so the scope is part of the key.
+func limitUsageKey(limit model.SpendingLimit) string {
+	return limit.LimitID + ":" + limit.ScopeID
+}
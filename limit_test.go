@@ -0,0 +1,202 @@
+package blnk
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var spendingLimitColumns = []string{"id", "limit_id", "scope", "scope_id", "period", "currency", "max_amount", "max_count", "created_at", "meta_data"}
+
+// expectNoSpendingLimits expects a transaction's source to be checked against spending limits and none to apply.
+func expectNoSpendingLimits(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.spending_limits`)).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(spendingLimitColumns))
+}
+
+// expectSpendingLimit expects a balance in ledger-id to be covered by a single limit, with the given usage so far
+// in the limit's window.
+func expectSpendingLimit(mock sqlmock.Sqlmock, balanceID string, limit model.SpendingLimit, usage model.LimitUsage) {
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.spending_limits`)).
+		WithArgs(model.LimitScopeBalance, balanceID, model.LimitScopeIdentity, "", model.LimitScopeLedger, "ledger-id").
+		WillReturnRows(sqlmock.NewRows(spendingLimitColumns).
+			AddRow(1, limit.LimitID, limit.Scope, limit.ScopeID, limit.Window, limit.Currency, limit.MaxAmount, limit.MaxCount, time.Now(), nil))
+	expectLimitUsage(mock, limit, usage)
+}
+
+// expectLimitUsage expects the usage of a limit in its current window to be read.
+func expectLimitUsage(mock sqlmock.Sqlmock, limit model.SpendingLimit, usage model.LimitUsage) {
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.spending_limit_usage`)).WithArgs(limit.LimitID, limit.ScopeID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"amount", "count"}).AddRow(usage.Amount, usage.Count))
+}
+
+func TestRecordTransactionRejectedBySpendingLimit(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	source, destination := gofakeit.UUID(), gofakeit.UUID()
+	txn := &model.Transaction{Reference: gofakeit.UUID(), Source: source, Destination: destination, Amount: "10", Currency: "USD"}
+	limit := model.SpendingLimit{LimitID: "lim_daily", Scope: model.LimitScopeLedger, ScopeID: "ledger-id", Window: model.LimitWindowDaily, Currency: "USD", MaxAmount: 1500}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "USD", 2)
+	expectBalanceLite(mock, source, 10000, 0, model.BalanceActive)
+	expectBalanceLite(mock, destination, 0, 0, model.BalanceActive)
+	// 10.00 on top of the 10.00 the ledger already sent today goes past its 15.00 cap.
+	expectSpendingLimit(mock, source, limit, model.LimitUsage{Amount: 1000, Count: 1})
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, model.ErrLimitExceeded)
+
+	var rejection *RejectionError
+	require.ErrorAs(t, ClassifyTransactionError(err), &rejection)
+	assert.Equal(t, "daily_amount_limit_exceeded", rejection.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordTransactionRejectedWhenLimitChargeFails(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	source, destination := gofakeit.UUID(), gofakeit.UUID()
+	txn := &model.Transaction{Reference: gofakeit.UUID(), Source: source, Destination: destination, Amount: "10", Currency: "USD"}
+	limit := model.SpendingLimit{LimitID: "lim_daily", Scope: model.LimitScopeLedger, ScopeID: "ledger-id", Window: model.LimitWindowDaily, Currency: "USD", MaxAmount: 1500}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "USD", 2)
+	expectBalanceLite(mock, source, 10000, 0, model.BalanceActive)
+	expectBalanceLite(mock, destination, 0, 0, model.BalanceActive)
+	expectSpendingLimit(mock, source, limit, model.LimitUsage{})
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).
+		WithArgs(source, 9000, 10000, 1000, 0, 0, 0, "USD", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).
+		WithArgs(destination, 1000, 1000, 0, 0, 0, 0, "USD", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// Another balance in the ledger sent 10.00 since the check, so the charge no longer fits under the cap.
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.spending_limit_usage`)).
+		WithArgs(limit.LimitID, limit.ScopeID, sqlmock.AnyArg(), int64(1000), limit.MaxAmount, limit.MaxCount).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectLimitUsage(mock, limit, model.LimitUsage{Amount: 1000, Count: 1})
+	mock.ExpectRollback()
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	var limitErr *model.LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "daily_amount_limit_exceeded", limitErr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestInflightTransactionKeepsItsLimitCharges(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	source, destination := gofakeit.UUID(), gofakeit.UUID()
+	txn := &model.Transaction{Reference: gofakeit.UUID(), Source: source, Destination: destination, Amount: "10", Currency: "USD", Inflight: true}
+	limit := model.SpendingLimit{LimitID: "lim_daily", Scope: model.LimitScopeLedger, ScopeID: "ledger-id", Window: model.LimitWindowDaily, Currency: "USD", MaxAmount: 1500}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "USD", 2)
+	expectBalanceLite(mock, source, 10000, 0, model.BalanceActive)
+	expectBalanceLite(mock, destination, 0, 0, model.BalanceActive)
+	expectSpendingLimit(mock, source, limit, model.LimitUsage{})
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).
+		WithArgs(source, 10000, 10000, 0, -1000, 0, 1000, "USD", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).
+		WithArgs(destination, 0, 0, 0, 1000, 1000, 0, "USD", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.spending_limit_usage`)).
+		WithArgs(limit.LimitID, limit.ScopeID, sqlmock.AnyArg(), int64(1000), limit.MaxAmount, limit.MaxCount).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// The charge is kept against the transaction, so voiding it can give it back.
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.spending_limit_charges`)).
+		WithArgs(sqlmock.AnyArg(), limit.LimitID, limit.ScopeID, sqlmock.AnyArg(), int64(1000)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.transactions`)).WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), source, txn.Reference,
+		sqlmock.AnyArg(), 1000, sqlmock.AnyArg(), sqlmock.AnyArg(), "USD", destination, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+		sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAtomicLegsShareSpendingLimit(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	source, first, second := gofakeit.UUID(), gofakeit.UUID(), gofakeit.UUID()
+	txn := &model.Transaction{
+		Reference: gofakeit.UUID(),
+		Source:    source,
+		Amount:    "10",
+		Currency:  "USD",
+		Atomic:    true,
+		Destinations: []model.Distribution{
+			{Identifier: first, Distribution: "50%"},
+			{Identifier: second, Distribution: "left"},
+		},
+	}
+	limit := model.SpendingLimit{LimitID: "lim_count", Scope: model.LimitScopeBalance, ScopeID: source, Window: model.LimitWindowWeekly, MaxCount: 1}
+	existsQuery := regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)
+
+	expectCurrencyLookup(mock, "USD", 2)
+	mock.ExpectQuery(existsQuery).WithArgs(txn.Reference + "-1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectBalanceLite(mock, source, 10000, 0, model.BalanceActive)
+	expectBalanceLite(mock, first, 0, 0, model.BalanceActive)
+	expectSpendingLimit(mock, source, limit, model.LimitUsage{})
+	// The first leg is not in the database yet, but still uses up the limit's only transaction.
+	mock.ExpectQuery(existsQuery).WithArgs(txn.Reference + "-2").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectBalanceLite(mock, second, 0, 0, model.BalanceActive)
+	expectSpendingLimit(mock, source, limit, model.LimitUsage{})
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	var limitErr *model.LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "weekly_count_limit_exceeded", limitErr.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBalanceAllowance(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	balanceID := gofakeit.UUID()
+	limit := model.SpendingLimit{LimitID: "lim_monthly", Scope: model.LimitScopeBalance, ScopeID: balanceID, Window: model.LimitWindowMonthly, Currency: "USD", MaxAmount: 50000, MaxCount: 20}
+	expectBalanceLite(mock, balanceID, 10000, 0, model.BalanceActive)
+	expectSpendingLimit(mock, balanceID, limit, model.LimitUsage{Amount: 12000, Count: 5})
+
+	allowances, err := d.GetBalanceAllowance(context.Background(), balanceID)
+	require.NoError(t, err)
+	require.Len(t, allowances, 1)
+	assert.Equal(t, int64(38000), *allowances[0].RemainingAmount)
+	assert.Equal(t, int64(15), *allowances[0].RemainingCount)
+	assert.True(t, allowances[0].ResetsAt.After(time.Now()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
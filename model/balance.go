@@ -5,6 +5,16 @@ import (
 	"time"
 )
 
+// Balance statuses. A frozen balance can neither send nor receive, a debit-blocked balance can only receive and
+// a credit-blocked balance can only send. Closed is final.
+const (
+	BalanceActive        = "active"
+	BalanceFrozen        = "frozen"
+	BalanceDebitBlocked  = "debit_blocked"
+	BalanceCreditBlocked = "credit_blocked"
+	BalanceClosed        = "closed"
+)
+
 type Balance struct {
 	ID                    int64                  `json:"-"`
 	Balance               int64                  `json:"balance"`
@@ -14,6 +24,14 @@ type Balance struct {
 	InflightCreditBalance int64                  `json:"inflight_credit_balance"`
 	DebitBalance          int64                  `json:"debit_balance"`
 	InflightDebitBalance  int64                  `json:"inflight_debit_balance"`
+	ReservedBalance       int64                  `json:"reserved_balance"`
+	AvailableBalance      int64                  `json:"available_balance"`
+	OverdraftLimit        int64                  `json:"overdraft_limit"`
+	OverdraftExpiresAt    *time.Time             `json:"overdraft_expires_at,omitempty"`
+	AvailableCredit       int64                  `json:"available_credit"`
+	Hot                   bool                   `json:"hot"`
+	Status                string                 `json:"status"`
+	StatusReason          string                 `json:"status_reason,omitempty"`
 	CurrencyMultiplier    float64                `json:"precision"`
 	LedgerID              string                 `json:"ledger_id"`
 	IdentityID            string                 `json:"identity_id"`
@@ -25,6 +43,52 @@ type Balance struct {
 	CreatedAt             time.Time              `json:"created_at"`
 	InflighExpiresAt      time.Time              `json:"inflight_expires_at"`
 	MetaData              map[string]interface{} `json:"meta_data"`
+
+	// baseline holds the figures a hot balance was loaded with, so what a transaction changed can be recorded
+	// as a delta.
+	baseline BalanceDelta
+}
+
+// BalanceDelta is a change to the figures of a hot balance. Transactions record deltas instead of updating a
+// hot balance's row, and the deltas are folded into the row in batches.
+type BalanceDelta struct {
+	BalanceID             string `json:"balance_id"`
+	TransactionID         string `json:"transaction_id"`
+	Balance               int64  `json:"balance"`
+	CreditBalance         int64  `json:"credit_balance"`
+	DebitBalance          int64  `json:"debit_balance"`
+	InflightBalance       int64  `json:"inflight_balance"`
+	InflightCreditBalance int64  `json:"inflight_credit_balance"`
+	InflightDebitBalance  int64  `json:"inflight_debit_balance"`
+}
+
+// BalanceSnapshot is an immutable copy of a balance's figures after one mutation, tied to the transaction,
+// transaction group or journal entry that caused it.
+type BalanceSnapshot struct {
+	BalanceID             string    `json:"balance_id"`
+	Version               int64     `json:"version"`
+	TransactionID         string    `json:"transaction_id"`
+	Balance               int64     `json:"balance"`
+	CreditBalance         int64     `json:"credit_balance"`
+	DebitBalance          int64     `json:"debit_balance"`
+	InflightBalance       int64     `json:"inflight_balance"`
+	InflightCreditBalance int64     `json:"inflight_credit_balance"`
+	InflightDebitBalance  int64     `json:"inflight_debit_balance"`
+	Currency              string    `json:"currency"`
+	CreatedAt             time.Time `json:"created_at"`
+}
+
+// BalanceStatusChange is one entry in the audit trail of a balance's status. SweepTransactionID is the
+// transaction that moved a closed balance's remainder out, if there was one.
+type BalanceStatusChange struct {
+	ID                 int64     `json:"-"`
+	BalanceID          string    `json:"balance_id"`
+	FromStatus         string    `json:"from_status"`
+	ToStatus           string    `json:"to_status"`
+	Reason             string    `json:"reason"`
+	ChangedBy          string    `json:"changed_by,omitempty"`
+	SweepTransactionID string    `json:"sweep_transaction_id,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
 }
 
 type BalanceMonitor struct {
@@ -36,6 +100,7 @@ type BalanceMonitor struct {
 	Condition   AlertCondition `json:"condition"`
 }
 
+// BalanceFilter narrows a balance listing. ID is the position to continue after; the ranges use ParseRange syntax.
 type BalanceFilter struct {
 	ID                 int64     `json:"id"`
 	BalanceRange       string    `json:"balance_range"`
@@ -43,6 +108,7 @@ type BalanceFilter struct {
 	DebitBalanceRange  string    `json:"debit_balance_range"`
 	Currency           string    `json:"currency"`
 	LedgerID           string    `json:"ledger_id"`
+	IdentityID         string    `json:"identity_id"`
 	From               time.Time `json:"from"`
 	To                 time.Time `json:"to"`
 }
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// MaxMinorUnits is the most decimal places a currency can have while its minor units still fit in an int64.
+const MaxMinorUnits = 18
+
+type Currency struct {
+	ID         int64                  `json:"-"`
+	Code       string                 `json:"code"`
+	Name       string                 `json:"name"`
+	Symbol     string                 `json:"symbol"`
+	MinorUnits int                    `json:"minor_units"`
+	CreatedAt  time.Time              `json:"created_at"`
+	MetaData   map[string]interface{} `json:"meta_data"`
+}
+
+// Precision returns the multiplier that turns an amount in this currency into minor units, e.g. 100 for 2 decimal places.
+func (currency *Currency) Precision() int64 {
+	precision := int64(1)
+	for i := 0; i < currency.MinorUnits; i++ {
+		precision *= 10
+	}
+	return precision
+}
@@ -0,0 +1,20 @@
+package model
+
+import "errors"
+
+var (
+	// ErrInsufficientFunds is returned when a debit would take a balance below what it has available.
+	ErrInsufficientFunds = errors.New("insufficient funds in source balance")
+	// ErrInvalidAmount is returned for transactions that do not move a positive amount.
+	ErrInvalidAmount = errors.New("transaction amount must be positive")
+	// ErrBalanceStatus is returned when a balance's status does not allow it to be debited or credited.
+	ErrBalanceStatus = errors.New("balance status does not allow this transaction")
+	// ErrNotFound is wrapped by lookups that find no matching record.
+	ErrNotFound = errors.New("not found")
+	// ErrPrecisionMismatch is returned when a precision differs from the one registered for its currency.
+	ErrPrecisionMismatch = errors.New("precision mismatch")
+	// ErrInvalidDistribution is returned when a transaction's sources or destinations cannot be split into legs.
+	ErrInvalidDistribution = errors.New("invalid distribution")
+	// ErrQuoteUsed is returned when a quote has already been posted with another transaction.
+	ErrQuoteUsed = errors.New("quote has already been used")
+)
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Page is one slice of a list endpoint. NextCursor is empty on the last page.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NewPage trims rows fetched with limit+1 down to limit and derives the next cursor from the last kept row.
+func NewPage[T any](rows []T, limit int, id func(T) int64) Page[T] {
+	page := Page[T]{Data: rows}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+	if len(rows) > limit {
+		page.Data = rows[:limit]
+		page.NextCursor = EncodeCursor(id(page.Data[limit-1]))
+	}
+	return page
+}
+
+// PageLimit clamps a requested page size to (0, MaxPageLimit], defaulting to DefaultPageLimit.
+func PageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		return MaxPageLimit
+	}
+	return limit
+}
+
+// EncodeCursor hides the row position a page ended at.
+func EncodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+// DecodeCursor returns the row position encoded by EncodeCursor. An empty cursor is the first page.
+func DecodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor '%s'", cursor)
+	}
+	id, err := strconv.ParseInt(string(decoded), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid cursor '%s'", cursor)
+	}
+	return id, nil
+}
+
+// Range is an inclusive bound on an amount in minor units. Nil ends are open.
+type Range struct {
+	Min *int64
+	Max *int64
+}
+
+// ParseRange reads ranges written as "min..max", where either end may be left out, e.g. "100..", "..500".
+func ParseRange(value string) (Range, error) {
+	var r Range
+	if value == "" {
+		return r, nil
+	}
+
+	bounds := strings.Split(value, "..")
+	if len(bounds) != 2 {
+		return r, fmt.Errorf("invalid range '%s'. use min..max, min.. or ..max", value)
+	}
+	for i, bound := range bounds {
+		if bound == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(bound, 10, 64)
+		if err != nil {
+			return r, fmt.Errorf("invalid range '%s'. bounds must be whole numbers in minor units", value)
+		}
+		if i == 0 {
+			r.Min = &n
+		} else {
+			r.Max = &n
+		}
+	}
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return r, fmt.Errorf("invalid range '%s'. min is greater than max", value)
+	}
+	return r, nil
+}
+
+// TransactionFilter narrows a transaction listing. ID is the position to continue after.
+type TransactionFilter struct {
+	ID                int64             `json:"id"`
+	Status            string            `json:"status"`
+	BalanceID         string            `json:"balance_id"`
+	Currency          string            `json:"currency"`
+	ParentTransaction string            `json:"parent_transaction"`
+	MetaData          map[string]string `json:"meta_data"`
+	From              time.Time         `json:"from"`
+	To                time.Time         `json:"to"`
+}
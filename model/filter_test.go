@@ -0,0 +1,70 @@
+package model
+
+import "testing"
+
+func TestCursorRoundTrip(t *testing.T) {
+	id, err := DecodeCursor(EncodeCursor(42))
+	if err != nil || id != 42 {
+		t.Errorf("DecodeCursor(EncodeCursor(42)) got = %d, %v", id, err)
+	}
+	if id, err := DecodeCursor(""); err != nil || id != 0 {
+		t.Errorf("DecodeCursor(\"\") got = %d, %v, want first page", id, err)
+	}
+	if _, err := DecodeCursor("not-a-cursor"); err == nil {
+		t.Error("DecodeCursor() expected an error for a malformed cursor")
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		value    string
+		min, max int64
+		hasMin   bool
+		hasMax   bool
+		wantErr  bool
+	}{
+		{value: "100..500", min: 100, max: 500, hasMin: true, hasMax: true},
+		{value: "100..", min: 100, hasMin: true},
+		{value: "..-20", max: -20, hasMax: true},
+		{value: ""},
+		{value: "500..100", wantErr: true},
+		{value: "1.5..2", wantErr: true},
+		{value: "100", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRange(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRange(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if (got.Min != nil) != tt.hasMin || (got.Max != nil) != tt.hasMax {
+			t.Errorf("ParseRange(%q) got bounds %v, %v", tt.value, got.Min, got.Max)
+			continue
+		}
+		if (tt.hasMin && *got.Min != tt.min) || (tt.hasMax && *got.Max != tt.max) {
+			t.Errorf("ParseRange(%q) got = %d..%d, want %d..%d", tt.value, *got.Min, *got.Max, tt.min, tt.max)
+		}
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	id := func(n int64) int64 { return n }
+
+	page := NewPage([]int64{9, 8, 7}, 2, id)
+	if len(page.Data) != 2 || page.NextCursor != EncodeCursor(8) {
+		t.Errorf("NewPage() got = %v, %s", page.Data, page.NextCursor)
+	}
+
+	last := NewPage([]int64{9}, 2, id)
+	if len(last.Data) != 1 || last.NextCursor != "" {
+		t.Errorf("NewPage() on the last page got = %v, %s", last.Data, last.NextCursor)
+	}
+
+	if empty := NewPage[int64](nil, 2, id); empty.Data == nil {
+		t.Error("NewPage() should return an empty, non-nil slice")
+	}
+}
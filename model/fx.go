@@ -0,0 +1,92 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// FXRateSet is one published batch of exchange rates. Every set gets a new, increasing version so a quote can
+// always be traced back to the rates it was priced from.
+type FXRateSet struct {
+	ID          int64                  `json:"-"`
+	RateSetID   string                 `json:"rate_set_id"`
+	Version     int64                  `json:"version"`
+	Source      string                 `json:"source"`
+	EffectiveAt time.Time              `json:"effective_at"`
+	CreatedAt   time.Time              `json:"created_at"`
+	Rates       []FXRate               `json:"rates"`
+	MetaData    map[string]interface{} `json:"meta_data,omitempty"`
+}
+
+// FXRate is the number of QuoteCurrency units one BaseCurrency unit buys.
+type FXRate struct {
+	RateSetID     string      `json:"rate_set_id,omitempty"`
+	Version       int64       `json:"version,omitempty"`
+	BaseCurrency  string      `json:"base_currency"`
+	QuoteCurrency string      `json:"quote_currency"`
+	Rate          json.Number `json:"rate"`
+}
+
+// FXQuote locks a rate and spread for a currency pair until ExpiresAt.
+type FXQuote struct {
+	ID             int64       `json:"-"`
+	QuoteID        string      `json:"quote_id"`
+	RateSetID      string      `json:"rate_set_id"`
+	RateSetVersion int64       `json:"rate_set_version"`
+	BaseCurrency   string      `json:"base_currency"`
+	QuoteCurrency  string      `json:"quote_currency"`
+	Rate           json.Number `json:"rate"`
+	SpreadBps      int64       `json:"spread_bps"`
+	ExpiresAt      time.Time   `json:"expires_at"`
+	CreatedAt      time.Time   `json:"created_at"`
+	// UsedBy is the ID of the transaction the quote was posted with, once it has been.
+	UsedBy string `json:"used_by,omitempty"`
+}
+
+// Validate checks that every rate is a positive decimal for a distinct pair of different currencies.
+func (set *FXRateSet) Validate() error {
+	if len(set.Rates) == 0 {
+		return errors.New("a rate set needs at least one rate")
+	}
+
+	seen := make(map[string]bool, len(set.Rates))
+	for _, rate := range set.Rates {
+		if rate.BaseCurrency == "" || rate.QuoteCurrency == "" {
+			return errors.New("base_currency and quote_currency are required")
+		}
+		if rate.BaseCurrency == rate.QuoteCurrency {
+			return fmt.Errorf("rate for %s/%s converts a currency to itself", rate.BaseCurrency, rate.QuoteCurrency)
+		}
+		pair := rate.BaseCurrency + "/" + rate.QuoteCurrency
+		if seen[pair] {
+			return fmt.Errorf("duplicate rate for %s", pair)
+		}
+		seen[pair] = true
+
+		value, err := parseDecimal(rate.Rate.String())
+		if err != nil {
+			return fmt.Errorf("invalid rate for %s: %w", pair, err)
+		}
+		if value.Sign() <= 0 {
+			return fmt.Errorf("rate for %s must be positive", pair)
+		}
+	}
+
+	return nil
+}
+
+// IsExpired reports whether the quote can no longer be used for a transaction created at the given time.
+func (quote *FXQuote) IsExpired(at time.Time) bool {
+	return at.After(quote.ExpiresAt)
+}
+
+// SpreadAmount returns the part of a minor-unit amount kept as FX revenue, rounded half away from zero.
+func SpreadAmount(preciseAmount, spreadBps int64) (int64, error) {
+	if spreadBps < 0 || spreadBps >= 10000 {
+		return 0, errors.New("spread must be between 0 and 9999 basis points")
+	}
+	return roundHalfUp(new(big.Rat).Mul(new(big.Rat).SetInt64(preciseAmount), big.NewRat(spreadBps, 10000)))
+}
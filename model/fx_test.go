@@ -0,0 +1,55 @@
+package model
+
+import "testing"
+
+func TestFXRateSetValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rates   []FXRate
+		wantErr bool
+	}{
+		{
+			name:  "Valid rates",
+			rates: []FXRate{{BaseCurrency: "USD", QuoteCurrency: "NGN", Rate: "1500.25"}, {BaseCurrency: "NGN", QuoteCurrency: "USD", Rate: "0.00066"}},
+		},
+		{name: "No rates", rates: nil, wantErr: true},
+		{name: "Same currency", rates: []FXRate{{BaseCurrency: "USD", QuoteCurrency: "USD", Rate: "1"}}, wantErr: true},
+		{name: "Duplicate pair", rates: []FXRate{{BaseCurrency: "USD", QuoteCurrency: "NGN", Rate: "1500"}, {BaseCurrency: "USD", QuoteCurrency: "NGN", Rate: "1501"}}, wantErr: true},
+		{name: "Zero rate", rates: []FXRate{{BaseCurrency: "USD", QuoteCurrency: "NGN", Rate: "0"}}, wantErr: true},
+		{name: "Invalid rate", rates: []FXRate{{BaseCurrency: "USD", QuoteCurrency: "NGN", Rate: "abc"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := &FXRateSet{Rates: tt.rates}
+			if err := set.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpreadAmount(t *testing.T) {
+	tests := []struct {
+		preciseAmount int64
+		spreadBps     int64
+		want          int64
+		wantErr       bool
+	}{
+		{preciseAmount: 10000, spreadBps: 100, want: 100},
+		{preciseAmount: 150, spreadBps: 50, want: 1}, // 0.75 rounds up
+		{preciseAmount: 10000, spreadBps: 0, want: 0},
+		{preciseAmount: 10000, spreadBps: 10000, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := SpreadAmount(tt.preciseAmount, tt.spreadBps)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SpreadAmount(%d, %d) error = %v, wantErr %v", tt.preciseAmount, tt.spreadBps, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("SpreadAmount(%d, %d) got = %d, want %d", tt.preciseAmount, tt.spreadBps, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	DirectionDebit  = "debit"
+	DirectionCredit = "credit"
+)
+
+type JournalEntry struct {
+	ID             int64                  `json:"-"`
+	EntryID        string                 `json:"entry_id"`
+	Reference      string                 `json:"reference"`
+	Description    string                 `json:"description,omitempty"`
+	Status         string                 `json:"status"`
+	AllowOverdraft bool                   `json:"allow_overdraft"`
+	Lines          []JournalLine          `json:"lines"`
+	CreatedAt      time.Time              `json:"created_at"`
+	MetaData       map[string]interface{} `json:"meta_data,omitempty"`
+	LimitCharges   []LimitCharge          `json:"-"`
+}
+
+type JournalLine struct {
+	LineID        string      `json:"line_id"`
+	EntryID       string      `json:"entry_id"`
+	BalanceID     string      `json:"balance_id"`
+	Direction     string      `json:"direction"` // Either "debit" or "credit"
+	Amount        json.Number `json:"amount"`
+	Precision     int64       `json:"precision"`
+	PreciseAmount int64       `json:"precise_amount"`
+	Currency      string      `json:"currency"`
+	Description   string      `json:"description,omitempty"`
+}
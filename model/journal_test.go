@@ -0,0 +1,103 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestJournalEntryValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []JournalLine
+		wantErr bool
+	}{
+		{
+			name: "Balanced across currencies",
+			lines: []JournalLine{
+				{BalanceID: "A", Direction: DirectionDebit, Amount: "100", Precision: 100, Currency: "USD"},
+				{BalanceID: "B", Direction: DirectionCredit, Amount: "90", Precision: 100, Currency: "USD"},
+				{BalanceID: "C", Direction: DirectionCredit, Amount: "10", Precision: 100, Currency: "USD"},
+				{BalanceID: "D", Direction: DirectionDebit, Amount: "5", Precision: 100, Currency: "EUR"},
+				{BalanceID: "E", Direction: DirectionCredit, Amount: "5", Precision: 100, Currency: "EUR"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Unbalanced",
+			lines: []JournalLine{
+				{BalanceID: "A", Direction: DirectionDebit, Amount: "100", Currency: "USD"},
+				{BalanceID: "B", Direction: DirectionCredit, Amount: "99", Currency: "USD"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Balanced in total but not per currency",
+			lines: []JournalLine{
+				{BalanceID: "A", Direction: DirectionDebit, Amount: "100", Currency: "USD"},
+				{BalanceID: "B", Direction: DirectionCredit, Amount: "100", Currency: "EUR"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid direction",
+			lines: []JournalLine{
+				{BalanceID: "A", Direction: "sideways", Amount: "100", Currency: "USD"},
+				{BalanceID: "B", Direction: DirectionCredit, Amount: "100", Currency: "USD"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &JournalEntry{Lines: tt.lines}
+			err := entry.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyJournalEntry(t *testing.T) {
+	entry := &JournalEntry{Lines: []JournalLine{
+		{BalanceID: "payroll", Direction: DirectionDebit, Amount: "100", Precision: 100, Currency: "USD"},
+		{BalanceID: "employee", Direction: DirectionCredit, Amount: "80", Precision: 100, Currency: "USD"},
+		{BalanceID: "tax", Direction: DirectionCredit, Amount: "20", Precision: 100, Currency: "USD"},
+	}}
+	if err := entry.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	balances := map[string]*Balance{
+		"payroll":  {BalanceID: "payroll", Currency: "USD", CreditBalance: 50000, Balance: 50000},
+		"employee": {BalanceID: "employee", Currency: "USD"},
+		"tax":      {BalanceID: "tax", Currency: "USD"},
+	}
+	if err := ApplyJournalEntry(entry, balances); err != nil {
+		t.Fatalf("ApplyJournalEntry() error = %v", err)
+	}
+
+	if balances["payroll"].Balance != 40000 || balances["employee"].Balance != 8000 || balances["tax"].Balance != 2000 {
+		t.Errorf("ApplyJournalEntry() got balances %d, %d, %d", balances["payroll"].Balance, balances["employee"].Balance, balances["tax"].Balance)
+	}
+
+	overdrawn := &JournalEntry{Lines: []JournalLine{
+		{BalanceID: "tax", Direction: DirectionDebit, Amount: "1000", Precision: 100, Currency: "USD"},
+		{BalanceID: "employee", Direction: DirectionCredit, Amount: "1000", Precision: 100, Currency: "USD"},
+	}}
+	_ = overdrawn.Validate()
+	if err := ApplyJournalEntry(overdrawn, balances); err == nil {
+		t.Errorf("ApplyJournalEntry() expected insufficient funds error")
+	}
+
+	// Funds held for queued transactions cannot be spent by a journal entry.
+	balances["payroll"].ReservedBalance = 35000
+	reserved := &JournalEntry{Lines: []JournalLine{
+		{BalanceID: "payroll", Direction: DirectionDebit, Amount: "60", Precision: 100, Currency: "USD"},
+		{BalanceID: "employee", Direction: DirectionCredit, Amount: "60", Precision: 100, Currency: "USD"},
+	}}
+	_ = reserved.Validate()
+	if err := ApplyJournalEntry(reserved, balances); err == nil {
+		t.Errorf("ApplyJournalEntry() expected reserved funds to be unavailable")
+	}
+}
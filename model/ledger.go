@@ -10,6 +10,7 @@ type Ledger struct {
 	MetaData  map[string]interface{} `json:"meta_data"`
 }
 
+// LedgerFilter narrows a ledger listing. ID is the position to continue after.
 type LedgerFilter struct {
 	ID   int64     `json:"id"`
 	From time.Time `json:"from"`
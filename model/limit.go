@@ -0,0 +1,164 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Spending limit scopes. A limit applies to the balance it names, to every balance of an identity or to every
+// balance in a ledger.
+const (
+	LimitScopeBalance  = "balance"
+	LimitScopeIdentity = "identity"
+	LimitScopeLedger   = "ledger"
+)
+
+// Spending limit windows. Windows are calendar periods in UTC; weeks start on Monday.
+const (
+	LimitWindowDaily   = "daily"
+	LimitWindowWeekly  = "weekly"
+	LimitWindowMonthly = "monthly"
+)
+
+// ErrLimitExceeded is wrapped by the *LimitError returned for transactions that would go past a spending limit.
+var ErrLimitExceeded = errors.New("spending limit exceeded")
+
+// SpendingLimit caps what the balances in its scope may send during each window: at most MaxAmount, in minor
+// units of Currency, over at most MaxCount transactions. A zero cap is not enforced. A limit without a currency
+// counts transactions in every currency and can only cap their number.
+type SpendingLimit struct {
+	ID        int64                  `json:"-"`
+	LimitID   string                 `json:"limit_id"`
+	Scope     string                 `json:"scope"`
+	ScopeID   string                 `json:"scope_id"`
+	Window    string                 `json:"window"`
+	Currency  string                 `json:"currency,omitempty"`
+	MaxAmount int64                  `json:"max_amount,omitempty"`
+	MaxCount  int64                  `json:"max_count,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	MetaData  map[string]interface{} `json:"meta_data,omitempty"`
+}
+
+// SpendingLimitFilter narrows a spending limit listing. ID is the position to continue after.
+type SpendingLimitFilter struct {
+	ID      int64  `json:"id"`
+	Scope   string `json:"scope"`
+	ScopeID string `json:"scope_id"`
+}
+
+// LimitUsage is what the balances in a limit's scope have sent during a window.
+type LimitUsage struct {
+	Amount int64 `json:"amount"`
+	Count  int64 `json:"count"`
+}
+
+// LimitCharge is what a posting takes from a limit in the window starting at WindowStart. Charges are added to
+// the limit's usage in the same database transaction as the balance updates, and only while the limit allows
+// them, so postings from balances that are locked separately cannot together go past the limit.
+type LimitCharge struct {
+	Limit       SpendingLimit
+	WindowStart time.Time
+	Amount      int64
+}
+
+// LimitAllowance is how much of a limit is used and left in its current window. The remaining figures are
+// omitted for caps the limit does not set.
+type LimitAllowance struct {
+	Limit           SpendingLimit `json:"limit"`
+	WindowStart     time.Time     `json:"window_start"`
+	ResetsAt        time.Time     `json:"resets_at"`
+	UsedAmount      int64         `json:"used_amount"`
+	UsedCount       int64         `json:"used_count"`
+	RemainingAmount *int64        `json:"remaining_amount,omitempty"`
+	RemainingCount  *int64        `json:"remaining_count,omitempty"`
+}
+
+// LimitError is returned for a transaction that would take its source past a spending limit. Code names the
+// cap that was hit, such as daily_amount_limit_exceeded.
+type LimitError struct {
+	Limit SpendingLimit
+	Code  string
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: limit %s on %s %s", e.Code, e.Limit.LimitID, e.Limit.Scope, e.Limit.ScopeID)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// Validate checks the scope and window and that at least one cap is set.
+func (l *SpendingLimit) Validate() error {
+	switch l.Scope {
+	case LimitScopeBalance, LimitScopeIdentity, LimitScopeLedger:
+	default:
+		return fmt.Errorf("scope must be one of %s, %s or %s", LimitScopeBalance, LimitScopeIdentity, LimitScopeLedger)
+	}
+	if l.ScopeID == "" {
+		return errors.New("scope_id is required")
+	}
+	switch l.Window {
+	case LimitWindowDaily, LimitWindowWeekly, LimitWindowMonthly:
+	default:
+		return fmt.Errorf("window must be one of %s, %s or %s", LimitWindowDaily, LimitWindowWeekly, LimitWindowMonthly)
+	}
+	if l.MaxAmount < 0 || l.MaxCount < 0 {
+		return errors.New("limits cannot be negative")
+	}
+	if l.MaxAmount == 0 && l.MaxCount == 0 {
+		return errors.New("pass max_amount, max_count or both")
+	}
+	if l.MaxAmount > 0 && l.Currency == "" {
+		return errors.New("an amount limit needs a currency")
+	}
+	return nil
+}
+
+// Period returns the start and end of the window that contains the given time.
+func (l *SpendingLimit) Period(at time.Time) (time.Time, time.Time) {
+	at = at.UTC()
+	day := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+	switch l.Window {
+	case LimitWindowWeekly:
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 7)
+	case LimitWindowMonthly:
+		start := time.Date(at.Year(), at.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+// AppliesTo reports whether transactions in the given currency count towards the limit.
+func (l *SpendingLimit) AppliesTo(currency string) bool {
+	return l.Currency == "" || l.Currency == currency
+}
+
+// Check returns a *LimitError when one more transaction of amount on top of usage would go past the limit.
+func (l *SpendingLimit) Check(usage LimitUsage, amount int64) error {
+	if l.MaxAmount > 0 && usage.Amount+amount > l.MaxAmount {
+		return &LimitError{Limit: *l, Code: fmt.Sprintf("%s_amount_limit_exceeded", l.Window)}
+	}
+	if l.MaxCount > 0 && usage.Count+1 > l.MaxCount {
+		return &LimitError{Limit: *l, Code: fmt.Sprintf("%s_count_limit_exceeded", l.Window)}
+	}
+	return nil
+}
+
+// Allowance reports usage against the limit for the window that contains the given time.
+func (l *SpendingLimit) Allowance(usage LimitUsage, at time.Time) LimitAllowance {
+	start, end := l.Period(at)
+	allowance := LimitAllowance{Limit: *l, WindowStart: start, ResetsAt: end, UsedAmount: usage.Amount, UsedCount: usage.Count}
+	if l.MaxAmount > 0 {
+		remaining := max(l.MaxAmount-usage.Amount, 0)
+		allowance.RemainingAmount = &remaining
+	}
+	if l.MaxCount > 0 {
+		remaining := max(l.MaxCount-usage.Count, 0)
+		allowance.RemainingCount = &remaining
+	}
+	return allowance
+}
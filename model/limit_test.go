@@ -0,0 +1,92 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSpendingLimitPeriod(t *testing.T) {
+	// A Wednesday afternoon in UTC+1 is still the Wednesday in UTC.
+	at := time.Date(2024, 5, 15, 14, 30, 0, 0, time.FixedZone("WAT", 3600))
+	tests := []struct {
+		window     string
+		start, end time.Time
+	}{
+		{LimitWindowDaily, time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)},
+		{LimitWindowWeekly, time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)},
+		{LimitWindowMonthly, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		limit := SpendingLimit{Window: tt.window}
+		start, end := limit.Period(at)
+		if !start.Equal(tt.start) || !end.Equal(tt.end) {
+			t.Errorf("Period() for %s got = %v - %v, want %v - %v", tt.window, start, end, tt.start, tt.end)
+		}
+	}
+
+	// Weeks start on Monday, so a Sunday belongs to the week before.
+	weekly := SpendingLimit{Window: LimitWindowWeekly}
+	if start, _ := weekly.Period(time.Date(2024, 5, 19, 23, 0, 0, 0, time.UTC)); !start.Equal(time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Period() for a Sunday got start = %v", start)
+	}
+}
+
+func TestSpendingLimitCheck(t *testing.T) {
+	limit := SpendingLimit{LimitID: "lim_1", Scope: LimitScopeBalance, ScopeID: "bln_1", Window: LimitWindowDaily, Currency: "USD", MaxAmount: 10000, MaxCount: 3}
+
+	if err := limit.Check(LimitUsage{Amount: 6000, Count: 1}, 4000); err != nil {
+		t.Errorf("Check() up to the cap got error %v", err)
+	}
+
+	var limitErr *LimitError
+	err := limit.Check(LimitUsage{Amount: 6000, Count: 1}, 4001)
+	if !errors.As(err, &limitErr) || limitErr.Code != "daily_amount_limit_exceeded" {
+		t.Errorf("Check() over the amount cap got = %v", err)
+	}
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Check() error does not wrap ErrLimitExceeded")
+	}
+
+	err = limit.Check(LimitUsage{Amount: 100, Count: 3}, 100)
+	if !errors.As(err, &limitErr) || limitErr.Code != "daily_count_limit_exceeded" {
+		t.Errorf("Check() over the count cap got = %v", err)
+	}
+}
+
+func TestSpendingLimitValidate(t *testing.T) {
+	valid := SpendingLimit{Scope: LimitScopeIdentity, ScopeID: "idt_1", Window: LimitWindowMonthly, MaxCount: 10}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() got error %v", err)
+	}
+
+	invalid := []SpendingLimit{
+		{Scope: "account", ScopeID: "acc_1", Window: LimitWindowDaily, MaxCount: 1},
+		{Scope: LimitScopeBalance, Window: LimitWindowDaily, MaxCount: 1},
+		{Scope: LimitScopeBalance, ScopeID: "bln_1", Window: "hourly", MaxCount: 1},
+		{Scope: LimitScopeBalance, ScopeID: "bln_1", Window: LimitWindowDaily},
+		{Scope: LimitScopeBalance, ScopeID: "bln_1", Window: LimitWindowDaily, MaxAmount: 100},
+		{Scope: LimitScopeBalance, ScopeID: "bln_1", Window: LimitWindowDaily, MaxCount: -1},
+	}
+	for _, limit := range invalid {
+		if err := limit.Validate(); err == nil {
+			t.Errorf("Validate() of %+v got no error", limit)
+		}
+	}
+}
+
+func TestSpendingLimitAllowance(t *testing.T) {
+	at := time.Date(2024, 5, 15, 12, 0, 0, 0, time.UTC)
+	limit := SpendingLimit{Window: LimitWindowDaily, Currency: "USD", MaxAmount: 10000}
+
+	allowance := limit.Allowance(LimitUsage{Amount: 12000, Count: 4}, at)
+	if allowance.RemainingAmount == nil || *allowance.RemainingAmount != 0 {
+		t.Errorf("Allowance() RemainingAmount got = %v, want 0", allowance.RemainingAmount)
+	}
+	if allowance.RemainingCount != nil {
+		t.Errorf("Allowance() RemainingCount got = %v, want none", *allowance.RemainingCount)
+	}
+	if !allowance.ResetsAt.Equal(time.Date(2024, 5, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Allowance() ResetsAt got = %v", allowance.ResetsAt)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/typesense/typesense-go/typesense/api"
@@ -21,7 +23,7 @@ func GenerateUUIDWithSuffix(module string) string {
 }
 
 func (transaction *Transaction) HashTxn() string {
-	data := fmt.Sprintf("%f%s%s%s%s", transaction.Amount, transaction.Reference, transaction.Currency, transaction.Source, transaction.Destination)
+	data := fmt.Sprintf("%s%s%s%s%s", transaction.Amount, transaction.Reference, transaction.Currency, transaction.Source, transaction.Destination)
 	hash := sha256.Sum256([]byte(data))
 	return hex.EncodeToString(hash[:])
 }
@@ -67,6 +69,104 @@ func (balance *Balance) computeBalance(inflight bool) {
 		return
 	}
 	balance.Balance = balance.CreditBalance - balance.DebitBalance
+	balance.ComputeAvailableBalance()
+}
+
+// ComputeAvailableBalance sets AvailableBalance to what is left of the balance once reserved funds are set aside,
+// and AvailableCredit to what is left of its overdraft limit.
+func (balance *Balance) ComputeAvailableBalance() {
+	balance.AvailableBalance = balance.Balance - balance.ReservedBalance
+	balance.AvailableCredit = balance.ActiveOverdraftLimit(time.Now())
+	if balance.AvailableBalance < 0 {
+		balance.AvailableCredit = max(balance.AvailableCredit+balance.AvailableBalance, 0)
+	}
+}
+
+// ActiveOverdraftLimit returns how far below zero the balance may be taken at the given time. A limit stops
+// applying once it expires.
+func (balance *Balance) ActiveOverdraftLimit(at time.Time) int64 {
+	if balance.OverdraftExpiresAt != nil && !at.Before(*balance.OverdraftExpiresAt) {
+		return 0
+	}
+	return balance.OverdraftLimit
+}
+
+// IsBalanceStatus reports whether status is one of the balance statuses.
+func IsBalanceStatus(status string) bool {
+	switch status {
+	case BalanceActive, BalanceFrozen, BalanceDebitBlocked, BalanceCreditBlocked, BalanceClosed:
+		return true
+	}
+	return false
+}
+
+// CheckDebit returns ErrBalanceStatus when the balance's status does not let money leave it.
+func (balance *Balance) CheckDebit() error {
+	switch balance.Status {
+	case BalanceFrozen, BalanceDebitBlocked, BalanceClosed:
+		return fmt.Errorf("%w: balance %s is %s", ErrBalanceStatus, balance.BalanceID, balance.Status)
+	}
+	return nil
+}
+
+// CheckCredit returns ErrBalanceStatus when the balance's status does not let money into it.
+func (balance *Balance) CheckCredit() error {
+	switch balance.Status {
+	case BalanceFrozen, BalanceCreditBlocked, BalanceClosed:
+		return fmt.Errorf("%w: balance %s is %s", ErrBalanceStatus, balance.BalanceID, balance.Status)
+	}
+	return nil
+}
+
+// CheckRelease returns ErrBalanceStatus when the balance's status does not let held inflight funds be released,
+// which is only the case for frozen and closed balances.
+func (balance *Balance) CheckRelease() error {
+	switch balance.Status {
+	case BalanceFrozen, BalanceClosed:
+		return fmt.Errorf("%w: balance %s is %s", ErrBalanceStatus, balance.BalanceID, balance.Status)
+	}
+	return nil
+}
+
+// MarkBaseline records the balance's current figures as the point PendingDelta measures from.
+func (balance *Balance) MarkBaseline() {
+	balance.baseline = BalanceDelta{
+		Balance:               balance.Balance,
+		CreditBalance:         balance.CreditBalance,
+		DebitBalance:          balance.DebitBalance,
+		InflightBalance:       balance.InflightBalance,
+		InflightCreditBalance: balance.InflightCreditBalance,
+		InflightDebitBalance:  balance.InflightDebitBalance,
+	}
+}
+
+// PendingDelta returns how the balance's figures have changed since MarkBaseline, attributed to transactionID.
+func (balance *Balance) PendingDelta(transactionID string) BalanceDelta {
+	return BalanceDelta{
+		BalanceID:             balance.BalanceID,
+		TransactionID:         transactionID,
+		Balance:               balance.Balance - balance.baseline.Balance,
+		CreditBalance:         balance.CreditBalance - balance.baseline.CreditBalance,
+		DebitBalance:          balance.DebitBalance - balance.baseline.DebitBalance,
+		InflightBalance:       balance.InflightBalance - balance.baseline.InflightBalance,
+		InflightCreditBalance: balance.InflightCreditBalance - balance.baseline.InflightCreditBalance,
+		InflightDebitBalance:  balance.InflightDebitBalance - balance.baseline.InflightDebitBalance,
+	}
+}
+
+// IsZero reports whether the delta changes nothing.
+func (delta BalanceDelta) IsZero() bool {
+	return delta.Balance == 0 && delta.CreditBalance == 0 && delta.DebitBalance == 0 &&
+		delta.InflightBalance == 0 && delta.InflightCreditBalance == 0 && delta.InflightDebitBalance == 0
+}
+
+// releaseReserved drops the part of the reserved balance that a transaction queued with a reservation was holding.
+func (balance *Balance) releaseReserved(amount int64) {
+	balance.ReservedBalance -= amount
+	if balance.ReservedBalance < 0 {
+		balance.ReservedBalance = 0
+	}
+	balance.ComputeAvailableBalance()
 }
 
 func canProcessTransaction(transaction *Transaction, sourceBalance *Balance) error {
@@ -75,18 +175,18 @@ func canProcessTransaction(transaction *Transaction, sourceBalance *Balance) err
 		return nil
 	}
 
-	// Use the provided sourceBalance for the check
-	if sourceBalance.Balance < transaction.PreciseAmount {
-		return fmt.Errorf("insufficient funds in source balance")
+	// Funds reserved for other queued transactions are not available, but the transaction's own reservation is.
+	// The balance may go as far below zero as its overdraft limit allows.
+	available := sourceBalance.Balance - sourceBalance.ReservedBalance + transaction.ReservedAmount
+	if available-transaction.PreciseAmount < -sourceBalance.ActiveOverdraftLimit(time.Now()) {
+		return ErrInsufficientFunds
 	}
 
 	return nil
 }
 
 func (balance *Balance) CommitInflightDebit(transaction *Transaction) {
-	preciseAmount := ApplyPrecision(transaction)
-
-	transaction.PreciseAmount = preciseAmount //set transaction precision amount
+	preciseAmount := transaction.PreciseAmount
 	if balance.InflightDebitBalance >= preciseAmount {
 		balance.InflightDebitBalance -= preciseAmount
 		balance.DebitBalance += preciseAmount
@@ -96,8 +196,7 @@ func (balance *Balance) CommitInflightDebit(transaction *Transaction) {
 }
 
 func (balance *Balance) CommitInflightCredit(transaction *Transaction) {
-	preciseAmount := ApplyPrecision(transaction)
-	transaction.PreciseAmount = preciseAmount
+	preciseAmount := transaction.PreciseAmount
 	if balance.InflightCreditBalance >= preciseAmount {
 		balance.InflightCreditBalance -= preciseAmount
 		balance.CreditBalance += preciseAmount
@@ -122,36 +221,84 @@ func (balance *Balance) RollbackInflightDebit(amount int64) {
 	}
 }
 
-func ApplyPrecision(transaction *Transaction) int64 {
+// ApplyPrecision returns the transaction amount in minor units. When only PreciseAmount was supplied it is
+// used as is and Amount is filled in from it.
+func ApplyPrecision(transaction *Transaction) (int64, error) {
 	if transaction.Precision == 0 {
 		transaction.Precision = 1
 	}
-	return int64(transaction.Amount * transaction.Precision)
+	if transaction.Amount == "" {
+		transaction.Amount = FromPrecise(transaction.PreciseAmount, transaction.Precision)
+		return transaction.PreciseAmount, nil
+	}
+	return ToPrecise(transaction.Amount, transaction.Precision)
 }
 
-func ApplyRate(transaction *Transaction) float64 {
-	if transaction.Rate == 0 {
-		transaction.Rate = 1
+// ApplyRate converts the transaction's minor-unit amount into the destination currency. destinationPrecision is the
+// destination balance's precision; zero means it matches the transaction. The result is rounded half away from zero
+// so the same inputs always credit the same amount.
+func ApplyRate(transaction *Transaction, destinationPrecision int64) (int64, error) {
+	if transaction.Rate == "" {
+		transaction.Rate = "1"
 	}
-	return transaction.Amount * transaction.Rate
+	rate, err := parseDecimal(transaction.Rate.String())
+	if err != nil {
+		return 0, err
+	}
+	if rate.Sign() == 0 {
+		transaction.Rate = "1"
+		rate.SetInt64(1)
+	}
+	if rate.Sign() < 0 {
+		return 0, errors.New("transaction rate must be positive")
+	}
+	rate.Mul(rate, new(big.Rat).SetInt64(transaction.PreciseAmount))
+	if destinationPrecision > 0 && transaction.Precision > 0 && destinationPrecision != transaction.Precision {
+		rate.Mul(rate, new(big.Rat).SetFrac64(destinationPrecision, transaction.Precision))
+	}
+	return roundHalfUp(rate)
+}
+
+// ConvertsCurrency reports whether the transaction carries an exchange rate other than 1.
+func (transaction *Transaction) ConvertsCurrency() bool {
+	if transaction.Rate == "" {
+		return false
+	}
+	rate, err := parseDecimal(transaction.Rate.String())
+	if err != nil {
+		return false
+	}
+	return rate.Sign() != 0 && rate.Cmp(big.NewRat(1, 1)) != 0
 }
 
 func (transaction *Transaction) validate() error {
-	if transaction.Amount <= 0 {
-		return errors.New("transaction amount must be positive")
+	if transaction.PreciseAmount <= 0 {
+		return ErrInvalidAmount
 	}
 
 	return nil
 }
 
 func UpdateBalances(transaction *Transaction, source, destination *Balance) error {
-	transaction.PreciseAmount = ApplyPrecision(transaction)
-	origanialAmount := transaction.Amount
-	err := transaction.validate()
+	preciseAmount, err := ApplyPrecision(transaction)
+	if err != nil {
+		return err
+	}
+	transaction.PreciseAmount = preciseAmount
+	err = transaction.validate()
 	if err != nil {
 		return err
 	}
 
+	if !transaction.SkipStatusCheck {
+		if err := source.CheckDebit(); err != nil {
+			return err
+		}
+		if err := destination.CheckCredit(); err != nil {
+			return err
+		}
+	}
+
 	err = canProcessTransaction(transaction, source)
 	if err != nil {
 		return err
@@ -160,15 +307,99 @@ func UpdateBalances(transaction *Transaction, source, destination *Balance) erro
 	//compute source balance
 	source.addDebit(transaction.PreciseAmount, transaction.Inflight)
 	source.computeBalance(transaction.Inflight)
+	if transaction.ReservedAmount > 0 {
+		source.releaseReserved(transaction.ReservedAmount)
+	}
 
 	//compute destination balance
-	transaction.Amount = ApplyRate(transaction) //apply exchange rate to destination if rate is passed.
-	transaction.PreciseAmount = ApplyPrecision(transaction)
-	destination.addCredit(transaction.PreciseAmount, transaction.Inflight)
+	destinationAmount, err := ApplyRate(transaction, int64(destination.CurrencyMultiplier)) //apply exchange rate to destination if rate is passed.
+	if err != nil {
+		return err
+	}
+	if transaction.ConvertsCurrency() {
+		if transaction.SourceAmount == "" {
+			transaction.SourceAmount = transaction.Amount
+		}
+		destinationPrecision := int64(destination.CurrencyMultiplier)
+		if destinationPrecision <= 0 {
+			destinationPrecision = transaction.Precision
+		}
+		transaction.DestinationAmount = FromPrecise(destinationAmount, destinationPrecision)
+	}
+	destination.addCredit(destinationAmount, transaction.Inflight)
 	destination.computeBalance(transaction.Inflight)
 
-	transaction.Amount = origanialAmount //revert *Transaction.Amount back original amount after modification for destination rates
-	transaction.PreciseAmount = ApplyPrecision(transaction)
+	return nil
+}
+
+// Validate checks that every line is well formed and that debits equal credits in each currency.
+func (entry *JournalEntry) Validate() error {
+	if len(entry.Lines) < 2 {
+		return errors.New("a journal entry needs at least one debit and one credit line")
+	}
+
+	totals := make(map[string]int64)
+	for i := range entry.Lines {
+		line := &entry.Lines[i]
+		preciseAmount, err := ToPrecise(line.Amount, line.Precision)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+		line.PreciseAmount = preciseAmount
+		if line.PreciseAmount <= 0 {
+			return fmt.Errorf("line %d: amount must be positive", i+1)
+		}
+
+		switch line.Direction {
+		case DirectionDebit:
+			totals[line.Currency] += line.PreciseAmount
+		case DirectionCredit:
+			totals[line.Currency] -= line.PreciseAmount
+		default:
+			return fmt.Errorf("line %d: direction must be either debit or credit", i+1)
+		}
+	}
+
+	for currency, total := range totals {
+		if total != 0 {
+			return fmt.Errorf("journal entry does not balance in %s: debits and credits differ by %d", currency, total)
+		}
+	}
+
+	return nil
+}
+
+// ApplyJournalEntry posts every line of a validated entry to its balance. Balances are keyed by balance ID and
+// are only modified in memory; the caller persists them.
+func ApplyJournalEntry(entry *JournalEntry, balances map[string]*Balance) error {
+	for i, line := range entry.Lines {
+		balance, ok := balances[line.BalanceID]
+		if !ok {
+			return fmt.Errorf("line %d: balance %s not loaded", i+1, line.BalanceID)
+		}
+		if balance.Currency != line.Currency {
+			return fmt.Errorf("line %d: balance %s is in %s, not %s", i+1, line.BalanceID, balance.Currency, line.Currency)
+		}
+
+		if line.Direction == DirectionDebit {
+			if err := balance.CheckDebit(); err != nil {
+				return fmt.Errorf("line %d: %w", i+1, err)
+			}
+			// As for transactions, funds reserved for queued transactions are not available.
+			available := balance.Balance - balance.ReservedBalance
+			if !entry.AllowOverdraft && available-line.PreciseAmount < -balance.ActiveOverdraftLimit(time.Now()) {
+				return fmt.Errorf("line %d: balance %s: %w", i+1, line.BalanceID, ErrInsufficientFunds)
+			}
+			balance.addDebit(line.PreciseAmount, false)
+		} else {
+			if err := balance.CheckCredit(); err != nil {
+				return fmt.Errorf("line %d: %w", i+1, err)
+			}
+			balance.addCredit(line.PreciseAmount, false)
+		}
+		balance.computeBalance(false)
+	}
+
 	return nil
 }
 
@@ -0,0 +1,87 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	bigZero = big.NewInt(0)
+	bigTwo  = big.NewInt(2)
+)
+
+// parseDecimal reads an exact decimal such as "0.29" or "1e3" without going through float64.
+func parseDecimal(value string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", value)
+	}
+	return r, nil
+}
+
+// ToPrecise converts a decimal amount into minor units using the precision multiplier (100 for cents).
+// Amounts with more decimal places than the precision can hold are rejected rather than rounded.
+func ToPrecise(amount json.Number, precision int64) (int64, error) {
+	if precision <= 0 {
+		precision = 1
+	}
+	r, err := parseDecimal(amount.String())
+	if err != nil {
+		return 0, err
+	}
+	r.Mul(r, new(big.Rat).SetInt64(precision))
+	if !r.IsInt() {
+		return 0, fmt.Errorf("amount %s has more decimal places than precision %d allows", amount, precision)
+	}
+	if !r.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %s is too large", amount)
+	}
+	return r.Num().Int64(), nil
+}
+
+// FromPrecise formats minor units as an exact decimal amount, dropping trailing zeros.
+func FromPrecise(preciseAmount int64, precision int64) json.Number {
+	if precision <= 0 {
+		precision = 1
+	}
+	digits := 0
+	for p := int64(1); p < precision; p *= 10 {
+		digits++
+	}
+	s := new(big.Rat).SetFrac64(preciseAmount, precision).FloatString(digits)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return json.Number(s)
+}
+
+// roundHalfUp rounds a rational to the nearest integer, with halves rounded away from zero.
+func roundHalfUp(r *big.Rat) (int64, error) {
+	quotient, remainder := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	doubled := new(big.Int).Mul(new(big.Int).Abs(remainder), bigTwo)
+	if doubled.Cmp(r.Denom()) >= 0 {
+		if r.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+	if !quotient.IsInt64() {
+		return 0, errors.New("amount is too large")
+	}
+	return quotient.Int64(), nil
+}
+
+// floorShare returns floor(total * fraction) along with what was left behind by the floor.
+func floorShare(total int64, fraction *big.Rat) (int64, *big.Rat) {
+	exact := new(big.Rat).Mul(new(big.Rat).SetInt64(total), fraction)
+	quotient, remainder := new(big.Int).QuoRem(exact.Num(), exact.Denom(), new(big.Int))
+	if remainder.Cmp(bigZero) < 0 {
+		quotient.Sub(quotient, big.NewInt(1))
+		remainder.Add(remainder, exact.Denom())
+	}
+	return quotient.Int64(), new(big.Rat).SetFrac(remainder, exact.Denom())
+}
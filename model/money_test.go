@@ -0,0 +1,76 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToPrecise(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    json.Number
+		precision int64
+		want      int64
+		wantErr   bool
+	}{
+		{name: "Cents without float drift", amount: "0.29", precision: 100, want: 29},
+		{name: "Large amount", amount: "92233720368547.75", precision: 100, want: 9223372036854775},
+		{name: "Zero precision treated as one", amount: "42", precision: 0, want: 42},
+		{name: "Too many decimal places", amount: "1.005", precision: 100, wantErr: true},
+		{name: "Not a number", amount: "abc", precision: 100, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToPrecise(tt.amount, tt.precision)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ToPrecise() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ToPrecise() got = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromPrecise(t *testing.T) {
+	tests := []struct {
+		preciseAmount int64
+		precision     int64
+		want          json.Number
+	}{
+		{preciseAmount: 29, precision: 100, want: "0.29"},
+		{preciseAmount: 1500, precision: 100, want: "15"},
+		{preciseAmount: -1250, precision: 1000, want: "-1.25"},
+		{preciseAmount: 7, precision: 1, want: "7"},
+	}
+
+	for _, tt := range tests {
+		if got := FromPrecise(tt.preciseAmount, tt.precision); got != tt.want {
+			t.Errorf("FromPrecise(%d, %d) got = %s, want %s", tt.preciseAmount, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestApplyRateRoundsHalfUp(t *testing.T) {
+	transaction := &Transaction{PreciseAmount: 5, Rate: "0.5"}
+	got, err := ApplyRate(transaction, 0)
+	if err != nil {
+		t.Fatalf("ApplyRate() error = %v", err)
+	}
+	if got != 3 {
+		t.Errorf("ApplyRate() got = %d, want 3", got)
+	}
+}
+
+func TestApplyRateScalesToDestinationPrecision(t *testing.T) {
+	transaction := &Transaction{PreciseAmount: 100, Precision: 100, Rate: "0.00002"}
+	got, err := ApplyRate(transaction, 100000000)
+	if err != nil {
+		t.Fatalf("ApplyRate() error = %v", err)
+	}
+	if got != 2000 {
+		t.Errorf("ApplyRate() got = %d, want 2000", got)
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// States a queued task can be listed in.
+const (
+	TaskStatePending   = "pending"
+	TaskStateActive    = "active"
+	TaskStateScheduled = "scheduled"
+	TaskStateRetry     = "retry"
+	TaskStateArchived  = "archived"
+)
+
+// QueueStats is the depth of one queue by task state, and how long its oldest pending task has waited.
+type QueueStats struct {
+	Queue     string        `json:"queue"`
+	Size      int           `json:"size"`
+	Pending   int           `json:"pending"`
+	Active    int           `json:"active"`
+	Scheduled int           `json:"scheduled"`
+	Retry     int           `json:"retry"`
+	Archived  int           `json:"archived"`
+	Latency   time.Duration `json:"-"`
+	LatencyMs int64         `json:"latency_ms"`
+	Paused    bool          `json:"paused"`
+}
+
+// QueueTask is a task in a queue. Payload is what was enqueued: the transaction for transaction queues, the
+// webhook for the webhook queue and the transaction ID for inflight expiries.
+type QueueTask struct {
+	ID            string          `json:"id"`
+	Queue         string          `json:"queue"`
+	State         string          `json:"state"`
+	Retried       int             `json:"retried"`
+	MaxRetry      int             `json:"max_retry"`
+	LastError     string          `json:"last_error,omitempty"`
+	LastFailedAt  *time.Time      `json:"last_failed_at,omitempty"`
+	NextProcessAt *time.Time      `json:"next_process_at,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
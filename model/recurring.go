@@ -0,0 +1,149 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	RecurringActive    = "ACTIVE"
+	RecurringPaused    = "PAUSED"
+	RecurringCancelled = "CANCELLED"
+	RecurringCompleted = "COMPLETED"
+)
+
+const (
+	OccurrencePending = "PENDING"
+	OccurrenceQueued  = "QUEUED"
+	OccurrenceFailed  = "FAILED"
+)
+
+// RecurringTransaction queues a copy of Template on every tick of its schedule, which is either a standard
+// five-field Cron expression or a fixed IntervalSeconds counted from StartAt. It stops after MaxOccurrences
+// occurrences or once the next tick falls after EndAt; zero values leave either bound off.
+type RecurringTransaction struct {
+	ID              int64                  `json:"-"`
+	RecurringID     string                 `json:"recurring_id"`
+	Cron            string                 `json:"cron,omitempty"`
+	IntervalSeconds int64                  `json:"interval_seconds,omitempty"`
+	StartAt         time.Time              `json:"start_at"`
+	EndAt           time.Time              `json:"end_at,omitempty"`
+	MaxOccurrences  int64                  `json:"max_occurrences,omitempty"`
+	Occurrences     int64                  `json:"occurrences"`
+	NextRunAt       time.Time              `json:"next_run_at,omitempty"`
+	LastRunAt       time.Time              `json:"last_run_at,omitempty"`
+	Status          string                 `json:"status"`
+	Template        Transaction            `json:"template"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+	MetaData        map[string]interface{} `json:"meta_data,omitempty"`
+}
+
+// RecurringOccurrence is one transaction a recurring transaction generated, or tried to. It is pending from
+// when it is claimed until its transaction is queued. Error is set when the transaction could not be queued;
+// failed and stale pending occurrences are tried again, Attempts times so far.
+type RecurringOccurrence struct {
+	ID            int64     `json:"-"`
+	RecurringID   string    `json:"recurring_id"`
+	Sequence      int64     `json:"sequence"`
+	ScheduledFor  time.Time `json:"scheduled_for"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	Reference     string    `json:"reference"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error,omitempty"`
+	Attempts      int       `json:"attempts"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// RecurringTransactionFilter narrows a recurring transaction listing. ID is the position to continue after.
+type RecurringTransactionFilter struct {
+	ID     int64     `json:"id"`
+	Status string    `json:"status"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+}
+
+// Validate checks that exactly one schedule is set and that the bounds leave room for an occurrence.
+func (r *RecurringTransaction) Validate() error {
+	if (r.Cron == "") == (r.IntervalSeconds == 0) {
+		return errors.New("pass either cron or interval_seconds")
+	}
+	if r.IntervalSeconds < 0 {
+		return errors.New("interval_seconds must be positive")
+	}
+	if r.Cron != "" {
+		if _, err := cron.ParseStandard(r.Cron); err != nil {
+			return fmt.Errorf("invalid cron expression: %w", err)
+		}
+	}
+	if r.MaxOccurrences < 0 {
+		return errors.New("max_occurrences cannot be negative")
+	}
+	if !r.EndAt.IsZero() && !r.EndAt.After(r.StartAt) {
+		return errors.New("end_at must be after start_at")
+	}
+	if r.Template.Reference == "" {
+		return errors.New("template reference is required")
+	}
+	if !r.Template.ScheduledFor.IsZero() || !r.Template.InflightExpiryDate.IsZero() {
+		return errors.New("a template cannot carry scheduled_for or inflight_expiry_date")
+	}
+	return nil
+}
+
+// First returns the first tick of the schedule at or after StartAt.
+func (r *RecurringTransaction) First() (time.Time, error) {
+	if r.IntervalSeconds > 0 {
+		return r.StartAt, nil
+	}
+	return r.Next(r.StartAt.Add(-time.Second))
+}
+
+// Next returns the first tick of the schedule after the given time.
+func (r *RecurringTransaction) Next(after time.Time) (time.Time, error) {
+	if r.IntervalSeconds > 0 {
+		interval := time.Duration(r.IntervalSeconds) * time.Second
+		if after.Before(r.StartAt) {
+			return r.StartAt, nil
+		}
+		return r.StartAt.Add((after.Sub(r.StartAt)/interval + 1) * interval), nil
+	}
+	schedule, err := cron.ParseStandard(r.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(after), nil
+}
+
+// IsExhausted reports whether an occurrence due at the given time would be past the schedule's bounds.
+func (r *RecurringTransaction) IsExhausted(sequence int64, at time.Time) bool {
+	if r.MaxOccurrences > 0 && sequence > r.MaxOccurrences {
+		return true
+	}
+	return !r.EndAt.IsZero() && at.After(r.EndAt)
+}
+
+// OccurrenceReference is the reference of the occurrence due at period. It is the same however many times the
+// period is materialized, so a period can never be posted twice.
+func (r *RecurringTransaction) OccurrenceReference(period time.Time) string {
+	return fmt.Sprintf("%s_%s", r.Template.Reference, period.UTC().Format("20060102T150405Z"))
+}
+
+// Occurrence builds the transaction for the occurrence due at period from the template.
+func (r *RecurringTransaction) Occurrence(sequence int64, period time.Time) *Transaction {
+	transaction := r.Template
+	transaction.Reference = r.OccurrenceReference(period)
+	transaction.Sources = append([]Distribution(nil), r.Template.Sources...)
+	transaction.Destinations = append([]Distribution(nil), r.Template.Destinations...)
+	transaction.MetaData = make(map[string]interface{}, len(r.Template.MetaData)+2)
+	for key, value := range r.Template.MetaData {
+		transaction.MetaData[key] = value
+	}
+	transaction.MetaData["recurring_id"] = r.RecurringID
+	transaction.MetaData["recurring_sequence"] = sequence
+	return &transaction
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurringTransactionNext(t *testing.T) {
+	start := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+
+	interval := RecurringTransaction{IntervalSeconds: 86400, StartAt: start}
+	if first, _ := interval.First(); !first.Equal(start) {
+		t.Errorf("First() got = %v, want %v", first, start)
+	}
+	if next, _ := interval.Next(start.Add(36 * time.Hour)); !next.Equal(start.Add(48 * time.Hour)) {
+		t.Errorf("Next() got = %v, want %v", next, start.Add(48*time.Hour))
+	}
+
+	monthly := RecurringTransaction{Cron: "0 9 1 * *", StartAt: start}
+	want := time.Date(2024, 2, 1, 9, 0, 0, 0, time.UTC)
+	if first, _ := monthly.First(); !first.Equal(want) {
+		t.Errorf("First() got = %v, want %v", first, want)
+	}
+}
+
+func TestRecurringTransactionValidateAndBounds(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	recurring := RecurringTransaction{IntervalSeconds: 60, StartAt: start, EndAt: start.Add(time.Hour), MaxOccurrences: 3, Template: Transaction{Reference: "ref"}}
+	if err := recurring.Validate(); err != nil {
+		t.Fatalf("Validate() got error %v", err)
+	}
+	if recurring.IsExhausted(3, start.Add(time.Minute)) || !recurring.IsExhausted(4, start) || !recurring.IsExhausted(1, start.Add(2*time.Hour)) {
+		t.Errorf("IsExhausted() does not respect max_occurrences and end_at")
+	}
+	if got := recurring.OccurrenceReference(start); got != "ref_20240101T000000Z" {
+		t.Errorf("OccurrenceReference() got = %s", got)
+	}
+
+	for _, invalid := range []RecurringTransaction{
+		{Cron: "0 9 1 * *", IntervalSeconds: 60, Template: Transaction{Reference: "ref"}},
+		{Cron: "not a cron", Template: Transaction{Reference: "ref"}},
+		{IntervalSeconds: 60, Template: Transaction{Reference: "ref", ScheduledFor: start}},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Validate() accepted %+v", invalid)
+		}
+	}
+}
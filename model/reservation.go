@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+const (
+	ReservationHeld      = "HELD"
+	ReservationConverted = "CONVERTED"
+	ReservationReleased  = "RELEASED"
+)
+
+// Reservation sets Amount aside on a source balance while the transaction that will debit it waits in the
+// queue. It is converted once the transaction is applied and released if the transaction is rejected.
+type Reservation struct {
+	TransactionID string    `json:"transaction_id"`
+	BalanceID     string    `json:"balance_id"`
+	Amount        int64     `json:"amount"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+}
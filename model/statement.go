@@ -0,0 +1,204 @@
+package model
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	StatementFormatJSON    = "json"
+	StatementFormatCSV     = "csv"
+	StatementFormatCamt053 = "camt053"
+
+	EntryCredit = "credit"
+	EntryDebit  = "debit"
+)
+
+// Statement lists the applied transactions on a balance between From and To. All figures are in the
+// balance's minor units; Precision converts them back to decimal amounts.
+type Statement struct {
+	StatementID    string           `json:"statement_id"`
+	BalanceID      string           `json:"balance_id"`
+	AccountID      string           `json:"account_id,omitempty"`
+	Currency       string           `json:"currency"`
+	Precision      int64            `json:"precision"`
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	OpeningBalance int64            `json:"opening_balance"`
+	ClosingBalance int64            `json:"closing_balance"`
+	TotalCredits   int64            `json:"total_credits"`
+	TotalDebits    int64            `json:"total_debits"`
+	Entries        []StatementEntry `json:"entries"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+type StatementEntry struct {
+	TransactionID  string    `json:"transaction_id"`
+	Reference      string    `json:"reference"`
+	Description    string    `json:"description,omitempty"`
+	Direction      string    `json:"direction"`
+	Counterparty   string    `json:"counterparty"`
+	Amount         int64     `json:"amount"`
+	RunningBalance int64     `json:"running_balance"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// AddEntry appends an entry and moves the running and closing balances by its amount.
+func (statement *Statement) AddEntry(entry StatementEntry) {
+	if entry.Direction == EntryCredit {
+		statement.ClosingBalance += entry.Amount
+		statement.TotalCredits += entry.Amount
+	} else {
+		statement.ClosingBalance -= entry.Amount
+		statement.TotalDebits += entry.Amount
+	}
+	entry.RunningBalance = statement.ClosingBalance
+	statement.Entries = append(statement.Entries, entry)
+}
+
+// Write renders the statement in one of the StatementFormat* formats.
+func (statement *Statement) Write(w io.Writer, format string) error {
+	switch strings.ToLower(format) {
+	case "", StatementFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(statement)
+	case StatementFormatCSV:
+		return statement.WriteCSV(w)
+	case StatementFormatCamt053:
+		return statement.WriteCamt053(w)
+	}
+	return fmt.Errorf("unsupported statement format '%s'. use json, csv or camt053", format)
+}
+
+// WriteCSV writes one row per entry, framed by opening and closing balance rows.
+func (statement *Statement) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	amount := func(value int64) string {
+		return FromPrecise(value, statement.Precision).String()
+	}
+
+	rows := [][]string{
+		{"date", "transaction_id", "reference", "description", "counterparty", "direction", "amount", "running_balance", "currency"},
+		{statement.From.Format(time.RFC3339), "", "", "Opening balance", "", "", "", amount(statement.OpeningBalance), statement.Currency},
+	}
+	for _, entry := range statement.Entries {
+		rows = append(rows, []string{
+			entry.CreatedAt.Format(time.RFC3339), entry.TransactionID, entry.Reference, entry.Description, entry.Counterparty,
+			entry.Direction, amount(entry.Amount), amount(entry.RunningBalance), statement.Currency,
+		})
+	}
+	rows = append(rows, []string{statement.To.Format(time.RFC3339), "", "", "Closing balance", "", "", "", amount(statement.ClosingBalance), statement.Currency})
+
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtDateTime struct {
+	DateTime string `xml:"DtTm"`
+}
+
+type camtBalance struct {
+	Code              string       `xml:"Tp>CdOrPrtry>Cd"`
+	Amount            camtAmount   `xml:"Amt"`
+	CreditDebitIndctr string       `xml:"CdtDbtInd"`
+	Date              camtDateTime `xml:"Dt"`
+}
+
+type camtEntry struct {
+	EntryRef          string       `xml:"NtryRef"`
+	Amount            camtAmount   `xml:"Amt"`
+	CreditDebitIndctr string       `xml:"CdtDbtInd"`
+	Status            string       `xml:"Sts"`
+	BookingDate       camtDateTime `xml:"BookgDt"`
+	ValueDate         camtDateTime `xml:"ValDt"`
+	ServicerRef       string       `xml:"AcctSvcrRef"`
+	BankTxCode        string       `xml:"BkTxCd>Prtry>Cd"`
+	EndToEndID        string       `xml:"NtryDtls>TxDtls>Refs>EndToEndId"`
+	AdditionalInfo    string       `xml:"NtryDtls>TxDtls>AddtlTxInf,omitempty"`
+}
+
+type camtDocument struct {
+	XMLName       xml.Name      `xml:"urn:iso:std:iso:20022:tech:xsd:camt.053.001.02 Document"`
+	MessageID     string        `xml:"BkToCstmrStmt>GrpHdr>MsgId"`
+	MessageTime   string        `xml:"BkToCstmrStmt>GrpHdr>CreDtTm"`
+	StatementID   string        `xml:"BkToCstmrStmt>Stmt>Id"`
+	StatementTime string        `xml:"BkToCstmrStmt>Stmt>CreDtTm"`
+	FromTime      string        `xml:"BkToCstmrStmt>Stmt>FrToDt>FrDtTm"`
+	ToTime        string        `xml:"BkToCstmrStmt>Stmt>FrToDt>ToDtTm"`
+	AccountID     string        `xml:"BkToCstmrStmt>Stmt>Acct>Id>Othr>Id"`
+	Currency      string        `xml:"BkToCstmrStmt>Stmt>Acct>Ccy"`
+	Balances      []camtBalance `xml:"BkToCstmrStmt>Stmt>Bal"`
+	Entries       []camtEntry   `xml:"BkToCstmrStmt>Stmt>Ntry"`
+}
+
+// WriteCamt053 renders the statement as an ISO 20022 camt.053.001.02 bank-to-customer statement.
+func (statement *Statement) WriteCamt053(w io.Writer) error {
+	amount := func(value int64) (camtAmount, string) {
+		indicator := "CRDT"
+		if value < 0 {
+			value, indicator = -value, "DBIT"
+		}
+		return camtAmount{Currency: statement.Currency, Value: string(FromPrecise(value, statement.Precision))}, indicator
+	}
+	balance := func(code string, value int64, at time.Time) camtBalance {
+		amt, indicator := amount(value)
+		return camtBalance{Code: code, Amount: amt, CreditDebitIndctr: indicator, Date: camtDateTime{DateTime: at.Format(time.RFC3339)}}
+	}
+
+	accountID := statement.BalanceID
+	if statement.AccountID != "" {
+		accountID = statement.AccountID
+	}
+	document := camtDocument{
+		MessageID:     statement.StatementID,
+		MessageTime:   statement.CreatedAt.Format(time.RFC3339),
+		StatementID:   statement.StatementID,
+		StatementTime: statement.CreatedAt.Format(time.RFC3339),
+		FromTime:      statement.From.Format(time.RFC3339),
+		ToTime:        statement.To.Format(time.RFC3339),
+		AccountID:     accountID,
+		Currency:      statement.Currency,
+		Balances: []camtBalance{
+			balance("OPBD", statement.OpeningBalance, statement.From),
+			balance("CLBD", statement.ClosingBalance, statement.To),
+		},
+	}
+	for _, entry := range statement.Entries {
+		amt, indicator := amount(entry.Amount)
+		if entry.Direction == EntryDebit {
+			indicator = "DBIT"
+		}
+		document.Entries = append(document.Entries, camtEntry{
+			EntryRef:          entry.TransactionID,
+			Amount:            amt,
+			CreditDebitIndctr: indicator,
+			Status:            "BOOK",
+			BookingDate:       camtDateTime{DateTime: entry.CreatedAt.Format(time.RFC3339)},
+			ValueDate:         camtDateTime{DateTime: entry.CreatedAt.Format(time.RFC3339)},
+			ServicerRef:       entry.TransactionID,
+			BankTxCode:        "BLNK",
+			EndToEndID:        entry.Reference,
+			AdditionalInfo:    entry.Description,
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(document)
+}
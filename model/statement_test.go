@@ -0,0 +1,80 @@
+package model
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testStatement() *Statement {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	statement := &Statement{
+		StatementID:    "stm_1",
+		BalanceID:      "bln_1",
+		Currency:       "USD",
+		Precision:      100,
+		From:           from,
+		To:             from.AddDate(0, 1, 0),
+		OpeningBalance: 1000,
+		ClosingBalance: 1000,
+		CreatedAt:      from,
+	}
+	statement.AddEntry(StatementEntry{TransactionID: "txn_1", Reference: "ref_1", Direction: EntryCredit, Amount: 2550, CreatedAt: from.Add(time.Hour)})
+	statement.AddEntry(StatementEntry{TransactionID: "txn_2", Reference: "ref_2", Direction: EntryDebit, Amount: 4000, CreatedAt: from.Add(2 * time.Hour)})
+	return statement
+}
+
+func TestStatementAddEntryTracksRunningBalance(t *testing.T) {
+	statement := testStatement()
+
+	if statement.Entries[0].RunningBalance != 3550 || statement.Entries[1].RunningBalance != -450 {
+		t.Errorf("running balances got = %d, %d, want 3550, -450", statement.Entries[0].RunningBalance, statement.Entries[1].RunningBalance)
+	}
+	if statement.ClosingBalance != -450 || statement.TotalCredits != 2550 || statement.TotalDebits != 4000 {
+		t.Errorf("totals got closing %d credits %d debits %d", statement.ClosingBalance, statement.TotalCredits, statement.TotalDebits)
+	}
+}
+
+func TestStatementWriteCSV(t *testing.T) {
+	var out bytes.Buffer
+	if err := testStatement().Write(&out, StatementFormatCSV); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Write() got %d lines, want 5", len(lines))
+	}
+	if !strings.HasSuffix(lines[1], "Opening balance,,,,10,USD") || !strings.HasSuffix(lines[4], "Closing balance,,,,-4.5,USD") {
+		t.Errorf("Write() opening/closing rows got %q and %q", lines[1], lines[4])
+	}
+	if !strings.Contains(lines[2], "txn_1,ref_1,,,credit,25.5,35.5,USD") {
+		t.Errorf("Write() entry row got %q", lines[2])
+	}
+}
+
+func TestStatementWriteCamt053(t *testing.T) {
+	var out bytes.Buffer
+	if err := testStatement().Write(&out, StatementFormatCamt053); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	var document camtDocument
+	if err := xml.Unmarshal(out.Bytes(), &document); err != nil {
+		t.Fatalf("camt.053 output does not parse: %v", err)
+	}
+	if len(document.Balances) != 2 || document.Balances[1].Code != "CLBD" || document.Balances[1].CreditDebitIndctr != "DBIT" || document.Balances[1].Amount.Value != "4.5" {
+		t.Errorf("camt.053 balances got %+v", document.Balances)
+	}
+	if len(document.Entries) != 2 || document.Entries[1].CreditDebitIndctr != "DBIT" || document.Entries[1].Amount.Value != "40" {
+		t.Errorf("camt.053 entries got %+v", document.Entries)
+	}
+}
+
+func TestStatementWriteRejectsUnknownFormat(t *testing.T) {
+	if err := testStatement().Write(&bytes.Buffer{}, "pdf"); err == nil {
+		t.Error("Write() expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// What happens to a credit that would take a balance past its tier's max balance: it is rejected, or it is
+// recorded as an inflight transaction that an operator can commit or void.
+const (
+	TierCapReject = "reject"
+	TierCapHold   = "hold"
+)
+
+// ErrTierCapExceeded is wrapped by the *TierError returned for transactions that go past a KYC tier's caps.
+var ErrTierCapExceeded = errors.New("kyc tier cap exceeded")
+
+// KYCTier is a level of verification an identity can be assigned. Its caps are in minor units of Currency and
+// apply to the identity's balances in that currency; a zero cap is not enforced. MaxBalance caps what a balance
+// may hold, MaxTransaction what a single transaction may move into or out of it, and DailyVolume what the
+// identity's balances may send together each UTC day.
+type KYCTier struct {
+	ID             int64                  `json:"-"`
+	TierID         string                 `json:"tier_id"`
+	Name           string                 `json:"name"`
+	Currency       string                 `json:"currency"`
+	MaxBalance     int64                  `json:"max_balance,omitempty"`
+	MaxTransaction int64                  `json:"max_transaction,omitempty"`
+	DailyVolume    int64                  `json:"daily_volume,omitempty"`
+	CapAction      string                 `json:"cap_action"`
+	CreatedAt      time.Time              `json:"created_at"`
+	MetaData       map[string]interface{} `json:"meta_data,omitempty"`
+}
+
+// TierChange is one entry in the history of an identity's tier. FromTier is empty for an identity's first tier.
+type TierChange struct {
+	ID         int64     `json:"-"`
+	IdentityID string    `json:"identity_id"`
+	FromTier   string    `json:"from_tier"`
+	ToTier     string    `json:"to_tier"`
+	Reason     string    `json:"reason,omitempty"`
+	ChangedBy  string    `json:"changed_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TierError is returned for a transaction that goes past a cap of the tier of one of its balances. Code names
+// the cap, such as tier_max_balance_exceeded.
+type TierError struct {
+	Tier      KYCTier
+	BalanceID string
+	Code      string
+}
+
+func (e *TierError) Error() string {
+	return fmt.Sprintf("%s: balance %s is limited by kyc tier %s", e.Code, e.BalanceID, e.Tier.Name)
+}
+
+func (e *TierError) Unwrap() error {
+	return ErrTierCapExceeded
+}
+
+// Validate checks the tier's name, currency, caps and cap action.
+func (t *KYCTier) Validate() error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	if t.Currency == "" {
+		return errors.New("currency is required")
+	}
+	if t.MaxBalance < 0 || t.MaxTransaction < 0 || t.DailyVolume < 0 {
+		return errors.New("caps cannot be negative")
+	}
+	if t.MaxBalance == 0 && t.MaxTransaction == 0 && t.DailyVolume == 0 {
+		return errors.New("pass max_balance, max_transaction, daily_volume or a combination")
+	}
+	if t.CapAction != TierCapReject && t.CapAction != TierCapHold {
+		return fmt.Errorf("cap_action must be %s or %s", TierCapReject, TierCapHold)
+	}
+	return nil
+}
+
+// AppliesTo reports whether the tier's caps bind the given balance.
+func (t *KYCTier) AppliesTo(balance *Balance) bool {
+	return balance.Currency == t.Currency
+}
+
+// CheckTransaction returns a *TierError when amount is more than a single transaction may move for the balance.
+func (t *KYCTier) CheckTransaction(balanceID string, amount int64) error {
+	if t.MaxTransaction > 0 && amount > t.MaxTransaction {
+		return &TierError{Tier: *t, BalanceID: balanceID, Code: "tier_max_transaction_exceeded"}
+	}
+	return nil
+}
+
+// CheckBalance returns a *TierError when crediting amount would take the balance past the tier's max balance.
+// Inflight credits count, so the cap cannot be sidestepped by holding funds and committing them later.
+func (t *KYCTier) CheckBalance(balance *Balance, amount int64) error {
+	if t.MaxBalance > 0 && balance.Balance+balance.InflightCreditBalance+amount > t.MaxBalance {
+		return &TierError{Tier: *t, BalanceID: balance.BalanceID, Code: "tier_max_balance_exceeded"}
+	}
+	return nil
+}
+
+// CheckCommit returns a *TierError when committing amount of an inflight credit would take the balance past the
+// tier's max balance. The credit is already among the balance's inflight credits, so only what the balance holds
+// counts; other credits still held are checked when they are committed.
+func (t *KYCTier) CheckCommit(balance *Balance, amount int64) error {
+	if t.MaxBalance > 0 && balance.Balance+amount > t.MaxBalance {
+		return &TierError{Tier: *t, BalanceID: balance.BalanceID, Code: "tier_max_balance_exceeded"}
+	}
+	return nil
+}
+
+// DailyLimit returns the tier's daily volume as a spending limit on the identity, so it is counted and enforced
+// like the limits set on the identity directly. ok is false when the tier has no daily volume.
+func (t *KYCTier) DailyLimit(identityID string) (limit SpendingLimit, ok bool) {
+	if t.DailyVolume == 0 {
+		return SpendingLimit{}, false
+	}
+	return SpendingLimit{
+		LimitID:   t.TierID,
+		Scope:     LimitScopeIdentity,
+		ScopeID:   identityID,
+		Window:    LimitWindowDaily,
+		Currency:  t.Currency,
+		MaxAmount: t.DailyVolume,
+		CreatedAt: t.CreatedAt,
+	}, true
+}
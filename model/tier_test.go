@@ -0,0 +1,73 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKYCTierValidate(t *testing.T) {
+	valid := KYCTier{Name: "basic", Currency: "NGN", MaxBalance: 5000000, CapAction: TierCapHold}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() got error %v", err)
+	}
+
+	invalid := []KYCTier{
+		{Currency: "NGN", MaxBalance: 1, CapAction: TierCapReject},
+		{Name: "basic", MaxBalance: 1, CapAction: TierCapReject},
+		{Name: "basic", Currency: "NGN", CapAction: TierCapReject},
+		{Name: "basic", Currency: "NGN", DailyVolume: -1, MaxBalance: 1, CapAction: TierCapReject},
+		{Name: "basic", Currency: "NGN", MaxBalance: 1, CapAction: "queue"},
+	}
+	for _, tier := range invalid {
+		if err := tier.Validate(); err == nil {
+			t.Errorf("Validate() of %+v got no error", tier)
+		}
+	}
+}
+
+func TestKYCTierCaps(t *testing.T) {
+	tier := KYCTier{TierID: "tier_1", Name: "basic", Currency: "NGN", MaxBalance: 10000, MaxTransaction: 5000}
+	balance := &Balance{BalanceID: "bln_1", Currency: "NGN", Balance: 6000, InflightCreditBalance: 2000}
+
+	if err := tier.CheckBalance(balance, 2000); err != nil {
+		t.Errorf("CheckBalance() up to the cap got error %v", err)
+	}
+
+	// Credits still inflight count towards the cap.
+	var tierErr *TierError
+	err := tier.CheckBalance(balance, 2001)
+	if !errors.As(err, &tierErr) || tierErr.Code != "tier_max_balance_exceeded" || tierErr.BalanceID != "bln_1" {
+		t.Errorf("CheckBalance() over the cap got = %v", err)
+	}
+	if !errors.Is(err, ErrTierCapExceeded) {
+		t.Errorf("CheckBalance() error does not wrap ErrTierCapExceeded")
+	}
+
+	err = tier.CheckTransaction("bln_1", 5001)
+	if !errors.As(err, &tierErr) || tierErr.Code != "tier_max_transaction_exceeded" {
+		t.Errorf("CheckTransaction() over the cap got = %v", err)
+	}
+
+	if !tier.AppliesTo(balance) || tier.AppliesTo(&Balance{Currency: "USD"}) {
+		t.Errorf("AppliesTo() should only match balances in the tier's currency")
+	}
+}
+
+func TestKYCTierDailyLimit(t *testing.T) {
+	tier := KYCTier{TierID: "tier_1", Currency: "NGN", MaxBalance: 10000}
+	if _, ok := tier.DailyLimit("idt_1"); ok {
+		t.Errorf("DailyLimit() of a tier without a daily volume should not be ok")
+	}
+
+	tier.DailyVolume = 3000
+	limit, ok := tier.DailyLimit("idt_1")
+	if !ok {
+		t.Fatalf("DailyLimit() got ok = false")
+	}
+	if limit.Scope != LimitScopeIdentity || limit.ScopeID != "idt_1" || limit.Window != LimitWindowDaily || limit.MaxAmount != 3000 || limit.Currency != "NGN" {
+		t.Errorf("DailyLimit() got = %+v", limit)
+	}
+	if err := limit.Validate(); err != nil {
+		t.Errorf("DailyLimit() is not a valid limit: %v", err)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
+	"math/big"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -14,12 +16,24 @@ type Distribution struct {
 	TransactionID string `json:"transaction_id"`
 }
 
+// DistributionLeg is a single source to destination movement resolved from a many-to-many distribution.
+type DistributionLeg struct {
+	Source        string      `json:"source"`
+	Destination   string      `json:"destination"`
+	Amount        json.Number `json:"amount"`
+	PreciseAmount int64       `json:"precise_amount"`
+	TransactionID string      `json:"transaction_id"`
+}
+
 type Transaction struct {
 	ID                 int64                  `json:"-"`
 	PreciseAmount      int64                  `json:"precise_amount,omitempty"`
-	Amount             float64                `json:"amount"`
-	Rate               float64                `json:"rate"`
-	Precision          float64                `json:"precision"`
+	Amount             json.Number            `json:"amount"`
+	Rate               json.Number            `json:"rate"`
+	QuoteID            string                 `json:"quote_id,omitempty"`
+	SourceAmount       json.Number            `json:"source_amount,omitempty"`
+	DestinationAmount  json.Number            `json:"destination_amount,omitempty"`
+	Precision          int64                  `json:"precision"`
 	TransactionID      string                 `json:"transaction_id"`
 	ParentTransaction  string                 `json:"parent_transaction"`
 	Source             string                 `json:"source,omitempty"`
@@ -31,21 +45,58 @@ type Transaction struct {
 	Hash               string                 `json:"hash"`
 	AllowOverdraft     bool                   `json:"allow_overdraft"`
 	Inflight           bool                   `json:"inflight"`
+	Atomic             bool                   `json:"atomic"`
+	Reserve            bool                   `json:"reserve,omitempty"`
+	ReservedAmount     int64                  `json:"reserved_amount,omitempty"`
 	SkipBalanceUpdate  bool                   `json:"-"`
-	GroupIds           []string               `json:"-"`
+	SkipStatusCheck    bool                   `json:"-"`
+	LimitCharges       []LimitCharge          `json:"-"`
+	ReleasedAmount     int64                  `json:"-"` // What a void gives back of the spending limits its parent was charged
+	GroupID            string                 `json:"group_id,omitempty"`
 	Sources            []Distribution         `json:"sources,omitempty"`
 	Destinations       []Distribution         `json:"destinations,omitempty"`
+	Legs               []DistributionLeg      `json:"legs,omitempty"`
 	CreatedAt          time.Time              `json:"created_at"`
 	ScheduledFor       time.Time              `json:"scheduled_for,omitempty"`
 	InflightExpiryDate time.Time              `json:"inflight_expiry_date,omitempty"`
 	MetaData           map[string]interface{} `json:"meta_data,omitempty"`
 }
 
+// TransactionResult is the outcome of a synchronously recorded transaction along with the balances it left behind.
+type TransactionResult struct {
+	Transaction *Transaction      `json:"transaction"`
+	Balances    []BalanceSnapshot `json:"balances"`
+}
+
+// TransactionSimulation is the projected outcome of a transaction that was run without being recorded.
+type TransactionSimulation struct {
+	Transaction     *Transaction   `json:"transaction"`
+	Status          string         `json:"status"`
+	RejectionReason string         `json:"rejection_reason,omitempty"`
+	RejectionCode   string         `json:"rejection_code,omitempty"`
+	Legs            []*Transaction `json:"legs,omitempty"`
+	Balances        []*Balance     `json:"balances,omitempty"`
+}
+
 func (transaction *Transaction) ToJSON() ([]byte, error) {
 	return json.Marshal(transaction)
 }
 
+// HasDistributions reports whether the transaction fans out to multiple sources or destinations.
+func (transaction *Transaction) HasDistributions() bool {
+	return len(transaction.Sources) > 0 || len(transaction.Destinations) > 0
+}
+
+// IsManyToMany reports whether the transaction has both multiple sources and multiple destinations.
+func (transaction *Transaction) IsManyToMany() bool {
+	return len(transaction.Sources) > 0 && len(transaction.Destinations) > 0
+}
+
 func (transaction *Transaction) SplitTransaction() ([]*Transaction, error) {
+	if transaction.IsManyToMany() {
+		return transaction.splitManyToMany()
+	}
+
 	var ds []Distribution
 
 	if len(transaction.Sources) > 0 {
@@ -55,63 +106,192 @@ func (transaction *Transaction) SplitTransaction() ([]*Transaction, error) {
 		ds = transaction.Destinations
 	}
 
-	distributions, err := CalculateDistributions(transaction.Amount, ds)
+	if len(ds) == 0 {
+		return nil, nil
+	}
+
+	totalAmount, err := ApplyPrecision(transaction)
 	if err != nil {
 		return nil, err
 	}
 
+	distributions, err := CalculateDistributions(totalAmount, ds, transaction.Precision)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDistribution, err)
+	}
+
 	var transactions []*Transaction
-	counter := 1
-	for direction, amount := range distributions {
-		newTransaction := *transaction                               // Create a copy of the original transaction
-		newTransaction.TransactionID = GenerateUUIDWithSuffix("txn") // Set the transacrtionid
-		newTransaction.Hash = newTransaction.HashTxn()               // Set the transacrtion hash
-		newTransaction.Amount = amount                               // Set the amount based on the distribution
-		newTransaction.Sources = nil                                 // Clear the Sources slice since we're dealing with individual sources now
-		newTransaction.Destinations = nil                            // Clear the Sources slice since we're dealing with individual sources now
+	// Walk the distributions in request order so each leg lines up with the entry it was computed from.
+	for i, dist := range ds {
+		newTransaction := *transaction // Create a copy of the original transaction
+		newTransaction.TransactionID = dist.TransactionID
+		if newTransaction.TransactionID == "" {
+			newTransaction.TransactionID = GenerateUUIDWithSuffix("txn") // Set the transacrtionid
+		}
+		newTransaction.PreciseAmount = distributions[dist.Identifier] // Set the amount based on the distribution
+		newTransaction.Amount = FromPrecise(newTransaction.PreciseAmount, transaction.Precision)
+		newTransaction.Sources = nil      // Clear the Sources slice since we're dealing with individual sources now
+		newTransaction.Destinations = nil // Clear the Sources slice since we're dealing with individual sources now
 		if len(transaction.Sources) > 0 {
-			newTransaction.Source = direction // Set the source
-			transaction.Sources[counter-1].TransactionID = newTransaction.TransactionID
+			newTransaction.Source = dist.Identifier // Set the source
+			transaction.Sources[i].TransactionID = newTransaction.TransactionID
+
+		} else {
+			newTransaction.Destination = dist.Identifier // Set the destination
+			transaction.Destinations[i].TransactionID = newTransaction.TransactionID
+		}
+
+		newTransaction.Reference = fmt.Sprintf("%s-%d", transaction.Reference, i+1)
+		newTransaction.Hash = newTransaction.HashTxn() // Set the transacrtion hash
+		transactions = append(transactions, &newTransaction)
+	}
+
+	return transactions, nil
+}
 
-		} else if len(transaction.Destinations) > 0 {
-			newTransaction.Destination = direction // Set the destination
-			transaction.Destinations[counter-1].TransactionID = newTransaction.TransactionID
+// splitManyToMany turns the resolved legs into transactions. Legs computed earlier, for example when the
+// transaction was queued, are reused so the IDs returned to the caller match what gets posted.
+func (transaction *Transaction) splitManyToMany() ([]*Transaction, error) {
+	if len(transaction.Legs) == 0 {
+		totalAmount, err := ApplyPrecision(transaction)
+		if err != nil {
+			return nil, err
+		}
+		legs, err := ResolveDistributionLegs(totalAmount, transaction.Sources, transaction.Destinations, transaction.Precision)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidDistribution, err)
+		}
+		for i := range legs {
+			legs[i].TransactionID = GenerateUUIDWithSuffix("txn")
 		}
+		transaction.Legs = legs
+	}
 
-		newTransaction.Reference = fmt.Sprintf("%s-%d", transaction.Reference, counter)
-		counter++
+	transactions := make([]*Transaction, 0, len(transaction.Legs))
+	for i, leg := range transaction.Legs {
+		newTransaction := *transaction
+		newTransaction.TransactionID = leg.TransactionID
+		newTransaction.Source = leg.Source
+		newTransaction.Destination = leg.Destination
+		newTransaction.Amount = leg.Amount
+		newTransaction.PreciseAmount = leg.PreciseAmount
+		newTransaction.Sources = nil
+		newTransaction.Destinations = nil
+		newTransaction.Legs = nil
+		newTransaction.Reference = fmt.Sprintf("%s-%d", transaction.Reference, i+1)
+		newTransaction.Hash = newTransaction.HashTxn()
 		transactions = append(transactions, &newTransaction)
 	}
 
 	return transactions, nil
 }
 
-// CalculateDistributions calculates and returns the amount for each identifier (source or destination) based on its distribution.
-func CalculateDistributions(totalAmount float64, distributions []Distribution) (map[string]float64, error) {
-	resultDistributions := make(map[string]float64)
+// ResolveDistributionLegs works out how much each source sends and each destination receives, nets out
+// identifiers that appear on both sides, then pairs senders with receivers in request order.
+// Amounts are in minor units.
+func ResolveDistributionLegs(totalAmount int64, sources, destinations []Distribution, precision int64) ([]DistributionLeg, error) {
+	sent, err := CalculateDistributions(totalAmount, sources, precision)
+	if err != nil {
+		return nil, fmt.Errorf("sources: %w", err)
+	}
+	received, err := CalculateDistributions(totalAmount, destinations, precision)
+	if err != nil {
+		return nil, fmt.Errorf("destinations: %w", err)
+	}
+
+	var order []string
+	net := make(map[string]int64)
+	for _, dist := range append(append([]Distribution{}, sources...), destinations...) {
+		if _, ok := net[dist.Identifier]; !ok {
+			order = append(order, dist.Identifier)
+			net[dist.Identifier] = received[dist.Identifier] - sent[dist.Identifier]
+		}
+	}
+
+	type position struct {
+		identifier string
+		amount     int64
+	}
+	var senders, receivers []position
+	for _, identifier := range order {
+		if net[identifier] < 0 {
+			senders = append(senders, position{identifier, -net[identifier]})
+		} else if net[identifier] > 0 {
+			receivers = append(receivers, position{identifier, net[identifier]})
+		}
+	}
+
+	var legs []DistributionLeg
+	i, j := 0, 0
+	for i < len(senders) && j < len(receivers) {
+		amount := senders[i].amount
+		if receivers[j].amount < amount {
+			amount = receivers[j].amount
+		}
+		legs = append(legs, DistributionLeg{Source: senders[i].identifier, Destination: receivers[j].identifier, Amount: FromPrecise(amount, precision), PreciseAmount: amount})
+		senders[i].amount -= amount
+		receivers[j].amount -= amount
+		if senders[i].amount == 0 {
+			i++
+		}
+		if receivers[j].amount == 0 {
+			j++
+		}
+	}
+
+	if i < len(senders) || j < len(receivers) {
+		return nil, errors.New("sources and destinations do not distribute the same amount")
+	}
+
+	return legs, nil
+}
+
+// CalculateDistributions calculates and returns the amount, in minor units, for each identifier (source or
+// destination) based on its distribution. Fixed amounts are decimals in major units and are converted with
+// precision. Percentages are floored, and whatever the floors leave behind goes to the "left" identifier. When
+// there is no "left" identifier and the distributions cover the whole amount, the rounding remainder is handed
+// out one minor unit at a time to the percentage shares with the largest remainders, earliest first. Distributions
+// that cover less than the whole amount, without a "left" identifier, only move what they ask for.
+func CalculateDistributions(totalAmount int64, distributions []Distribution, precision int64) (map[string]int64, error) {
+	resultDistributions := make(map[string]int64)
 	var amountLeft = totalAmount
-	var totalPercentage float64 = 0
-	var fixedTotal float64 = 0
+	var fixedTotal int64 = 0
+	totalPercentage := new(big.Rat)
+	hundred := big.NewRat(100, 1)
+
+	type percentageShare struct {
+		identifier string
+		remainder  *big.Rat
+	}
+	var shares []percentageShare
+	leftIdentifier := ""
 
 	// First pass: calculate fixed and percentage amounts, track total percentage
 	for _, dist := range distributions {
-		fmt.Println(dist)
+		if _, exists := resultDistributions[dist.Identifier]; exists {
+			return nil, fmt.Errorf("identifier %s appears more than once", dist.Identifier)
+		}
 		if dist.Distribution == "left" {
+			if leftIdentifier != "" {
+				return nil, errors.New("multiple identifiers with 'left' distribution")
+			}
+			leftIdentifier = dist.Identifier
 			continue // Handle "left" distribution later
-		} else if dist.Distribution[len(dist.Distribution)-1] == '%' {
+		} else if strings.HasSuffix(dist.Distribution, "%") {
 			// Percentage distribution
-			percentage, err := strconv.ParseFloat(dist.Distribution[:len(dist.Distribution)-1], 64)
-			if err != nil {
+			percentage, err := parseDecimal(strings.TrimSuffix(dist.Distribution, "%"))
+			if err != nil || percentage.Sign() < 0 {
 				return nil, errors.New("invalid percentage format")
 			}
-			totalPercentage += percentage
-			amount := (percentage / 100) * totalAmount
+			totalPercentage.Add(totalPercentage, percentage)
+			amount, remainder := floorShare(totalAmount, new(big.Rat).Quo(percentage, hundred))
 			resultDistributions[dist.Identifier] = amount
+			shares = append(shares, percentageShare{dist.Identifier, remainder})
 			amountLeft -= amount
 		} else {
 			// Fixed amount distribution
-			fixedAmount, err := strconv.ParseFloat(dist.Distribution, 64)
-			if err != nil {
+			fixedAmount, err := ToPrecise(json.Number(dist.Distribution), precision)
+			if err != nil || fixedAmount < 0 {
 				return nil, errors.New("invalid fixed amount format")
 			}
 			if fixedAmount > amountLeft {
@@ -124,19 +304,30 @@ func CalculateDistributions(totalAmount float64, distributions []Distribution) (
 	}
 
 	// Validate total percentage and fixed amounts do not exceed 100% or total amount
-	if totalPercentage > 100 || fixedTotal > totalAmount {
+	if totalPercentage.Cmp(hundred) > 0 || fixedTotal > totalAmount || amountLeft < 0 {
 		return nil, errors.New("total distributions exceed 100% or total amount")
 	}
 
 	// Second pass: calculate "left" distribution
-	for _, dist := range distributions {
-		if dist.Distribution == "left" {
-			if _, exists := resultDistributions[dist.Identifier]; exists {
-				return nil, errors.New("multiple identifiers with 'left' distribution")
-			}
-			resultDistributions[dist.Identifier] = amountLeft
-			break // Only one identifier should have "left" distribution
-		}
+	if leftIdentifier != "" {
+		resultDistributions[leftIdentifier] = amountLeft
+		return resultDistributions, nil
+	}
+
+	// Without a "left" identifier the rounding remainder is only handed out when the shares add up to the whole
+	// amount before rounding; otherwise what is left was never asked for.
+	requested := new(big.Rat).Mul(new(big.Rat).SetInt64(totalAmount), new(big.Rat).Quo(totalPercentage, hundred))
+	requested.Add(requested, new(big.Rat).SetInt64(fixedTotal))
+	if requested.Cmp(new(big.Rat).SetInt64(totalAmount)) != 0 {
+		return resultDistributions, nil
+	}
+
+	sort.SliceStable(shares, func(i, j int) bool {
+		return shares[i].remainder.Cmp(shares[j].remainder) > 0
+	})
+	for i := 0; amountLeft > 0; i++ {
+		resultDistributions[shares[i%len(shares)].identifier]++
+		amountLeft--
 	}
 
 	return resultDistributions, nil
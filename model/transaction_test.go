@@ -1,16 +1,20 @@
 package model
 
 import (
+	"encoding/json"
+	"errors"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestCalculateDistributions(t *testing.T) {
 	tests := []struct {
 		name          string
-		totalAmount   float64
+		totalAmount   int64
+		precision     int64
 		distributions []Distribution
-		want          map[string]float64
+		want          map[string]int64
 		wantErr       bool
 	}{
 		{
@@ -21,13 +25,68 @@ func TestCalculateDistributions(t *testing.T) {
 				{Identifier: "B", Distribution: "50%"},  // Percentage
 				{Identifier: "C", Distribution: "left"}, // Leftover
 			},
-			want: map[string]float64{
+			want: map[string]int64{
 				"A": 200, // Fixed
 				"B": 500, // 50% of 1000
 				"C": 300, // Leftover (1000 - 200 - 500)
 			},
 			wantErr: false,
 		},
+		{
+			name:        "Fixed decimal amount uses precision",
+			totalAmount: 1000,
+			precision:   100,
+			distributions: []Distribution{
+				{Identifier: "A", Distribution: "0.29"},
+				{Identifier: "B", Distribution: "left"},
+			},
+			want: map[string]int64{
+				"A": 29,
+				"B": 971,
+			},
+			wantErr: false,
+		},
+		{
+			name:        "Rounding remainder goes to the largest remainders",
+			totalAmount: 100,
+			distributions: []Distribution{
+				{Identifier: "A", Distribution: "33.5%"},
+				{Identifier: "B", Distribution: "33.5%"},
+				{Identifier: "C", Distribution: "33%"},
+			},
+			want: map[string]int64{
+				"A": 34,
+				"B": 33,
+				"C": 33,
+			},
+			wantErr: false,
+		},
+		{
+			name:        "Distributions that do not cover the amount only move what they ask for",
+			totalAmount: 1000,
+			distributions: []Distribution{
+				{Identifier: "A", Distribution: "30%"},
+				{Identifier: "B", Distribution: "100"},
+			},
+			want: map[string]int64{
+				"A": 300,
+				"B": 100,
+			},
+			wantErr: false,
+		},
+		{
+			name:        "Partial percentages are floored without a remainder",
+			totalAmount: 100,
+			distributions: []Distribution{
+				{Identifier: "A", Distribution: "33.5%"},
+				{Identifier: "B", Distribution: "33.5%"},
+			},
+			want: map[string]int64{
+				"A": 33,
+				"B": 33,
+			},
+			wantErr: false,
+		},
 		{
 			name:        "Exceeding Percentage",
 			totalAmount: 1000,
@@ -50,7 +109,7 @@ func TestCalculateDistributions(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := CalculateDistributions(tt.totalAmount, tt.distributions)
+			got, err := CalculateDistributions(tt.totalAmount, tt.distributions, tt.precision)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CalculateDistributions() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -61,3 +120,196 @@ func TestCalculateDistributions(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitTransactionKeepsDistributionOrder(t *testing.T) {
+	transaction := &Transaction{
+		Reference: "ref",
+		Amount:    "1000",
+		Source:    "source",
+		Destinations: []Distribution{
+			{Identifier: "A", Distribution: "10%"},
+			{Identifier: "B", Distribution: "left", TransactionID: "txn_existing"},
+			{Identifier: "C", Distribution: "200"},
+		},
+	}
+
+	legs, err := transaction.SplitTransaction()
+	if err != nil {
+		t.Fatalf("SplitTransaction() error = %v", err)
+	}
+
+	wantDestinations := []string{"A", "B", "C"}
+	wantAmounts := []json.Number{"100", "700", "200"}
+	for i, leg := range legs {
+		if leg.Destination != wantDestinations[i] || leg.Amount != wantAmounts[i] {
+			t.Errorf("leg %d got = %s %v, want %s %v", i, leg.Destination, leg.Amount, wantDestinations[i], wantAmounts[i])
+		}
+		if transaction.Destinations[i].TransactionID != leg.TransactionID {
+			t.Errorf("leg %d transaction id not recorded on distribution", i)
+		}
+	}
+	if legs[1].TransactionID != "txn_existing" {
+		t.Errorf("SplitTransaction() should reuse an existing leg transaction id, got %s", legs[1].TransactionID)
+	}
+}
+
+func TestResolveDistributionLegs(t *testing.T) {
+	tests := []struct {
+		name         string
+		totalAmount  int64
+		sources      []Distribution
+		destinations []Distribution
+		want         []DistributionLeg
+		wantErr      bool
+	}{
+		{
+			name:        "Two funding balances to three payees",
+			totalAmount: 1000,
+			sources: []Distribution{
+				{Identifier: "S1", Distribution: "60%"},
+				{Identifier: "S2", Distribution: "left"},
+			},
+			destinations: []Distribution{
+				{Identifier: "D1", Distribution: "500"},
+				{Identifier: "D2", Distribution: "30%"},
+				{Identifier: "D3", Distribution: "left"},
+			},
+			want: []DistributionLeg{
+				{Source: "S1", Destination: "D1", Amount: "500", PreciseAmount: 500},
+				{Source: "S1", Destination: "D2", Amount: "100", PreciseAmount: 100},
+				{Source: "S2", Destination: "D2", Amount: "200", PreciseAmount: 200},
+				{Source: "S2", Destination: "D3", Amount: "200", PreciseAmount: 200},
+			},
+		},
+		{
+			name:        "Identifier on both sides is netted",
+			totalAmount: 100,
+			sources: []Distribution{
+				{Identifier: "A", Distribution: "50%"},
+				{Identifier: "B", Distribution: "left"},
+			},
+			destinations: []Distribution{
+				{Identifier: "A", Distribution: "20%"},
+				{Identifier: "C", Distribution: "left"},
+			},
+			want: []DistributionLeg{
+				{Source: "A", Destination: "C", Amount: "30", PreciseAmount: 30},
+				{Source: "B", Destination: "C", Amount: "50", PreciseAmount: 50},
+			},
+		},
+		{
+			name:        "Sides that do not add up",
+			totalAmount: 100,
+			sources: []Distribution{
+				{Identifier: "A", Distribution: "50%"},
+			},
+			destinations: []Distribution{
+				{Identifier: "C", Distribution: "left"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveDistributionLegs(tt.totalAmount, tt.sources, tt.destinations, 1)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ResolveDistributionLegs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ResolveDistributionLegs() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateBalancesRespectsReservedFunds(t *testing.T) {
+	newSource := func() *Balance {
+		return &Balance{Balance: 1000, CreditBalance: 1000, ReservedBalance: 800, CurrencyMultiplier: 100}
+	}
+
+	// Only 200 is left once the reservation is set aside.
+	unreserved := &Transaction{PreciseAmount: 500, Precision: 100, Currency: "USD"}
+	if err := UpdateBalances(unreserved, newSource(), &Balance{CurrencyMultiplier: 100}); err == nil {
+		t.Fatal("UpdateBalances() expected insufficient funds for a transaction without a reservation")
+	}
+
+	// A transaction may spend the funds reserved for it.
+	source := newSource()
+	reserved := &Transaction{PreciseAmount: 500, Precision: 100, Currency: "USD", ReservedAmount: 500}
+	if err := UpdateBalances(reserved, source, &Balance{CurrencyMultiplier: 100}); err != nil {
+		t.Fatalf("UpdateBalances() error = %v", err)
+	}
+	if source.Balance != 500 || source.ReservedBalance != 300 || source.AvailableBalance != 200 {
+		t.Errorf("source balance = %d, reserved = %d, available = %d, want 500, 300, 200", source.Balance, source.ReservedBalance, source.AvailableBalance)
+	}
+}
+
+func TestUpdateBalancesEnforcesOverdraftLimit(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	newSource := func(expiresAt *time.Time) *Balance {
+		return &Balance{Balance: 1000, CreditBalance: 1000, OverdraftLimit: 500, OverdraftExpiresAt: expiresAt, CurrencyMultiplier: 100}
+	}
+
+	// 1000 on the balance and 500 of credit cover exactly 1500.
+	source := newSource(nil)
+	if err := UpdateBalances(&Transaction{PreciseAmount: 1500, Precision: 100, Currency: "USD"}, source, &Balance{CurrencyMultiplier: 100}); err != nil {
+		t.Fatalf("UpdateBalances() error = %v", err)
+	}
+	if source.Balance != -500 || source.AvailableCredit != 0 {
+		t.Errorf("source balance = %d, available credit = %d, want -500, 0", source.Balance, source.AvailableCredit)
+	}
+
+	if err := UpdateBalances(&Transaction{PreciseAmount: 1501, Precision: 100, Currency: "USD"}, newSource(nil), &Balance{CurrencyMultiplier: 100}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("UpdateBalances() past the limit got = %v, want ErrInsufficientFunds", err)
+	}
+	if err := UpdateBalances(&Transaction{PreciseAmount: 1100, Precision: 100, Currency: "USD"}, newSource(&expired), &Balance{CurrencyMultiplier: 100}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("UpdateBalances() with an expired limit got = %v, want ErrInsufficientFunds", err)
+	}
+
+	partial := &Balance{Balance: -200, OverdraftLimit: 500}
+	partial.ComputeAvailableBalance()
+	if partial.AvailableCredit != 300 {
+		t.Errorf("available credit = %d, want 300", partial.AvailableCredit)
+	}
+}
+
+func TestUpdateBalancesEnforcesBalanceStatus(t *testing.T) {
+	tests := []struct {
+		name              string
+		sourceStatus      string
+		destinationStatus string
+		wantErr           bool
+	}{
+		{"active balances", BalanceActive, BalanceActive, false},
+		{"balances saved before statuses", "", "", false},
+		{"frozen source", BalanceFrozen, BalanceActive, true},
+		{"frozen destination", BalanceActive, BalanceFrozen, true},
+		{"debit-blocked source", BalanceDebitBlocked, BalanceActive, true},
+		{"debit-blocked destination", BalanceActive, BalanceDebitBlocked, false},
+		{"credit-blocked source", BalanceCreditBlocked, BalanceActive, false},
+		{"credit-blocked destination", BalanceActive, BalanceCreditBlocked, true},
+		{"closed destination", BalanceActive, BalanceClosed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &Balance{Balance: 1000, CreditBalance: 1000, CurrencyMultiplier: 100, Status: tt.sourceStatus}
+			destination := &Balance{CurrencyMultiplier: 100, Status: tt.destinationStatus}
+			err := UpdateBalances(&Transaction{PreciseAmount: 500, Precision: 100, Currency: "USD"}, source, destination)
+			if tt.wantErr != errors.Is(err, ErrBalanceStatus) {
+				t.Errorf("UpdateBalances() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && (source.Balance != 1000 || destination.Balance != 0) {
+				t.Errorf("a rejected transaction changed the balances")
+			}
+		})
+	}
+
+	// The sweep of a balance that is being closed is let through.
+	source := &Balance{Balance: 1000, CreditBalance: 1000, CurrencyMultiplier: 100, Status: BalanceFrozen}
+	if err := UpdateBalances(&Transaction{PreciseAmount: 1000, Precision: 100, Currency: "USD", SkipStatusCheck: true}, source, &Balance{CurrencyMultiplier: 100}); err != nil {
+		t.Errorf("UpdateBalances() skipping the status check error = %v", err)
+	}
+}
@@ -3,6 +3,7 @@ package blnk
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"log"
@@ -12,16 +13,22 @@ import (
 
 	"github.com/hibiken/asynq"
 	"github.com/northstar-pay/nucleus/model"
+	"github.com/redis/go-redis/v9"
 )
 
 const TRANSACTION_QUEUE = "new:transaction"
 const WEBHOOK_QUEUE = "new:webhoook"
 const EXPIREDINFLIGHT_QUEUE = "new:inflight-expiry"
-const NumberOfQueues = 20
+
+// reshardPollInterval is how often a drain checks whether the old transaction queues are empty.
+const reshardPollInterval = time.Second
 
 type Queue struct {
-	Client    *asynq.Client
-	Inspector *asynq.Inspector
+	Client            *asynq.Client
+	Inspector         *asynq.Inspector
+	transactionQueues int
+	// redis is the queues' Redis, where scheduled transactions are indexed by ID next to their tasks.
+	redis redis.UniversalClient
 }
 
 type TransactionTypePayload struct {
@@ -32,10 +39,35 @@ func NewQueue(conf *config.Configuration) *Queue {
 	client := asynq.NewClient(asynq.RedisClientOpt{Addr: conf.Redis.Dns, Password: conf.Redis.Password})
 	inspector := asynq.NewInspector(asynq.RedisClientOpt{Addr: conf.Redis.Dns, Password: conf.Redis.Password})
 	return &Queue{
-		Client:    client,
-		Inspector: inspector,
+		Client:            client,
+		Inspector:         inspector,
+		transactionQueues: conf.Queue.TransactionQueues,
+		redis:             redis.NewClient(&redis.Options{Addr: conf.Redis.Dns, Password: conf.Redis.Password}),
 	}
 }
+
+// TransactionQueueName names queue index (1-based) of count transaction queues. The default count keeps the
+// original names, so deployments that never resharded have nothing to drain.
+func TransactionQueueName(index, count int) string {
+	if count == config.DEFAULT_TRANSACTION_QUEUES {
+		return fmt.Sprintf("%s_%d", TRANSACTION_QUEUE, index)
+	}
+	return fmt.Sprintf("%s_%d_of_%d", TRANSACTION_QUEUE, index, count)
+}
+
+// TransactionQueues returns the names of count transaction queues.
+func TransactionQueues(count int) []string {
+	queues := make([]string, 0, count)
+	for i := 1; i <= count; i++ {
+		queues = append(queues, TransactionQueueName(i, count))
+	}
+	return queues
+}
+
+// transactionQueueFor picks the queue a balance's transactions go to when there are count queues.
+func transactionQueueFor(balanceID string, count int) string {
+	return TransactionQueueName(hashBalanceID(balanceID)%count+1, count)
+}
 func (q *Queue) queueInflightExpiry(transactionID string, expiresAt time.Time) error {
 	IPayload, err := json.Marshal(transactionID)
 	if err != nil {
@@ -52,11 +84,16 @@ func (q *Queue) queueInflightExpiry(transactionID string, expiresAt time.Time) e
 	return nil
 }
 
-func (q *Queue) Enqueue(_ context.Context, transaction *model.Transaction) error {
+func (q *Queue) Enqueue(ctx context.Context, transaction *model.Transaction) error {
 	payload, err := json.Marshal(transaction)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if !transaction.ScheduledFor.IsZero() {
+		if err := q.indexScheduledTask(ctx, transaction, transaction.ScheduledFor); err != nil {
+			return err
+		}
+	}
 	info, err := q.Client.Enqueue(q.geTask(transaction, payload), asynq.MaxRetry(5))
 	if err != nil {
 		log.Println(err, info)
@@ -73,9 +110,7 @@ func (q *Queue) Enqueue(_ context.Context, transaction *model.Transaction) error
 }
 
 func (q *Queue) geTask(transaction *model.Transaction, payload []byte) *asynq.Task {
-	// Hash the balance ID and use modulo to select a queue
-	queueIndex := hashBalanceID(transaction.Source) % NumberOfQueues
-	queueName := fmt.Sprintf("%s_%d", TRANSACTION_QUEUE, queueIndex+1) // Queue names are 1-based
+	queueName := transactionQueueFor(transaction.Source, q.transactionQueues)
 
 	taskOptions := []asynq.Option{asynq.TaskID(transaction.Reference), asynq.Queue(queueName)}
 
@@ -91,3 +126,105 @@ func hashBalanceID(balanceID string) int {
 	_, _ = hasher.Write([]byte(balanceID))
 	return int(hasher.Sum32())
 }
+
+// DrainTransactionQueues finishes a reshard from the from count of transaction queues to the to count. Scheduled
+// transactions are moved to the queue the new count routes them to, then it waits until the old queues have
+// nothing pending, running or waiting to be retried. Until then, taking transactions from the new queues could
+// let a balance's newer transactions overtake older ones.
+func (q *Queue) DrainTransactionQueues(ctx context.Context, from, to int) error {
+	queues, err := q.existingQueues(TransactionQueues(from))
+	if err != nil {
+		return err
+	}
+	for _, queue := range queues {
+		if err := q.moveScheduledTasks(queue, to); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(reshardPollInterval)
+	defer ticker.Stop()
+	for {
+		drained, err := q.transactionQueuesDrained(from)
+		if err != nil || drained {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// moveScheduledTasks re-enqueues the scheduled transactions of an old queue where the to count routes them,
+// keeping their IDs and due times. A task moved by another worker already is skipped.
+func (q *Queue) moveScheduledTasks(queue string, to int) error {
+	for {
+		tasks, err := q.Inspector.ListScheduledTasks(queue, asynq.PageSize(100))
+		if err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return nil
+		}
+
+		for _, task := range tasks {
+			var transaction model.Transaction
+			if err := json.Unmarshal(task.Payload, &transaction); err != nil {
+				return err
+			}
+			queueName := transactionQueueFor(transaction.Source, to)
+			_, err := q.Client.Enqueue(asynq.NewTask(queueName, task.Payload), asynq.TaskID(task.ID), asynq.Queue(queueName),
+				asynq.ProcessAt(task.NextProcessAt), asynq.MaxRetry(task.MaxRetry))
+			if err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+				return err
+			}
+			if err := q.Inspector.DeleteTask(queue, task.ID); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+				return err
+			}
+		}
+	}
+}
+
+// transactionQueuesDrained reports whether none of count transaction queues hold work that must finish first.
+func (q *Queue) transactionQueuesDrained(count int) (bool, error) {
+	queues, err := q.existingQueues(TransactionQueues(count))
+	if err != nil {
+		return false, err
+	}
+	for _, queue := range queues {
+		info, err := q.Inspector.GetQueueInfo(queue)
+		if err != nil {
+			return false, err
+		}
+		if info.Pending+info.Active+info.Scheduled+info.Retry > 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// existingQueues returns the named queues that exist in Redis; asynq creates a queue with its first task.
+func (q *Queue) existingQueues(names []string) ([]string, error) {
+	all, err := q.Inspector.Queues()
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(all))
+	for _, queue := range all {
+		existing[queue] = true
+	}
+	var queues []string
+	for _, name := range names {
+		if existing[name] {
+			queues = append(queues, name)
+		}
+	}
+	return queues, nil
+}
+
+// DrainTransactionQueues waits for a reshard of the transaction queues to finish; see Queue.DrainTransactionQueues.
+func (l *Blnk) DrainTransactionQueues(ctx context.Context, from, to int) error {
+	return l.queue.DrainTransactionQueues(ctx, from, to)
+}
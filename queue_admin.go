@@ -0,0 +1,166 @@
+package blnk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hibiken/asynq"
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// GetQueueStats returns the depth and latency of every queue, in name order.
+func (l *Blnk) GetQueueStats() ([]model.QueueStats, error) {
+	queues, err := l.queue.Inspector.Queues()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(queues)
+
+	stats := make([]model.QueueStats, 0, len(queues))
+	for _, queue := range queues {
+		info, err := l.queue.Inspector.GetQueueInfo(queue)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, model.QueueStats{
+			Queue:     info.Queue,
+			Size:      info.Size,
+			Pending:   info.Pending,
+			Active:    info.Active,
+			Scheduled: info.Scheduled,
+			Retry:     info.Retry,
+			Archived:  info.Archived,
+			Latency:   info.Latency,
+			LatencyMs: info.Latency.Milliseconds(),
+			Paused:    info.Paused,
+		})
+	}
+	return stats, nil
+}
+
+// ListQueueTasks returns one page (1-based) of the tasks in a queue that are in the given state.
+func (l *Blnk) ListQueueTasks(queue, state string, page, limit int) ([]model.QueueTask, error) {
+	if page < 1 {
+		page = 1
+	}
+	opts := []asynq.ListOption{asynq.Page(page), asynq.PageSize(model.PageLimit(limit))}
+
+	var tasks []*asynq.TaskInfo
+	var err error
+	switch state {
+	case model.TaskStatePending:
+		tasks, err = l.queue.Inspector.ListPendingTasks(queue, opts...)
+	case model.TaskStateActive:
+		tasks, err = l.queue.Inspector.ListActiveTasks(queue, opts...)
+	case model.TaskStateScheduled:
+		tasks, err = l.queue.Inspector.ListScheduledTasks(queue, opts...)
+	case model.TaskStateRetry:
+		tasks, err = l.queue.Inspector.ListRetryTasks(queue, opts...)
+	case model.TaskStateArchived:
+		tasks, err = l.queue.Inspector.ListArchivedTasks(queue, opts...)
+	default:
+		return nil, fmt.Errorf("unknown task state %q; use pending, active, scheduled, retry or archived", state)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.QueueTask, 0, len(tasks))
+	for _, task := range tasks {
+		result = append(result, toQueueTask(task))
+	}
+	return result, nil
+}
+
+// RedriveArchivedTask puts an archived task back in its queue to be processed again.
+func (l *Blnk) RedriveArchivedTask(queue, id string) error {
+	if _, err := l.archivedTask(queue, id); err != nil {
+		return err
+	}
+	return l.queue.Inspector.RunTask(queue, id)
+}
+
+// DeleteArchivedTask drops an archived task for good. The funds still held for the transaction of a transaction
+// task are released first, since it will not be applied.
+func (l *Blnk) DeleteArchivedTask(ctx context.Context, queue, id string) error {
+	task, err := l.archivedTask(queue, id)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(queue, TRANSACTION_QUEUE) {
+		var transaction model.Transaction
+		if err := json.Unmarshal(task.Payload, &transaction); err != nil {
+			return err
+		}
+		if err := l.releaseReservation(ctx, &transaction); err != nil {
+			return err
+		}
+	}
+	return l.queue.Inspector.DeleteTask(queue, id)
+}
+
+// RejectArchivedTask records the transaction of an archived transaction task as rejected, since it ran out of
+// retries, and drops the task. Reserved funds are released and the rejection webhook is sent.
+func (l *Blnk) RejectArchivedTask(ctx context.Context, queue, id string) (*model.Transaction, error) {
+	if !strings.HasPrefix(queue, TRANSACTION_QUEUE) {
+		return nil, fmt.Errorf("only transaction tasks can be rejected, %s is not a transaction queue", queue)
+	}
+	task, err := l.archivedTask(queue, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var transaction model.Transaction
+	if err := json.Unmarshal(task.Payload, &transaction); err != nil {
+		return nil, err
+	}
+	exists, err := l.datasource.TransactionExistsByRef(ctx, transaction.Reference)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("transaction with reference %s has already been recorded; delete the task instead", transaction.Reference)
+	}
+
+	rejection := &RejectionError{Code: RejectionRetriesExhausted, Err: errors.New(task.LastErr)}
+	rejected, err := l.RejectTransaction(ctx, &transaction, rejection)
+	if err != nil {
+		return nil, err
+	}
+	return rejected, l.queue.Inspector.DeleteTask(queue, id)
+}
+
+// archivedTask fetches a task, making sure it is archived.
+func (l *Blnk) archivedTask(queue, id string) (*asynq.TaskInfo, error) {
+	task, err := l.queue.Inspector.GetTaskInfo(queue, id)
+	if err != nil {
+		return nil, err
+	}
+	if task.State != asynq.TaskStateArchived {
+		return nil, fmt.Errorf("task %s in queue %s is %s; only archived tasks can be changed", id, queue, task.State)
+	}
+	return task, nil
+}
+
+func toQueueTask(task *asynq.TaskInfo) model.QueueTask {
+	queueTask := model.QueueTask{
+		ID:        task.ID,
+		Queue:     task.Queue,
+		State:     task.State.String(),
+		Retried:   task.Retried,
+		MaxRetry:  task.MaxRetry,
+		LastError: task.LastErr,
+		Payload:   task.Payload,
+	}
+	if !task.LastFailedAt.IsZero() {
+		queueTask.LastFailedAt = &task.LastFailedAt
+	}
+	if !task.NextProcessAt.IsZero() {
+		queueTask.NextProcessAt = &task.NextProcessAt
+	}
+	return queueTask
+}
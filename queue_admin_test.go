@@ -0,0 +1,111 @@
+package blnk
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/hibiken/asynq"
+	"github.com/northstar-pay/nucleus/config"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newArchivedTransactionTask queues a transaction on its own Redis and archives its task, as if it had run out of retries.
+func newArchivedTransactionTask(t *testing.T, d *Blnk, reserved int64) (model.Transaction, string) {
+	mr := miniredis.RunT(t)
+	d.queue = NewQueue(&config.Configuration{Redis: config.RedisConfig{Dns: mr.Addr()}, Queue: config.QueueConfig{TransactionQueues: config.DEFAULT_TRANSACTION_QUEUES}})
+
+	transaction := getTransactionMock(100, false)
+	transaction.TransactionID = model.GenerateUUIDWithSuffix("txn")
+	transaction.Source = gofakeit.UUID()
+	transaction.Status = StatusQueued
+	transaction.ReservedAmount = reserved
+	require.NoError(t, d.queue.Enqueue(context.Background(), &transaction))
+
+	queue := transactionQueueFor(transaction.Source, config.DEFAULT_TRANSACTION_QUEUES)
+	require.NoError(t, d.queue.Inspector.ArchiveTask(queue, transaction.Reference))
+	return transaction, queue
+}
+
+func TestQueueAdminListsAndRedrivesArchivedTasks(t *testing.T) {
+	datasource, _, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+	transaction, queue := newArchivedTransactionTask(t, d, 0)
+
+	stats, err := d.GetQueueStats()
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, queue, stats[0].Queue)
+	assert.Equal(t, 1, stats[0].Archived)
+
+	tasks, err := d.ListQueueTasks(queue, model.TaskStateArchived, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, transaction.Reference, tasks[0].ID)
+	assert.Contains(t, string(tasks[0].Payload), transaction.TransactionID)
+
+	_, err = d.ListQueueTasks(queue, "completed", 1, 10)
+	assert.Error(t, err)
+
+	require.NoError(t, d.RedriveArchivedTask(queue, transaction.Reference))
+	task, err := d.queue.Inspector.GetTaskInfo(queue, transaction.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, asynq.TaskStatePending, task.State)
+
+	// Only archived tasks can be changed.
+	assert.Error(t, d.DeleteArchivedTask(context.Background(), queue, transaction.Reference))
+}
+
+func TestQueueAdminRejectsArchivedTransaction(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+	transaction, queue := newArchivedTransactionTask(t, d, 0)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(transaction.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	insertArgs := make([]driver.Value, 20)
+	for i := range insertArgs {
+		insertArgs[i] = sqlmock.AnyArg()
+	}
+	insertArgs[11] = StatusRejected
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.transactions`)).WithArgs(insertArgs...).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	rejected, err := d.RejectArchivedTask(context.Background(), queue, transaction.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRejected, rejected.Status)
+	assert.Equal(t, RejectionRetriesExhausted, rejected.MetaData["blnk_rejection_code"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = d.queue.Inspector.GetTaskInfo(queue, transaction.Reference)
+	assert.ErrorIs(t, err, asynq.ErrTaskNotFound)
+
+	_, err = d.RejectArchivedTask(context.Background(), WEBHOOK_QUEUE, "any")
+	assert.Error(t, err)
+}
+
+func TestQueueAdminDeleteReleasesReservation(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+	transaction, queue := newArchivedTransactionTask(t, d, 10000)
+
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.reservations SET status = $2`)).
+		WithArgs(transaction.TransactionID, model.ReservationReleased, model.ReservationHeld).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, d.DeleteArchivedTask(context.Background(), queue, transaction.Reference))
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = d.queue.Inspector.GetTaskInfo(queue, transaction.Reference)
+	assert.ErrorIs(t, err, asynq.ErrTaskNotFound)
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/alicebob/miniredis/v2"
+	"github.com/brianvoe/gofakeit/v6"
 	"github.com/hibiken/asynq"
 	"github.com/northstar-pay/nucleus/config"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEnqueueImmediateTransactionSuccess(t *testing.T) {
@@ -18,6 +22,7 @@ func TestEnqueueImmediateTransactionSuccess(t *testing.T) {
 		Redis: config.RedisConfig{
 			Dns: "localhost:6379",
 		},
+		Queue: config.QueueConfig{TransactionQueues: config.DEFAULT_TRANSACTION_QUEUES},
 	})
 	q.Client = client
 	q.Inspector = inspector
@@ -46,6 +51,7 @@ func TestEnqueueScheduledTransaction(t *testing.T) {
 		Redis: config.RedisConfig{
 			Dns: "localhost:6379",
 		},
+		Queue: config.QueueConfig{TransactionQueues: config.DEFAULT_TRANSACTION_QUEUES},
 	})
 	q.Client = client
 	q.Inspector = inspector
@@ -73,6 +79,7 @@ func TestEnqueueWithAsynqClientEnqueueError(t *testing.T) {
 		Redis: config.RedisConfig{
 			Dns: "localhost:6379",
 		},
+		Queue: config.QueueConfig{TransactionQueues: config.DEFAULT_TRANSACTION_QUEUES},
 	})
 	q.Client = client
 	q.Inspector = inspector
@@ -93,3 +100,40 @@ func TestEnqueueWithAsynqClientEnqueueError(t *testing.T) {
 	assert.Equal(t, "tx_123", task.ID)
 
 }
+
+func TestTransactionQueueNames(t *testing.T) {
+	// The default count keeps the names deployments already have.
+	assert.Equal(t, "new:transaction_1", TransactionQueueName(1, config.DEFAULT_TRANSACTION_QUEUES))
+	assert.Equal(t, "new:transaction_3_of_8", TransactionQueueName(3, 8))
+	assert.Len(t, TransactionQueues(8), 8)
+
+	queues := TransactionQueues(8)
+	for i := 0; i < 50; i++ {
+		assert.Contains(t, queues, transactionQueueFor(gofakeit.UUID(), 8))
+	}
+	source := gofakeit.UUID()
+	assert.Equal(t, transactionQueueFor(source, 8), transactionQueueFor(source, 8))
+}
+
+func TestDrainTransactionQueuesMovesScheduledTransactions(t *testing.T) {
+	mr := miniredis.RunT(t)
+	conf := &config.Configuration{Redis: config.RedisConfig{Dns: mr.Addr()}, Queue: config.QueueConfig{TransactionQueues: 2}}
+	old := NewQueue(conf)
+
+	transaction := getTransactionMock(100, false)
+	transaction.Source = gofakeit.UUID()
+	transaction.ScheduledFor = time.Now().Add(time.Hour)
+	require.NoError(t, old.Enqueue(context.Background(), &transaction))
+
+	conf.Queue.TransactionQueues = 3
+	resharded := NewQueue(conf)
+	require.NoError(t, resharded.DrainTransactionQueues(context.Background(), 2, 3))
+
+	task, err := resharded.Inspector.GetTaskInfo(transactionQueueFor(transaction.Source, 3), transaction.Reference)
+	require.NoError(t, err)
+	assert.Equal(t, asynq.TaskStateScheduled, task.State)
+	assert.WithinDuration(t, transaction.ScheduledFor, task.NextProcessAt, time.Second)
+
+	_, err = resharded.Inspector.GetTaskInfo(transactionQueueFor(transaction.Source, 2), transaction.Reference)
+	assert.ErrorIs(t, err, asynq.ErrTaskNotFound)
+}
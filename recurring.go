@@ -0,0 +1,254 @@
+package blnk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// recurringBatchSize bounds how many due recurring transactions or occurrences to retry are loaded at a time.
+	recurringBatchSize = 100
+	// recurringRetryDelay is how long an occurrence is left alone after it was claimed or tried before it is
+	// retried, so the worker that holds it can finish first.
+	recurringRetryDelay = time.Minute
+	// recurringMaxAttempts bounds how many times an occurrence is tried before it is left failed.
+	recurringMaxAttempts = 5
+)
+
+// CreateRecurringTransaction starts a schedule that queues a copy of the template on every tick.
+func (l *Blnk) CreateRecurringTransaction(recurring model.RecurringTransaction) (model.RecurringTransaction, error) {
+	now := time.Now()
+	if recurring.StartAt.IsZero() {
+		recurring.StartAt = now
+	}
+	if err := recurring.Validate(); err != nil {
+		return model.RecurringTransaction{}, err
+	}
+
+	first, err := recurring.First()
+	if err != nil {
+		return model.RecurringTransaction{}, err
+	}
+	if recurring.IsExhausted(1, first) {
+		return model.RecurringTransaction{}, errors.New("the schedule has no occurrence before end_at")
+	}
+
+	recurring.RecurringID = model.GenerateUUIDWithSuffix("rec")
+	recurring.Status = model.RecurringActive
+	recurring.Occurrences = 0
+	recurring.NextRunAt = first
+	recurring.CreatedAt = now
+	recurring.UpdatedAt = now
+	return l.datasource.CreateRecurringTransaction(recurring)
+}
+
+func (l *Blnk) GetRecurringTransaction(id string) (*model.RecurringTransaction, error) {
+	return l.datasource.GetRecurringTransaction(id)
+}
+
+func (l *Blnk) GetAllRecurringTransactions(filter model.RecurringTransactionFilter, limit int) (model.Page[model.RecurringTransaction], error) {
+	limit = model.PageLimit(limit)
+	recurringTransactions, err := l.datasource.GetAllRecurringTransactions(filter, limit+1)
+	if err != nil {
+		return model.Page[model.RecurringTransaction]{}, err
+	}
+	return model.NewPage(recurringTransactions, limit, func(recurring model.RecurringTransaction) int64 { return recurring.ID }), nil
+}
+
+// GetRecurringOccurrences returns the history of a recurring transaction, newest first.
+func (l *Blnk) GetRecurringOccurrences(id string, cursor int64, limit int) (model.Page[model.RecurringOccurrence], error) {
+	if _, err := l.datasource.GetRecurringTransaction(id); err != nil {
+		return model.Page[model.RecurringOccurrence]{}, err
+	}
+	limit = model.PageLimit(limit)
+	occurrences, err := l.datasource.GetRecurringOccurrences(id, cursor, limit+1)
+	if err != nil {
+		return model.Page[model.RecurringOccurrence]{}, err
+	}
+	return model.NewPage(occurrences, limit, func(occurrence model.RecurringOccurrence) int64 { return occurrence.ID }), nil
+}
+
+// PauseRecurringTransaction stops a recurring transaction from generating occurrences until it is resumed.
+func (l *Blnk) PauseRecurringTransaction(ctx context.Context, id string) (*model.RecurringTransaction, error) {
+	return l.updateRecurringStatus(ctx, id, func(recurring *model.RecurringTransaction) error {
+		if recurring.Status != model.RecurringActive {
+			return fmt.Errorf("only an active recurring transaction can be paused, %s is %s", id, recurring.Status)
+		}
+		recurring.Status = model.RecurringPaused
+		return nil
+	})
+}
+
+// ResumeRecurringTransaction restarts a paused recurring transaction. Occurrences that fell due while it was
+// paused are skipped; it picks up from the next tick after now.
+func (l *Blnk) ResumeRecurringTransaction(ctx context.Context, id string) (*model.RecurringTransaction, error) {
+	return l.updateRecurringStatus(ctx, id, func(recurring *model.RecurringTransaction) error {
+		if recurring.Status != model.RecurringPaused {
+			return fmt.Errorf("only a paused recurring transaction can be resumed, %s is %s", id, recurring.Status)
+		}
+		recurring.Status = model.RecurringActive
+		if now := time.Now(); recurring.NextRunAt.Before(now) {
+			next, err := recurring.Next(now)
+			if err != nil {
+				return err
+			}
+			recurring.NextRunAt = next
+		}
+		if recurring.IsExhausted(recurring.Occurrences+1, recurring.NextRunAt) {
+			recurring.Status = model.RecurringCompleted
+		}
+		return nil
+	})
+}
+
+// CancelRecurringTransaction ends a recurring transaction for good. Occurrences already queued are unaffected.
+func (l *Blnk) CancelRecurringTransaction(ctx context.Context, id string) (*model.RecurringTransaction, error) {
+	return l.updateRecurringStatus(ctx, id, func(recurring *model.RecurringTransaction) error {
+		if recurring.Status != model.RecurringActive && recurring.Status != model.RecurringPaused {
+			return fmt.Errorf("recurring transaction %s is already %s", id, recurring.Status)
+		}
+		recurring.Status = model.RecurringCancelled
+		return nil
+	})
+}
+
+// updateRecurringStatus applies change to a recurring transaction and saves it, unless its status was changed
+// by someone else in the meantime.
+func (l *Blnk) updateRecurringStatus(ctx context.Context, id string, change func(recurring *model.RecurringTransaction) error) (*model.RecurringTransaction, error) {
+	recurring, err := l.datasource.GetRecurringTransaction(id)
+	if err != nil {
+		return nil, err
+	}
+	from := recurring.Status
+	if err := change(recurring); err != nil {
+		return nil, err
+	}
+
+	updated, err := l.datasource.UpdateRecurringTransactionStatus(ctx, recurring, from)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("recurring transaction %s changed while it was being updated, try again", id)
+	}
+	recurring.UpdatedAt = time.Now()
+	return recurring, nil
+}
+
+// RunDueRecurringTransactions queues every occurrence that is due, catching up on ticks missed while no worker
+// was running, then retries the occurrences whose transaction could not be queued. It returns how many
+// occurrences were generated.
+func (l *Blnk) RunDueRecurringTransactions(ctx context.Context) (int, error) {
+	generated := 0
+	for {
+		due, err := l.datasource.GetDueRecurringTransactions(ctx, time.Now(), recurringBatchSize)
+		if err != nil {
+			return generated, err
+		}
+
+		claimed := 0
+		for i := range due {
+			ok, err := l.runRecurringOccurrence(ctx, &due[i])
+			if err != nil {
+				return generated, err
+			}
+			if ok {
+				claimed++
+			}
+		}
+		generated += claimed
+		if claimed == 0 {
+			break
+		}
+	}
+	return generated, l.retryRecurringOccurrences(ctx)
+}
+
+// runRecurringOccurrence claims the occurrence a recurring transaction has due, recording it as pending, and
+// queues its transaction. It reports false if another worker claimed the occurrence first. The occurrence's
+// reference is derived from its period, so it can never be posted twice however many times it is queued.
+func (l *Blnk) runRecurringOccurrence(ctx context.Context, recurring *model.RecurringTransaction) (bool, error) {
+	period := recurring.NextRunAt
+	previous := recurring.Occurrences
+	sequence := previous + 1
+
+	next, err := recurring.Next(period)
+	if err != nil {
+		return false, err
+	}
+	advanced := *recurring
+	advanced.Occurrences = sequence
+	advanced.LastRunAt = period
+	advanced.NextRunAt = next
+	if advanced.IsExhausted(sequence+1, next) {
+		advanced.Status = model.RecurringCompleted
+	}
+	occurrence := model.RecurringOccurrence{
+		RecurringID:  recurring.RecurringID,
+		Sequence:     sequence,
+		ScheduledFor: period,
+		Reference:    recurring.OccurrenceReference(period),
+		Status:       model.OccurrencePending,
+		CreatedAt:    time.Now(),
+	}
+	claimed, err := l.datasource.ClaimRecurringOccurrence(ctx, &advanced, previous, occurrence)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	return true, l.queueRecurringOccurrence(ctx, recurring, occurrence)
+}
+
+// retryRecurringOccurrences queues again the occurrences that failed to queue or were left pending by a worker
+// that stopped, a batch at a time, until none are left to claim.
+func (l *Blnk) retryRecurringOccurrences(ctx context.Context) error {
+	for {
+		occurrences, err := l.datasource.ClaimRetryableRecurringOccurrences(ctx, time.Now().Add(-recurringRetryDelay), recurringMaxAttempts, recurringBatchSize)
+		if err != nil || len(occurrences) == 0 {
+			return err
+		}
+
+		for _, occurrence := range occurrences {
+			// An earlier attempt may have queued the transaction before its occurrence was marked.
+			if existing, err := l.datasource.GetTransactionByRef(ctx, occurrence.Reference); err == nil {
+				occurrence.Status = model.OccurrenceQueued
+				occurrence.TransactionID = existing.TransactionID
+				occurrence.Error = ""
+				if err := l.datasource.UpdateRecurringOccurrence(ctx, occurrence); err != nil {
+					return err
+				}
+				continue
+			}
+
+			recurring, err := l.datasource.GetRecurringTransaction(occurrence.RecurringID)
+			if err != nil {
+				return err
+			}
+			if err := l.queueRecurringOccurrence(ctx, recurring, occurrence); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// queueRecurringOccurrence queues the transaction of a claimed occurrence and saves the outcome: queued, or
+// failed with the error to be retried.
+func (l *Blnk) queueRecurringOccurrence(ctx context.Context, recurring *model.RecurringTransaction, occurrence model.RecurringOccurrence) error {
+	transaction := recurring.Occurrence(occurrence.Sequence, occurrence.ScheduledFor)
+	queued, err := l.QueueTransaction(ctx, transaction)
+	if err != nil {
+		logrus.Errorf("failed to queue occurrence %d of recurring transaction %s: %v", occurrence.Sequence, recurring.RecurringID, err)
+		occurrence.Status = model.OccurrenceFailed
+		occurrence.Error = err.Error()
+	} else {
+		occurrence.Status = model.OccurrenceQueued
+		occurrence.TransactionID = queued.TransactionID
+		occurrence.Error = ""
+	}
+	return l.datasource.UpdateRecurringOccurrence(ctx, occurrence)
+}
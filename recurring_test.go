@@ -0,0 +1,222 @@
+package blnk
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/northstar-pay/nucleus/config"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	dueRecurringQuery       = regexp.QuoteMeta(`FROM blnk.recurring_transactions WHERE status = $1 AND next_run_at <= $2`)
+	advanceRecurringQuery   = regexp.QuoteMeta(`UPDATE blnk.recurring_transactions SET occurrences = $2`)
+	recordOccurrenceQuery   = regexp.QuoteMeta(`INSERT INTO blnk.recurring_occurrences`)
+	updateOccurrenceQuery   = regexp.QuoteMeta(`UPDATE blnk.recurring_occurrences SET status = $3`)
+	retryOccurrencesQuery   = regexp.QuoteMeta(`UPDATE blnk.recurring_occurrences o SET attempts = o.attempts + 1`)
+	recurringOccurrenceRows = []string{"id", "recurring_id", "sequence", "scheduled_for", "transaction_id", "reference", "status", "error", "attempts", "created_at", "updated_at"}
+)
+
+func expectNoOccurrenceRetries(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(retryOccurrencesQuery).
+		WithArgs(model.OccurrencePending, model.OccurrenceFailed, sqlmock.AnyArg(), recurringMaxAttempts, model.RecurringCancelled, recurringBatchSize).
+		WillReturnRows(sqlmock.NewRows(recurringOccurrenceRows))
+}
+
+func recurringRows(recurring model.RecurringTransaction) *sqlmock.Rows {
+	template, _ := json.Marshal(recurring.Template)
+	return sqlmock.NewRows([]string{"id", "recurring_id", "cron", "interval_seconds", "start_at", "end_at", "max_occurrences", "occurrences",
+		"next_run_at", "last_run_at", "status", "template", "created_at", "updated_at", "meta_data"}).
+		AddRow(1, recurring.RecurringID, recurring.Cron, recurring.IntervalSeconds, recurring.StartAt, recurring.EndAt, recurring.MaxOccurrences,
+			recurring.Occurrences, recurring.NextRunAt, recurring.LastRunAt, recurring.Status, template, recurring.CreatedAt, recurring.UpdatedAt, nil)
+}
+
+func TestRunDueRecurringTransactionsCatchesUpAndCompletes(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+	d.queue = NewQueue(&config.Configuration{Redis: config.RedisConfig{Dns: miniredis.RunT(t).Addr()}, Queue: config.QueueConfig{TransactionQueues: config.DEFAULT_TRANSACTION_QUEUES}})
+
+	start := time.Now().Add(-90 * time.Minute).Truncate(time.Second)
+	recurring := model.RecurringTransaction{
+		RecurringID:     "rec_test",
+		IntervalSeconds: 3600,
+		StartAt:         start,
+		MaxOccurrences:  2,
+		NextRunAt:       start,
+		Status:          model.RecurringActive,
+		Template: model.Transaction{
+			Reference:   "subscription",
+			Source:      gofakeit.UUID(),
+			Destination: gofakeit.UUID(),
+			Amount:      "10",
+			Precision:   100,
+			Currency:    "NGN",
+			Description: "monthly plan",
+		},
+	}
+
+	// Both ticks since start are due; the second one is the last occurrence allowed.
+	for sequence := int64(1); sequence <= 2; sequence++ {
+		period := start.Add(time.Duration(sequence-1) * time.Hour)
+		status := model.RecurringActive
+		if sequence == 2 {
+			status = model.RecurringCompleted
+		}
+		reference := recurring.OccurrenceReference(period)
+
+		// The occurrence is recorded as pending with the claim and marked queued once its transaction is.
+		mock.ExpectQuery(dueRecurringQuery).WithArgs(model.RecurringActive, sqlmock.AnyArg(), recurringBatchSize).WillReturnRows(recurringRows(recurring))
+		mock.ExpectBegin()
+		mock.ExpectExec(advanceRecurringQuery).WithArgs(recurring.RecurringID, sequence, period.Add(time.Hour), period, status, sequence-1, model.RecurringActive).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(recordOccurrenceQuery).
+			WithArgs(recurring.RecurringID, sequence, period, reference, model.OccurrencePending, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+			WithArgs(reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		expectCurrencyLookup(mock, "NGN", 2)
+		mock.ExpectExec(updateOccurrenceQuery).
+			WithArgs(recurring.RecurringID, sequence, model.OccurrenceQueued, sqlmock.AnyArg(), "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		recurring.Occurrences = sequence
+		recurring.NextRunAt = period.Add(time.Hour)
+	}
+	mock.ExpectQuery(dueRecurringQuery).WithArgs(model.RecurringActive, sqlmock.AnyArg(), recurringBatchSize).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	expectNoOccurrenceRetries(mock)
+
+	generated, err := d.RunDueRecurringTransactions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, generated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	scheduled, err := d.queue.Inspector.ListPendingTasks(transactionQueueFor(recurring.Template.Source, config.DEFAULT_TRANSACTION_QUEUES))
+	require.NoError(t, err)
+	assert.Len(t, scheduled, 2)
+}
+
+func TestRunDueRecurringTransactionsSkipsClaimedOccurrence(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	start := time.Now().Add(-time.Minute).Truncate(time.Second)
+	recurring := model.RecurringTransaction{
+		RecurringID:     "rec_test",
+		IntervalSeconds: 60,
+		StartAt:         start,
+		NextRunAt:       start,
+		Status:          model.RecurringActive,
+		Template:        model.Transaction{Reference: "sweep", Currency: "NGN"},
+	}
+
+	// Another worker advanced the schedule first, so nothing is queued here.
+	mock.ExpectQuery(dueRecurringQuery).WithArgs(model.RecurringActive, sqlmock.AnyArg(), recurringBatchSize).WillReturnRows(recurringRows(recurring))
+	mock.ExpectBegin()
+	mock.ExpectExec(advanceRecurringQuery).WithArgs(recurring.RecurringID, int64(1), start.Add(time.Minute), start, model.RecurringActive, int64(0), model.RecurringActive).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	expectNoOccurrenceRetries(mock)
+
+	generated, err := d.RunDueRecurringTransactions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, generated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunDueRecurringTransactionsRetriesFailedOccurrence(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+	d.queue = NewQueue(&config.Configuration{Redis: config.RedisConfig{Dns: miniredis.RunT(t).Addr()}, Queue: config.QueueConfig{TransactionQueues: config.DEFAULT_TRANSACTION_QUEUES}})
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	recurring := model.RecurringTransaction{
+		RecurringID:     "rec_test",
+		IntervalSeconds: 3600,
+		StartAt:         start,
+		NextRunAt:       start.Add(time.Hour),
+		Occurrences:     1,
+		Status:          model.RecurringActive,
+		Template: model.Transaction{
+			Reference:   "subscription",
+			Source:      gofakeit.UUID(),
+			Destination: gofakeit.UUID(),
+			Amount:      "10",
+			Precision:   100,
+			Currency:    "NGN",
+		},
+	}
+	reference := recurring.OccurrenceReference(start)
+
+	// Nothing new is due, but the first occurrence failed to queue earlier and is queued now with its reference.
+	mock.ExpectQuery(dueRecurringQuery).WithArgs(model.RecurringActive, sqlmock.AnyArg(), recurringBatchSize).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(retryOccurrencesQuery).
+		WithArgs(model.OccurrencePending, model.OccurrenceFailed, sqlmock.AnyArg(), recurringMaxAttempts, model.RecurringCancelled, recurringBatchSize).
+		WillReturnRows(sqlmock.NewRows(recurringOccurrenceRows).
+			AddRow(1, recurring.RecurringID, 1, start, "", reference, model.OccurrenceFailed, "queue unavailable", 2, start, start))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.transactions WHERE reference = $1`)).WithArgs(reference).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.recurring_transactions WHERE recurring_id = $1`)).WithArgs(recurring.RecurringID).
+		WillReturnRows(recurringRows(recurring))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "NGN", 2)
+	mock.ExpectExec(updateOccurrenceQuery).
+		WithArgs(recurring.RecurringID, int64(1), model.OccurrenceQueued, sqlmock.AnyArg(), "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectNoOccurrenceRetries(mock)
+
+	generated, err := d.RunDueRecurringTransactions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, generated)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	scheduled, err := d.queue.Inspector.ListPendingTasks(transactionQueueFor(recurring.Template.Source, config.DEFAULT_TRANSACTION_QUEUES))
+	require.NoError(t, err)
+	assert.Len(t, scheduled, 1)
+}
+
+func TestResumeRecurringTransactionSkipsMissedTicks(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	start := time.Now().Add(-150 * time.Minute).Truncate(time.Second)
+	recurring := model.RecurringTransaction{
+		RecurringID:     "rec_test",
+		IntervalSeconds: 3600,
+		StartAt:         start,
+		NextRunAt:       start.Add(time.Hour),
+		Occurrences:     1,
+		Status:          model.RecurringPaused,
+		Template:        model.Transaction{Reference: "sweep", Currency: "NGN"},
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.recurring_transactions WHERE recurring_id = $1`)).WithArgs(recurring.RecurringID).
+		WillReturnRows(recurringRows(recurring))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.recurring_transactions SET status = $2`)).
+		WithArgs(recurring.RecurringID, model.RecurringActive, start.Add(3*time.Hour), model.RecurringPaused).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	resumed, err := d.ResumeRecurringTransaction(context.Background(), recurring.RecurringID)
+	require.NoError(t, err)
+	assert.Equal(t, model.RecurringActive, resumed.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = d.PauseRecurringTransaction(context.Background(), "missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package blnk
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// reserveFunds holds the amount a transaction queued with Reserve will debit on each of its source balances,
+// so later transactions cannot spend it while it waits in the queue. tasks are the split legs that will be
+// queued on their own; each holds its own amount. A transaction queued as a single task holds the total per
+// source against its own ID.
+func (l *Blnk) reserveFunds(ctx context.Context, transaction *model.Transaction, tasks []*model.Transaction) error {
+	if !transaction.Reserve {
+		return nil
+	}
+
+	holders := tasks
+	if transaction.Atomic || len(tasks) == 0 {
+		holders = []*model.Transaction{transaction}
+	}
+	// legs are what each holder will debit once it reaches the worker.
+	legsOf := func(holder *model.Transaction) []*model.Transaction {
+		if holder == transaction && len(tasks) > 0 {
+			return tasks
+		}
+		return []*model.Transaction{holder}
+	}
+
+	var reservations []model.Reservation
+	var lockKeys []string
+	for _, holder := range holders {
+		held := make(map[string]int)
+		holder.ReservedAmount = 0
+		for _, leg := range legsOf(holder) {
+			source, err := l.resolveBalance(leg.Source, leg.Currency)
+			if err != nil {
+				return err
+			}
+			if source.Currency != leg.Currency {
+				return &RejectionError{Code: RejectionCurrencyMismatch, Err: fmt.Errorf("source balance %s is in %s but the transaction is in %s", source.BalanceID, source.Currency, leg.Currency)}
+			}
+			if err := source.CheckDebit(); err != nil {
+				return err
+			}
+			lockKeys = append(lockKeys, source.BalanceID)
+			holder.ReservedAmount += leg.PreciseAmount
+
+			if i, ok := held[source.BalanceID]; ok {
+				reservations[i].Amount += leg.PreciseAmount
+				continue
+			}
+			held[source.BalanceID] = len(reservations)
+			reservations = append(reservations, model.Reservation{TransactionID: holder.TransactionID, BalanceID: source.BalanceID, Amount: leg.PreciseAmount})
+		}
+	}
+
+	ctx, locker, err := l.lockBalances(ctx, lockKeys)
+	if err != nil {
+		return fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer l.releaseLock(ctx, locker)
+
+	return l.datasource.PlaceReservations(ctx, reservations, transaction.AllowOverdraft)
+}
+
+// releaseReservation gives the funds held for a transaction that will not be applied back to its sources.
+func (l *Blnk) releaseReservation(ctx context.Context, transaction *model.Transaction) error {
+	if transaction.ReservedAmount == 0 {
+		return nil
+	}
+	return l.datasource.ReleaseReservations(ctx, transaction.TransactionID)
+}
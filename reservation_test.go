@@ -0,0 +1,194 @@
+package blnk
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	reserveQuery = regexp.QuoteMeta(`UPDATE blnk.balances SET reserved_balance = reserved_balance + $2`)
+	settleQuery  = regexp.QuoteMeta(`UPDATE blnk.reservations SET status = $2`)
+)
+
+func expectReservationSource(mock sqlmock.Sqlmock, txn *model.Transaction, source string, balance int64) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "NGN", 2)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.balances WHERE balance_id = $1`)).WithArgs(source).
+		WillReturnRows(sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+			AddRow(source, "NGN", 100, "ledger-id", balance, balance, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+}
+
+func TestQueueTransactionReservesFunds(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := gofakeit.UUID()
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      source,
+		Destination: gofakeit.UUID(),
+		Amount:      "10",
+		Precision:   100,
+		Currency:    "NGN",
+		Reserve:     true,
+	}
+
+	expectReservationSource(mock, txn, source, 10000)
+	mock.ExpectBegin()
+	mock.ExpectExec(reserveQuery).WithArgs(source, int64(1000), false, model.BalanceFrozen, model.BalanceDebitBlocked, model.BalanceClosed).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.reservations`)).
+		WithArgs(sqlmock.AnyArg(), source, int64(1000), model.ReservationHeld).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	queued, err := d.QueueTransaction(context.Background(), txn)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), queued.ReservedAmount)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestQueueTransactionReserveInsufficientFunds(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := gofakeit.UUID()
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      source,
+		Destination: gofakeit.UUID(),
+		Amount:      "10",
+		Precision:   100,
+		Currency:    "NGN",
+		Reserve:     true,
+	}
+
+	expectReservationSource(mock, txn, source, 500)
+	mock.ExpectBegin()
+	mock.ExpectExec(reserveQuery).WithArgs(source, int64(1000), false, model.BalanceFrozen, model.BalanceDebitBlocked, model.BalanceClosed).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT status FROM blnk.balances WHERE balance_id = $1`)).WithArgs(source).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(model.BalanceActive))
+	mock.ExpectRollback()
+
+	_, err = d.QueueTransaction(context.Background(), txn)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient funds")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestQueueTransactionReserveRefusedOnFrozenBalance(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := gofakeit.UUID()
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      source,
+		Destination: gofakeit.UUID(),
+		Amount:      "10",
+		Precision:   100,
+		Currency:    "NGN",
+		Reserve:     true,
+	}
+
+	// The balance is frozen after it was read, so the hold itself is refused.
+	expectReservationSource(mock, txn, source, 10000)
+	mock.ExpectBegin()
+	mock.ExpectExec(reserveQuery).WithArgs(source, int64(1000), false, model.BalanceFrozen, model.BalanceDebitBlocked, model.BalanceClosed).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT status FROM blnk.balances WHERE balance_id = $1`)).WithArgs(source).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(model.BalanceFrozen))
+	mock.ExpectRollback()
+
+	_, err = d.QueueTransaction(context.Background(), txn)
+	assert.ErrorIs(t, err, model.ErrBalanceStatus)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueueTransactionReleasesReservationWhenEnqueueFails(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := gofakeit.UUID()
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      source,
+		Destination: gofakeit.UUID(),
+		Amount:      "10",
+		Precision:   100,
+		Currency:    "NGN",
+		Reserve:     true,
+	}
+
+	// A task already queued under the same reference makes queuing the transaction fail.
+	queued := &model.Transaction{Reference: txn.Reference, Source: source}
+	assert.NoError(t, d.queue.Enqueue(context.Background(), queued))
+	defer func() {
+		_ = d.queue.Inspector.DeleteTask(transactionQueueFor(source, d.queue.transactionQueues), txn.Reference)
+	}()
+
+	expectReservationSource(mock, txn, source, 10000)
+	mock.ExpectBegin()
+	mock.ExpectExec(reserveQuery).WithArgs(source, int64(1000), false, model.BalanceFrozen, model.BalanceDebitBlocked, model.BalanceClosed).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.reservations`)).
+		WithArgs(sqlmock.AnyArg(), source, int64(1000), model.ReservationHeld).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(settleQuery).WithArgs(sqlmock.AnyArg(), model.ReservationReleased, model.ReservationHeld).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	_, err = d.QueueTransaction(context.Background(), txn)
+	assert.Error(t, err)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRecordTransactionConvertsReservation(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := gofakeit.UUID()
+	destination := gofakeit.UUID()
+	txn := &model.Transaction{
+		TransactionID:  model.GenerateUUIDWithSuffix("txn"),
+		Reference:      gofakeit.UUID(),
+		Source:         source,
+		Destination:    destination,
+		Amount:         "10",
+		Precision:      100,
+		Currency:       "NGN",
+		ReservedAmount: 1000,
+	}
+
+	expectTransfer(mock, txn, source, destination)
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	assert.NoError(t, err)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,231 @@
+package blnk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/northstar-pay/nucleus/internal/notification"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/sirupsen/logrus"
+)
+
+// scheduledTask is a transaction waiting in a transaction queue for its scheduled time.
+type scheduledTask struct {
+	queue       string
+	info        *asynq.TaskInfo
+	transaction model.Transaction
+}
+
+// scheduledIndexGrace is how long the index of a scheduled transaction outlives the time it was due, so a task
+// that is retried or waits on a reshard can still be found; stale entries are skipped on lookup.
+const scheduledIndexGrace = 7 * 24 * time.Hour
+
+func scheduledIndexKey(id string) string {
+	return "blnk:scheduled:" + id
+}
+
+// indexScheduledTask records the task ID of a scheduled transaction under its transaction ID and, when it is part
+// of a distributed transaction, its group ID, so it can be found without listing every scheduled task. The
+// index is only extended, since the legs of a group may run at different times.
+func (q *Queue) indexScheduledTask(ctx context.Context, transaction *model.Transaction, at time.Time) error {
+	ttl := time.Until(at) + scheduledIndexGrace
+	for _, id := range []string{transaction.TransactionID, transaction.GroupID} {
+		if id == "" {
+			continue
+		}
+		key := scheduledIndexKey(id)
+		if err := q.redis.SAdd(ctx, key, transaction.Reference).Err(); err != nil {
+			return err
+		}
+		current, err := q.redis.TTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if current < ttl {
+			if err := q.redis.Expire(ctx, key, ttl).Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// transactionQueueNames returns the transaction queues that exist, including those left over from an earlier
+// queue count.
+func (q *Queue) transactionQueueNames() ([]string, error) {
+	queues, err := q.Inspector.Queues()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(queues))
+	for _, queue := range queues {
+		if strings.HasPrefix(queue, TRANSACTION_QUEUE) {
+			names = append(names, queue)
+		}
+	}
+	return names, nil
+}
+
+// findScheduledTasks returns the scheduled tasks of a transaction. id is a transaction ID, or the group ID of a
+// distributed transaction whose legs were queued separately. Tasks are looked up by the IDs indexed for id,
+// in each transaction queue, since a reshard may have moved them.
+func (l *Blnk) findScheduledTasks(ctx context.Context, id string) ([]scheduledTask, error) {
+	taskIDs, err := l.queue.redis.SMembers(ctx, scheduledIndexKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	var queues []string
+	if len(taskIDs) > 0 {
+		if queues, err = l.queue.transactionQueueNames(); err != nil {
+			return nil, err
+		}
+	}
+
+	var found []scheduledTask
+	for _, taskID := range taskIDs {
+		for _, queue := range queues {
+			info, err := l.queue.Inspector.GetTaskInfo(queue, taskID)
+			if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if info.State != asynq.TaskStateScheduled {
+				break
+			}
+			var transaction model.Transaction
+			if err := json.Unmarshal(info.Payload, &transaction); err != nil {
+				return nil, err
+			}
+			if transaction.TransactionID == id || transaction.GroupID == id {
+				found = append(found, scheduledTask{queue: queue, info: info, transaction: transaction})
+			}
+			break
+		}
+	}
+	if len(found) == 0 {
+		return nil, fmt.Errorf("scheduled transaction with ID '%s' %w", id, model.ErrNotFound)
+	}
+	return found, nil
+}
+
+// GetScheduledTransactions returns up to limit transactions that are waiting for their scheduled time, soonest
+// first. Each queue keeps its scheduled tasks in due order, so only the first limit of each are read.
+func (l *Blnk) GetScheduledTransactions(limit int) ([]model.Transaction, error) {
+	queues, err := l.queue.transactionQueueNames()
+	if err != nil {
+		return nil, err
+	}
+
+	limit = model.PageLimit(limit)
+	var tasks []*asynq.TaskInfo
+	for _, queue := range queues {
+		infos, err := l.queue.Inspector.ListScheduledTasks(queue, asynq.PageSize(limit))
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, infos...)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].NextProcessAt.Before(tasks[j].NextProcessAt) })
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+	}
+
+	transactions := make([]model.Transaction, 0, len(tasks))
+	for _, task := range tasks {
+		var transaction model.Transaction
+		if err := json.Unmarshal(task.Payload, &transaction); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, transaction)
+	}
+	return transactions, nil
+}
+
+// CancelScheduledTransaction removes a scheduled transaction from its queue before it runs and records it as
+// cancelled, releasing any funds it reserved. The legs of a distributed transaction are cancelled together
+// when id is their group ID.
+func (l *Blnk) CancelScheduledTransaction(ctx context.Context, id string) ([]*model.Transaction, error) {
+	tasks, err := l.findScheduledTasks(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled := make([]*model.Transaction, 0, len(tasks))
+	for _, task := range tasks {
+		if err := l.queue.Inspector.DeleteTask(task.queue, task.info.ID); err != nil {
+			return cancelled, fmt.Errorf("failed to remove transaction %s from its queue: %w", task.transaction.TransactionID, err)
+		}
+		if !task.transaction.InflightExpiryDate.IsZero() {
+			err := l.queue.Inspector.DeleteTask(EXPIREDINFLIGHT_QUEUE, task.transaction.TransactionID)
+			if err != nil && !errors.Is(err, asynq.ErrTaskNotFound) && !errors.Is(err, asynq.ErrQueueNotFound) {
+				logrus.Errorf("failed to remove inflight expiry of transaction %s: %v", task.transaction.TransactionID, err)
+			}
+		}
+
+		transaction := task.transaction
+		transaction.Status = StatusCancelled
+		if _, err := l.datasource.RecordTransaction(ctx, &transaction); err != nil {
+			return cancelled, err
+		}
+		if err := l.releaseReservation(ctx, &transaction); err != nil {
+			logrus.Errorf("failed to release reservation for transaction %s: %v", transaction.TransactionID, err)
+			notification.NotifyError(err)
+		}
+		l.postTransactionActions(ctx, &transaction)
+		cancelled = append(cancelled, &transaction)
+	}
+	return cancelled, nil
+}
+
+// RescheduleTransaction moves a scheduled transaction to run at scheduledFor instead. Its task is replaced
+// under the same ID in the same queue, so it keeps its place in the balance's ordering.
+func (l *Blnk) RescheduleTransaction(ctx context.Context, id string, scheduledFor time.Time) ([]*model.Transaction, error) {
+	if !scheduledFor.After(time.Now()) {
+		return nil, errors.New("scheduled_for must be in the future")
+	}
+	tasks, err := l.findScheduledTasks(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rescheduled := make([]*model.Transaction, 0, len(tasks))
+	for _, task := range tasks {
+		transaction := task.transaction
+		transaction.ScheduledFor = scheduledFor
+		payload, err := json.Marshal(transaction)
+		if err != nil {
+			return rescheduled, err
+		}
+		if err := l.queue.indexScheduledTask(ctx, &transaction, scheduledFor); err != nil {
+			return rescheduled, err
+		}
+
+		if err := l.queue.Inspector.DeleteTask(task.queue, task.info.ID); err != nil {
+			return rescheduled, fmt.Errorf("failed to remove transaction %s from its queue: %w", transaction.TransactionID, err)
+		}
+		if err := l.enqueueScheduledTask(task, payload, scheduledFor); err != nil {
+			// Put the transaction back as it was rather than lose it.
+			if restoreErr := l.enqueueScheduledTask(task, task.info.Payload, task.info.NextProcessAt); restoreErr != nil {
+				notification.NotifyError(restoreErr)
+				return rescheduled, errors.Join(err, restoreErr)
+			}
+			return rescheduled, err
+		}
+		rescheduled = append(rescheduled, &transaction)
+	}
+	return rescheduled, nil
+}
+
+func (l *Blnk) enqueueScheduledTask(task scheduledTask, payload []byte, at time.Time) error {
+	_, err := l.queue.Client.Enqueue(asynq.NewTask(task.info.Type, payload), asynq.TaskID(task.info.ID), asynq.Queue(task.queue),
+		asynq.ProcessAt(at), asynq.MaxRetry(task.info.MaxRetry))
+	return err
+}
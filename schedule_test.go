@@ -0,0 +1,138 @@
+package blnk
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/northstar-pay/nucleus/config"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newScheduledTransaction queues a transaction on its own Redis to run in an hour.
+func newScheduledTransaction(t *testing.T, d *Blnk) (model.Transaction, string) {
+	mr := miniredis.RunT(t)
+	d.queue = NewQueue(&config.Configuration{Redis: config.RedisConfig{Dns: mr.Addr()}, Queue: config.QueueConfig{TransactionQueues: config.DEFAULT_TRANSACTION_QUEUES}})
+
+	transaction := getTransactionMock(100, false)
+	transaction.TransactionID = model.GenerateUUIDWithSuffix("txn")
+	transaction.Source = gofakeit.UUID()
+	transaction.Status = StatusQueued
+	transaction.ScheduledFor = time.Now().Add(time.Hour)
+	require.NoError(t, d.queue.Enqueue(context.Background(), &transaction))
+	return transaction, transactionQueueFor(transaction.Source, config.DEFAULT_TRANSACTION_QUEUES)
+}
+
+func TestRescheduleTransaction(t *testing.T) {
+	datasource, _, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+	transaction, queue := newScheduledTransaction(t, d)
+
+	scheduled, err := d.GetScheduledTransactions(10)
+	require.NoError(t, err)
+	require.Len(t, scheduled, 1)
+	assert.Equal(t, transaction.TransactionID, scheduled[0].TransactionID)
+
+	_, err = d.RescheduleTransaction(context.Background(), transaction.TransactionID, time.Now().Add(-time.Minute))
+	assert.Error(t, err)
+
+	scheduledFor := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	rescheduled, err := d.RescheduleTransaction(context.Background(), transaction.TransactionID, scheduledFor)
+	require.NoError(t, err)
+	require.Len(t, rescheduled, 1)
+	assert.True(t, scheduledFor.Equal(rescheduled[0].ScheduledFor))
+
+	task, err := d.queue.Inspector.GetTaskInfo(queue, transaction.Reference)
+	require.NoError(t, err)
+	assert.True(t, scheduledFor.Equal(task.NextProcessAt))
+
+	_, err = d.RescheduleTransaction(context.Background(), "unknown", scheduledFor)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
+
+func TestCancelScheduledTransaction(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+	transaction, queue := newScheduledTransaction(t, d)
+
+	insertArgs := make([]driver.Value, 20)
+	for i := range insertArgs {
+		insertArgs[i] = sqlmock.AnyArg()
+	}
+	insertArgs[11] = StatusCancelled
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.transactions`)).WithArgs(insertArgs...).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	cancelled, err := d.CancelScheduledTransaction(context.Background(), transaction.TransactionID)
+	require.NoError(t, err)
+	require.Len(t, cancelled, 1)
+	assert.Equal(t, StatusCancelled, cancelled[0].Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	scheduled, err := d.GetScheduledTransactions(10)
+	require.NoError(t, err)
+	assert.Empty(t, scheduled)
+	_, err = d.queue.Inspector.GetTaskInfo(queue, transaction.Reference)
+	assert.Error(t, err)
+}
+
+func TestScheduledTransactionsFoundByGroup(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+	other, _ := newScheduledTransaction(t, d)
+
+	groupID := model.GenerateUUIDWithSuffix("grp")
+	reference := gofakeit.UUID()
+	var legs []model.Transaction
+	for i, in := range []time.Duration{30 * time.Minute, 3 * time.Hour} {
+		leg := getTransactionMock(50, false)
+		leg.TransactionID = model.GenerateUUIDWithSuffix("txn")
+		leg.Reference = fmt.Sprintf("%s-%d", reference, i+1)
+		leg.GroupID = groupID
+		leg.Source = gofakeit.UUID()
+		leg.Status = StatusQueued
+		leg.ScheduledFor = time.Now().Add(in)
+		require.NoError(t, d.queue.Enqueue(context.Background(), &leg))
+		legs = append(legs, leg)
+	}
+
+	// Only the soonest task of each queue is read for a page of one.
+	scheduled, err := d.GetScheduledTransactions(1)
+	require.NoError(t, err)
+	require.Len(t, scheduled, 1)
+	assert.Equal(t, legs[0].TransactionID, scheduled[0].TransactionID)
+
+	insertArgs := make([]driver.Value, 20)
+	for i := range insertArgs {
+		insertArgs[i] = sqlmock.AnyArg()
+	}
+	insertArgs[11] = StatusCancelled
+	for range legs {
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.transactions`)).WithArgs(insertArgs...).WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	cancelled, err := d.CancelScheduledTransaction(context.Background(), groupID)
+	require.NoError(t, err)
+	require.Len(t, cancelled, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	scheduled, err = d.GetScheduledTransactions(10)
+	require.NoError(t, err)
+	require.Len(t, scheduled, 1)
+	assert.Equal(t, other.TransactionID, scheduled[0].TransactionID)
+
+	_, err = d.CancelScheduledTransaction(context.Background(), groupID)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
@@ -0,0 +1,91 @@
+package blnk
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// SimulateTransaction runs a transaction through the same checks and balance arithmetic as RecordTransaction,
+// including indicator resolution, distributions, rates and quotes, against the current balances. Nothing is
+// persisted and no locks are taken, so the projection can go stale as soon as it is returned. A transaction
+// that would fail is reported as REJECTED with the reason rather than as an error.
+func (l *Blnk) SimulateTransaction(ctx context.Context, transaction *model.Transaction) (*model.TransactionSimulation, error) {
+	ctx, span := tracer.Start(ctx, "Simulating transaction")
+	defer span.End()
+
+	setTransactionStatus(transaction)
+	if transaction.TransactionID == "" {
+		transaction.TransactionID = model.GenerateUUIDWithSuffix("txn")
+	}
+
+	reject := func(err error) (*model.TransactionSimulation, error) {
+		transaction.Status = StatusRejected
+		simulation := &model.TransactionSimulation{Transaction: transaction, Status: StatusRejected, RejectionReason: err.Error()}
+		var rejection *RejectionError
+		if errors.As(ClassifyTransactionError(err), &rejection) {
+			simulation.RejectionCode = rejection.Code
+		}
+		return simulation, nil
+	}
+
+	var quote *model.FXQuote
+	var legs []*model.Transaction
+	var err error
+	switch {
+	case transaction.QuoteID != "":
+		quote, legs, err = l.quotedLegs(transaction)
+	case transaction.HasDistributions():
+		if err = l.applyCurrencyPrecision(transaction); err == nil {
+			legs, err = transaction.SplitTransaction()
+		}
+	default:
+		err = l.applyCurrencyPrecision(transaction)
+		legs = []*model.Transaction{transaction}
+	}
+	if err != nil {
+		return reject(err)
+	}
+
+	balances, err := l.applyLegsWith(ctx, span, legs, l.peekBalance)
+	if err == nil && quote != nil {
+		err = checkQuoteDestination(quote, transaction, balances)
+	}
+	if err != nil {
+		return reject(err)
+	}
+
+	transaction.Status = legs[0].Status
+	simulation := &model.TransactionSimulation{Transaction: transaction, Status: transaction.Status, Balances: balances}
+	if len(legs) > 1 {
+		simulation.Legs = legs
+	}
+	return simulation, nil
+}
+
+// peekBalance is resolveBalance without creating balances for unknown indicators. A missing indicator is
+// projected as an empty balance that would be created when the transaction is recorded.
+func (l *Blnk) peekBalance(identifier, currency string) (*model.Balance, error) {
+	if !strings.HasPrefix(identifier, "@") {
+		return l.datasource.GetBalanceByIDLite(identifier)
+	}
+
+	balance, err := l.datasource.GetBalanceByIndicator(identifier, currency)
+	if err == nil {
+		return balance, nil
+	}
+
+	precision, err := l.resolvePrecision(currency, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Balance{
+		BalanceID:          identifier,
+		Indicator:          identifier,
+		LedgerID:           GeneralLedgerID,
+		Currency:           currency,
+		CurrencyMultiplier: float64(precision),
+	}, nil
+}
@@ -0,0 +1,90 @@
+package blnk
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+var simulationBalanceQuery = regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
+
+func simulationBalanceRow(id string, balance int64) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+		AddRow(id, "USD", 100, "ledger-id", balance, balance, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", "")
+}
+
+func TestSimulateTransaction(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := gofakeit.UUID()
+	txn := &model.Transaction{Reference: gofakeit.UUID(), Source: source, Destination: "@Merchant", Amount: "25.50", Currency: "USD"}
+
+	expectCurrencyLookup(mock, "USD", 2)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(simulationBalanceQuery).WithArgs(source).WillReturnRows(simulationBalanceRow(source, 10000))
+	mock.ExpectQuery("FROM blnk.balances WHERE indicator = \\$1 AND currency = \\$2").
+		WithArgs("@Merchant", "USD").WillReturnError(errors.New("balance with indicator '@Merchant' not found"))
+	expectCurrencyLookup(mock, "USD", 2)
+	expectNoSpendingLimits(mock)
+
+	simulation, err := d.SimulateTransaction(context.Background(), txn)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusApplied, simulation.Status)
+	assert.Empty(t, simulation.RejectionReason)
+	assert.Len(t, simulation.Balances, 2)
+	for _, balance := range simulation.Balances {
+		if balance.BalanceID == source {
+			assert.Equal(t, int64(7450), balance.Balance)
+		} else {
+			assert.Equal(t, "@Merchant", balance.Indicator)
+			assert.Equal(t, int64(2550), balance.Balance)
+		}
+	}
+
+	// Nothing beyond the reads above may run: no balance updates, inserts or indicator balances created.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSimulateTransactionReportsRejection(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := gofakeit.UUID()
+	destination := gofakeit.UUID()
+	txn := &model.Transaction{Reference: gofakeit.UUID(), Source: source, Destination: destination, Amount: "500", Currency: "USD"}
+
+	expectCurrencyLookup(mock, "USD", 2)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(simulationBalanceQuery).WithArgs(source).WillReturnRows(simulationBalanceRow(source, 10000))
+	mock.ExpectQuery(simulationBalanceQuery).WithArgs(destination).WillReturnRows(simulationBalanceRow(destination, 0))
+	expectNoSpendingLimits(mock)
+
+	simulation, err := d.SimulateTransaction(context.Background(), txn)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusRejected, simulation.Status)
+	assert.Contains(t, simulation.RejectionReason, "insufficient funds")
+	assert.Equal(t, RejectionInsufficientFunds, simulation.RejectionCode)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE blnk.transactions ADD COLUMN group_id TEXT;
+CREATE INDEX IF NOT EXISTS idx_transactions_group_id ON blnk.transactions (group_id);
+
+-- +migrate Down
+DROP INDEX IF EXISTS blnk.idx_transactions_group_id;
+ALTER TABLE blnk.transactions DROP COLUMN group_id;
@@ -0,0 +1,34 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.journal_entries
+(
+    id          SERIAL PRIMARY KEY,
+    entry_id    TEXT      NOT NULL UNIQUE,
+    reference   TEXT      NOT NULL UNIQUE,
+    description TEXT,
+    status      TEXT      NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    meta_data   JSONB
+);
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.journal_lines
+(
+    id             SERIAL PRIMARY KEY,
+    line_id        TEXT   NOT NULL UNIQUE,
+    entry_id       TEXT   NOT NULL REFERENCES blnk.journal_entries (entry_id),
+    balance_id     TEXT   NOT NULL REFERENCES blnk.balances (balance_id),
+    direction      TEXT   NOT NULL CHECK (direction IN ('debit', 'credit')),
+    amount         FLOAT  NOT NULL,
+    precise_amount BIGINT NOT NULL,
+    precision      BIGINT DEFAULT 1,
+    currency       TEXT   NOT NULL,
+    description    TEXT
+);
+
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS idx_journal_lines_entry_id ON blnk.journal_lines (entry_id);
+CREATE INDEX IF NOT EXISTS idx_journal_lines_balance_id ON blnk.journal_lines (balance_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.journal_lines CASCADE;
+DROP TABLE IF EXISTS blnk.journal_entries CASCADE;
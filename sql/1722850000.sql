@@ -0,0 +1,9 @@
+-- +migrate Up
+ALTER TABLE blnk.transactions ALTER COLUMN amount TYPE NUMERIC USING amount::NUMERIC;
+ALTER TABLE blnk.transactions ALTER COLUMN rate TYPE NUMERIC USING rate::NUMERIC;
+ALTER TABLE blnk.journal_lines ALTER COLUMN amount TYPE NUMERIC USING amount::NUMERIC;
+
+-- +migrate Down
+ALTER TABLE blnk.transactions ALTER COLUMN amount TYPE FLOAT USING amount::FLOAT;
+ALTER TABLE blnk.transactions ALTER COLUMN rate TYPE BIGINT USING rate::BIGINT;
+ALTER TABLE blnk.journal_lines ALTER COLUMN amount TYPE FLOAT USING amount::FLOAT;
@@ -0,0 +1,58 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.currencies
+(
+    id          SERIAL PRIMARY KEY,
+    code        TEXT      NOT NULL UNIQUE,
+    name        TEXT,
+    symbol      TEXT,
+    minor_units INTEGER   NOT NULL CHECK (minor_units BETWEEN 0 AND 18),
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    meta_data   JSONB
+);
+
+-- +migrate Up
+-- Every balance of a currency must count minor units the same way, as a power of ten, before the currency can be
+-- registered with their precision. Stop and name the currencies whose balances disagree, so they are fixed by
+-- hand rather than registered with a precision some of their balances do not use.
+-- +migrate StatementBegin
+DO $$
+DECLARE
+    conflicts TEXT;
+BEGIN
+    SELECT string_agg(currency || ' (' || multipliers || ')', ', ')
+    INTO conflicts
+    FROM (SELECT currency, string_agg(DISTINCT currency_multiplier::TEXT, ', ') AS multipliers
+          FROM blnk.balances
+          GROUP BY currency
+          HAVING COUNT(DISTINCT GREATEST(currency_multiplier, 1)) > 1
+              OR BOOL_OR(GREATEST(currency_multiplier, 1) <> POWER(10, LENGTH(GREATEST(currency_multiplier, 1)::TEXT) - 1))) c;
+    IF conflicts IS NOT NULL THEN
+        RAISE EXCEPTION 'balances disagree on the precision of their currency: %', conflicts;
+    END IF;
+END
+$$;
+-- +migrate StatementEnd
+
+-- +migrate Up
+-- Register any currency already held by a balance, using the precision those balances were created with. This
+-- runs before the defaults are added so existing balances keep the precision they were created with.
+INSERT INTO blnk.currencies (code, minor_units)
+SELECT currency, LENGTH(MAX(GREATEST(currency_multiplier, 1))::TEXT) - 1
+FROM blnk.balances
+GROUP BY currency
+ON CONFLICT (code) DO NOTHING;
+
+-- +migrate Up
+INSERT INTO blnk.currencies (code, name, symbol, minor_units)
+VALUES ('USD', 'US Dollar', '$', 2),
+       ('EUR', 'Euro', '€', 2),
+       ('GBP', 'Pound Sterling', '£', 2),
+       ('NGN', 'Naira', '₦', 2),
+       ('KES', 'Kenyan Shilling', 'KSh', 2),
+       ('GHS', 'Ghana Cedi', 'GH₵', 2),
+       ('ZAR', 'Rand', 'R', 2),
+       ('JPY', 'Yen', '¥', 0)
+ON CONFLICT (code) DO NOTHING;
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.currencies CASCADE;
@@ -0,0 +1,53 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.fx_rate_sets
+(
+    id           SERIAL PRIMARY KEY,
+    rate_set_id  TEXT      NOT NULL UNIQUE,
+    version      BIGSERIAL NOT NULL UNIQUE,
+    source       TEXT      NOT NULL,
+    effective_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    meta_data    JSONB
+);
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.fx_rates
+(
+    id             SERIAL PRIMARY KEY,
+    rate_set_id    TEXT    NOT NULL REFERENCES blnk.fx_rate_sets (rate_set_id),
+    base_currency  TEXT    NOT NULL,
+    quote_currency TEXT    NOT NULL,
+    rate           NUMERIC NOT NULL CHECK (rate > 0),
+    UNIQUE (rate_set_id, base_currency, quote_currency)
+);
+
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS idx_fx_rates_pair ON blnk.fx_rates (base_currency, quote_currency);
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.fx_quotes
+(
+    id               SERIAL PRIMARY KEY,
+    quote_id         TEXT      NOT NULL UNIQUE,
+    rate_set_id      TEXT      NOT NULL REFERENCES blnk.fx_rate_sets (rate_set_id),
+    rate_set_version BIGINT    NOT NULL,
+    base_currency    TEXT      NOT NULL,
+    quote_currency   TEXT      NOT NULL,
+    rate             NUMERIC   NOT NULL,
+    spread_bps       BIGINT    NOT NULL DEFAULT 0,
+    expires_at       TIMESTAMP NOT NULL,
+    created_at       TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +migrate Up
+ALTER TABLE blnk.transactions ADD COLUMN IF NOT EXISTS quote_id TEXT;
+ALTER TABLE blnk.transactions ADD COLUMN IF NOT EXISTS source_amount NUMERIC;
+ALTER TABLE blnk.transactions ADD COLUMN IF NOT EXISTS destination_amount NUMERIC;
+
+-- +migrate Down
+ALTER TABLE blnk.transactions DROP COLUMN IF EXISTS destination_amount;
+ALTER TABLE blnk.transactions DROP COLUMN IF EXISTS source_amount;
+ALTER TABLE blnk.transactions DROP COLUMN IF EXISTS quote_id;
+DROP TABLE IF EXISTS blnk.fx_quotes CASCADE;
+DROP TABLE IF EXISTS blnk.fx_rates CASCADE;
+DROP TABLE IF EXISTS blnk.fx_rate_sets CASCADE;
@@ -0,0 +1,47 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.balance_snapshots
+(
+    id                      BIGSERIAL PRIMARY KEY,
+    balance_id              TEXT      NOT NULL REFERENCES blnk.balances (balance_id),
+    version                 BIGINT    NOT NULL,
+    transaction_id          TEXT,
+    balance                 BIGINT    NOT NULL,
+    credit_balance          BIGINT    NOT NULL,
+    debit_balance           BIGINT    NOT NULL,
+    inflight_balance        BIGINT    NOT NULL,
+    inflight_credit_balance BIGINT    NOT NULL,
+    inflight_debit_balance  BIGINT    NOT NULL,
+    currency                TEXT      NOT NULL,
+    created_at              TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (balance_id, version)
+);
+
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS idx_balance_snapshots_balance_created ON blnk.balance_snapshots (balance_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_balance_snapshots_transaction_id ON blnk.balance_snapshots (transaction_id);
+
+-- +migrate StatementBegin
+create or replace FUNCTION blnk.reject_snapshot_changes()
+    returns TRIGGER
+as
+$$
+begin
+    raise exception 'balance snapshots are immutable';
+end;
+$$
+language plpgsql;
+-- +migrate StatementEnd
+
+-- +migrate Up
+CREATE TRIGGER balance_snapshots_immutable BEFORE UPDATE OR DELETE ON blnk.balance_snapshots FOR EACH ROW EXECUTE FUNCTION blnk.reject_snapshot_changes();
+
+-- +migrate Up
+-- Existing balances start their history from the state they are in when this migration runs.
+INSERT INTO blnk.balance_snapshots (balance_id, version, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, currency)
+SELECT balance_id, version, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, currency
+FROM blnk.balances
+ON CONFLICT (balance_id, version) DO NOTHING;
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.balance_snapshots CASCADE;
+DROP FUNCTION IF EXISTS blnk.reject_snapshot_changes();
@@ -0,0 +1,19 @@
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS idx_transactions_source ON blnk.transactions (source);
+CREATE INDEX IF NOT EXISTS idx_transactions_destination ON blnk.transactions (destination);
+CREATE INDEX IF NOT EXISTS idx_transactions_currency ON blnk.transactions (currency);
+CREATE INDEX IF NOT EXISTS idx_transactions_created_at ON blnk.transactions (created_at);
+CREATE INDEX IF NOT EXISTS idx_transactions_meta_data ON blnk.transactions USING GIN (meta_data jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS idx_balances_ledger_id ON blnk.balances (ledger_id);
+CREATE INDEX IF NOT EXISTS idx_balances_identity_id ON blnk.balances (identity_id);
+CREATE INDEX IF NOT EXISTS idx_balances_currency ON blnk.balances (currency);
+
+-- +migrate Down
+DROP INDEX IF EXISTS blnk.idx_transactions_source;
+DROP INDEX IF EXISTS blnk.idx_transactions_destination;
+DROP INDEX IF EXISTS blnk.idx_transactions_currency;
+DROP INDEX IF EXISTS blnk.idx_transactions_created_at;
+DROP INDEX IF EXISTS blnk.idx_transactions_meta_data;
+DROP INDEX IF EXISTS blnk.idx_balances_ledger_id;
+DROP INDEX IF EXISTS blnk.idx_balances_identity_id;
+DROP INDEX IF EXISTS blnk.idx_balances_currency;
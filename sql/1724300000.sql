@@ -0,0 +1,22 @@
+-- +migrate Up
+ALTER TABLE blnk.balances ADD COLUMN IF NOT EXISTS reserved_balance BIGINT NOT NULL DEFAULT 0;
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.reservations
+(
+    id             BIGSERIAL PRIMARY KEY,
+    transaction_id TEXT      NOT NULL,
+    balance_id     TEXT      NOT NULL REFERENCES blnk.balances (balance_id),
+    amount         BIGINT    NOT NULL CHECK (amount > 0),
+    status         TEXT      NOT NULL DEFAULT 'HELD' CHECK (status IN ('HELD', 'CONVERTED', 'RELEASED')),
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (transaction_id, balance_id)
+);
+
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS idx_reservations_balance_status ON blnk.reservations (balance_id, status);
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.reservations;
+ALTER TABLE blnk.balances DROP COLUMN IF EXISTS reserved_balance;
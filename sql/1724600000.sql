@@ -0,0 +1,24 @@
+-- +migrate Up
+ALTER TABLE blnk.balances ADD COLUMN IF NOT EXISTS hot BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.balance_deltas
+(
+    id                      BIGSERIAL PRIMARY KEY,
+    balance_id              TEXT      NOT NULL REFERENCES blnk.balances (balance_id),
+    transaction_id          TEXT      NOT NULL,
+    balance                 BIGINT    NOT NULL DEFAULT 0,
+    credit_balance          BIGINT    NOT NULL DEFAULT 0,
+    debit_balance           BIGINT    NOT NULL DEFAULT 0,
+    inflight_balance        BIGINT    NOT NULL DEFAULT 0,
+    inflight_credit_balance BIGINT    NOT NULL DEFAULT 0,
+    inflight_debit_balance  BIGINT    NOT NULL DEFAULT 0,
+    created_at              TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS idx_balance_deltas_balance_id ON blnk.balance_deltas (balance_id, id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.balance_deltas;
+ALTER TABLE blnk.balances DROP COLUMN IF EXISTS hot;
@@ -0,0 +1,41 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.recurring_transactions
+(
+    id               SERIAL PRIMARY KEY,
+    recurring_id     TEXT      NOT NULL UNIQUE,
+    cron             TEXT,
+    interval_seconds BIGINT    NOT NULL DEFAULT 0,
+    start_at         TIMESTAMP NOT NULL,
+    end_at           TIMESTAMP,
+    max_occurrences  BIGINT    NOT NULL DEFAULT 0,
+    occurrences      BIGINT    NOT NULL DEFAULT 0,
+    next_run_at      TIMESTAMP NOT NULL,
+    last_run_at      TIMESTAMP,
+    status           TEXT      NOT NULL,
+    template         JSONB     NOT NULL,
+    created_at       TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMP NOT NULL DEFAULT NOW(),
+    meta_data        JSONB
+);
+
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS idx_recurring_transactions_due ON blnk.recurring_transactions (next_run_at) WHERE status = 'ACTIVE';
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.recurring_occurrences
+(
+    id             SERIAL PRIMARY KEY,
+    recurring_id   TEXT      NOT NULL REFERENCES blnk.recurring_transactions (recurring_id),
+    sequence       BIGINT    NOT NULL,
+    scheduled_for  TIMESTAMP NOT NULL,
+    transaction_id TEXT,
+    reference      TEXT      NOT NULL,
+    status         TEXT      NOT NULL,
+    error          TEXT,
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (recurring_id, sequence)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.recurring_occurrences CASCADE;
+DROP TABLE IF EXISTS blnk.recurring_transactions CASCADE;
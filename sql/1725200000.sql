@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE blnk.balances ADD COLUMN IF NOT EXISTS overdraft_limit BIGINT NOT NULL DEFAULT 0 CHECK (overdraft_limit >= 0);
+ALTER TABLE blnk.balances ADD COLUMN IF NOT EXISTS overdraft_expires_at TIMESTAMP;
+
+-- +migrate Down
+ALTER TABLE blnk.balances DROP COLUMN IF EXISTS overdraft_expires_at;
+ALTER TABLE blnk.balances DROP COLUMN IF EXISTS overdraft_limit;
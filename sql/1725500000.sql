@@ -0,0 +1,24 @@
+-- +migrate Up
+ALTER TABLE blnk.balances ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
+ALTER TABLE blnk.balances ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.balance_status_changes
+(
+    id                   SERIAL PRIMARY KEY,
+    balance_id           TEXT      NOT NULL REFERENCES blnk.balances (balance_id),
+    from_status          TEXT      NOT NULL,
+    to_status            TEXT      NOT NULL,
+    reason               TEXT      NOT NULL,
+    changed_by           TEXT      NOT NULL DEFAULT '',
+    sweep_transaction_id TEXT,
+    created_at           TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS idx_balance_status_changes_balance_id ON blnk.balance_status_changes (balance_id, id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.balance_status_changes CASCADE;
+ALTER TABLE blnk.balances DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE blnk.balances DROP COLUMN IF EXISTS status;
@@ -0,0 +1,20 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.spending_limits
+(
+    id         SERIAL PRIMARY KEY,
+    limit_id   TEXT      NOT NULL UNIQUE,
+    scope      TEXT      NOT NULL,
+    scope_id   TEXT      NOT NULL,
+    period     TEXT      NOT NULL,
+    currency   TEXT      NOT NULL DEFAULT '',
+    max_amount BIGINT    NOT NULL DEFAULT 0 CHECK (max_amount >= 0),
+    max_count  BIGINT    NOT NULL DEFAULT 0 CHECK (max_count >= 0),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    meta_data  JSONB
+);
+
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS idx_spending_limits_scope ON blnk.spending_limits (scope, scope_id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.spending_limits CASCADE;
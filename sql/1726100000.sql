@@ -0,0 +1,37 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.kyc_tiers
+(
+    id              SERIAL PRIMARY KEY,
+    tier_id         TEXT      NOT NULL UNIQUE,
+    name            TEXT      NOT NULL UNIQUE,
+    currency        TEXT      NOT NULL,
+    max_balance     BIGINT    NOT NULL DEFAULT 0 CHECK (max_balance >= 0),
+    max_transaction BIGINT    NOT NULL DEFAULT 0 CHECK (max_transaction >= 0),
+    daily_volume    BIGINT    NOT NULL DEFAULT 0 CHECK (daily_volume >= 0),
+    cap_action      TEXT      NOT NULL DEFAULT 'reject',
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    meta_data       JSONB
+);
+
+-- +migrate Up
+ALTER TABLE blnk.identity ADD COLUMN IF NOT EXISTS tier_id TEXT REFERENCES blnk.kyc_tiers (tier_id);
+
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.identity_tier_changes
+(
+    id          SERIAL PRIMARY KEY,
+    identity_id TEXT      NOT NULL REFERENCES blnk.identity (identity_id),
+    from_tier   TEXT      NOT NULL DEFAULT '',
+    to_tier     TEXT      NOT NULL,
+    reason      TEXT      NOT NULL DEFAULT '',
+    changed_by  TEXT      NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +migrate Up
+CREATE INDEX IF NOT EXISTS idx_identity_tier_changes_identity_id ON blnk.identity_tier_changes (identity_id, id);
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.identity_tier_changes CASCADE;
+ALTER TABLE blnk.identity DROP COLUMN IF EXISTS tier_id;
+DROP TABLE IF EXISTS blnk.kyc_tiers CASCADE;
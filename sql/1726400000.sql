@@ -0,0 +1,8 @@
+-- +migrate Up
+-- Deltas are kept as the history of hot balances; flushed_at marks those already folded into their balance.
+ALTER TABLE blnk.balance_deltas ADD COLUMN IF NOT EXISTS flushed_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_balance_deltas_pending ON blnk.balance_deltas (id) WHERE flushed_at IS NULL;
+
+-- +migrate Down
+DROP INDEX IF EXISTS blnk.idx_balance_deltas_pending;
+ALTER TABLE blnk.balance_deltas DROP COLUMN IF EXISTS flushed_at;
@@ -0,0 +1,40 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS blnk.spending_limit_usage
+(
+    limit_id     TEXT      NOT NULL,
+    scope_id     TEXT      NOT NULL,
+    window_start TIMESTAMP NOT NULL,
+    amount       BIGINT    NOT NULL DEFAULT 0,
+    count        BIGINT    NOT NULL DEFAULT 0,
+    PRIMARY KEY (limit_id, scope_id, window_start)
+);
+
+-- +migrate Up
+-- Usage was summed from transactions before; carry the current window of every limit over so no limit resets
+-- early. A tier's daily volume is a limit on each identity on the tier, keyed by the tier's ID.
+WITH limits AS (
+    SELECT limit_id, scope, scope_id, period, currency FROM blnk.spending_limits
+    UNION ALL
+    SELECT t.tier_id, 'identity', i.identity_id, 'daily', t.currency
+    FROM blnk.identity i JOIN blnk.kyc_tiers t ON t.tier_id = i.tier_id
+    WHERE t.daily_volume > 0
+), windows AS (
+    SELECT limits.*, date_trunc(CASE period WHEN 'weekly' THEN 'week' WHEN 'monthly' THEN 'month' ELSE 'day' END,
+                                NOW() AT TIME ZONE 'UTC') AS window_start
+    FROM limits
+)
+INSERT INTO blnk.spending_limit_usage (limit_id, scope_id, window_start, amount, count)
+SELECT w.limit_id, w.scope_id, w.window_start, COALESCE(SUM(t.precise_amount), 0)::BIGINT, COUNT(*)
+FROM windows w
+JOIN blnk.balances b ON (w.scope = 'balance' AND b.balance_id = w.scope_id)
+    OR (w.scope = 'identity' AND b.identity_id = w.scope_id)
+    OR (w.scope = 'ledger' AND b.ledger_id = w.scope_id)
+JOIN blnk.transactions t ON t.source = b.balance_id
+WHERE t.status IN ('APPLIED', 'INFLIGHT') AND t.source <> t.destination AND t.created_at >= w.window_start
+  AND (w.currency = '' OR t.currency = w.currency)
+  AND NOT EXISTS (SELECT 1 FROM blnk.transactions p WHERE p.transaction_id = t.parent_transaction AND p.status = 'INFLIGHT')
+GROUP BY w.limit_id, w.scope_id, w.window_start
+ON CONFLICT (limit_id, scope_id, window_start) DO NOTHING;
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.spending_limit_usage;
@@ -0,0 +1,14 @@
+-- +migrate Up
+-- The transaction a distributed transaction's legs were split from. Only the legs move balances, so it is kept
+-- apart from blnk.transactions and found by its own ID when it is fetched.
+CREATE TABLE IF NOT EXISTS blnk.transaction_groups
+(
+    group_id       TEXT PRIMARY KEY,
+    transaction_id TEXT      NOT NULL UNIQUE,
+    reference      TEXT      NOT NULL,
+    transaction    JSONB     NOT NULL,
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.transaction_groups;
@@ -0,0 +1,10 @@
+-- +migrate Up
+-- Occurrences are recorded as pending when they are claimed and retried until their transaction is queued.
+ALTER TABLE blnk.recurring_occurrences ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 1;
+ALTER TABLE blnk.recurring_occurrences ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+CREATE INDEX IF NOT EXISTS idx_recurring_occurrences_retry ON blnk.recurring_occurrences (updated_at) WHERE status IN ('PENDING', 'FAILED');
+
+-- +migrate Down
+DROP INDEX IF EXISTS blnk.idx_recurring_occurrences_retry;
+ALTER TABLE blnk.recurring_occurrences DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE blnk.recurring_occurrences DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,8 @@
+-- +migrate Up
+-- A quote is used by the first transaction posted with it; later transactions cannot reuse it.
+ALTER TABLE blnk.fx_quotes ADD COLUMN IF NOT EXISTS used_by TEXT;
+ALTER TABLE blnk.fx_quotes ADD COLUMN IF NOT EXISTS used_at TIMESTAMP;
+
+-- +migrate Down
+ALTER TABLE blnk.fx_quotes DROP COLUMN IF EXISTS used_at;
+ALTER TABLE blnk.fx_quotes DROP COLUMN IF EXISTS used_by;
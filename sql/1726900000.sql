@@ -0,0 +1,14 @@
+-- +migrate Up
+-- What each inflight transaction was charged against its spending limits, so voiding it can give the usage back.
+CREATE TABLE IF NOT EXISTS blnk.spending_limit_charges
+(
+    transaction_id TEXT      NOT NULL,
+    limit_id       TEXT      NOT NULL,
+    scope_id       TEXT      NOT NULL,
+    window_start   TIMESTAMP NOT NULL,
+    amount         BIGINT    NOT NULL,
+    PRIMARY KEY (transaction_id, limit_id, scope_id)
+);
+
+-- +migrate Down
+DROP TABLE IF EXISTS blnk.spending_limit_charges;
@@ -0,0 +1,54 @@
+package blnk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// GenerateStatement builds the statement of a balance, or of the balance behind an account, for [from, to).
+// The opening balance is the balance's snapshot at from; the entries are its applied transactions and journal
+// lines in the period, read together with it.
+func (l *Blnk) GenerateStatement(id string, from, to time.Time) (*model.Statement, error) {
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("statement start %s must be before its end %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	statement := &model.Statement{StatementID: model.GenerateUUIDWithSuffix("stm"), BalanceID: id, From: from, To: to, Entries: []model.StatementEntry{}}
+	if strings.HasPrefix(id, "acc_") {
+		account, err := l.datasource.GetAccountByID(id, nil)
+		if err != nil {
+			return nil, err
+		}
+		statement.AccountID, statement.BalanceID = account.AccountID, account.BalanceID
+	}
+
+	balance, err := l.datasource.GetBalanceByID(statement.BalanceID, nil)
+	if err != nil {
+		return nil, err
+	}
+	statement.Currency = balance.Currency
+	statement.Precision = int64(balance.CurrencyMultiplier)
+	if statement.Precision <= 0 {
+		statement.Precision = 1
+	}
+
+	opening, entries, err := l.datasource.GetBalanceStatement(context.Background(), balance.BalanceID, statement.Precision, from, to)
+	if err != nil {
+		return nil, err
+	}
+	statement.OpeningBalance = opening
+	statement.ClosingBalance = opening
+	for _, entry := range entries {
+		statement.AddEntry(entry)
+	}
+	statement.CreatedAt = time.Now()
+
+	return statement, nil
+}
@@ -0,0 +1,90 @@
+package blnk
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateStatement(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	if err != nil {
+		t.Fatalf("Error creating test data source: %s", err)
+	}
+
+	d, err := NewBlnk(datasource)
+	if err != nil {
+		t.Fatalf("Error creating Blnk instance: %s", err)
+	}
+	balanceID := "bln_statement"
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	mock.ExpectBegin()
+	rows := sqlmock.NewRows([]string{"balance_id", "balance", "credit_balance", "debit_balance", "currency", "currency_multiplier", "ledger_id", "identity_id", "created_at", "meta_data", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason"}).
+		AddRow(balanceID, 5000, 9000, 4000, "USD", 100, "test-ledger", "", from.AddDate(-1, 0, 0), `{}`, 0, 0, 0, 6, 0, false, 0, nil, "active", "")
+	mock.ExpectQuery("SELECT b\\.balance_id").WithArgs(balanceID).WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	// The opening balance and the entries are read in one read-only transaction.
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM blnk.balance_snapshots").
+		WithArgs(balanceID, from).
+		WillReturnRows(sqlmock.NewRows([]string{"balance_id", "version", "transaction_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "currency", "created_at"}).
+			AddRow(balanceID, 4, "txn_0", 3500, 7000, 3500, 0, 0, 0, "USD", from.Add(-time.Hour)))
+	mock.ExpectQuery("FROM blnk.journal_lines").
+		WithArgs(balanceID, int64(100), from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reference", "description", "source", "destination", "amount", "created_at"}).
+			AddRow("txn_1", "ref_1", "", "bln_other", balanceID, 2000, from.Add(time.Hour)).
+			AddRow("txn_2", "ref_2", "", balanceID, "bln_other", 500, from.Add(2*time.Hour)).
+			AddRow("jrn_1", "ref_3", "accrual", "", balanceID, 300, from.Add(3*time.Hour)))
+	mock.ExpectCommit()
+
+	statement, err := d.GenerateStatement(balanceID, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3500), statement.OpeningBalance)
+	assert.Equal(t, int64(5300), statement.ClosingBalance)
+	assert.Equal(t, int64(5500), statement.Entries[0].RunningBalance)
+	assert.Equal(t, "bln_other", statement.Entries[1].Counterparty)
+	assert.Equal(t, model.EntryCredit, statement.Entries[2].Direction)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGenerateStatementWithoutSnapshotAtStart(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+	balanceID := "bln_statement"
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT b\\.balance_id").WithArgs(balanceID).WillReturnRows(sqlmock.NewRows([]string{"balance_id", "balance", "credit_balance", "debit_balance", "currency", "currency_multiplier", "ledger_id", "identity_id", "created_at", "meta_data", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason"}).
+		AddRow(balanceID, 5000, 9000, 4000, "USD", 100, "test-ledger", "", from.AddDate(-1, 0, 0), `{}`, 0, 0, 0, 6, 0, false, 0, nil, "active", ""))
+	mock.ExpectCommit()
+
+	// Without a snapshot by from, the balance opens at its current figure less what moved it since, journal
+	// lines included.
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM blnk.balance_snapshots").WithArgs(balanceID, from).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT b.balance - COALESCE").WithArgs(balanceID, int64(100), from).
+		WillReturnRows(sqlmock.NewRows([]string{"opening"}).AddRow(4700))
+	mock.ExpectQuery("FROM blnk.journal_lines").WithArgs(balanceID, int64(100), from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "reference", "description", "source", "destination", "amount", "created_at"}).
+			AddRow("jrn_1", "ref_3", "", "", balanceID, 300, from.Add(time.Hour)))
+	mock.ExpectCommit()
+
+	statement, err := d.GenerateStatement(balanceID, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4700), statement.OpeningBalance)
+	assert.Equal(t, int64(5000), statement.ClosingBalance)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,228 @@
+package blnk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/northstar-pay/nucleus/internal/notification"
+	"github.com/northstar-pay/nucleus/model"
+)
+
+// CreateKYCTier adds a tier. maxBalance, maxTransaction and dailyVolume are caps in major units of the tier's
+// currency; each one left empty is taken from the tier, in minor units.
+func (l *Blnk) CreateKYCTier(tier model.KYCTier, maxBalance, maxTransaction, dailyVolume json.Number) (model.KYCTier, error) {
+	if tier.Currency == "" {
+		return model.KYCTier{}, errors.New("currency is required")
+	}
+	precision, err := l.resolvePrecision(tier.Currency, 0)
+	if err != nil {
+		return model.KYCTier{}, err
+	}
+	caps := []struct {
+		amount json.Number
+		cap    *int64
+	}{{maxBalance, &tier.MaxBalance}, {maxTransaction, &tier.MaxTransaction}, {dailyVolume, &tier.DailyVolume}}
+	for _, c := range caps {
+		if c.amount == "" {
+			continue
+		}
+		if *c.cap, err = model.ToPrecise(c.amount, precision); err != nil {
+			return model.KYCTier{}, err
+		}
+	}
+	if tier.CapAction == "" {
+		tier.CapAction = model.TierCapReject
+	}
+	if err := tier.Validate(); err != nil {
+		return model.KYCTier{}, err
+	}
+
+	tier.TierID = model.GenerateUUIDWithSuffix("tier")
+	tier.CreatedAt = time.Now()
+	return l.datasource.CreateKYCTier(tier)
+}
+
+func (l *Blnk) GetKYCTier(id string) (*model.KYCTier, error) {
+	return l.datasource.GetKYCTier(id)
+}
+
+func (l *Blnk) GetAllKYCTiers() ([]model.KYCTier, error) {
+	return l.datasource.GetAllKYCTiers()
+}
+
+// ChangeIdentityTier assigns an identity to change.ToTier, keeping change.Reason and change.ChangedBy in the
+// identity's tier history. The new tier's caps bind the identity's balances from their next transaction;
+// balances already over a lower tier's max balance keep what they hold but cannot be credited further. An
+// identity with hot balances cannot move to a tier with a max balance in their currency, see checkHotAllowed.
+func (l *Blnk) ChangeIdentityTier(ctx context.Context, identityID string, change model.TierChange) (*model.Identity, error) {
+	if change.ChangedBy == "" {
+		return nil, errors.New("changed_by is required to change an identity's tier")
+	}
+	identity, err := l.datasource.GetIdentityByID(identityID)
+	if err != nil {
+		return nil, err
+	}
+	tier, err := l.datasource.GetKYCTier(change.ToTier)
+	if err != nil {
+		return nil, err
+	}
+	if tier.MaxBalance > 0 {
+		hot, err := l.datasource.IdentityHasHotBalances(ctx, identityID, tier.Currency)
+		if err != nil {
+			return nil, err
+		}
+		if hot {
+			return nil, fmt.Errorf("identity %s has hot %s balances, which cannot be held to the max balance of kyc tier %s", identityID, tier.Currency, tier.Name)
+		}
+	}
+	if identity.TierID == change.ToTier {
+		return nil, fmt.Errorf("identity %s is already on tier %s", identityID, change.ToTier)
+	}
+
+	change.IdentityID = identityID
+	change.FromTier = identity.TierID
+	change.CreatedAt = time.Now()
+	updated, err := l.datasource.UpdateIdentityTier(ctx, change)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("identity %s changed tier while it was being updated, try again", identityID)
+	}
+
+	identity.TierID = change.ToTier
+	go func() {
+		err := SendWebhook(NewWebhook{
+			Event:   "identity.tier_changed",
+			Payload: change,
+		})
+		if err != nil {
+			notification.NotifyError(err)
+		}
+	}()
+	return identity, nil
+}
+
+// GetIdentityTierChanges returns the history of an identity's tier, newest first.
+func (l *Blnk) GetIdentityTierChanges(identityID string, cursor int64, limit int) (model.Page[model.TierChange], error) {
+	if _, err := l.datasource.GetIdentityByID(identityID); err != nil {
+		return model.Page[model.TierChange]{}, err
+	}
+	limit = model.PageLimit(limit)
+	changes, err := l.datasource.GetIdentityTierChanges(identityID, cursor, limit+1)
+	if err != nil {
+		return model.Page[model.TierChange]{}, err
+	}
+	return model.NewPage(changes, limit, func(change model.TierChange) int64 { return change.ID }), nil
+}
+
+// balanceTier returns the tier that binds a balance through its identity, or nil when the balance has no
+// identity, the identity has no tier or the tier is for another currency.
+func (l *Blnk) balanceTier(ctx context.Context, balance *model.Balance) (*model.KYCTier, error) {
+	if balance.IdentityID == "" {
+		return nil, nil
+	}
+	tier, err := l.datasource.GetIdentityTier(ctx, balance.IdentityID)
+	if err != nil || tier == nil || !tier.AppliesTo(balance) {
+		return nil, err
+	}
+	return tier, nil
+}
+
+// checkHotAllowed refuses to make a balance hot when its identity's tier caps what it may hold. Hot balances are
+// credited without a lock, so concurrent credits could together take one past the cap.
+func (l *Blnk) checkHotAllowed(ctx context.Context, balance *model.Balance) error {
+	tier, err := l.balanceTier(ctx, balance)
+	if err != nil {
+		return err
+	}
+	if tier != nil && tier.MaxBalance > 0 {
+		return fmt.Errorf("balance cannot be hot: identity %s is on kyc tier %s, which caps its %s balances", balance.IdentityID, tier.Name, tier.Currency)
+	}
+	return nil
+}
+
+// checkLimits runs the checks that depend on who holds the balances: the spending limits on the source and the
+// caps of both balances' KYC tiers. used is passed on to checkSpendingLimits. When canHold is set, a credit that
+// would take the destination past the max balance of a tier that holds is turned into an inflight transaction
+// instead of being rejected; batches pass false, since their legs are applied together.
+func (l *Blnk) checkLimits(ctx context.Context, transaction *model.Transaction, source, destination *model.Balance, used map[string]model.LimitUsage, canHold bool) error {
+	sourceTier, err := l.balanceTier(ctx, source)
+	if err != nil {
+		return err
+	}
+	destinationTier, err := l.balanceTier(ctx, destination)
+	if err != nil {
+		return err
+	}
+
+	if err := l.checkSpendingLimits(ctx, transaction, source, sourceTier, used); err != nil {
+		return err
+	}
+	return checkTierCaps(transaction, source, destination, sourceTier, destinationTier, canHold)
+}
+
+// checkCommitTierCap checks that committing an inflight transaction keeps its destination within the max balance
+// of its tier. The cap may have been lowered, or the balance credited, while the transaction was held.
+func (l *Blnk) checkCommitTierCap(ctx context.Context, transaction *model.Transaction, destination *model.Balance) error {
+	tier, err := l.balanceTier(ctx, destination)
+	if err != nil || tier == nil {
+		return err
+	}
+	return tier.CheckCommit(destination, transaction.PreciseAmount)
+}
+
+// checkTierCaps checks the transaction against the max transaction of both balances' tiers and the credit
+// against the destination tier's max balance.
+func checkTierCaps(transaction *model.Transaction, source, destination *model.Balance, sourceTier, destinationTier *model.KYCTier, canHold bool) error {
+	if sourceTier == nil && destinationTier == nil {
+		return nil
+	}
+	amount, err := model.ApplyPrecision(transaction)
+	if err != nil {
+		return err
+	}
+	if sourceTier != nil {
+		if err := sourceTier.CheckTransaction(source.BalanceID, amount); err != nil {
+			return err
+		}
+	}
+	if destinationTier == nil {
+		return nil
+	}
+
+	credit := amount
+	if transaction.ConvertsCurrency() {
+		priced := *transaction
+		priced.PreciseAmount = amount
+		if credit, err = model.ApplyRate(&priced, int64(destination.CurrencyMultiplier)); err != nil {
+			return err
+		}
+	}
+	if err := destinationTier.CheckTransaction(destination.BalanceID, credit); err != nil {
+		return err
+	}
+
+	err = destinationTier.CheckBalance(destination, credit)
+	var capErr *model.TierError
+	if canHold && destinationTier.CapAction == model.TierCapHold && errors.As(err, &capErr) {
+		holdTransaction(transaction, capErr)
+		return nil
+	}
+	return err
+}
+
+// holdTransaction records a transaction as inflight instead of applying it, noting why under the
+// blnk_hold_reason and blnk_hold_code metadata keys. Committing it releases the funds to the destination.
+func holdTransaction(transaction *model.Transaction, reason *model.TierError) {
+	transaction.Inflight = true
+	transaction.Status = StatusInflight
+	if transaction.MetaData == nil {
+		transaction.MetaData = make(map[string]interface{})
+	}
+	transaction.MetaData["blnk_hold_reason"] = reason.Error()
+	transaction.MetaData["blnk_hold_code"] = reason.Code
+}
@@ -0,0 +1,195 @@
+package blnk
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/northstar-pay/nucleus/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var kycTierColumns = []string{"id", "tier_id", "name", "currency", "max_balance", "max_transaction", "daily_volume", "cap_action", "created_at", "meta_data"}
+
+// expectIdentityBalanceLite expects a lite read of a USD balance in ledger-id held by the given identity.
+func expectIdentityBalanceLite(mock sqlmock.Sqlmock, balanceID string, balance int64, identityID string) {
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.balances WHERE balance_id = $1`)).WithArgs(balanceID).
+		WillReturnRows(sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+			AddRow(balanceID, "USD", 100, "ledger-id", balance, balance, 0, 0, 0, 0, time.Now(), 1, 0, false, 0, nil, model.BalanceActive, "", identityID))
+}
+
+// expectIdentityTier expects the tier of an identity to be looked up.
+func expectIdentityTier(mock sqlmock.Sqlmock, identityID string, tier model.KYCTier) {
+	mock.ExpectQuery(regexp.QuoteMeta(`JOIN blnk.kyc_tiers t ON t.tier_id = i.tier_id`)).WithArgs(identityID).
+		WillReturnRows(sqlmock.NewRows(kycTierColumns).AddRow(1, tier.TierID, tier.Name, tier.Currency, tier.MaxBalance, tier.MaxTransaction,
+			tier.DailyVolume, tier.CapAction, time.Now(), nil))
+}
+
+func expectTierTransfer(mock sqlmock.Sqlmock, txn *model.Transaction, source, destination, identityID string, tier model.KYCTier) {
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "USD", 2)
+	expectBalanceLite(mock, source, 100000, 0, model.BalanceActive)
+	expectIdentityBalanceLite(mock, destination, 45000, identityID)
+	expectIdentityTier(mock, identityID, tier)
+	expectNoSpendingLimits(mock)
+}
+
+func TestRecordTransactionRejectedByTierMaxBalance(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	source, destination, identityID := gofakeit.UUID(), gofakeit.UUID(), "idt_basic"
+	tier := model.KYCTier{TierID: "tier_basic", Name: "basic", Currency: "USD", MaxBalance: 50000, CapAction: model.TierCapReject}
+	txn := &model.Transaction{Reference: gofakeit.UUID(), Source: source, Destination: destination, Amount: "60", Currency: "USD"}
+
+	// 450.00 plus 60.00 goes past the tier's 500.00 max balance.
+	expectTierTransfer(mock, txn, source, destination, identityID, tier)
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	assert.ErrorIs(t, err, model.ErrTierCapExceeded)
+
+	var rejection *RejectionError
+	require.ErrorAs(t, ClassifyTransactionError(err), &rejection)
+	assert.Equal(t, "tier_max_balance_exceeded", rejection.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordTransactionHeldByTierMaxBalance(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	source, destination, identityID := gofakeit.UUID(), gofakeit.UUID(), "idt_basic"
+	tier := model.KYCTier{TierID: "tier_basic", Name: "basic", Currency: "USD", MaxBalance: 50000, CapAction: model.TierCapHold}
+	txn := &model.Transaction{Reference: gofakeit.UUID(), Source: source, Destination: destination, Amount: "60", Currency: "USD", Status: StatusQueued}
+
+	expectTierTransfer(mock, txn, source, destination, identityID, tier)
+	// The credit waits as inflight instead of being applied.
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).
+		WithArgs(source, 100000, 100000, 0, -6000, 0, 6000, "USD", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).
+		WithArgs(destination, 45000, 45000, 0, 6000, 6000, 0, "USD", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	insertArgs := make([]driver.Value, 20)
+	for i := range insertArgs {
+		insertArgs[i] = sqlmock.AnyArg()
+	}
+	insertArgs[2], insertArgs[9], insertArgs[11] = source, destination, StatusInflight
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.transactions`)).WithArgs(insertArgs...).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	held, err := d.RecordTransaction(context.Background(), txn)
+	require.NoError(t, err)
+	assert.Equal(t, StatusInflight, held.Status)
+	assert.Equal(t, "tier_max_balance_exceeded", held.MetaData["blnk_hold_code"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCommitInflightTransactionRechecksTierMaxBalance(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	transactionID, source, destination, identityID := gofakeit.UUID(), gofakeit.UUID(), gofakeit.UUID(), "idt_basic"
+	tier := model.KYCTier{TierID: "tier_basic", Name: "basic", Currency: "USD", MaxBalance: 50000, CapAction: model.TierCapHold}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.transactions WHERE transaction_id = $1`)).WithArgs(transactionID).
+		WillReturnRows(sqlmock.NewRows([]string{"transaction_id", "source", "reference", "amount", "precise_amount", "precision", "currency", "destination", "description", "status", "created_at", "meta_data", "group_id", "rate", "quote_id", "source_amount", "destination_amount"}).
+			AddRow(transactionID, source, gofakeit.UUID(), "60", 6000, 100, "USD", destination, "", StatusInflight, time.Now(), []byte("{}"), "", "1", "", "", ""))
+	expectBalanceLite(mock, source, 100000, 0, model.BalanceActive)
+	expectIdentityBalanceLite(mock, destination, 45000, identityID)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.transactions`)).WithArgs(transactionID).
+		WillReturnRows(sqlmock.NewRows([]string{"total_amount"}))
+	// The held 60.00 still does not fit under the 500.00 max balance, so it cannot be committed.
+	expectIdentityTier(mock, identityID, tier)
+
+	_, err = d.CommitInflightTransaction(context.Background(), transactionID, "")
+	assert.ErrorIs(t, err, model.ErrTierCapExceeded)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordTransactionRejectedByTierDailyVolume(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	source, destination, identityID := gofakeit.UUID(), gofakeit.UUID(), "idt_basic"
+	tier := model.KYCTier{TierID: "tier_basic", Name: "basic", Currency: "USD", DailyVolume: 20000, CapAction: model.TierCapReject}
+	txn := &model.Transaction{Reference: gofakeit.UUID(), Source: source, Destination: destination, Amount: "50", Currency: "USD"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "USD", 2)
+	expectIdentityBalanceLite(mock, source, 100000, identityID)
+	expectBalanceLite(mock, destination, 0, 0, model.BalanceActive)
+	expectIdentityTier(mock, identityID, tier)
+	expectNoSpendingLimits(mock)
+	// The identity's balances already sent 160.00 of the tier's 200.00 today.
+	expectLimitUsage(mock, model.SpendingLimit{LimitID: tier.TierID, ScopeID: identityID}, model.LimitUsage{Amount: 16000, Count: 4})
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	var limitErr *model.LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "daily_amount_limit_exceeded", limitErr.Code)
+	assert.Equal(t, "tier_basic", limitErr.Limit.LimitID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestChangeIdentityTier(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	identityID := "idt_basic"
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.identity`)).WithArgs(identityID).
+		WillReturnRows(sqlmock.NewRows([]string{"identity_id", "identity_type", "first_name", "last_name", "other_names", "gender", "dob", "email_address", "phone_number", "nationality", "organization_name", "category", "street", "country", "state", "post_code", "city", "tier_id", "created_at", "meta_data"}).
+			AddRow(identityID, "individual", "Ada", "Obi", "", "female", time.Now(), "ada@example.com", "", "NG", "", "", "", "NG", "", "", "", "tier_basic", time.Now(), []byte(`{}`)))
+	mock.ExpectCommit()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.kyc_tiers t`)).WithArgs("tier_full").
+		WillReturnRows(sqlmock.NewRows(kycTierColumns).AddRow(2, "tier_full", "full", "USD", 0, 0, 0, model.TierCapReject, time.Now(), nil))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.identity`)).WithArgs(identityID, "tier_full", "tier_basic").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.identity_tier_changes`)).
+		WithArgs(identityID, "tier_basic", "tier_full", "documents verified", "ops@example.com", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	change := model.TierChange{ToTier: "tier_full", Reason: "documents verified", ChangedBy: "ops@example.com"}
+	identity, err := d.ChangeIdentityTier(context.Background(), identityID, change)
+	require.NoError(t, err)
+	assert.Equal(t, "tier_full", identity.TierID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// Who made the change is part of the history and cannot be left out.
+	_, err = d.ChangeIdentityTier(context.Background(), identityID, model.TierChange{ToTier: "tier_basic"})
+	assert.Error(t, err)
+}
+
+func TestCreateHotBalanceRefusedForTierWithMaxBalance(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	require.NoError(t, err)
+	d, err := NewBlnk(datasource)
+	require.NoError(t, err)
+
+	identityID := "idt_basic"
+	expectCurrencyLookup(mock, "USD", 2)
+	expectIdentityTier(mock, identityID, model.KYCTier{TierID: "tier_basic", Name: "basic", Currency: "USD", MaxBalance: 50000, CapAction: model.TierCapReject})
+
+	_, err = d.CreateBalance(model.Balance{LedgerID: "ledger-id", IdentityID: identityID, Currency: "USD", Hot: true})
+	assert.ErrorContains(t, err, "balance cannot be hot")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,6 +3,7 @@ package blnk
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -16,6 +17,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/otel"
 
+	"github.com/northstar-pay/nucleus/config"
 	"github.com/northstar-pay/nucleus/model"
 )
 
@@ -30,6 +32,7 @@ const (
 	StatusInflight  = "INFLIGHT"
 	StatusVoid      = "VOID"
 	StatusRejected  = "REJECTED"
+	StatusCancelled = "CANCELLED"
 )
 
 func getEventFromStatus(status string) string {
@@ -46,57 +49,151 @@ func getEventFromStatus(status string) string {
 		return "transaction.void"
 	case strings.ToLower(StatusRejected):
 		return "transaction.rejected"
+	case strings.ToLower(StatusCancelled):
+		return "transaction.cancelled"
 	default:
 		return "transaction.unknown"
 	}
 }
 
-func (l *Blnk) getSourceAndDestination(transaction *model.Transaction) (source *model.Balance, destination *model.Balance, err error) {
-	var sourceBalance, destinationBalance *model.Balance
-
-	// Check if Source starts with "@"
-	if strings.HasPrefix(transaction.Source, "@") {
-		sourceBalance, err = l.getOrCreateBalanceByIndicator(transaction.Source, transaction.Currency)
-		if err != nil {
-			logrus.Errorf("source error %v", err)
-			return nil, nil, err
-		}
-		// Update transaction source with the balance ID
-		transaction.Source = sourceBalance.BalanceID
+// resolveBalance returns the balance an identifier points at, creating indicator balances ("@name") on first use.
+func (l *Blnk) resolveBalance(identifier, currency string) (*model.Balance, error) {
+	var balance *model.Balance
+	var err error
+	if strings.HasPrefix(identifier, "@") {
+		balance, err = l.getOrCreateBalanceByIndicator(identifier, currency)
 	} else {
-		sourceBalance, err = l.datasource.GetBalanceByIDLite(transaction.Source)
-		if err != nil {
-			logrus.Errorf("source error %v", err)
-			return nil, nil, err
+		balance, err = l.datasource.GetBalanceByIDLite(identifier)
+		if errors.Is(err, model.ErrNotFound) {
+			return nil, &RejectionError{Code: RejectionBalanceNotFound, Err: err}
 		}
 	}
+	if err != nil {
+		return nil, err
+	}
+	l.noteHot(balance)
+	return balance, nil
+}
 
-	// Check if Destination starts with "@"
-	if strings.HasPrefix(transaction.Destination, "@") {
-		destinationBalance, err = l.getOrCreateBalanceByIndicator(transaction.Destination, transaction.Currency)
-		if err != nil {
-			logrus.Errorf("destination error %v", err)
-			return nil, nil, err
-		}
-		// Update transaction destination with the balance ID
-		transaction.Destination = destinationBalance.BalanceID
-	} else {
-		destinationBalance, err = l.datasource.GetBalanceByIDLite(transaction.Destination)
-		if err != nil {
-			logrus.Errorf("destination error %v", err)
-			return nil, nil, err
-		}
+func (l *Blnk) getSourceAndDestination(transaction *model.Transaction) (source *model.Balance, destination *model.Balance, err error) {
+	sourceBalance, err := l.resolveBalance(transaction.Source, transaction.Currency)
+	if err != nil {
+		logrus.Errorf("source error %v", err)
+		return nil, nil, err
+	}
+	// Update transaction source with the balance ID
+	transaction.Source = sourceBalance.BalanceID
+
+	destinationBalance, err := l.resolveBalance(transaction.Destination, transaction.Currency)
+	if err != nil {
+		logrus.Errorf("destination error %v", err)
+		return nil, nil, err
+	}
+	// Update transaction destination with the balance ID
+	transaction.Destination = destinationBalance.BalanceID
+
+	if err := l.checkBalanceCurrencies(transaction, sourceBalance, destinationBalance); err != nil {
+		return nil, nil, err
 	}
+
 	return sourceBalance, destinationBalance, nil
 }
 
-func (l *Blnk) acquireLock(ctx context.Context, transaction *model.Transaction) (*redlock.Locker, error) {
-	locker := redlock.NewLocker(l.redis, transaction.Source, model.GenerateUUIDWithSuffix("loc"))
-	err := locker.Lock(ctx, time.Minute*30)
+// checkBalanceCurrencies makes sure both balances hold the transaction currency, with the precision the currency
+// registry gives it. The destination may only differ when the transaction carries an exchange rate, in which case
+// its precision is checked against the registry entry for its own currency. The transaction's precision must
+// already be resolved from the registry.
+func (l *Blnk) checkBalanceCurrencies(transaction *model.Transaction, sourceBalance, destinationBalance *model.Balance) error {
+	if sourceBalance.Currency != transaction.Currency {
+		return &RejectionError{Code: RejectionCurrencyMismatch, Err: fmt.Errorf("source balance %s is in %s but the transaction is in %s", sourceBalance.BalanceID, sourceBalance.Currency, transaction.Currency)}
+	}
+	if err := checkBalancePrecision(sourceBalance, transaction.Precision); err != nil {
+		return err
+	}
+
+	if destinationBalance.Currency == transaction.Currency {
+		return checkBalancePrecision(destinationBalance, transaction.Precision)
+	}
+	if !transaction.ConvertsCurrency() {
+		return &RejectionError{Code: RejectionCurrencyMismatch, Err: fmt.Errorf("destination balance %s is in %s but the transaction is in %s", destinationBalance.BalanceID, destinationBalance.Currency, transaction.Currency)}
+	}
+	precision, err := l.resolvePrecision(destinationBalance.Currency, 0)
+	if err != nil {
+		return err
+	}
+	return checkBalancePrecision(destinationBalance, precision)
+}
+
+// checkBalancePrecision rejects postings to a balance whose precision differs from precision, the one registered
+// for its currency, since the same minor-unit amount would mean different sums on each side.
+func checkBalancePrecision(balance *model.Balance, precision int64) error {
+	if int64(max(balance.CurrencyMultiplier, 1)) != max(precision, 1) {
+		return &RejectionError{Code: RejectionPrecisionMismatch, Err: fmt.Errorf("balance %s has precision %v but %s is registered with precision %d: %w",
+			balance.BalanceID, balance.CurrencyMultiplier, balance.Currency, precision, model.ErrPrecisionMismatch)}
+	}
+	return nil
+}
+
+const (
+	// baseLockTTL and perBalanceLockTTL size how long balance locks last if their holder stops renewing them,
+	// for example because the process died. Operations touching more balances get longer.
+	baseLockTTL       = 10 * time.Second
+	perBalanceLockTTL = 2 * time.Second
+)
+
+func lockWaitTimeout() time.Duration {
+	timeout := int64(config.DEFAULT_LOCK_WAIT_TIMEOUT)
+	if cnf, err := config.Fetch(); err == nil && cnf.Transaction.LockWaitTimeoutSeconds > 0 {
+		timeout = cnf.Transaction.LockWaitTimeoutSeconds
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// balanceLockKey is the key a balance is locked under. Indicators are resolved to their balance ID so the
+// balance is locked under the same key however a transaction refers to it.
+func (l *Blnk) balanceLockKey(identifier, currency string) (string, error) {
+	if !strings.HasPrefix(identifier, "@") {
+		return identifier, nil
+	}
+	balance, err := l.getOrCreateBalanceByIndicator(identifier, currency)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	l.noteHot(balance)
+	return balance.BalanceID, nil
+}
+
+// lockTransactions locks the source and destination balances of every transaction together. Hot balances take
+// their changes as deltas and are left unlocked, unless one is debited without an overdraft and its funds must
+// be checked.
+func (l *Blnk) lockTransactions(ctx context.Context, transactions ...*model.Transaction) (context.Context, redlock.Lock, error) {
+	keys := make([]string, 0, 2*len(transactions))
+	for _, transaction := range transactions {
+		for i, identifier := range []string{transaction.Source, transaction.Destination} {
+			key, err := l.balanceLockKey(identifier, transaction.Currency)
+			if err != nil {
+				return ctx, nil, err
+			}
+			if l.isHot(key) && (i == 1 || transaction.AllowOverdraft) {
+				continue
+			}
+			keys = append(keys, key)
+		}
 	}
-	return locker, nil
+	return l.lockBalances(ctx, keys)
+}
+
+// lockBalances locks every key with the configured provider, in an order that keeps transactions touching the
+// same balances from deadlocking, waiting for balances that are busy. Lock failures are retryable. The returned
+// context carries the lock to the database transactions that write the balances, which take it themselves when
+// the provider leaves it to them.
+func (l *Blnk) lockBalances(ctx context.Context, keys []string) (context.Context, redlock.Lock, error) {
+	ttl := baseLockTTL + time.Duration(len(keys))*perBalanceLockTTL
+	locker, err := l.locks.Lock(ctx, keys, ttl, lockWaitTimeout())
+	if err != nil {
+		return ctx, nil, &RetryableError{Err: err}
+	}
+	return redlock.WithLock(ctx, locker), locker, nil
 }
 
 func (l *Blnk) updateTransactionDetails(transaction *model.Transaction, sourceBalance, destinationBalance *model.Balance) *model.Transaction {
@@ -129,15 +226,16 @@ func (l *Blnk) postTransactionActions(_ context.Context, transaction *model.Tran
 	}()
 }
 
-func (l *Blnk) updateBalances(ctx context.Context, sourceBalance, destinationBalance *model.Balance) error {
+func (l *Blnk) updateBalances(ctx context.Context, transaction *model.Transaction, sourceBalance, destinationBalance *model.Balance) error {
 	var wg sync.WaitGroup
-	if err := l.datasource.UpdateBalances(ctx, sourceBalance, destinationBalance); err != nil {
+	if err := l.datasource.UpdateBalances(ctx, transaction, sourceBalance, destinationBalance); err != nil {
 		return err
 	}
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
 		l.checkBalanceMonitors(sourceBalance)
+		l.notifyOverdrawn(sourceBalance)
 	}()
 	go func() {
 		defer wg.Done()
@@ -157,7 +255,7 @@ func (l *Blnk) validateTxn(cxt context.Context, transaction *model.Transaction)
 	}
 
 	if txn {
-		return fmt.Errorf("reference %s has already been used", transaction.Reference)
+		return &RejectionError{Code: RejectionDuplicateReference, Err: fmt.Errorf("reference %s has already been used", transaction.Reference)}
 	}
 
 	return nil
@@ -175,6 +273,14 @@ func (l *Blnk) applyTransactionToBalances(span trace.Span, balances []*model.Bal
 }
 
 func (l *Blnk) RecordTransaction(ctx context.Context, transaction *model.Transaction) (*model.Transaction, error) {
+	if transaction.QuoteID != "" {
+		return l.RecordQuotedTransaction(ctx, transaction)
+	}
+
+	if (transaction.Atomic || transaction.IsManyToMany()) && transaction.HasDistributions() {
+		return l.RecordAtomicTransaction(ctx, transaction)
+	}
+
 	ctx, span := tracer.Start(ctx, "Recording transaction")
 	defer span.End()
 
@@ -192,15 +298,125 @@ func (l *Blnk) RecordTransaction(ctx context.Context, transaction *model.Transac
 		if err != nil {
 			return nil, err
 		}
-
 		l.postTransactionActions(ctx, transaction)
 
 		return transaction, nil
 	})
 }
 
+// RecordAtomicTransaction splits a distributed transaction into its legs and posts them all in one database
+// transaction. If any leg fails, for example with insufficient funds, no balance is changed.
+func (l *Blnk) RecordAtomicTransaction(ctx context.Context, transaction *model.Transaction) (*model.Transaction, error) {
+	ctx, span := tracer.Start(ctx, "Recording atomic transaction")
+	defer span.End()
+
+	if transaction.GroupID == "" {
+		transaction.GroupID = model.GenerateUUIDWithSuffix("grp")
+	}
+
+	if err := l.applyCurrencyPrecision(transaction); err != nil {
+		return nil, l.logAndRecordError(span, "currency validation failed", err)
+	}
+
+	legs, err := transaction.SplitTransaction()
+	if err != nil {
+		return nil, l.logAndRecordError(span, "failed to split transaction", err)
+	}
+	// The transaction's reservation covers every leg, so each leg may spend its own share of it.
+	if transaction.ReservedAmount > 0 {
+		for _, leg := range legs {
+			leg.ReservedAmount = leg.PreciseAmount
+		}
+	}
+
+	ctx, locker, err := l.lockTransactions(ctx, legs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock: %w", err)
+	}
+	defer l.releaseLock(ctx, locker)
+
+	balances, err := l.applyLegs(ctx, span, legs)
+	if err != nil {
+		return nil, err
+	}
+
+	// The transaction itself is kept with its legs, so it can be fetched by the ID its caller was given.
+	if transaction.TransactionID == "" {
+		transaction.TransactionID = model.GenerateUUIDWithSuffix("txn")
+	}
+	if transaction.CreatedAt.IsZero() {
+		transaction.CreatedAt = time.Now()
+	}
+	transaction.Status = StatusApplied
+	if err := l.datasource.RecordTransactionGroup(ctx, transaction, balances, legs); err != nil {
+		return nil, l.logAndRecordError(span, "failed to persist transaction group", err)
+	}
+
+	for _, balance := range balances {
+		go l.checkBalanceMonitors(balance)
+	}
+	for _, leg := range legs {
+		l.postTransactionActions(ctx, leg)
+	}
+
+	return transaction, nil
+}
+
+// applyLegs validates and applies each leg in memory. Balances shared by several legs are loaded once so
+// every leg sees the effect of the ones before it.
+func (l *Blnk) applyLegs(ctx context.Context, span trace.Span, legs []*model.Transaction) ([]*model.Balance, error) {
+	return l.applyLegsWith(ctx, span, legs, l.resolveBalance)
+}
+
+// applyLegsWith is applyLegs with the balance lookup supplied by the caller.
+func (l *Blnk) applyLegsWith(ctx context.Context, span trace.Span, legs []*model.Transaction, resolveBalance func(identifier, currency string) (*model.Balance, error)) ([]*model.Balance, error) {
+	loaded := make(map[string]*model.Balance)
+	used := make(map[string]model.LimitUsage)
+	resolve := func(identifier, currency string) (*model.Balance, error) {
+		if balance, ok := loaded[identifier]; ok {
+			return balance, nil
+		}
+		balance, err := resolveBalance(identifier, currency)
+		if err != nil {
+			return nil, err
+		}
+		loaded[identifier] = balance
+		loaded[balance.BalanceID] = balance
+		return balance, nil
+	}
+
+	for _, leg := range legs {
+		if err := l.validateTxn(ctx, leg); err != nil {
+			return nil, l.logAndRecordError(span, "transaction validation failed", err)
+		}
+
+		sourceBalance, err := resolve(leg.Source, leg.Currency)
+		if err != nil {
+			return nil, l.logAndRecordError(span, "failed to get source balance", err)
+		}
+		destinationBalance, err := resolve(leg.Destination, leg.Currency)
+		if err != nil {
+			return nil, l.logAndRecordError(span, "failed to get destination balance", err)
+		}
+
+		if err := l.checkBalanceCurrencies(leg, sourceBalance, destinationBalance); err != nil {
+			return nil, l.logAndRecordError(span, fmt.Sprintf("leg %s failed", leg.Reference), err)
+		}
+		if err := l.checkLimits(ctx, leg, sourceBalance, destinationBalance, used, len(legs) == 1); err != nil {
+			return nil, l.logAndRecordError(span, fmt.Sprintf("leg %s failed", leg.Reference), err)
+		}
+
+		if err := model.UpdateBalances(leg, sourceBalance, destinationBalance); err != nil {
+			return nil, l.logAndRecordError(span, fmt.Sprintf("leg %s failed", leg.Reference), err)
+		}
+		l.updateTransactionDetails(leg, sourceBalance, destinationBalance)
+	}
+
+	return uniqueBalances(loaded), nil
+}
+
 func (l *Blnk) executeWithLock(ctx context.Context, transaction *model.Transaction, fn func(context.Context) (*model.Transaction, error)) (*model.Transaction, error) {
-	locker, err := l.acquireLock(ctx, transaction)
+	ctx, locker, err := l.lockTransactions(ctx, transaction)
 	if err != nil {
 		return nil, fmt.Errorf("failed to acquire lock: %w", err)
 	}
@@ -214,11 +430,19 @@ func (l *Blnk) validateAndPrepareTransaction(ctx context.Context, span trace.Spa
 		return nil, nil, l.logAndRecordError(span, "transaction validation failed", err)
 	}
 
+	if err := l.applyCurrencyPrecision(transaction); err != nil {
+		return nil, nil, l.logAndRecordError(span, "currency validation failed", err)
+	}
+
 	sourceBalance, destinationBalance, err := l.getSourceAndDestination(transaction)
 	if err != nil {
 		return nil, nil, l.logAndRecordError(span, "failed to get source and destination balances", err)
 	}
 
+	if err := l.checkLimits(ctx, transaction, sourceBalance, destinationBalance, nil, true); err != nil {
+		return nil, nil, l.logAndRecordError(span, "limit check failed", err)
+	}
+
 	transaction.Source = sourceBalance.BalanceID
 	transaction.Destination = destinationBalance.BalanceID
 
@@ -230,7 +454,11 @@ func (l *Blnk) processBalances(ctx context.Context, span trace.Span, transaction
 		return l.logAndRecordError(span, "failed to apply transaction to balances", err)
 	}
 
-	if err := l.updateBalances(ctx, sourceBalance, destinationBalance); err != nil {
+	if transaction.TransactionID == "" {
+		transaction.TransactionID = model.GenerateUUIDWithSuffix("txn")
+	}
+
+	if err := l.updateBalances(ctx, transaction, sourceBalance, destinationBalance); err != nil {
 		return l.logAndRecordError(span, "failed to update balances", err)
 	}
 
@@ -248,7 +476,7 @@ func (l *Blnk) finalizeTransaction(ctx context.Context, span trace.Span, transac
 	return transaction, nil
 }
 
-func (l *Blnk) releaseLock(ctx context.Context, locker *redlock.Locker) {
+func (l *Blnk) releaseLock(ctx context.Context, locker redlock.Lock) {
 	if err := locker.Unlock(ctx); err != nil {
 		logrus.Error("failed to release lock", err)
 	}
@@ -260,29 +488,49 @@ func (l *Blnk) logAndRecordError(span trace.Span, msg string, err error) error {
 	return fmt.Errorf("%s: %w", msg, err)
 }
 
-func (l *Blnk) RejectTransaction(ctx context.Context, transaction *model.Transaction, reason string) (*model.Transaction, error) {
+// TransactionRecorded reports whether a transaction that failed with a duplicate reference was posted by an
+// earlier attempt whose task was not acknowledged: its reference belongs to it or, for a distributed transaction
+// posted atomically, whose legs carry their own references, it is kept applied with its group.
+func (l *Blnk) TransactionRecorded(ctx context.Context, transaction *model.Transaction) bool {
+	existing, err := l.datasource.GetTransactionByRef(ctx, transaction.Reference)
+	if err == nil {
+		return existing.TransactionID == transaction.TransactionID
+	}
+	if transaction.GroupID == "" {
+		return false
+	}
+	parent, err := l.datasource.GetTransaction(transaction.TransactionID)
+	return err == nil && parent.GroupID == transaction.GroupID && parent.Status == StatusApplied
+}
+
+// RejectTransaction records a transaction that will not be applied as REJECTED, with the rejection's reason and
+// code in its metadata, gives back any funds reserved for it and sends a transaction.rejected webhook.
+func (l *Blnk) RejectTransaction(ctx context.Context, transaction *model.Transaction, rejection *RejectionError) (*model.Transaction, error) {
 	transaction.Status = StatusRejected
 	if transaction.MetaData == nil {
 		transaction.MetaData = make(map[string]interface{})
 	}
-	transaction.MetaData["blnk_rejection_reason"] = reason
+	transaction.MetaData["blnk_rejection_reason"] = rejection.Error()
+	transaction.MetaData["blnk_rejection_code"] = rejection.Code
 
 	transaction, err := l.datasource.RecordTransaction(ctx, transaction)
 	if err != nil {
 		logrus.Errorf("ERROR saving transaction to db. %s", err)
+		return nil, err
 	}
 
-	err = SendWebhook(NewWebhook{
-		Event:   "transaction.applied",
-		Payload: transaction,
-	})
-	if err != nil {
+	if err := l.releaseReservation(ctx, transaction); err != nil {
+		logrus.Errorf("failed to release reservation for transaction %s: %v", transaction.TransactionID, err)
 		notification.NotifyError(err)
 	}
 
+	l.postTransactionActions(ctx, transaction)
+
 	return transaction, nil
 }
-func (l *Blnk) CommitInflightTransaction(ctx context.Context, transactionID string, amount float64) (*model.Transaction, error) {
+
+// CommitInflightTransaction applies an inflight transaction. An empty or zero amount commits whatever is left uncommitted.
+func (l *Blnk) CommitInflightTransaction(ctx context.Context, transactionID string, amount json.Number) (*model.Transaction, error) {
 	ctx, span := tracer.Start(ctx, "Committing inflight transaction")
 	defer span.End()
 
@@ -300,6 +548,10 @@ func (l *Blnk) CommitInflightTransaction(ctx context.Context, transactionID stri
 		if err := l.validateAndUpdateAmount(ctx, span, transaction, amount); err != nil {
 			return nil, err
 		}
+		if err := l.checkCommitTierCap(ctx, transaction, destinationBalance); err != nil {
+			return nil, l.logAndRecordError(span, "kyc tier cap error", err)
+		}
+		markAsChildTransaction(transaction)
 
 		if err := l.commitBalances(ctx, span, transaction, sourceBalance, destinationBalance); err != nil {
 			return nil, err
@@ -309,6 +561,14 @@ func (l *Blnk) CommitInflightTransaction(ctx context.Context, transactionID stri
 	})
 }
 
+// markAsChildTransaction turns a loaded inflight transaction into the commit or void record that follows it, so
+// the balance snapshots written next point at the new record.
+func markAsChildTransaction(transaction *model.Transaction) {
+	transaction.ParentTransaction = transaction.TransactionID
+	transaction.TransactionID = model.GenerateUUIDWithSuffix("txn")
+	transaction.Reference = model.GenerateUUIDWithSuffix("ref")
+}
+
 func (l *Blnk) fetchAndValidateTransaction(_ context.Context, span trace.Span, transactionID string) (*model.Transaction, error) {
 	transaction, err := l.datasource.GetTransaction(transactionID)
 	if err != nil {
@@ -322,7 +582,7 @@ func (l *Blnk) fetchAndValidateTransaction(_ context.Context, span trace.Span, t
 	return transaction, nil
 }
 
-func (l *Blnk) validateAndUpdateAmount(_ context.Context, span trace.Span, transaction *model.Transaction, amount float64) error {
+func (l *Blnk) validateAndUpdateAmount(_ context.Context, span trace.Span, transaction *model.Transaction, amount json.Number) error {
 	committedAmount, err := l.datasource.GetTotalCommittedTransactions(transaction.TransactionID)
 	if err != nil {
 		return l.logAndRecordError(span, "error fetching committed amount", err)
@@ -331,29 +591,38 @@ func (l *Blnk) validateAndUpdateAmount(_ context.Context, span trace.Span, trans
 	originalAmount := transaction.PreciseAmount
 	amountLeft := originalAmount - committedAmount
 
-	if amount != 0 {
-		transaction.Amount = amount
-		transaction.PreciseAmount = 0
-	} else {
-		transaction.Amount = float64(amountLeft) / transaction.Precision
+	preciseAmount := amountLeft
+	if amount != "" {
+		requested, err := model.ToPrecise(amount, transaction.Precision)
+		if err != nil {
+			return l.logAndRecordError(span, "invalid commit amount", err)
+		}
+		if requested != 0 {
+			preciseAmount = requested
+		}
 	}
+	transaction.PreciseAmount = preciseAmount
+	transaction.Amount = model.FromPrecise(preciseAmount, transaction.Precision)
 
-	if amountLeft < model.ApplyPrecision(transaction) {
-		return fmt.Errorf("can not commit %s%.2f. You can only commit an amount between 1.00 - %s%.2f",
-			transaction.Currency, amount, transaction.Currency, float64(amountLeft)/transaction.Precision)
+	if amountLeft < preciseAmount {
+		return fmt.Errorf("can not commit %s%s. You can only commit an amount up to %s%s",
+			transaction.Currency, transaction.Amount, transaction.Currency, model.FromPrecise(amountLeft, transaction.Precision))
 	} else if amountLeft == 0 {
-		return fmt.Errorf("can not commit %s%.2f. Transaction already committed with amount of - %s%.2f",
-			transaction.Currency, amount, transaction.Currency, float64(committedAmount)/transaction.Precision)
+		return fmt.Errorf("can not commit %s%s. Transaction already committed with amount of - %s%s",
+			transaction.Currency, transaction.Amount, transaction.Currency, model.FromPrecise(committedAmount, transaction.Precision))
 	}
 
 	return nil
 }
 
 func (l *Blnk) commitBalances(ctx context.Context, span trace.Span, transaction *model.Transaction, sourceBalance, destinationBalance *model.Balance) error {
+	if err := errors.Join(sourceBalance.CheckDebit(), destinationBalance.CheckCredit()); err != nil {
+		return l.logAndRecordError(span, "balance status error", err)
+	}
 	sourceBalance.CommitInflightDebit(transaction)
 	destinationBalance.CommitInflightCredit(transaction)
 
-	if err := l.updateBalances(ctx, sourceBalance, destinationBalance); err != nil {
+	if err := l.updateBalances(ctx, transaction, sourceBalance, destinationBalance); err != nil {
 		return l.logAndRecordError(span, "update balances error", err)
 	}
 
@@ -362,9 +631,6 @@ func (l *Blnk) commitBalances(ctx context.Context, span trace.Span, transaction
 
 func (l *Blnk) finalizeCommitment(ctx context.Context, span trace.Span, transaction *model.Transaction) (*model.Transaction, error) {
 	transaction.Status = StatusApplied
-	transaction.ParentTransaction = transaction.TransactionID
-	transaction.TransactionID = model.GenerateUUIDWithSuffix("txn")
-	transaction.Reference = model.GenerateUUIDWithSuffix("ref")
 	transaction.Hash = transaction.HashTxn()
 
 	transaction, err := l.datasource.RecordTransaction(ctx, transaction)
@@ -394,6 +660,8 @@ func (l *Blnk) VoidInflightTransaction(ctx context.Context, transactionID string
 		if err != nil {
 			return nil, err
 		}
+		markAsChildTransaction(transaction)
+		transaction.ReleasedAmount = amountLeft
 
 		if err := l.rollbackBalances(ctx, span, transaction, sourceBalance, destinationBalance, amountLeft); err != nil {
 			return nil, err
@@ -434,11 +702,14 @@ func (l *Blnk) calculateRemainingAmount(_ context.Context, span trace.Span, tran
 	return transaction.PreciseAmount - committedAmount, nil
 }
 
-func (l *Blnk) rollbackBalances(ctx context.Context, span trace.Span, _ *model.Transaction, sourceBalance, destinationBalance *model.Balance, amountLeft int64) error {
+func (l *Blnk) rollbackBalances(ctx context.Context, span trace.Span, transaction *model.Transaction, sourceBalance, destinationBalance *model.Balance, amountLeft int64) error {
+	if err := errors.Join(sourceBalance.CheckRelease(), destinationBalance.CheckRelease()); err != nil {
+		return l.logAndRecordError(span, "balance status error", err)
+	}
 	sourceBalance.RollbackInflightDebit(amountLeft)
 	destinationBalance.RollbackInflightCredit(amountLeft)
 
-	if err := l.updateBalances(ctx, sourceBalance, destinationBalance); err != nil {
+	if err := l.updateBalances(ctx, transaction, sourceBalance, destinationBalance); err != nil {
 		return l.logAndRecordError(span, "update balances error", err)
 	}
 
@@ -447,11 +718,8 @@ func (l *Blnk) rollbackBalances(ctx context.Context, span trace.Span, _ *model.T
 
 func (l *Blnk) finalizeVoidTransaction(ctx context.Context, span trace.Span, transaction *model.Transaction, amountLeft int64) (*model.Transaction, error) {
 	transaction.Status = StatusVoid
-	transaction.Amount = float64(amountLeft) / transaction.Precision
+	transaction.Amount = model.FromPrecise(amountLeft, transaction.Precision)
 	transaction.PreciseAmount = amountLeft
-	transaction.ParentTransaction = transaction.TransactionID
-	transaction.TransactionID = model.GenerateUUIDWithSuffix("txn")
-	transaction.Reference = model.GenerateUUIDWithSuffix("ref")
 	transaction.Hash = transaction.HashTxn()
 
 	transaction, err := l.datasource.RecordTransaction(ctx, transaction)
@@ -470,21 +738,141 @@ func (l *Blnk) QueueTransaction(ctx context.Context, transaction *model.Transact
 		return nil, err
 	}
 
+	if err := l.applyCurrencyPrecision(transaction); err != nil {
+		return nil, err
+	}
+
+	if transaction.QuoteID != "" {
+		quote, err := l.getTransactionQuote(transaction)
+		if err != nil {
+			return nil, err
+		}
+		transaction.Rate = quote.Rate
+	}
+
 	setTransactionStatus(transaction)
-	setTransactionMetadata(transaction)
+	if err := setTransactionMetadata(transaction); err != nil {
+		return nil, err
+	}
+
+	if transaction.HasDistributions() {
+		transaction.GroupID = model.GenerateUUIDWithSuffix("grp")
+	}
+
+	// Many-to-many legs only make sense together, so they are always posted atomically.
+	if transaction.IsManyToMany() {
+		transaction.Atomic = true
+	}
 
 	transactions, err := transaction.SplitTransaction()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := enqueueTransactions(ctx, l.queue, transaction, transactions); err != nil {
+	if err := l.reserveFunds(ctx, transaction, transactions); err != nil {
+		return nil, err
+	}
+
+	// Atomic transactions travel as a single task so the worker can post every leg together.
+	if transaction.Atomic {
+		transactions = nil
+	}
+
+	if unqueued, err := enqueueTransactions(ctx, l.queue, transaction, transactions); err != nil {
+		// Nothing will apply the transactions that did not make it into the queue, so give back their funds.
+		for _, txn := range unqueued {
+			if err := l.releaseReservation(ctx, txn); err != nil {
+				logrus.Errorf("failed to release reservation for transaction %s: %v", txn.TransactionID, err)
+				notification.NotifyError(err)
+			}
+		}
 		return nil, err
 	}
 
 	return transaction, nil
 }
 
+// ErrSyncTimeout is returned when a synchronous transaction is still being recorded after the configured timeout.
+var ErrSyncTimeout = errors.New("transaction is still being processed. fetch it by id for its final status")
+
+func syncTimeout() time.Duration {
+	timeout := int64(config.DEFAULT_SYNC_TIMEOUT)
+	if cnf, err := config.Fetch(); err == nil && cnf.Transaction.SyncTimeoutSeconds > 0 {
+		timeout = cnf.Transaction.SyncTimeoutSeconds
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+// RecordTransactionSync records a transaction inline under the balance lock instead of queuing it, and returns
+// its final status with the balances it left behind. Distributed transactions are posted atomically so they
+// have a single outcome. A transaction that is rejected is recorded as REJECTED and returned with the rejection.
+// If recording outlasts the sync timeout, ErrSyncTimeout is returned along with the transaction so the caller
+// can look it up later.
+func (l *Blnk) RecordTransactionSync(ctx context.Context, transaction *model.Transaction) (*model.TransactionResult, error) {
+	if !transaction.ScheduledFor.IsZero() {
+		return nil, errors.New("scheduled transactions cannot be recorded synchronously")
+	}
+
+	setTransactionStatus(transaction)
+	transaction.TransactionID = model.GenerateUUIDWithSuffix("txn")
+	transaction.CreatedAt = time.Now()
+	if transaction.HasDistributions() {
+		transaction.Atomic = true
+	}
+
+	type outcome struct {
+		transaction *model.Transaction
+		err         error
+	}
+	// pending is what the caller gets back on a timeout, copied so it is not shared with the recording goroutine.
+	pending := *transaction
+	pending.Sources = append([]model.Distribution(nil), transaction.Sources...)
+	pending.Destinations = append([]model.Distribution(nil), transaction.Destinations...)
+	pending.MetaData = make(map[string]interface{}, len(transaction.MetaData))
+	for key, value := range transaction.MetaData {
+		pending.MetaData[key] = value
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		// Recording is not tied to the request, so a client that gives up does not leave it half done.
+		ctx := context.WithoutCancel(ctx)
+		recorded, err := l.RecordTransaction(ctx, transaction)
+		var rejection *RejectionError
+		if err != nil && errors.As(ClassifyTransactionError(err), &rejection) {
+			// A transaction that can never apply is rejected as the worker would reject it, so it is kept with its
+			// reason and announced, even when the caller stopped waiting for it.
+			rejected, rejectErr := l.RejectTransaction(ctx, transaction, rejection)
+			if rejectErr != nil {
+				logrus.Errorf("failed to reject transaction %s: %v", transaction.TransactionID, rejectErr)
+			}
+			recorded = rejected
+		}
+		done <- outcome{recorded, err}
+	}()
+
+	select {
+	case <-time.After(syncTimeout()):
+		return &model.TransactionResult{Transaction: &pending}, ErrSyncTimeout
+	case result := <-done:
+		if result.err != nil {
+			if result.transaction != nil {
+				return &model.TransactionResult{Transaction: result.transaction}, result.err
+			}
+			return nil, result.err
+		}
+
+		snapshotKey := result.transaction.TransactionID
+		if result.transaction.GroupID != "" {
+			snapshotKey = result.transaction.GroupID
+		}
+		balances, err := l.datasource.GetBalanceSnapshotsByTransaction(snapshotKey)
+		if err != nil {
+			return nil, err
+		}
+		return &model.TransactionResult{Transaction: result.transaction, Balances: balances}, nil
+	}
+}
+
 func setTransactionStatus(transaction *model.Transaction) {
 	if !transaction.ScheduledFor.IsZero() {
 		transaction.Status = StatusScheduled
@@ -495,37 +883,50 @@ func setTransactionStatus(transaction *model.Transaction) {
 	}
 }
 
-func setTransactionMetadata(transaction *model.Transaction) {
+func setTransactionMetadata(transaction *model.Transaction) error {
+	preciseAmount, err := model.ApplyPrecision(transaction)
+	if err != nil {
+		return err
+	}
+	transaction.PreciseAmount = preciseAmount
 	transaction.SkipBalanceUpdate = true
 	transaction.CreatedAt = time.Now()
 	transaction.TransactionID = model.GenerateUUIDWithSuffix("txn")
 	transaction.Hash = transaction.HashTxn()
-	transaction.PreciseAmount = int64(transaction.Amount * transaction.Precision)
+	return nil
 }
 
-func enqueueTransactions(ctx context.Context, queue *Queue, originalTransaction *model.Transaction, splitTransactions []*model.Transaction) error {
+// enqueueTransactions queues the split transactions, or the original one when it was not split. When queuing
+// fails it returns the transactions that were not queued along with the error.
+func enqueueTransactions(ctx context.Context, queue *Queue, originalTransaction *model.Transaction, splitTransactions []*model.Transaction) ([]*model.Transaction, error) {
 	transactionsToEnqueue := splitTransactions
 	if len(transactionsToEnqueue) == 0 {
 		transactionsToEnqueue = []*model.Transaction{originalTransaction}
 	}
 
-	for _, txn := range transactionsToEnqueue {
+	for i, txn := range transactionsToEnqueue {
 		if err := queue.Enqueue(ctx, txn); err != nil {
 			notification.NotifyError(err)
 			logrus.Errorf("Error queuing transaction: %v", err)
-			return err
+			return transactionsToEnqueue[i:], err
 		}
 	}
 
-	return nil
+	return nil, nil
 }
 
 func (l *Blnk) GetTransaction(TransactionID string) (*model.Transaction, error) {
 	return l.datasource.GetTransaction(TransactionID)
 }
 
-func (l *Blnk) GetAllTransactions() ([]model.Transaction, error) {
-	return l.datasource.GetAllTransactions()
+// GetAllTransactions returns one page of transactions matching the filter, newest first.
+func (l *Blnk) GetAllTransactions(filter model.TransactionFilter, limit int) (model.Page[model.Transaction], error) {
+	limit = model.PageLimit(limit)
+	transactions, err := l.datasource.GetAllTransactions(filter, limit+1)
+	if err != nil {
+		return model.Page[model.Transaction]{}, err
+	}
+	return model.NewPage(transactions, limit, func(transaction model.Transaction) int64 { return transaction.ID }), nil
 }
 
 func (l *Blnk) GetTransactionByRef(cxt context.Context, reference string) (model.Transaction, error) {
@@ -2,6 +2,8 @@ package blnk
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
 	"regexp"
 	"testing"
@@ -30,89 +32,13 @@ func TestRecordTransaction(t *testing.T) {
 		Reference:      gofakeit.UUID(),
 		Source:         source,
 		Destination:    destination,
-		Amount:         10,
+		Amount:         "10",
 		AllowOverdraft: false,
 		Precision:      100,
 		Currency:       "NGN",
 	}
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-        SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)
-    `)).WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
-
-	sourceBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version"}).
-		AddRow(source, "NGN", 1, "ledger-id-source", 10000, 10000, 0, 0, 0, 0, time.Now(), 0)
-
-	destinationBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version"}).
-		AddRow(destination, "NGN", 1, "ledger-id-destination", 0, 0, 0, 0, 0, 0, time.Now(), 0)
-
-	balanceQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version FROM blnk.balances WHERE balance_id = $1`)
-	balanceQuery2 := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version FROM blnk.balances WHERE balance_id = $1`)
-
-	mock.ExpectQuery(balanceQuery).WithArgs(source).WillReturnRows(sourceBalanceRows)
-	mock.ExpectQuery(balanceQuery2).WithArgs(destination).WillReturnRows(destinationBalanceRows)
-	mock.ExpectBegin()
-
-	mock.ExpectExec(regexp.QuoteMeta(`
-	  UPDATE blnk.balances
-	  SET balance = $2, credit_balance = $3, debit_balance = $4, inflight_balance = $5, inflight_credit_balance = $6, inflight_debit_balance = $7, currency = $8, currency_multiplier = $9, ledger_id = $10, created_at = $11, meta_data = $12, version = version + 1
-	  WHERE balance_id = $1 AND version = $13
-	`)).WithArgs(
-		source,
-		9000,
-		10000,
-		1000,
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		0,
-	).WillReturnResult(sqlmock.NewResult(1, 1))
-
-	mock.ExpectExec(regexp.QuoteMeta(`
-	  UPDATE blnk.balances
-	  SET balance = $2, credit_balance = $3, debit_balance = $4, inflight_balance = $5, inflight_credit_balance = $6, inflight_debit_balance = $7, currency = $8, currency_multiplier = $9, ledger_id = $10, created_at = $11, meta_data = $12, version = version + 1
-	  WHERE balance_id = $1 AND version = $13
-	`)).WithArgs(
-		destination,
-		1000,
-		1000,
-		0,
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		0,
-	).WillReturnResult(sqlmock.NewResult(1, 1))
-
-	mock.ExpectCommit()
-	expectedSQL := `INSERT INTO blnk.transactions(transaction_id,parent_transaction,source,reference,amount,precise_amount,precision,rate,currency,destination,description,status,created_at,meta_data,scheduled_for,hash) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)`
-	mock.ExpectExec(regexp.QuoteMeta(expectedSQL)).WithArgs(
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		source,
-		txn.Reference,
-		txn.Amount,
-		1000,
-		txn.Precision,
-		float64(1),
-		txn.Currency,
-		txn.Destination,
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-		sqlmock.AnyArg(),
-	).WillReturnResult(sqlmock.NewResult(1, 1))
+	expectTransfer(mock, txn, source, destination)
 
 	_, err = d.RecordTransaction(context.Background(), txn)
 	assert.NoError(t, err)
@@ -135,28 +61,31 @@ func TestRecordTransactionWithRate(t *testing.T) {
 		Reference:      gofakeit.UUID(),
 		Source:         source,
 		Destination:    destination,
-		Amount:         1000000,
-		Rate:           1300,
+		Amount:         "1000000",
+		Rate:           "1300",
 		AllowOverdraft: true,
 		Precision:      100,
-		Currency:       "NGN",
+		Currency:       "USD",
 	}
 
 	mock.ExpectQuery(regexp.QuoteMeta(`
         SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)
     `)).WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "USD", 2)
 
-	sourceBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version"}).
-		AddRow(source, "USD", 1, "ledger-id-source", 0, 0, 0, 0, 0, 0, time.Now(), 0)
+	sourceBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+		AddRow(source, "USD", 100, "ledger-id-source", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", "")
 
-	destinationBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version"}).
-		AddRow(destination, "NGN", 1, "ledger-id-destination", 0, 0, 0, 0, 0, 0, time.Now(), 0)
+	destinationBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+		AddRow(destination, "NGN", 100, "ledger-id-destination", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", "")
 
-	balanceQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version FROM blnk.balances WHERE balance_id = $1`)
-	balanceQuery2 := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version FROM blnk.balances WHERE balance_id = $1`)
+	balanceQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
+	balanceQuery2 := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
 
 	mock.ExpectQuery(balanceQuery).WithArgs(source).WillReturnRows(sourceBalanceRows)
 	mock.ExpectQuery(balanceQuery2).WithArgs(destination).WillReturnRows(destinationBalanceRows)
+	expectCurrencyLookup(mock, "NGN", 2)
+	expectNoSpendingLimits(mock)
 	mock.ExpectBegin()
 
 	mock.ExpectExec(regexp.QuoteMeta(`
@@ -177,6 +106,7 @@ func TestRecordTransactionWithRate(t *testing.T) {
 		sqlmock.AnyArg(),
 		sqlmock.AnyArg(),
 		0,
+		sqlmock.AnyArg(),
 	).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.ExpectExec(regexp.QuoteMeta(`
@@ -197,10 +127,16 @@ func TestRecordTransactionWithRate(t *testing.T) {
 		sqlmock.AnyArg(),
 		sqlmock.AnyArg(),
 		0,
+		sqlmock.AnyArg(),
 	).WillReturnResult(sqlmock.NewResult(1, 1))
 
+	// Funds reserved for the transaction are converted with the balance update.
+	if txn.ReservedAmount > 0 {
+		mock.ExpectExec(settleQuery).WithArgs(txn.TransactionID, model.ReservationConverted, model.ReservationHeld).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
 	mock.ExpectCommit()
-	expectedSQL := `INSERT INTO blnk.transactions(transaction_id,parent_transaction,source,reference,amount,precise_amount,precision,rate,currency,destination,description,status,created_at,meta_data,scheduled_for,hash) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)`
+	expectedSQL := `INSERT INTO blnk.transactions(transaction_id,parent_transaction,source,reference,amount,precise_amount,precision,rate,currency,destination,description,status,created_at,meta_data,scheduled_for,hash,group_id,quote_id,source_amount,destination_amount) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20)`
 	mock.ExpectExec(regexp.QuoteMeta(expectedSQL)).WithArgs(
 		sqlmock.AnyArg(),
 		sqlmock.AnyArg(),
@@ -209,7 +145,7 @@ func TestRecordTransactionWithRate(t *testing.T) {
 		txn.Amount,
 		100000000,
 		txn.Precision,
-		float64(1300),
+		json.Number("1300"),
 		txn.Currency,
 		txn.Destination,
 		sqlmock.AnyArg(),
@@ -218,6 +154,10 @@ func TestRecordTransactionWithRate(t *testing.T) {
 		sqlmock.AnyArg(),
 		sqlmock.AnyArg(),
 		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		"",
+		json.Number("1000000"),
+		json.Number("1300000000"),
 	).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	_, err = d.RecordTransaction(context.Background(), txn)
@@ -240,24 +180,24 @@ func TestVoidInflightTransaction(t *testing.T) {
 	metaDataJSON, _ := json.Marshal(map[string]interface{}{"key": "value"})
 
 	// Mock GetTransaction
-	mock.ExpectQuery(regexp.QuoteMeta(`SELECT transaction_id, source, reference, amount, precise_amount, precision, currency,destination, description, status,created_at, meta_data FROM blnk.transactions WHERE transaction_id = $1`)).
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT transaction_id, source, reference, amount, precise_amount, precision, currency,destination, description, status,created_at, meta_data, COALESCE(group_id, ''), COALESCE(rate::TEXT, '1'), COALESCE(quote_id, ''), COALESCE(source_amount::TEXT, ''), COALESCE(destination_amount::TEXT, '') FROM blnk.transactions WHERE transaction_id = $1`)).
 		WithArgs(transactionID).
-		WillReturnRows(sqlmock.NewRows([]string{"transaction_id", "source", "reference", "amount", "precise_amount", "precision", "currency", "destination", "description", "status", "created_at", "meta_data"}).
-			AddRow(transactionID, source, gofakeit.UUID(), 100.0, 10000, 100, "USD", destination, gofakeit.UUID(), "INFLIGHT", time.Now(), metaDataJSON))
+		WillReturnRows(sqlmock.NewRows([]string{"transaction_id", "source", "reference", "amount", "precise_amount", "precision", "currency", "destination", "description", "status", "created_at", "meta_data", "group_id", "rate", "quote_id", "source_amount", "destination_amount"}).
+			AddRow(transactionID, source, gofakeit.UUID(), "100", 10000, 100, "USD", destination, gofakeit.UUID(), "INFLIGHT", time.Now(), metaDataJSON, "", "1", "", "", ""))
 
 	// Mock IsParentTransactionVoid
 	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS ( SELECT 1 FROM blnk.transactions WHERE parent_transaction = $1 AND status = 'VOID' )`)).
 		WithArgs(transactionID).
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
-	sourceBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version"}).
-		AddRow(source, "USD", 1, "ledger-id-source", 0, 0, 0, 0, 0, 0, time.Now(), 0)
+	sourceBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+		AddRow(source, "USD", 100, "ledger-id-source", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", "")
 
-	destinationBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version"}).
-		AddRow(destination, "NGN", 1, "ledger-id-destination", 0, 0, 0, 0, 0, 0, time.Now(), 0)
+	destinationBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+		AddRow(destination, "USD", 100, "ledger-id-destination", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", "")
 
-	balanceQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version FROM blnk.balances WHERE balance_id = $1`)
-	balanceQuery2 := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version FROM blnk.balances WHERE balance_id = $1`)
+	balanceQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
+	balanceQuery2 := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
 
 	mock.ExpectQuery(balanceQuery).WithArgs(source).WillReturnRows(sourceBalanceRows)
 	mock.ExpectQuery(balanceQuery2).WithArgs(destination).WillReturnRows(destinationBalanceRows)
@@ -288,6 +228,7 @@ func TestVoidInflightTransaction(t *testing.T) {
 		sqlmock.AnyArg(),
 		sqlmock.AnyArg(),
 		0,
+		sqlmock.AnyArg(),
 	).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.ExpectExec(regexp.QuoteMeta(`
@@ -307,20 +248,25 @@ func TestVoidInflightTransaction(t *testing.T) {
 		sqlmock.AnyArg(),
 		sqlmock.AnyArg(),
 		0,
+		sqlmock.AnyArg(),
 	).WillReturnResult(sqlmock.NewResult(1, 1))
 
+	// What is left of the inflight transaction is given back to the spending limits it was charged to.
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.spending_limit_usage AS u`)).WithArgs(transactionID, int64(8000)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM blnk.spending_limit_charges WHERE transaction_id = $1`)).WithArgs(transactionID).WillReturnResult(sqlmock.NewResult(0, 1))
+
 	mock.ExpectCommit()
 
 	// Mock RecordTransaction for void transaction
-	expectedSQL := `INSERT INTO blnk.transactions(transaction_id,parent_transaction,source,reference,amount,precise_amount,precision,rate,currency,destination,description,status,created_at,meta_data,scheduled_for,hash) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16)`
+	expectedSQL := `INSERT INTO blnk.transactions(transaction_id,parent_transaction,source,reference,amount,precise_amount,precision,rate,currency,destination,description,status,created_at,meta_data,scheduled_for,hash,group_id,quote_id,source_amount,destination_amount) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20)`
 	mock.ExpectExec(regexp.QuoteMeta(expectedSQL)).WithArgs(
 		sqlmock.AnyArg(),
 		transactionID,
 		source,
 		sqlmock.AnyArg(),
-		80.0,
+		json.Number("80"),
 		8000,
-		float64(100),
+		int64(100),
 		sqlmock.AnyArg(),
 		"USD",
 		destination,
@@ -330,6 +276,10 @@ func TestVoidInflightTransaction(t *testing.T) {
 		sqlmock.AnyArg(),
 		sqlmock.AnyArg(),
 		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
 	).WillReturnResult(sqlmock.NewResult(1, 1))
 
 	// Execute VoidInflightTransaction
@@ -338,7 +288,7 @@ func TestVoidInflightTransaction(t *testing.T) {
 	assert.NotNil(t, voidedTxn)
 	if voidedTxn != nil {
 		assert.Equal(t, "VOID", voidedTxn.Status)
-		assert.Equal(t, float64(80), voidedTxn.Amount)
+		assert.Equal(t, json.Number("80"), voidedTxn.Amount)
 		assert.Equal(t, int64(8000), voidedTxn.PreciseAmount)
 		assert.Equal(t, transactionID, voidedTxn.ParentTransaction)
 	}
@@ -362,11 +312,11 @@ func TestVoidInflightTransaction_Negative(t *testing.T) {
 	t.Run("Transaction not in INFLIGHT status", func(t *testing.T) {
 		transactionID := gofakeit.UUID()
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT transaction_id, source, reference, amount, precise_amount, precision, currency,destination, description, status,created_at, meta_data FROM blnk.transactions WHERE transaction_id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT transaction_id, source, reference, amount, precise_amount, precision, currency,destination, description, status,created_at, meta_data, COALESCE(group_id, ''), COALESCE(rate::TEXT, '1'), COALESCE(quote_id, ''), COALESCE(source_amount::TEXT, ''), COALESCE(destination_amount::TEXT, '') FROM blnk.transactions WHERE transaction_id = $1`)).
 			WithArgs(transactionID).
 			WithArgs(transactionID).
-			WillReturnRows(sqlmock.NewRows([]string{"transaction_id", "source", "reference", "amount", "precise_amount", "precision", "currency", "destination", "description", "status", "created_at", "meta_data"}).
-				AddRow(transactionID, source, gofakeit.UUID(), 100.0, 10000, 100, "USD", destination, gofakeit.UUID(), "APPLIED", time.Now(), metaDataJSON))
+			WillReturnRows(sqlmock.NewRows([]string{"transaction_id", "source", "reference", "amount", "precise_amount", "precision", "currency", "destination", "description", "status", "created_at", "meta_data", "group_id", "rate", "quote_id", "source_amount", "destination_amount"}).
+				AddRow(transactionID, source, gofakeit.UUID(), "100", 10000, 100, "USD", destination, gofakeit.UUID(), "APPLIED", time.Now(), metaDataJSON, "", "1", "", "", ""))
 
 		_, err := d.VoidInflightTransaction(context.Background(), transactionID)
 		assert.Error(t, err)
@@ -376,11 +326,11 @@ func TestVoidInflightTransaction_Negative(t *testing.T) {
 	t.Run("Transaction already voided", func(t *testing.T) {
 		transactionID := gofakeit.UUID()
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT transaction_id, source, reference, amount, precise_amount, precision, currency,destination, description, status,created_at, meta_data FROM blnk.transactions WHERE transaction_id = $1`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT transaction_id, source, reference, amount, precise_amount, precision, currency,destination, description, status,created_at, meta_data, COALESCE(group_id, ''), COALESCE(rate::TEXT, '1'), COALESCE(quote_id, ''), COALESCE(source_amount::TEXT, ''), COALESCE(destination_amount::TEXT, '') FROM blnk.transactions WHERE transaction_id = $1`)).
 			WithArgs(transactionID).
 			WithArgs(transactionID).
-			WillReturnRows(sqlmock.NewRows([]string{"transaction_id", "source", "reference", "amount", "precise_amount", "precision", "currency", "destination", "description", "status", "created_at", "meta_data"}).
-				AddRow(transactionID, source, gofakeit.UUID(), 100.0, 10000, 100, "USD", destination, gofakeit.UUID(), "INFLIGHT", time.Now(), metaDataJSON))
+			WillReturnRows(sqlmock.NewRows([]string{"transaction_id", "source", "reference", "amount", "precise_amount", "precision", "currency", "destination", "description", "status", "created_at", "meta_data", "group_id", "rate", "quote_id", "source_amount", "destination_amount"}).
+				AddRow(transactionID, source, gofakeit.UUID(), "100", 10000, 100, "USD", destination, gofakeit.UUID(), "INFLIGHT", time.Now(), metaDataJSON, "", "1", "", "", ""))
 
 		// Mock IsParentTransactionVoid
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS ( SELECT 1 FROM blnk.transactions WHERE parent_transaction = $1 AND status = 'VOID' )`)).
@@ -392,8 +342,419 @@ func TestVoidInflightTransaction_Negative(t *testing.T) {
 		assert.Contains(t, err.Error(), "transaction has already been voided")
 	})
 
+	t.Run("Source balance frozen", func(t *testing.T) {
+		transactionID := gofakeit.UUID()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT transaction_id, source, reference, amount, precise_amount, precision, currency,destination, description, status,created_at, meta_data, COALESCE(group_id, ''), COALESCE(rate::TEXT, '1'), COALESCE(quote_id, ''), COALESCE(source_amount::TEXT, ''), COALESCE(destination_amount::TEXT, '') FROM blnk.transactions WHERE transaction_id = $1`)).
+			WithArgs(transactionID).
+			WillReturnRows(sqlmock.NewRows([]string{"transaction_id", "source", "reference", "amount", "precise_amount", "precision", "currency", "destination", "description", "status", "created_at", "meta_data", "group_id", "rate", "quote_id", "source_amount", "destination_amount"}).
+				AddRow(transactionID, source, gofakeit.UUID(), "100", 10000, 100, "USD", destination, gofakeit.UUID(), "INFLIGHT", time.Now(), metaDataJSON, "", "1", "", "", ""))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS ( SELECT 1 FROM blnk.transactions WHERE parent_transaction = $1 AND status = 'VOID' )`)).
+			WithArgs(transactionID).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		expectBalanceLite(mock, source, 0, 10000, model.BalanceFrozen)
+		expectBalanceLite(mock, destination, 0, 0, model.BalanceActive)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT SUM(precise_amount) AS total_amount`)).WithArgs(transactionID).
+			WillReturnRows(sqlmock.NewRows([]string{"total_amount"}).AddRow(0))
+
+		_, err := d.VoidInflightTransaction(context.Background(), transactionID)
+		assert.ErrorIs(t, err, model.ErrBalanceStatus)
+	})
+
 	// Verify all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestRecordAtomicTransaction_RollsBackOnFailedLeg(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	funded := gofakeit.UUID()
+	empty := gofakeit.UUID()
+	destination := gofakeit.UUID()
+
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Destination: destination,
+		Amount:      "100",
+		Precision:   100,
+		Currency:    "NGN",
+		Atomic:      true,
+		Sources: []model.Distribution{
+			{Identifier: funded, Distribution: "50%"},
+			{Identifier: empty, Distribution: "left"},
+		},
+	}
+
+	balanceColumns := []string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}
+	balanceQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
+	existsQuery := regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)
+
+	expectCurrencyLookup(mock, "NGN", 2)
+	mock.ExpectQuery(existsQuery).WithArgs(txn.Reference + "-1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(balanceQuery).WithArgs(funded).WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(funded, "NGN", 100, "ledger-id", 10000, 10000, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+	mock.ExpectQuery(balanceQuery).WithArgs(destination).WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(destination, "NGN", 100, "ledger-id", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+	expectNoSpendingLimits(mock)
+	mock.ExpectQuery(existsQuery).WithArgs(txn.Reference + "-2").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery(balanceQuery).WithArgs(empty).WillReturnRows(sqlmock.NewRows(balanceColumns).
+		AddRow(empty, "NGN", 100, "ledger-id", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", ""))
+	expectNoSpendingLimits(mock)
+
+	_, err = d.RecordTransaction(context.Background(), txn)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient funds")
+	assert.NotEmpty(t, txn.GroupID)
+
+	// No database transaction should have been opened, so nothing was written for the funded leg either.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRecordAtomicTransaction_KeepsParent(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	first, second, destination := gofakeit.UUID(), gofakeit.UUID(), gofakeit.UUID()
+	txn := &model.Transaction{
+		TransactionID: model.GenerateUUIDWithSuffix("txn"),
+		Reference:     gofakeit.UUID(),
+		Destination:   destination,
+		Amount:        "100",
+		Precision:     100,
+		Currency:      "USD",
+		Atomic:        true,
+		Sources: []model.Distribution{
+			{Identifier: first, Distribution: "50%"},
+			{Identifier: second, Distribution: "left"},
+		},
+	}
+	existsQuery := regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)
+	balanceUpdateArgs := make([]driver.Value, 14)
+	for i := range balanceUpdateArgs {
+		balanceUpdateArgs[i] = sqlmock.AnyArg()
+	}
+	insertArgs := make([]driver.Value, 20)
+	for i := range insertArgs {
+		insertArgs[i] = sqlmock.AnyArg()
+	}
+
+	expectCurrencyLookup(mock, "USD", 2)
+	mock.ExpectQuery(existsQuery).WithArgs(txn.Reference + "-1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectBalanceLite(mock, first, 10000, 0, model.BalanceActive)
+	expectBalanceLite(mock, destination, 0, 0, model.BalanceActive)
+	expectNoSpendingLimits(mock)
+	mock.ExpectQuery(existsQuery).WithArgs(txn.Reference + "-2").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectBalanceLite(mock, second, 10000, 0, model.BalanceActive)
+	expectNoSpendingLimits(mock)
+	mock.ExpectBegin()
+	for i := 0; i < 3; i++ {
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE blnk.balances`)).WithArgs(balanceUpdateArgs...).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	for i := 0; i < 2; i++ {
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.transactions`)).WithArgs(insertArgs...).WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	// The parent is saved with its legs, in the same database transaction.
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.transaction_groups`)).
+		WithArgs(sqlmock.AnyArg(), txn.TransactionID, txn.Reference, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	recorded, err := d.RecordTransaction(context.Background(), txn)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusApplied, recorded.Status)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The parent has no row in blnk.transactions, so it is found by its group.
+	parentJSON, err := json.Marshal(recorded)
+	assert.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.transactions WHERE transaction_id = $1`)).WithArgs(txn.TransactionID).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT transaction FROM blnk.transaction_groups WHERE transaction_id = $1`)).WithArgs(txn.TransactionID).
+		WillReturnRows(sqlmock.NewRows([]string{"transaction"}).AddRow(parentJSON))
+
+	fetched, err := d.GetTransaction(txn.TransactionID)
+	assert.NoError(t, err)
+	assert.Equal(t, txn.GroupID, fetched.GroupID)
+	assert.Equal(t, StatusApplied, fetched.Status)
+	assert.Len(t, fetched.Sources, 2)
+	assert.NotEmpty(t, fetched.Sources[0].TransactionID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedeliveredAtomicTransactionIsRecognised(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	txn := &model.Transaction{
+		TransactionID: model.GenerateUUIDWithSuffix("txn"),
+		GroupID:       model.GenerateUUIDWithSuffix("grp"),
+		Reference:     gofakeit.UUID(),
+		Destination:   gofakeit.UUID(),
+		Amount:        "100",
+		Precision:     100,
+		Currency:      "USD",
+		Atomic:        true,
+		Sources: []model.Distribution{
+			{Identifier: gofakeit.UUID(), Distribution: "50%"},
+			{Identifier: gofakeit.UUID(), Distribution: "left"},
+		},
+	}
+
+	// The task is delivered again after its legs were posted, so the first leg's reference is taken.
+	expectCurrencyLookup(mock, "USD", 2)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference + "-1").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	_, err = d.RecordTransaction(context.Background(), txn)
+	var rejection *RejectionError
+	assert.ErrorAs(t, ClassifyTransactionError(err), &rejection)
+	assert.Equal(t, RejectionDuplicateReference, rejection.Code)
+
+	// The parent's own reference has no row; it is kept with its group.
+	parent := *txn
+	parent.Status = StatusApplied
+	parentJSON, err := json.Marshal(parent)
+	assert.NoError(t, err)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.transactions WHERE reference = $1`)).WithArgs(txn.Reference).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.transactions WHERE transaction_id = $1`)).WithArgs(txn.TransactionID).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT transaction FROM blnk.transaction_groups WHERE transaction_id = $1`)).WithArgs(txn.TransactionID).
+		WillReturnRows(sqlmock.NewRows([]string{"transaction"}).AddRow(parentJSON))
+	assert.True(t, d.TransactionRecorded(context.Background(), txn))
+
+	// Another transaction that reuses the reference was not recorded.
+	other := *txn
+	other.TransactionID = model.GenerateUUIDWithSuffix("txn")
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.transactions WHERE reference = $1`)).WithArgs(txn.Reference).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.transactions WHERE transaction_id = $1`)).WithArgs(other.TransactionID).WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT transaction FROM blnk.transaction_groups WHERE transaction_id = $1`)).WithArgs(other.TransactionID).
+		WillReturnError(sql.ErrNoRows)
+	assert.False(t, d.TransactionRecorded(context.Background(), &other))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAllTransactionsFilters(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	if err != nil {
+		t.Fatalf("Error creating test data source: %s", err)
+	}
+
+	d, err := NewBlnk(datasource)
+	if err != nil {
+		t.Fatalf("Error creating Blnk instance: %s", err)
+	}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter := model.TransactionFilter{
+		Status:    "APPLIED",
+		BalanceID: "bln_1",
+		MetaData:  map[string]string{"order_id": "ord_1", "attempt": "2"},
+		From:      from,
+	}
+	rows := sqlmock.NewRows([]string{"id", "transaction_id", "source", "reference", "amount", "precise_amount", "precision", "currency", "destination", "description", "status", "hash", "created_at", "meta_data", "parent_transaction", "group_id"}).
+		AddRow(3, "txn_3", "bln_1", "ref_3", "10", 1000, 100, "USD", "bln_2", "", "APPLIED", "", from.Add(time.Hour), `{"order_id":"ord_1"}`, "", "")
+
+	// A value that reads as a number matches the number as well as the string.
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM blnk.transactions WHERE status = $1 AND (source = $2 OR destination = $2) AND (meta_data @> $3::jsonb OR meta_data @> $4::jsonb) AND meta_data @> $5::jsonb AND created_at >= $6 ORDER BY id DESC LIMIT $7`)).
+		WithArgs("APPLIED", "bln_1", `{"attempt":"2"}`, `{"attempt":2}`, `{"order_id":"ord_1"}`, from, 21).
+		WillReturnRows(rows)
+
+	page, err := d.GetAllTransactions(filter, 0)
+	assert.NoError(t, err)
+	assert.Len(t, page.Data, 1)
+	assert.Equal(t, "txn_3", page.Data[0].TransactionID)
+	assert.Empty(t, page.NextCursor)
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// expectTransfer mocks recording a 10 NGN transfer from a funded source balance to an empty destination.
+func expectTransfer(mock sqlmock.Sqlmock, txn *model.Transaction, source, destination string) {
+	mock.ExpectQuery(regexp.QuoteMeta(`
+        SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)
+    `)).WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	expectCurrencyLookup(mock, "NGN", 2)
+
+	sourceBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+		AddRow(source, "NGN", 100, "ledger-id-source", 10000, 10000, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", "")
+
+	destinationBalanceRows := sqlmock.NewRows([]string{"balance_id", "currency", "currency_multiplier", "ledger_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "created_at", "version", "reserved_balance", "hot", "overdraft_limit", "overdraft_expires_at", "status", "status_reason", "identity_id"}).
+		AddRow(destination, "NGN", 100, "ledger-id-destination", 0, 0, 0, 0, 0, 0, time.Now(), 0, 0, false, 0, nil, "active", "", "")
+
+	balanceQuery := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
+	balanceQuery2 := regexp.QuoteMeta(`SELECT balance_id, currency, currency_multiplier,ledger_id, balance, credit_balance, debit_balance, inflight_balance, inflight_credit_balance, inflight_debit_balance, created_at, version, reserved_balance, hot, overdraft_limit, overdraft_expires_at, status, status_reason, COALESCE(identity_id, '') FROM blnk.balances WHERE balance_id = $1`)
+
+	mock.ExpectQuery(balanceQuery).WithArgs(source).WillReturnRows(sourceBalanceRows)
+	mock.ExpectQuery(balanceQuery2).WithArgs(destination).WillReturnRows(destinationBalanceRows)
+	expectNoSpendingLimits(mock)
+	mock.ExpectBegin()
+
+	mock.ExpectExec(regexp.QuoteMeta(`
+	  UPDATE blnk.balances
+	  SET balance = $2, credit_balance = $3, debit_balance = $4, inflight_balance = $5, inflight_credit_balance = $6, inflight_debit_balance = $7, currency = $8, currency_multiplier = $9, ledger_id = $10, created_at = $11, meta_data = $12, version = version + 1
+	  WHERE balance_id = $1 AND version = $13
+	`)).WithArgs(
+		source,
+		9000,
+		10000,
+		1000,
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		0,
+		sqlmock.AnyArg(),
+	).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec(regexp.QuoteMeta(`
+	  UPDATE blnk.balances
+	  SET balance = $2, credit_balance = $3, debit_balance = $4, inflight_balance = $5, inflight_credit_balance = $6, inflight_debit_balance = $7, currency = $8, currency_multiplier = $9, ledger_id = $10, created_at = $11, meta_data = $12, version = version + 1
+	  WHERE balance_id = $1 AND version = $13
+	`)).WithArgs(
+		destination,
+		1000,
+		1000,
+		0,
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		0,
+		sqlmock.AnyArg(),
+	).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	// Funds reserved for the transaction are converted with the balance update.
+	if txn.ReservedAmount > 0 {
+		mock.ExpectExec(settleQuery).WithArgs(txn.TransactionID, model.ReservationConverted, model.ReservationHeld).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+	expectedSQL := `INSERT INTO blnk.transactions(transaction_id,parent_transaction,source,reference,amount,precise_amount,precision,rate,currency,destination,description,status,created_at,meta_data,scheduled_for,hash,group_id,quote_id,source_amount,destination_amount) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20)`
+	mock.ExpectExec(regexp.QuoteMeta(expectedSQL)).WithArgs(
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		source,
+		txn.Reference,
+		txn.Amount,
+		1000,
+		txn.Precision,
+		json.Number("1"),
+		txn.Currency,
+		txn.Destination,
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+		sqlmock.AnyArg(),
+	).WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+func TestRecordTransactionSync(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	source := gofakeit.UUID()
+	destination := gofakeit.UUID()
+
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      source,
+		Destination: destination,
+		Amount:      "10",
+		Precision:   100,
+		Currency:    "NGN",
+	}
+	expectTransfer(mock, txn, source, destination)
+
+	snapshotColumns := []string{"balance_id", "version", "transaction_id", "balance", "credit_balance", "debit_balance", "inflight_balance", "inflight_credit_balance", "inflight_debit_balance", "currency", "created_at"}
+	mock.ExpectQuery(regexp.QuoteMeta("FROM blnk.balance_snapshots WHERE transaction_id = $1")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(snapshotColumns).
+			AddRow(source, 1, "txn", 9000, 10000, 1000, 0, 0, 0, "NGN", time.Now()).
+			AddRow(destination, 1, "txn", 1000, 1000, 0, 0, 0, 0, "NGN", time.Now()))
+
+	result, err := d.RecordTransactionSync(context.Background(), txn)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusApplied, result.Transaction.Status)
+	assert.Len(t, result.Balances, 2)
+	assert.Equal(t, int64(9000), result.Balances[0].Balance)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRecordTransactionSyncRecordsRejection(t *testing.T) {
+	datasource, mock, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	txn := &model.Transaction{
+		Reference:   gofakeit.UUID(),
+		Source:      gofakeit.UUID(),
+		Destination: gofakeit.UUID(),
+		Amount:      "10",
+		Precision:   100,
+		Currency:    "NGN",
+	}
+
+	// The reference is taken, so the transaction is kept as rejected rather than dropped.
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT EXISTS(SELECT 1 FROM blnk.transactions WHERE reference = $1)`)).
+		WithArgs(txn.Reference).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	insertArgs := make([]driver.Value, 20)
+	for i := range insertArgs {
+		insertArgs[i] = sqlmock.AnyArg()
+	}
+	insertArgs[11] = StatusRejected
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO blnk.transactions`)).WithArgs(insertArgs...).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	result, err := d.RecordTransactionSync(context.Background(), txn)
+	var rejection *RejectionError
+	assert.ErrorAs(t, ClassifyTransactionError(err), &rejection)
+	assert.Equal(t, RejectionDuplicateReference, rejection.Code)
+	if assert.NotNil(t, result) {
+		assert.Equal(t, StatusRejected, result.Transaction.Status)
+		assert.Equal(t, RejectionDuplicateReference, result.Transaction.MetaData["blnk_rejection_code"])
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRecordTransactionSyncRejectsScheduled(t *testing.T) {
+	datasource, _, err := newTestDataSource()
+	assert.NoError(t, err)
+
+	d, err := NewBlnk(datasource)
+	assert.NoError(t, err)
+
+	_, err = d.RecordTransactionSync(context.Background(), &model.Transaction{ScheduledFor: time.Now().Add(time.Hour)})
+	assert.Error(t, err)
+}